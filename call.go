@@ -0,0 +1,29 @@
+package skynet
+
+import (
+	"time"
+)
+
+// Call is a single RPC request sent as part of a batch.
+type Call struct {
+	// Fn is the name of the service method to call.
+	Fn string
+	// In is the request value sent to the method.
+	In interface{}
+	// Out is a pointer to the value the response is copied into.
+	Out interface{}
+}
+
+// SendResult describes how a client's request was served.
+type SendResult struct {
+	// Instance is the instance that answered, or the last one attempted if the request failed.
+	Instance ServiceInfo
+	// Attempts is the number of attempts sent, including retries.
+	Attempts int
+	// Duration is the total time from the request being sent until it returned.
+	Duration time.Duration
+	// ConnectFailures is the number of attempts that failed to open a connection to their instance.
+	ConnectFailures int
+	// CallFailures is the number of attempts that failed once they were sent to their instance.
+	CallFailures int
+}
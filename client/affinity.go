@@ -0,0 +1,93 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/config"
+	"time"
+)
+
+// replaced in tests to control when affinity keys expire
+var affinityNow = time.Now
+
+// message sent to mux() to choose the instance for a request with an AffinityKey
+type affinityQuery struct {
+	ri   *skynet.RequestInfo
+	resp chan affinityResult
+}
+
+type affinityResult struct {
+	service skynet.ServiceInfo
+	err     error
+}
+
+type affinityEntry struct {
+	uuid     string
+	lastUsed time.Time
+}
+
+/*
+this should only be called by mux()
+Requests with the same AffinityKey are sent to the instance that was chosen for the first of them, until it's removed,
+unregistered or excluded after repeated failures, at which point another is chosen from the LoadBalancer.
+Keys without a request for client.affinity.ttl are forgotten, so only recently used keys are kept
+*/
+func (c *ServiceClient) affinityInstance(ri *skynet.RequestInfo) (s skynet.ServiceInfo, err error) {
+	now := affinityNow()
+	c.expireAffinity(now)
+
+	if e, ok := c.affinity[ri.AffinityKey]; ok {
+		if s, ok = c.instances[e.uuid]; ok && s.Registered && !c.isExcluded(e.uuid) && !c.affinityExpired(e, now) {
+			c.affinity[ri.AffinityKey] = affinityEntry{uuid: e.uuid, lastUsed: now}
+			return
+		}
+
+		delete(c.affinity, ri.AffinityKey)
+	}
+
+	s, err = c.chooseFromLoadBalancer(ri)
+	if err == nil {
+		c.affinity[ri.AffinityKey] = affinityEntry{uuid: s.UUID, lastUsed: now}
+	}
+
+	return
+}
+
+// this should only be called by mux(), sweeps at most once per ttl so keys are never kept for more than twice it
+func (c *ServiceClient) expireAffinity(now time.Time) {
+	if c.affinityTTL <= 0 || now.Sub(c.affinitySwept) < c.affinityTTL {
+		return
+	}
+
+	for key, e := range c.affinity {
+		if c.affinityExpired(e, now) {
+			delete(c.affinity, key)
+		}
+	}
+
+	c.affinitySwept = now
+}
+
+func (c *ServiceClient) affinityExpired(e affinityEntry, now time.Time) bool {
+	return c.affinityTTL > 0 && now.Sub(e.lastUsed) >= c.affinityTTL
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) removeAffinity(uuid string) {
+	for key, e := range c.affinity {
+		if e.uuid == uuid {
+			delete(c.affinity, key)
+		}
+	}
+}
+
+func getAffinityTTL(service, version string) time.Duration {
+	if d, err := config.String(service, version, "client.affinity.ttl"); err == nil {
+		if ttl, err := time.ParseDuration(d); err == nil {
+			return ttl
+		}
+
+		logger.Error("Failed to parse client.affinity.ttl", err)
+	}
+
+	return config.DefaultAffinityTTL
+}
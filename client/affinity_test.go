@@ -0,0 +1,129 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/client/loadbalancer/roundrobin"
+	"github.com/skynetservices/skynet/config"
+	"github.com/skynetservices/skynet/test"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSendAffinityKeySticksToInstance(t *testing.T) {
+	defer resetClient()
+
+	var mutex sync.Mutex
+	var sentTo string
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					mutex.Lock()
+					sentTo = s.UUID
+					mutex.Unlock()
+
+					return
+				},
+			}, nil
+		},
+	})
+
+	sc := GetService("TestService", "", "", "")
+
+	for i := 0; i < 3; i++ {
+		si := serviceInfo()
+		si.UUID = config.NewUUID()
+		sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: *si})
+	}
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 3
+	})
+
+	send := func(key string) string {
+		var val string
+		if err := sc.Send(&skynet.RequestInfo{AffinityKey: key}, "Foo", val, &val); err != nil {
+			t.Fatal(err)
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return sentTo
+	}
+
+	first := send("session")
+
+	// without affinity requests would be spread across the instances
+	for i := 0; i < 5; i++ {
+		if s := send("session"); s != first {
+			t.Fatal("Requests with the same AffinityKey should be sent to the same instance", first, s)
+		}
+	}
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceRemoved, Service: skynet.ServiceInfo{UUID: first}})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 2
+	})
+
+	second := send("session")
+	if second == first {
+		t.Fatal("Requests should fail over once the instance is removed")
+	}
+
+	for i := 0; i < 5; i++ {
+		if s := send("session"); s != second {
+			t.Fatal("Requests should stick to the instance they failed over to", second, s)
+		}
+	}
+}
+
+func TestIdleAffinityKeysExpire(t *testing.T) {
+	clock := time.Now()
+	affinityNow = func() time.Time {
+		return clock
+	}
+	defer func() { affinityNow = time.Now }()
+
+	a, b := *serviceInfo(), *serviceInfo()
+	a.UUID, b.UUID = config.NewUUID(), config.NewUUID()
+
+	// affinityInstance() is only called from mux(), here we call it directly
+	c := &ServiceClient{
+		loadBalancer: roundrobin.New([]skynet.ServiceInfo{a, b}),
+		instances:    map[string]skynet.ServiceInfo{a.UUID: a, b.UUID: b},
+		affinity:     make(map[string]affinityEntry),
+		affinityTTL:  time.Minute,
+	}
+
+	active := &skynet.RequestInfo{AffinityKey: "active"}
+	idle := &skynet.RequestInfo{AffinityKey: "idle"}
+
+	first, _ := c.affinityInstance(active)
+	c.affinityInstance(idle)
+
+	clock = clock.Add(40 * time.Second)
+	if s, _ := c.affinityInstance(active); s.UUID != first.UUID {
+		t.Fatal("Key used within the ttl should keep its instance")
+	}
+
+	clock = clock.Add(40 * time.Second)
+	if s, _ := c.affinityInstance(active); s.UUID != first.UUID {
+		t.Fatal("Each request should extend the key's ttl")
+	}
+
+	if _, ok := c.affinity["idle"]; ok || len(c.affinity) != 1 {
+		t.Fatal("Keys idle for longer than the ttl should be forgotten", c.affinity)
+	}
+
+	clock = clock.Add(2 * time.Minute)
+	c.affinityInstance(idle)
+
+	if _, ok := c.affinity["active"]; ok || len(c.affinity) != 1 {
+		t.Fatal("Keys idle for longer than the ttl should be forgotten", c.affinity)
+	}
+}
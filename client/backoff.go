@@ -0,0 +1,104 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet/config"
+	"math/rand"
+	"time"
+)
+
+// replaced in tests to control when retries fire
+var retryAfter = time.After
+
+/*
+backoff produces the intervals between retries of a request. Intervals start at base and grow by multiplier
+up to max, each interval is randomized between 0 and its full length (full jitter) so that clients
+retrying against a recovering cluster spread out rather than arriving together.
+A multiplier of 1 or less retries at a fixed interval of base.
+*/
+type backoff struct {
+	base       time.Duration
+	max        time.Duration
+	multiplier float64
+
+	interval time.Duration
+	jitter   func(d time.Duration) time.Duration
+}
+
+func newBackoff(base, max time.Duration, multiplier float64) *backoff {
+	return &backoff{
+		base:       base,
+		max:        max,
+		multiplier: multiplier,
+		jitter:     fullJitter,
+	}
+}
+
+/*
+backoff.next() returns how long to wait before the next retry
+*/
+func (b *backoff) next() time.Duration {
+	if !b.backingOff() {
+		return b.base
+	}
+
+	if b.interval == 0 {
+		b.interval = b.base
+	} else {
+		b.interval = time.Duration(float64(b.interval) * b.multiplier)
+	}
+
+	if b.max > 0 && b.interval > b.max {
+		b.interval = b.max
+	}
+
+	return b.jitter(b.interval)
+}
+
+// backoff.reset() starts the intervals again from base
+func (b *backoff) reset() {
+	b.interval = 0
+}
+
+/*
+backoff.backingOff() returns false if retries happen at a fixed interval
+*/
+func (b *backoff) backingOff() bool {
+	return b.multiplier > 1
+}
+
+func fullJitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// equalJitter randomizes d between half and its full length, so it's never much shorter than d
+func equalJitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+
+	return d/2 + fullJitter(d-d/2)
+}
+
+func getRetryMax(service, version string) time.Duration {
+	if d, err := config.String(service, version, "client.timeout.retry.max"); err == nil {
+		if timeout, err := time.ParseDuration(d); err == nil {
+			return timeout
+		}
+
+		logger.Error("Failed to parse client.timeout.retry.max", err)
+	}
+
+	return config.DefaultRetryMaxDuration
+}
+
+func getRetryMultiplier(service, version string) float64 {
+	if m, err := config.Float(service, version, "client.timeout.retry.multiplier"); err == nil {
+		return m
+	}
+
+	return config.DefaultRetryMultiplier
+}
@@ -0,0 +1,185 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/skynetservices/skynet"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoffSchedule(t *testing.T) {
+	b := newBackoff(100*time.Millisecond, 800*time.Millisecond, 2)
+	b.jitter = func(d time.Duration) time.Duration { return d }
+
+	expected := []time.Duration{100, 200, 400, 800, 800, 800}
+
+	for i, e := range expected {
+		if d := b.next(); d != e*time.Millisecond {
+			t.Fatalf("Retry %d expected interval %s got %s", i, e*time.Millisecond, d)
+		}
+	}
+}
+
+func TestBackoffFixedInterval(t *testing.T) {
+	b := newBackoff(100*time.Millisecond, 800*time.Millisecond, 1)
+
+	for i := 0; i < 5; i++ {
+		if d := b.next(); d != 100*time.Millisecond {
+			t.Fatal("Multiplier of 1 should retry at a fixed interval", d)
+		}
+	}
+}
+
+func TestBackoffJitterWithinInterval(t *testing.T) {
+	b := newBackoff(100*time.Millisecond, 800*time.Millisecond, 2)
+	var interval time.Duration = 100 * time.Millisecond
+
+	for i := 0; i < 100; i++ {
+		if d := b.next(); d < 0 || d > interval {
+			t.Fatalf("Retry %d interval %s outside of 0-%s", i, d, interval)
+		}
+
+		if interval < 800*time.Millisecond {
+			interval *= 2
+		}
+	}
+}
+
+func TestConnectBackoffWindowsAtLeastHalfInterval(t *testing.T) {
+	cb := newConnectBackoff(100*time.Millisecond, 800*time.Millisecond)
+	var interval time.Duration = 100 * time.Millisecond
+
+	for i := 0; i < 100; i++ {
+		if d := cb.backoff.next(); d < interval/2 || d > interval {
+			t.Fatalf("Window %d of %s outside of %s-%s", i, d, interval/2, interval)
+		}
+
+		if interval < 800*time.Millisecond {
+			interval *= 2
+		}
+	}
+}
+
+func TestSendRetriesFollowBackoff(t *testing.T) {
+	defer resetClient()
+
+	intervals := make(chan time.Duration)
+	fire := make(chan time.Time)
+
+	retryAfter = func(d time.Duration) <-chan time.Time {
+		go func() { intervals <- d }()
+		return fire
+	}
+
+	block := make(chan bool)
+
+	attempts := make(chan bool, 10)
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		attempts <- true
+		<-block
+		return
+	})
+
+	sClient := sc.(*ServiceClient)
+	sClient.retryMax = 400 * time.Millisecond
+	sClient.retryMultiplier = 2
+
+	done := make(chan bool)
+	go func() {
+		sClient.send(context.Background(), 100*time.Millisecond, 0, nil, "Foo", "", new(string))
+		close(done)
+	}()
+
+	schedule := []time.Duration{100, 200, 400, 400}
+
+	for i, max := range schedule {
+		<-attempts
+
+		d := <-intervals
+		if d < 0 || d > max*time.Millisecond {
+			t.Fatalf("Retry %d interval %s exceeds backoff of %s", i, d, max*time.Millisecond)
+		}
+
+		fire <- time.Now()
+	}
+
+	<-attempts
+
+	// the send must finish before resetClient() replaces the package vars it reads
+	close(block)
+	<-done
+}
+
+func TestSendGiveupBoundsBackoff(t *testing.T) {
+	defer resetClient()
+
+	// retries never fire
+	retryAfter = func(d time.Duration) <-chan time.Time {
+		return nil
+	}
+
+	block := make(chan bool)
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		<-block
+		return
+	})
+
+	sClient := sc.(*ServiceClient)
+	sClient.retryMultiplier = 2
+
+	var val string
+	err := make(chan error)
+
+	go func() {
+		err <- sClient.send(context.Background(), time.Second, 20*time.Millisecond, nil, "Foo", val, &val)
+	}()
+
+	select {
+	case e := <-err:
+		if e != RequestTimeout {
+			t.Fatal("Expected request to time out", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Giveup timeout did not bound request")
+	}
+}
+
+func TestSendStopsAfterMaxAttempts(t *testing.T) {
+	defer resetClient()
+
+	var attempts int32
+	failed := errors.New("Failed")
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		atomic.AddInt32(&attempts, 1)
+		return failed
+	})
+
+	// failures are retried straight away
+	sClient := sc.(*ServiceClient)
+	sClient.retryMultiplier = 1
+	sClient.maxAttempts = 3
+
+	var val string
+	start := time.Now()
+	err := sClient.SendTimeout(time.Minute, time.Minute, nil, "Foo", val, &val)
+
+	if err != failed {
+		t.Fatal("The last attempt's error should be returned once max attempts have failed", err)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Fatal("Send() should return without waiting for the giveup time")
+	}
+
+	if n := atomic.LoadInt32(&attempts); n != 3 {
+		t.Fatal("Expected exactly max attempts to be sent", n)
+	}
+}
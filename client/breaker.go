@@ -0,0 +1,284 @@
+package client
+
+import (
+	"fmt"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"github.com/skynetservices/skynet/config"
+	"github.com/skynetservices/skynet/pools"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+/*
+circuitBreaker tracks consecutive failures to an instance. Once the failure threshold is reached
+the breaker opens and the instance is removed from the LoadBalancer until the cooldown passes,
+at which point a single probe request is let through (half open); if it fails the breaker opens again,
+a success closes it and returns the instance to the LoadBalancer.
+Errors returned by the service for a request don't count as failures, as the instance handled it.
+*/
+type circuitBreaker struct {
+	state       int
+	failures    int
+	lastFailure time.Time
+
+	// when the probe was sent while half open, a probe that's never heard from is replaced after a cooldown
+	probeSent time.Time
+}
+
+// message sent to mux() after each attempt to an instance
+type instanceResult struct {
+	service skynet.ServiceInfo
+	err     error
+
+	// how long the instance took to respond, 0 if it wasn't measured
+	duration time.Duration
+
+	// whether the request was retried on a new connection after its first broke
+	retried bool
+}
+
+// message sent to mux() once an open breaker's cooldown has passed
+type breakerCooldown struct {
+	uuid string
+}
+
+// message sent to mux() to find out if every known instance has an open breaker
+type excludedQuery struct {
+	resp chan bool
+}
+
+// message sent to mux() for a half open instance that's due a probe, and that ri could be sent to
+type probeQuery struct {
+	ri   *skynet.RequestInfo
+	resp chan probeResult
+}
+
+type probeResult struct {
+	service skynet.ServiceInfo
+	ok      bool
+}
+
+// Only failures of the instance or the connection to it count towards its breaker, not errors from the service,
+// from encoding the request or decoding its response, or from our own connections to it all being busy or rate limited,
+// or refused while connecting to it backs off as the failure that started the backoff already counted, nor from the
+// ConnectionPool not having been told of it yet
+func instanceFailure(err error) error {
+	if conn.IsServiceError(err) || conn.IsCodecError(err) || err == pools.PoolExhausted || err == InstanceRateLimited || err == InstanceUnreachable ||
+		err == UnknownService {
+		return nil
+	}
+
+	return err
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) breakerFor(uuid string) *circuitBreaker {
+	b, ok := c.breakers[uuid]
+
+	if !ok {
+		b = &circuitBreaker{}
+		c.breakers[uuid] = b
+	}
+
+	return b
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) isBreakerOpen(uuid string) bool {
+	b, ok := c.breakers[uuid]
+
+	return ok && b.state == breakerOpen
+}
+
+// this should only be called by mux(), instances with an open or half open breaker, or that have been evicted,
+// aren't in the LoadBalancer
+func (c *ServiceClient) isExcluded(uuid string) bool {
+	if c.isEvicted(uuid) {
+		return true
+	}
+
+	b, ok := c.breakers[uuid]
+
+	return ok && b.state != breakerClosed
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) allInstancesExcluded() bool {
+	if len(c.instances) == 0 {
+		return false
+	}
+
+	// unregistered instances aren't in the LoadBalancer either, so they can't serve requests
+	for uuid, s := range c.instances {
+		if s.Registered && !c.isExcluded(uuid) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) recordInstanceResult(r instanceResult) {
+	// Instance is no longer known to us, nothing to track
+	if _, ok := c.instances[r.service.UUID]; !ok {
+		return
+	}
+
+	c.recordLatency(r)
+	c.resetTransportFailures(r)
+
+	if r.err == nil {
+		c.recordServed(r.service.UUID)
+	}
+
+	if c.failureThreshold <= 0 {
+		return
+	}
+
+	b := c.breakerFor(r.service.UUID)
+
+	if r.err == nil {
+		// an open instance is out of the LoadBalancer until its probe succeeds, successes from attempts that were
+		// already sent, or from SendTo() and SendAll(), mustn't close the breaker without re-admitting it
+		if b.state == breakerOpen {
+			return
+		}
+
+		if b.state == breakerHalfOpen {
+			c.closeBreaker(r.service.UUID, b)
+		}
+
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	if b.state == breakerOpen {
+		return
+	}
+
+	// Failures are only consecutive if they happen within the cooldown window
+	if time.Since(b.lastFailure) > c.cooldown {
+		b.failures = 0
+	}
+
+	b.failures++
+	b.lastFailure = time.Now()
+
+	if b.state == breakerHalfOpen || b.failures >= c.failureThreshold {
+		c.openBreaker(r.service.UUID, b)
+	}
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) openBreaker(uuid string, b *circuitBreaker) {
+	logger.Error(fmt.Sprintf("Instance %s failed %d consecutive requests, excluding it for %s", uuid, b.failures, c.cooldown.String()))
+
+	// half open and evicted instances were never returned to the LoadBalancer
+	if b.state == breakerHalfOpen {
+		atomic.AddInt32(&c.halfOpen, -1)
+	} else if !c.isEvicted(uuid) {
+		c.loadBalancer.RemoveInstance(c.instances[uuid])
+	}
+
+	b.state = breakerOpen
+
+	time.AfterFunc(c.cooldown, func() {
+		c.sendMux(breakerCooldown{uuid: uuid})
+	})
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) halfOpenBreaker(uuid string) {
+	b, ok := c.breakers[uuid]
+	if !ok || b.state != breakerOpen {
+		return
+	}
+
+	if _, ok := c.instances[uuid]; !ok {
+		delete(c.breakers, uuid)
+		return
+	}
+
+	logger.Debug(fmt.Sprintf("Instance %s cooldown complete, sending a probe", uuid))
+
+	b.state = breakerHalfOpen
+	b.probeSent = time.Time{}
+	atomic.AddInt32(&c.halfOpen, 1)
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) closeBreaker(uuid string, b *circuitBreaker) {
+	logger.Debug(fmt.Sprintf("Instance %s probe succeeded, re-admitting", uuid))
+
+	atomic.AddInt32(&c.halfOpen, -1)
+
+	if !c.isEvicted(uuid) {
+		c.loadBalancer.AddInstance(c.instances[uuid])
+	}
+}
+
+// this should only be called by mux(), the returned instance is the only one sent a request until the probe completes.
+// Requests with a LabelSelector only probe instances with its labels, others only those the LoadBalancer would choose,
+// ex: not of another version
+func (c *ServiceClient) nextProbe(ri *skynet.RequestInfo) (s skynet.ServiceInfo, ok bool) {
+	var selector map[string]string
+	if ri.LabelSelector != "" {
+		selector = skynet.ParseLabels(ri.LabelSelector)
+	}
+
+	for uuid, b := range c.breakers {
+		if b.state != breakerHalfOpen || time.Since(b.probeSent) < c.cooldown {
+			continue
+		}
+
+		if s, ok = c.instances[uuid]; !ok || !s.Registered || c.isEvicted(uuid) {
+			continue
+		}
+
+		if (selector != nil && s.MatchesLabels(selector)) || (selector == nil && loadbalancer.Admits(c.loadBalancer, s)) {
+			b.probeSent = time.Now()
+			return
+		}
+	}
+
+	return s, false
+}
+
+// this should only be called by mux(), when an instance is removed
+func (c *ServiceClient) removeBreaker(uuid string) {
+	if b, ok := c.breakers[uuid]; ok && b.state == breakerHalfOpen {
+		atomic.AddInt32(&c.halfOpen, -1)
+	}
+
+	delete(c.breakers, uuid)
+}
+
+func getFailureThreshold(service, version string) int {
+	if n, err := config.Int(service, version, "client.breaker.threshold"); err == nil {
+		return n
+	}
+
+	return config.DefaultFailureThreshold
+}
+
+func getCooldownDuration(service, version string) time.Duration {
+	if d, err := config.String(service, version, "client.breaker.cooldown"); err == nil {
+		if timeout, err := time.ParseDuration(d); err == nil {
+			return timeout
+		}
+
+		logger.Error("Failed to parse client.breaker.cooldown", err)
+	}
+
+	return config.DefaultCooldownDuration
+}
@@ -0,0 +1,498 @@
+package client
+
+import (
+	"errors"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/client/loadbalancer/roundrobin"
+	"github.com/skynetservices/skynet/client/loadbalancer/versioned"
+	"github.com/skynetservices/skynet/config"
+	"github.com/skynetservices/skynet/rpc/bsonrpc"
+	"github.com/skynetservices/skynet/test"
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerExcludesFailingInstance(t *testing.T) {
+	defer resetClient()
+
+	good, bad := *serviceInfo(), *serviceInfo()
+	good.UUID, bad.UUID = config.NewUUID(), config.NewUUID()
+
+	criteria := &skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}}
+
+	sc := NewServiceClient(criteria)
+	sClient := sc.(*ServiceClient)
+	sClient.loadBalancer = roundrobin.New([]skynet.ServiceInfo{})
+	sClient.failureThreshold = 2
+	sClient.cooldown = 200 * time.Millisecond
+
+	counter := stubFailingPool(bad.UUID)
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: good})
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: bad})
+
+	var val string
+
+	// Wait for both instances to be known to the LoadBalancer
+	waitFor(t, func() bool {
+		sc.SendOnce(nil, "Foo", val, &val)
+		return counter.count(bad.UUID) > 0
+	})
+
+	for i := 0; i < 20; i++ {
+		sc.SendOnce(nil, "Foo", val, &val)
+	}
+
+	if counter.count(bad.UUID) > 3 {
+		t.Fatal("Failing instance should have been excluded after reaching the failure threshold", counter.count(bad.UUID))
+	}
+
+	counter.reset()
+
+	for i := 0; i < 10; i++ {
+		sc.SendOnce(nil, "Foo", val, &val)
+	}
+
+	if counter.count(bad.UUID) != 0 {
+		t.Fatal("Failing instance should not be sent requests while excluded")
+	}
+
+	if counter.count(good.UUID) != 10 {
+		t.Fatal("Healthy instance should receive all requests while failing instance is excluded")
+	}
+
+	// Once the cooldown passes the instance is re-admitted for a probe
+	time.Sleep(sClient.cooldown + 50*time.Millisecond)
+	counter.reset()
+
+	for i := 0; i < 10; i++ {
+		sc.SendOnce(nil, "Foo", val, &val)
+	}
+
+	if counter.count(bad.UUID) == 0 {
+		t.Fatal("Failing instance should be re-admitted after cooldown")
+	}
+
+	if counter.count(bad.UUID) > 2 {
+		t.Fatal("Failing instance should be excluded again after a failed probe", counter.count(bad.UUID))
+	}
+}
+
+func TestSendReturnsWhenAllInstancesExcluded(t *testing.T) {
+	defer resetClient()
+
+	a, b := *serviceInfo(), *serviceInfo()
+	a.UUID, b.UUID = config.NewUUID(), config.NewUUID()
+
+	criteria := &skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}}
+
+	sc := NewServiceClient(criteria)
+	sClient := sc.(*ServiceClient)
+	sClient.loadBalancer = roundrobin.New([]skynet.ServiceInfo{})
+	sClient.failureThreshold = 1
+	sClient.cooldown = time.Minute
+	sc.SetDefaultTimeout(5*time.Millisecond, time.Minute)
+
+	// backing off rather than retrying as soon as an attempt fails, which could choose its instance before it's excluded
+	sClient.retryMultiplier = 2
+
+	counter := stubFailingPool(a.UUID, b.UUID)
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: a})
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: b})
+
+	err := make(chan error)
+
+	go func() {
+		var val string
+		err <- sc.Send(nil, "Foo", val, &val)
+	}()
+
+	select {
+	case e := <-err:
+		if e != NoAvailableInstances {
+			t.Fatal("Send() should return NoAvailableInstances once every instance is excluded", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send() should not wait for giveup once every instance is excluded")
+	}
+
+	if counter.count(a.UUID) != 1 || counter.count(b.UUID) != 1 {
+		t.Fatal("Each instance should be tried once before it is excluded", counter.count(a.UUID), counter.count(b.UUID))
+	}
+}
+
+func TestSendReturnsWhenFailingAndUnregisteredInstancesExcluded(t *testing.T) {
+	defer resetClient()
+
+	a, b := *serviceInfo(), *serviceInfo()
+	a.UUID, b.UUID = config.NewUUID(), config.NewUUID()
+	b.Registered = false
+
+	criteria := &skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}}
+
+	sc := NewServiceClient(criteria)
+	sClient := sc.(*ServiceClient)
+	sClient.loadBalancer = roundrobin.New([]skynet.ServiceInfo{})
+	sClient.failureThreshold = 1
+	sClient.cooldown = time.Minute
+	// long enough that a retry isn't sent to the instance before its first failure excludes it, backing off
+	// rather than retrying as soon as it fails
+	sc.SetDefaultTimeout(50*time.Millisecond, time.Minute)
+	sClient.retryMultiplier = 2
+
+	counter := stubFailingPool(a.UUID, b.UUID)
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: a})
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: b})
+
+	err := make(chan error)
+
+	go func() {
+		var val string
+		err <- sc.Send(nil, "Foo", val, &val)
+	}()
+
+	select {
+	case e := <-err:
+		if e != NoAvailableInstances {
+			t.Fatal("Send() should return NoAvailableInstances once the only registered instance is excluded", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send() should not wait for giveup while the remaining instance is unregistered")
+	}
+
+	if counter.count(a.UUID) != 1 || counter.count(b.UUID) != 0 {
+		t.Fatal("Only the registered instance should be tried", counter.count(a.UUID), counter.count(b.UUID))
+	}
+}
+
+func TestServiceErrorsDontOpenBreaker(t *testing.T) {
+	defer resetClient()
+
+	si := *serviceInfo()
+	si.UUID = config.NewUUID()
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+	sClient := sc.(*ServiceClient)
+	sClient.loadBalancer = roundrobin.New([]skynet.ServiceInfo{})
+	sClient.failureThreshold = 2
+	sClient.cooldown = time.Minute
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			return serviceErrorConnection(t)
+		},
+	})
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: si})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 1
+	})
+
+	// bson only encodes documents, so the request gets as far as the service
+	in := map[string]string{}
+	var val string
+	for i := 0; i < 5; i++ {
+		if err := sc.SendOnce(nil, "Foo", in, &val); !conn.IsServiceError(err) {
+			t.Fatal("Expected the error from the service", err)
+		}
+	}
+
+	if sc.InstanceCount() != 1 {
+		t.Fatal("Errors returned by the service should not exclude the instance")
+	}
+}
+
+func TestHalfOpenBreakerSendsSingleProbe(t *testing.T) {
+	defer resetClient()
+
+	good, bad := *serviceInfo(), *serviceInfo()
+	good.UUID, bad.UUID = config.NewUUID(), config.NewUUID()
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+	sClient := sc.(*ServiceClient)
+	sClient.loadBalancer = roundrobin.New([]skynet.ServiceInfo{})
+	sClient.failureThreshold = 1
+	sClient.cooldown = 50 * time.Millisecond
+
+	probing := make(chan bool, 20)
+	finishProbe := make(chan bool)
+	counter := &acquireCounter{counts: make(map[string]int)}
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			counter.Lock()
+			counter.counts[s.UUID]++
+			n := counter.counts[s.UUID]
+			counter.Unlock()
+
+			c := &test.Connection{}
+
+			if s.UUID == bad.UUID {
+				c.SendTimeoutFunc = func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					// the first request opens the breaker, later ones are probes that wait to be failed
+					if n > 1 {
+						probing <- true
+						<-finishProbe
+					}
+
+					return errors.New("Instance failed")
+				}
+			}
+
+			return c, nil
+		},
+	})
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: good})
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: bad})
+
+	var val string
+	waitFor(t, func() bool {
+		sc.SendOnce(nil, "Foo", val, &val)
+		return counter.count(bad.UUID) > 0
+	})
+
+	time.Sleep(sClient.cooldown + 20*time.Millisecond)
+
+	var wait sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			var val string
+			sc.SendOnce(nil, "Foo", val, &val)
+		}()
+	}
+
+	<-probing
+
+	// every other request should go to the healthy instance while the probe is outstanding
+	waitFor(t, func() bool {
+		return counter.count(good.UUID) >= 9
+	})
+
+	if counter.count(bad.UUID) != 2 {
+		t.Fatal("Only a single probe should be sent to a half open instance", counter.count(bad.UUID))
+	}
+
+	close(finishProbe)
+	wait.Wait()
+}
+
+func TestSuccessWhileBreakerOpenLeavesRecoveryToProbe(t *testing.T) {
+	defer resetClient()
+
+	s := *serviceInfo()
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+	sClient := sc.(*ServiceClient)
+	sClient.loadBalancer = roundrobin.New([]skynet.ServiceInfo{})
+	sClient.failureThreshold = 1
+	sClient.cooldown = 100 * time.Millisecond
+	sc.SetDefaultTimeout(10*time.Millisecond, 200*time.Millisecond)
+
+	// the instance fails the first request, opening its breaker, and succeeds from then on
+	var mutex sync.Mutex
+	failed := false
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					mutex.Lock()
+					defer mutex.Unlock()
+
+					if !failed {
+						failed = true
+						return errors.New("Instance failed")
+					}
+
+					return
+				},
+			}, nil
+		},
+	})
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: s})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 1
+	})
+
+	var val string
+	if err := sc.SendOnce(nil, "Foo", val, &val); err == nil {
+		t.Fatal("First request should fail, opening the breaker")
+	}
+
+	// SendTo() sends to the instance while it's excluded
+	if err := sc.SendTo(s.AddrString(), nil, "Foo", val, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(sClient.cooldown + 50*time.Millisecond)
+
+	if err := sc.Send(nil, "Foo", val, &val); err != nil {
+		t.Fatal("Instance should be re-admitted once the cooldown passes after a success while its breaker was open", err)
+	}
+
+	if err := sc.Send(nil, "Foo", val, &val); err != nil {
+		t.Fatal("Instance should be back in the LoadBalancer once its probe succeeds", err)
+	}
+}
+
+func TestProbesOnlySentToInstancesRequestsCouldChoose(t *testing.T) {
+	defer resetClient()
+
+	current, other := *serviceInfo(), *serviceInfo()
+	current.UUID, other.UUID = config.NewUUID(), config.NewUUID()
+	other.Version = "2.0.0"
+
+	constraint, _ := versioned.ParseConstraint(current.Version)
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+	sClient := sc.(*ServiceClient)
+	sClient.loadBalancer = versioned.New(constraint, false, roundrobin.New, nil)
+	sClient.failureThreshold = 1
+	sClient.cooldown = 50 * time.Millisecond
+
+	counter := stubFailingPool()
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: current})
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: other})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 2
+	})
+
+	// both breakers are opened by requests sent outside the LoadBalancer, ex: by SendTo()
+	for _, s := range []skynet.ServiceInfo{current, other} {
+		sClient.sendMux(instanceResult{service: s, err: errors.New("Instance failed")})
+	}
+
+	time.Sleep(sClient.cooldown + 20*time.Millisecond)
+
+	var val string
+	if err := sc.SendOnce(&skynet.RequestInfo{RoutingKey: "key"}, "Foo", val, &val); err != NoAvailableInstances {
+		t.Fatal("Requests with a RoutingKey should not probe half open instances", err)
+	}
+
+	if err := sc.SendOnce(nil, "Foo", val, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	if counter.count(other.UUID) != 0 {
+		t.Fatal("Half open instances the LoadBalancer wouldn't choose should not be probed")
+	}
+
+	if counter.count(current.UUID) != 1 {
+		t.Fatal("Half open instances the LoadBalancer would choose should be probed", counter.count(current.UUID))
+	}
+}
+
+type acquireCounter struct {
+	sync.Mutex
+	counts map[string]int
+}
+
+func (ac *acquireCounter) count(uuid string) int {
+	ac.Lock()
+	defer ac.Unlock()
+
+	return ac.counts[uuid]
+}
+
+func (ac *acquireCounter) reset() {
+	ac.Lock()
+	defer ac.Unlock()
+
+	ac.counts = make(map[string]int)
+}
+
+// Stubs the pool so that requests to every instance in failing return an error
+func stubFailingPool(failing ...string) *acquireCounter {
+	ac := &acquireCounter{counts: make(map[string]int)}
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			ac.Lock()
+			ac.counts[s.UUID]++
+			ac.Unlock()
+
+			c := &test.Connection{}
+
+			for _, f := range failing {
+				if f == s.UUID {
+					c.SendTimeoutFunc = func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+						return errors.New("Instance failed")
+					}
+				}
+			}
+
+			return c, nil
+		},
+	})
+
+	return ac
+}
+
+func waitFor(t *testing.T, f func() bool) {
+	timeout := time.After(time.Second)
+
+	for !f() {
+		select {
+		case <-timeout:
+			t.Fatal("Timed out waiting for condition")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// Connects to a fake service that returns an error for every request
+func serviceErrorConnection(t *testing.T) (conn.Connection, error) {
+	client, server := net.Pipe()
+
+	go func() {
+		err := bsonrpc.NewEncoder(server).Encode(skynet.ServiceHandshake{Name: "TestService", Registered: true, ClientID: "abc"})
+
+		var ch skynet.ClientHandshake
+		if err != nil || bsonrpc.NewDecoder(server).Decode(&ch) != nil {
+			return
+		}
+
+		s := rpc.NewServer()
+		s.RegisterName("TestService", &erroringService{})
+		s.ServeCodec(bsonrpc.NewServerCodec(server))
+	}()
+
+	return conn.NewConnectionFromNetConn("TestService", client)
+}
+
+type erroringService struct{}
+
+func (es *erroringService) Forward(in skynet.ServiceRPCInRead, out *skynet.ServiceRPCOutWrite) error {
+	out.ErrString = "Bad request"
+	return nil
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/config"
+	"hash/fnv"
+)
+
+/*
+With client.canary.labels and client.canary.percent set, that share of requests with an ExperimentKey are sent to the
+instances with the labels as though it were their LabelSelector. Requests are chosen by a hash of their key, so those with
+the same key are always routed alike. They're sent to any instance while no canary is available.
+The LoadBalancer passes over the canaries while there are other instances, so other requests aren't sent to them
+*/
+type canaryPolicy struct {
+	selector string
+	percent  float64
+}
+
+// whether ri is routed to the canary instances
+func (p canaryPolicy) routes(ri *skynet.RequestInfo) bool {
+	if p.selector == "" || p.percent <= 0 || ri.ExperimentKey == "" {
+		return false
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(ri.ExperimentKey))
+
+	return float64(h.Sum32()%10000) < p.percent*100
+}
+
+// sets the LabelSelector of requests routed to the canary instances, unless they have their own
+func (c *ServiceClient) routeCanary(ri *skynet.RequestInfo) {
+	if ri.LabelSelector == "" && c.canary.routes(ri) {
+		ri.LabelSelector = c.canary.selector
+	}
+}
+
+func getCanaryPolicy(service, version string) canaryPolicy {
+	p := canaryPolicy{
+		selector: config.DefaultCanaryLabels,
+		percent:  config.DefaultCanaryPercent,
+	}
+
+	if s, err := config.String(service, version, "client.canary.labels"); err == nil {
+		p.selector = s
+	}
+
+	if f, err := config.Float(service, version, "client.canary.percent"); err == nil {
+		p.percent = f
+	}
+
+	return p
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/client/loadbalancer/canary"
+	"github.com/skynetservices/skynet/client/loadbalancer/roundrobin"
+	"github.com/skynetservices/skynet/config"
+	"github.com/skynetservices/skynet/test"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCanaryReceivesShareOfExperimentKeys(t *testing.T) {
+	defer resetClient()
+
+	canaryInstance, stable := *serviceInfo(), *serviceInfo()
+	canaryInstance.UUID, stable.UUID = config.NewUUID(), config.NewUUID()
+	canaryInstance.Labels = skynet.ParseLabels("canary=true")
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+	sc.(*ServiceClient).canary = canaryPolicy{selector: "canary=true", percent: 10}
+	sc.(*ServiceClient).loadBalancer = canary.New(skynet.ParseLabels("canary=true"), roundrobin.New, nil)
+
+	// the keys routed to the canary, whether any was sent elsewhere, and the requests that reached the canary
+	var mutex sync.Mutex
+	routed := map[string]bool{}
+	misrouted := false
+	canaried := 0
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					mutex.Lock()
+					defer mutex.Unlock()
+
+					if ri.LabelSelector != "" {
+						routed[ri.ExperimentKey] = true
+						misrouted = misrouted || s.UUID != canaryInstance.UUID
+					}
+
+					if s.UUID == canaryInstance.UUID {
+						canaried++
+					}
+
+					return
+				},
+			}, nil
+		},
+	})
+
+	for _, s := range []skynet.ServiceInfo{canaryInstance, stable} {
+		sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: s})
+	}
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 2
+	})
+
+	send := func(key string) {
+		var val string
+		if err := sc.Send(&skynet.RequestInfo{ExperimentKey: key}, "Foo", val, &val); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	requests := 2000
+	for i := 0; i < requests; i++ {
+		send("user-" + strconv.Itoa(i))
+	}
+
+	mutex.Lock()
+	n := len(routed)
+
+	if canaried < requests*7/100 || canaried > requests*13/100 {
+		t.Fatal("About client.canary.percent of requests should be sent to the canary", canaried, requests)
+	}
+
+	if n != canaried {
+		t.Fatal("Only requests routed to the canary should be sent to it", n, canaried)
+	}
+
+	if misrouted {
+		t.Fatal("Requests routed to the canary should only be sent to instances with client.canary.labels")
+	}
+	mutex.Unlock()
+
+	// keys are routed alike every time, and to other instances while there's no canary
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceRemoved, Service: canaryInstance})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 1
+	})
+
+	for key := range routed {
+		send(key)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if !misrouted {
+		t.Fatal("Requests routed to the canary should be sent to other instances while there's no canary")
+	}
+
+	if len(routed) != n {
+		t.Fatal("Requests with the same experiment key should be routed alike", len(routed), n)
+	}
+}
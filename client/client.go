@@ -1,37 +1,79 @@
 package client
 
 import (
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"github.com/skynetservices/skynet"
 	"github.com/skynetservices/skynet/client/conn"
 	"github.com/skynetservices/skynet/client/loadbalancer"
 	"github.com/skynetservices/skynet/client/loadbalancer/roundrobin"
 	"github.com/skynetservices/skynet/config"
-	"github.com/skynetservices/skynet/log"
+	"github.com/skynetservices/skynet/rpc/compress"
+	"math/rand"
+	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	DIAL_TIMEOUT = 500 * time.Millisecond
+	// DIAL_TIMEOUT is the default of client.timeout.dial
+	DIAL_TIMEOUT = config.DefaultDialTimeout
 )
 
+// how long to wait before watching the ServiceManager again if it closes our watch
+var watchRetryDelay = time.Second
+
+// how often the instance cache is re-synced with the ServiceManager, replaced in tests
+var reconcileInterval = getReconcileInterval
+
+// the most the first watch of the ServiceManager is delayed by, replaced in tests
+var startupJitter = getStartupJitter
+
 func init() {
 	go mux()
 }
 
-// TODO: implement a way to report/remove instances that fail a number of times
 var (
 	network        = "tcp"
+	tlsConfig      *tls.Config
+	codec          conn.Codec
+	compressors    []compress.Compressor
+	dial           conn.DialFunc
+	identity       conn.Identity
+	idGenerator    = config.NewUUID
 	knownNetworks  = []string{"tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "ip", "ip4", "ip6", "unix", "unixgram", "unixpacket"}
 	serviceClients = []ServiceClientProvider{}
 
-	closeChan       = make(chan bool, 1)
-	instanceWatcher = make(chan skynet.InstanceNotification, 100)
-
+	closeChan           = make(chan bool, 1)
+	instanceWatcher     = make(chan skynet.InstanceNotification, 100)
+	serviceClientChan   = make(chan serviceClientRequest)
+	removeClientChan    = make(chan serviceClientRequest)
+	cachedInstancesChan = make(chan cachedInstancesRequest)
+	rewatchChan         = make(chan bool)
+	reconcileChan       = make(chan int)
+	startWatchChan      = make(chan int)
+	resetChan           = make(chan chan bool)
+
+	// only accessed from mux()
+	knownInstances      = make(map[string]skynet.ServiceInfo)
+	watching            = false
+	reconcileGeneration = 0
+	watchRevision       uint64
+
+	// set while the first watch is delayed by startupJitter, see scheduleWatch()
+	watchTimer      *time.Timer
+	watchGeneration = 0
+
+	// use getPool() and setPool(), it's read from the goroutines mux() starts
 	pool                ConnectionPooler     = NewPool()
 	LoadBalancerFactory loadbalancer.Factory = roundrobin.New
 	waiter              sync.WaitGroup
+	poolMutex           sync.RWMutex
+
+	// instances from the ServiceManager that were ignored as they were malformed
+	discoveryErrors uint64
 )
 
 var (
@@ -61,12 +103,90 @@ func SetNetwork(network string) error {
 }
 
 /*
-client.SetLoadBalancer() provide a custom load balancer to determine the order in which instances are sent requests
+client.SetTLSConfig() connect to services over TLS using the supplied config, by default connections are plaintext.
+Only connections made after it's called will use TLS
+*/
+func SetTLSConfig(c *tls.Config) {
+	tlsConfig = c
+}
+
+/*
+client.SetCodec() encode requests to services with the supplied codec (default conn.BSONCodec), services that don't
+support it will refuse the connection during the handshake. Only connections made after it's called will use it.
+Use conn.BSONFuncCodec to encode In and Out values with custom marshal and unmarshal funcs
+*/
+func SetCodec(c conn.Codec) {
+	codec = c
+}
+
+/*
+client.SetCompressors() compress request and response payloads with the first of the supplied compressors the service
+supports, falling back to uncompressed payloads if it supports none. Only connections made after it's called will use them
+*/
+func SetCompressors(c ...compress.Compressor) {
+	compressors = c
+}
+
+/*
+client.SetIdentity() identify this client to services during the handshake with a name, version and free-form metadata,
+ex: for them to log or authorize it. Only connections made after it's called will send it
+*/
+func SetIdentity(name, version string, metadata map[string]string) {
+	identity = conn.Identity{Name: name, Version: version, Metadata: metadata}
+}
+
+/*
+client.SetDialFunc() connect to services with the supplied DialFunc, ex: through a SOCKS proxy, by default they're
+dialed directly over GetNetwork(). Only connections made after it's called will use it
+*/
+func SetDialFunc(d conn.DialFunc) {
+	dial = d
+}
+
+/*
+client.SetIDGenerator() mint the RequestID of requests sent without a RequestInfo with the supplied func (default config.NewUUID),
+ex: to carry on an upstream ID scheme. It must be safe to call from multiple goroutines
+*/
+func SetIDGenerator(g func() string) {
+	if g == nil {
+		g = config.NewUUID
+	}
+
+	idGenerator = g
+}
+
+/*
+client.SetLoadBalancerFactory() provide a custom load balancer to determine the order in which instances are sent requests
+Available strategies are roundrobin.New (default), random.New, weighted.New, consistenthash.New, latency.New and leastconnections.NewFactory(client.ActiveConnections)
 */
 func SetLoadBalancerFactory(factory loadbalancer.Factory) {
 	LoadBalancerFactory = factory
 }
 
+/*
+client.ActiveConnections() returns the number of connections to the instance currently in use.
+This can be supplied to leastconnections.NewFactory
+*/
+func ActiveConnections(s skynet.ServiceInfo) int {
+	return getPool().NumActiveConnections(s)
+}
+
+/*
+client.InFlightRequests() returns the number of requests to the instance currently in flight.
+This can be supplied to capped.NewFactory
+*/
+func InFlightRequests(s skynet.ServiceInfo) int {
+	return getPool().NumInFlight(s)
+}
+
+/*
+client.DiscoveryErrors() returns the number of instances reported by the ServiceManager that were ignored because
+they were malformed, such as missing a UUID or address
+*/
+func DiscoveryErrors() uint64 {
+	return atomic.LoadUint64(&discoveryErrors)
+}
+
 /*
 client.GetServiceFromCriteria() Returns a client specific to the skynet.Criteria provided.
 Only instances that match this criteria will service the requests.
@@ -121,25 +241,86 @@ func GetService(name string, version string, region string, host string) Service
 func mux() {
 	for {
 		select {
-		case n := <-instanceWatcher:
-			updateInstance(n)
-		case <-closeChan:
-			for _, sc := range serviceClients {
-				sc.Close()
+		case n, ok := <-instanceWatcher:
+			if !ok {
+				watchClosed()
+				continue
+			}
+
+			watchedInstance(n)
+		case <-rewatchChan:
+			rewatch()
+		case gen := <-startWatchChan:
+			// ignore a delayed watch that was cancelled as it started
+			if gen == watchGeneration && !watching {
+				startWatch()
 			}
+		case gen := <-reconcileChan:
+			// ignore passes scheduled before the watch was restarted
+			if gen == reconcileGeneration {
+				reconcile()
+				scheduleReconcile()
+			}
+		case r := <-serviceClientChan:
+			addServiceClientMux(r.sc)
+			close(r.done)
+		case r := <-removeClientChan:
+			removeServiceClientMux(r.sc)
+			close(r.done)
+		case r := <-cachedInstancesChan:
+			r.instances <- filterInstances(r.criteria)
+		case done := <-resetChan:
+			resetMux()
+			close(done)
+		case <-closeChan:
+			cancelWatch()
 
-			pool.Close()
+			closing := serviceClients
 			serviceClients = []ServiceClientProvider{}
-			waiter.Done()
+
+			// ServiceClients remove themselves through mux() as they close
+			go func() {
+				for _, sc := range closing {
+					sc.Close()
+				}
+
+				getPool().Close()
+				waiter.Done()
+			}()
 		}
 	}
 }
 
+// this should only be called by mux()
+func resetMux() {
+	serviceClients = []ServiceClientProvider{}
+	knownInstances = make(map[string]skynet.ServiceInfo)
+	watching = false
+	watchRevision = 0
+	cancelWatch()
+
+	// any reconcile already scheduled belongs to the old watch
+	reconcileGeneration++
+}
+
+func getPool() ConnectionPooler {
+	poolMutex.RLock()
+	defer poolMutex.RUnlock()
+
+	return pool
+}
+
+func setPool(p ConnectionPooler) {
+	poolMutex.Lock()
+	pool = p
+	poolMutex.Unlock()
+}
+
 /*
 client.acquire will return an idle connection or a new one
 */
 func acquire(s skynet.ServiceInfo) (c conn.Connection, err error) {
-	return pool.Acquire(s)
+	return getPool().Acquire(s)
 }
 
 /*
@@ -147,41 +328,375 @@ client.release will release a resource for use by others. If the idle queue is
 full, the resource will be closed.
 */
 func release(c conn.Connection) {
-	pool.Release(c)
+	getPool().Release(c)
+}
+
+type serviceClientRequest struct {
+	sc   ServiceClientProvider
+	done chan bool
 }
 
 func addServiceClient(sc ServiceClientProvider) {
+	done := make(chan bool)
+	serviceClientChan <- serviceClientRequest{sc: sc, done: done}
+
+	<-done
+}
+
+func removeServiceClient(sc ServiceClientProvider) {
+	done := make(chan bool)
+	removeClientChan <- serviceClientRequest{sc: sc, done: done}
+
+	<-done
+}
+
+// only call from mux()
+func addServiceClientMux(sc ServiceClientProvider) {
 	serviceClients = append(serviceClients, sc)
 
-	instances := skynet.GetServiceManager().Watch(sc, instanceWatcher)
+	// We only ask the ServiceManager for instances once, from then on knownInstances is kept up to date by the
+	// notifications from the watch, and new ServiceClients are populated from it
+	switch {
+	case watching:
+		populateServiceClient(sc)
+	case watchTimer == nil:
+		scheduleWatch()
+	}
+}
+
+/*
+only call from mux()
+Clients started together, such as after a deploy, would all watch the ServiceManager at once, so the first watch
+is delayed by up to client.discovery.jitter. ServiceClients added in the meantime are populated once it starts
+*/
+func scheduleWatch() {
+	d := startupJitter()
+	if d <= 0 {
+		startWatch()
+		return
+	}
+
+	delay := time.Duration(rand.Int63n(int64(d)))
+	logger.Debug("Watching ServiceManager in " + delay.String())
+
+	gen := watchGeneration
+	watchTimer = time.AfterFunc(delay, func() {
+		startWatchChan <- gen
+	})
+}
+
+// only call from mux()
+func startWatch() {
+	watchTimer = nil
+
+	for _, i := range skynet.GetServiceManager().Watch(&skynet.Criteria{}, instanceWatcher) {
+		if validInstance(i, skynet.InstanceAdded) {
+			knownInstances[i.UUID] = i
+		}
+	}
+
+	watching = true
+	watchRevision = 0
+	scheduleReconcile()
+
+	for _, sc := range serviceClients {
+		populateServiceClient(sc)
+	}
+}
+
+// only call from mux(), stops a delayed watch from starting
+func cancelWatch() {
+	if watchTimer != nil {
+		watchTimer.Stop()
+		watchTimer = nil
+	}
+
+	watchGeneration++
+}
+
+// only call from mux()
+func populateServiceClient(sc ServiceClientProvider) {
+	for _, i := range knownInstances {
+		if sc.Matches(i) {
+			getPool().AddInstance(i)
+			sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: i})
+		}
+	}
+}
+
+// only call from mux()
+func watchClosed() {
+	logger.Warn("ServiceManager closed instance watch, watching again in " + watchRetryDelay.String())
+
+	// stop receiving from the closed channel until we're watching again
+	instanceWatcher = nil
+
+	time.AfterFunc(watchRetryDelay, func() {
+		rewatchChan <- true
+	})
+}
+
+// only call from mux()
+func rewatch() {
+	instanceWatcher = make(chan skynet.InstanceNotification, 100)
+	watchRevision = 0
+
+	current := make(map[string]skynet.ServiceInfo)
+	for _, i := range skynet.GetServiceManager().Watch(&skynet.Criteria{}, instanceWatcher) {
+		if validInstance(i, skynet.InstanceAdded) {
+			current[i.UUID] = i
+		}
+	}
+
+	// Apply anything we missed while we weren't watching
+	applyMissed(current)
+
+	// the cache is now in sync, start the reconcile schedule again so passes scheduled before are ignored
+	scheduleReconcile()
+}
+
+// only call from mux()
+func scheduleReconcile() {
+	reconcileGeneration++
+
+	d := reconcileInterval()
+	if d <= 0 {
+		return
+	}
+
+	// jitter by up to a quarter either way so clients started together don't list instances together
+	d = d - d/4 + time.Duration(rand.Int63n(int64(d/2)+1))
+
+	gen := reconcileGeneration
+	time.AfterFunc(d, func() {
+		reconcileChan <- gen
+	})
+}
+
+// only call from mux(), repairs the cache should we have missed any notifications from the watch
+func reconcile() {
+	// the cache will be re-synced when we watch again
+	if !watching || instanceWatcher == nil {
+		return
+	}
+
+	current, err := listInstances()
+	if err != nil {
+		logger.Error("Failed to list instances to reconcile with ServiceManager", err)
+		return
+	}
 
+	if n := applyMissed(current); n > 0 {
+		logger.Warn(fmt.Sprintf("Repaired %d instances that were out of sync with ServiceManager", n))
+	}
+}
+
+/*
+only call from mux()
+Notifications from a ServiceManager that numbers its changes should arrive with increasing revisions, a revision that
+goes backwards means its store was restarted or failed over, and changes may have been missed or be replayed.
+Rather than trusting the notification the cache is re-synced from a listing, and later revisions are compared to its
+*/
+func watchedInstance(n skynet.InstanceNotification) {
+	if n.Revision == 0 {
+		updateInstance(n)
+		return
+	}
+
+	if n.Revision > watchRevision {
+		watchRevision = n.Revision
+		updateInstance(n)
+		return
+	}
+
+	logger.Warn(fmt.Sprintf("ServiceManager revision went back from %d to %d, re-syncing instances", watchRevision, n.Revision))
+	watchRevision = n.Revision
+
+	current, err := listInstances()
+	if err != nil {
+		logger.Error("Failed to list instances to re-sync with ServiceManager", err)
+		updateInstance(n)
+		return
+	}
+
+	if c := applyMissed(current); c > 0 {
+		logger.Warn(fmt.Sprintf("Repaired %d instances that were out of sync with ServiceManager", c))
+	}
+}
+
+// only call from mux(), returns the valid instances the ServiceManager knows of
+func listInstances() (map[string]skynet.ServiceInfo, error) {
+	instances, err := skynet.GetServiceManager().ListInstances(&skynet.Criteria{})
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]skynet.ServiceInfo)
 	for _, i := range instances {
-		pool.AddInstance(i)
-		sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: i})
+		if validInstance(i, skynet.InstanceAdded) {
+			current[i.UUID] = i
+		}
+	}
+
+	return current, nil
+}
+
+// only call from mux(), updates knownInstances to match current returning the number of instances that differed
+func applyMissed(current map[string]skynet.ServiceInfo) (n int) {
+	for uuid, i := range knownInstances {
+		if _, ok := current[uuid]; !ok {
+			logger.Warn(fmt.Sprintf("Instance %s of %s was removed without a notification", uuid, i.Name))
+			updateInstance(skynet.InstanceNotification{Type: skynet.InstanceRemoved, Service: i, Reason: skynet.RemovedMissing})
+			n++
+		}
+	}
+
+	for uuid, i := range current {
+		if existing, ok := knownInstances[uuid]; !ok {
+			logger.Warn(fmt.Sprintf("Instance %s of %s was added without a notification", uuid, i.Name))
+			updateInstance(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: i})
+			n++
+		} else if !reflect.DeepEqual(existing, i) {
+			logger.Warn(fmt.Sprintf("Instance %s of %s was updated without a notification", uuid, i.Name))
+			updateInstance(skynet.InstanceNotification{Type: skynet.InstanceUpdated, Service: i})
+			n++
+		}
+	}
+
+	return
+}
+
+type cachedInstancesRequest struct {
+	criteria  skynet.CriteriaMatcher
+	instances chan []skynet.ServiceInfo
+}
+
+// returns the known instances that match the criteria
+func cachedInstances(c skynet.CriteriaMatcher) []skynet.ServiceInfo {
+	r := cachedInstancesRequest{criteria: c, instances: make(chan []skynet.ServiceInfo)}
+	cachedInstancesChan <- r
+
+	return <-r.instances
+}
+
+// only call from mux()
+func filterInstances(c skynet.CriteriaMatcher) (instances []skynet.ServiceInfo) {
+	for _, i := range knownInstances {
+		if c.Matches(i) {
+			instances = append(instances, i)
+		}
+	}
+
+	return
+}
+
+// only call from mux()
+func removeServiceClientMux(sc ServiceClientProvider) {
+	removed := false
+
+	for i, s := range serviceClients {
+		if s == sc {
+			serviceClients = append(serviceClients[:i], serviceClients[i+1:]...)
+			removed = true
+			break
+		}
+	}
+
+	if !removed {
+		return
+	}
+
+	// Connections are only needed to instances a remaining ServiceClient may use, the watch is left
+	// running so knownInstances stays current for future ServiceClients
+	for _, i := range knownInstances {
+		if sc.Matches(i) && !matchesServiceClient(i) {
+			go getPool().RemoveInstance(i)
+		}
 	}
 }
 
-// TODO: we need a method here to removeServiceClient
-// it should remove instances from the pool if there are no remaining
-// ServiceClients that match the instance, and should end the watch from ServiceManager
+// only call from mux()
+func matchesServiceClient(s skynet.ServiceInfo) bool {
+	for _, sc := range serviceClients {
+		if sc.Matches(s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// only call from mux(), instances we wouldn't be able to track or connect to are counted and ignored
+func validInstance(s skynet.ServiceInfo, typ int) bool {
+	var reason string
+
+	switch {
+	case s.UUID == "":
+		reason = "no UUID"
+	case typ != skynet.InstanceRemoved && (s.ServiceAddr.IPAddress == "" || s.ServiceAddr.Port == 0):
+		reason = "no address"
+	case typ != skynet.InstanceRemoved && s.Name == "":
+		reason = "no name"
+	default:
+		return true
+	}
+
+	atomic.AddUint64(&discoveryErrors, 1)
+	logger.Error(fmt.Sprintf("Ignoring malformed instance %q of %q from ServiceManager with %s", s.UUID, s.Name, reason))
+
+	return false
+}
 
 // only call from mux()
 func updateInstance(n skynet.InstanceNotification) {
+	if !validInstance(n.Service, n.Type) {
+		return
+	}
+
+	// the watch and a reconcile pass may both report an instance, it's only added once
+	if existing, ok := knownInstances[n.Service.UUID]; ok && n.Type == skynet.InstanceAdded {
+		if reflect.DeepEqual(existing, n.Service) {
+			logger.Debug(fmt.Sprintf("Ignoring instance %s of %s at %s, it's already known", n.Service.UUID, n.Service.Name, n.Service.AddrString()))
+			return
+		}
+
+		n.Type = skynet.InstanceUpdated
+	}
+
+	switch n.Type {
+	case skynet.InstanceAdded:
+		logger.Debug(fmt.Sprintf("Instance %s of %s at %s added", n.Service.UUID, n.Service.Name, n.Service.AddrString()))
+		knownInstances[n.Service.UUID] = n.Service
+	case skynet.InstanceUpdated:
+		logger.Debug(fmt.Sprintf("Instance %s of %s at %s updated", n.Service.UUID, n.Service.Name, n.Service.AddrString()))
+		knownInstances[n.Service.UUID] = n.Service
+	case skynet.InstanceRemoved:
+		logger.Debug(fmt.Sprintf("Instance %s of %s at %s %s", n.Service.UUID, n.Service.Name, n.Service.AddrString(), n.Reason))
+		delete(knownInstances, n.Service.UUID)
+	default:
+		logger.Warn(fmt.Sprintf("Ignoring instance notification with unknown type %d", n.Type))
+		return
+	}
+
 	// Forward notification on to ServiceClients that match
+	matched := false
 	for _, sc := range serviceClients {
 		if sc.Matches(n.Service) {
+			matched = true
 			go sc.Notify(n)
 		}
 	}
 
-	// Update our internal pools
+	// Update our internal pools, we only need connections to instances a ServiceClient may use
 	switch n.Type {
 	case skynet.InstanceAdded:
-		go pool.AddInstance(n.Service)
+		if matched {
+			go getPool().AddInstance(n.Service)
+		}
 	case skynet.InstanceUpdated:
-		go pool.UpdateInstance(n.Service)
+		go getPool().UpdateInstance(n.Service)
 	case skynet.InstanceRemoved:
-		go pool.RemoveInstance(n.Service)
+		go getPool().RemoveInstance(n.Service)
 	}
 
 }
@@ -194,6 +709,14 @@ func getIdleConnectionsToInstance(s skynet.ServiceInfo) int {
 	return config.DefaultIdleConnectionsToInstance
 }
 
+func getMinConnectionsToInstance(s skynet.ServiceInfo) int {
+	if n, err := config.Int(s.Name, s.Version, "client.conn.min"); err == nil {
+		return n
+	}
+
+	return config.DefaultMinConnectionsToInstance
+}
+
 func getMaxConnectionsToInstance(s skynet.ServiceInfo) int {
 	if n, err := config.Int(s.Name, s.Version, "client.conn.max"); err == nil {
 		return n
@@ -208,8 +731,128 @@ func getIdleTimeout(s skynet.ServiceInfo) time.Duration {
 			return timeout
 		}
 
-		log.Println(log.ERROR, "Failed to parse client.timeout.idle", err)
+		logger.Error("Failed to parse client.timeout.idle", err)
 	}
 
 	return config.DefaultIdleTimeout
 }
+
+func getHandshakeTimeout(s skynet.ServiceInfo) time.Duration {
+	if d, err := config.String(s.Name, s.Version, "client.timeout.handshake"); err == nil {
+		if timeout, err := time.ParseDuration(d); err == nil {
+			return timeout
+		}
+
+		logger.Error("Failed to parse client.timeout.handshake", err)
+	}
+
+	return config.DefaultHandshakeTimeout
+}
+
+func getStartupJitter() time.Duration {
+	if d, err := config.RawStringDefault("client.discovery.jitter"); err == nil {
+		if jitter, err := time.ParseDuration(d); err == nil {
+			return jitter
+		}
+
+		logger.Error("Failed to parse client.discovery.jitter", err)
+	}
+
+	return config.DefaultStartupJitter
+}
+
+func getReconcileInterval() time.Duration {
+	if d, err := config.RawStringDefault("client.reconcile.interval"); err == nil {
+		if interval, err := time.ParseDuration(d); err == nil {
+			return interval
+		}
+
+		logger.Error("Failed to parse client.reconcile.interval", err)
+	}
+
+	return config.DefaultReconcileInterval
+}
+
+func getWarmConnections(s skynet.ServiceInfo) int {
+	if n, err := config.Int(s.Name, s.Version, "client.conn.warm"); err == nil {
+		return n
+	}
+
+	return config.DefaultWarmConnections
+}
+
+func getMaxLifetime(s skynet.ServiceInfo) time.Duration {
+	if d, err := config.String(s.Name, s.Version, "client.conn.lifetime"); err == nil {
+		if timeout, err := time.ParseDuration(d); err == nil {
+			return timeout
+		}
+
+		logger.Error("Failed to parse client.conn.lifetime", err)
+	}
+
+	return config.DefaultMaxLifetime
+}
+
+func getStaleTimeout(s skynet.ServiceInfo) time.Duration {
+	if d, err := config.String(s.Name, s.Version, "client.conn.stale"); err == nil {
+		if timeout, err := time.ParseDuration(d); err == nil {
+			return timeout
+		}
+
+		logger.Error("Failed to parse client.conn.stale", err)
+	}
+
+	return config.DefaultStaleTimeout
+}
+
+func getKeepAliveInterval(s skynet.ServiceInfo) time.Duration {
+	if d, err := config.String(s.Name, s.Version, "client.conn.keepalive"); err == nil {
+		if interval, err := time.ParseDuration(d); err == nil {
+			return interval
+		}
+
+		logger.Error("Failed to parse client.conn.keepalive", err)
+	}
+
+	return config.DefaultKeepAliveInterval
+}
+
+func getDialTimeout(s skynet.ServiceInfo) time.Duration {
+	if d, err := config.String(s.Name, s.Version, "client.timeout.dial"); err == nil {
+		if timeout, err := time.ParseDuration(d); err == nil {
+			return timeout
+		}
+
+		logger.Error("Failed to parse client.timeout.dial", err)
+	}
+
+	return config.DefaultDialTimeout
+}
+
+func getAcquireTimeout(s skynet.ServiceInfo) time.Duration {
+	if d, err := config.String(s.Name, s.Version, "client.conn.acquire.timeout"); err == nil {
+		if timeout, err := time.ParseDuration(d); err == nil {
+			return timeout
+		}
+
+		logger.Error("Failed to parse client.conn.acquire.timeout", err)
+	}
+
+	return config.DefaultAcquireTimeout
+}
+
+func getMaxResponseBytes(s skynet.ServiceInfo) int {
+	if n, err := config.Int(s.Name, s.Version, "client.response.max"); err == nil {
+		return n
+	}
+
+	return config.DefaultMaxResponseBytes
+}
+
+func getPingMethod(s skynet.ServiceInfo) string {
+	if m, err := config.String(s.Name, s.Version, "client.conn.ping"); err == nil {
+		return m
+	}
+
+	return config.DefaultPingMethod
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/skynetservices/skynet"
 	"github.com/skynetservices/skynet/client/loadbalancer/roundrobin"
+	"github.com/skynetservices/skynet/config"
 	"github.com/skynetservices/skynet/test"
 	"testing"
 	"time"
@@ -14,6 +15,13 @@ var serviceManager = new(test.ServiceManager)
 
 func init() {
 	skynet.SetServiceManager(serviceManager)
+
+	// tests that reconcile enable it, otherwise it would empty the cache as serviceManager lists no instances
+	reconcileInterval = noReconcile
+}
+
+func noReconcile() time.Duration {
+	return 0
 }
 
 func TestGetNetwork(t *testing.T) {
@@ -143,11 +151,11 @@ func TestInstanceNotificationsUpdatePool(t *testing.T) {
 	si := serviceInfo()
 
 	// Add
-	pool = &test.Pool{
+	setPool(&test.Pool{
 		AddInstanceFunc: func(s skynet.ServiceInfo) {
 			watch <- true
 		},
-	}
+	})
 
 	go receiveOrTimeout(watch, receive, timeout)
 	go sendInstanceNotification(skynet.InstanceAdded, *si)
@@ -159,11 +167,11 @@ func TestInstanceNotificationsUpdatePool(t *testing.T) {
 
 	// Update
 	si.Registered = false
-	pool = &test.Pool{
+	setPool(&test.Pool{
 		UpdateInstanceFunc: func(s skynet.ServiceInfo) {
 			watch <- true
 		},
-	}
+	})
 
 	go receiveOrTimeout(watch, receive, timeout)
 	go sendInstanceNotification(skynet.InstanceUpdated, *si)
@@ -174,11 +182,11 @@ func TestInstanceNotificationsUpdatePool(t *testing.T) {
 	}
 
 	// Remove
-	pool = &test.Pool{
+	setPool(&test.Pool{
 		RemoveInstanceFunc: func(s skynet.ServiceInfo) {
 			watch <- true
 		},
-	}
+	})
 
 	go receiveOrTimeout(watch, receive, timeout)
 	go sendInstanceNotification(skynet.InstanceRemoved, *si)
@@ -189,6 +197,578 @@ func TestInstanceNotificationsUpdatePool(t *testing.T) {
 	}
 }
 
+func TestInstanceCacheConvergesFromWatch(t *testing.T) {
+	defer resetClient()
+
+	setPool(&test.Pool{})
+
+	a, b, c, d := *serviceInfo(), *serviceInfo(), *serviceInfo(), *serviceInfo()
+	a.UUID, b.UUID, c.UUID, d.UUID = config.NewUUID(), config.NewUUID(), config.NewUUID(), config.NewUUID()
+
+	watchCalls := 0
+	skynet.SetServiceManager(serviceManager)
+	serviceManager.WatchFunc = func(criteria skynet.CriteriaMatcher, c chan<- skynet.InstanceNotification) []skynet.ServiceInfo {
+		watchCalls++
+		return []skynet.ServiceInfo{a, b}
+	}
+	defer func() { serviceManager.WatchFunc = nil }()
+
+	addServiceClient(&test.ServiceClient{})
+
+	b.Registered = false
+
+	// the update to b is last so that the cache only matches once every notification is applied
+	sendInstanceNotification(skynet.InstanceAdded, c)
+	sendInstanceNotification(skynet.InstanceRemoved, a)
+	sendInstanceNotification(skynet.InstanceAdded, d)
+	sendInstanceNotification(skynet.InstanceRemoved, d)
+	sendInstanceNotification(skynet.InstanceUpdated, b)
+
+	// ignore any instances left over from other tests
+	criteria := &skynet.Criteria{Instances: []string{a.UUID, b.UUID, c.UUID, d.UUID}}
+
+	waitFor(t, func() bool {
+		instances := cachedInstances(criteria)
+
+		for _, i := range instances {
+			if i.UUID == b.UUID && i.Registered {
+				return false
+			}
+		}
+
+		return len(instances) == 2 && containsInstance(instances, b.UUID) && containsInstance(instances, c.UUID)
+	})
+
+	// Additional ServiceClients should be populated from the cache
+	notified := []skynet.ServiceInfo{}
+
+	addServiceClient(&test.ServiceClient{
+		MatchesFunc: func(s skynet.ServiceInfo) bool {
+			return criteria.Matches(s)
+		},
+		NotifyFunc: func(n skynet.InstanceNotification) {
+			notified = append(notified, n.Service)
+		},
+	})
+
+	if watchCalls != 1 {
+		t.Fatal("ServiceManager should only be watched once", watchCalls)
+	}
+
+	if len(notified) != 2 || !containsInstance(notified, b.UUID) || !containsInstance(notified, c.UUID) {
+		t.Fatal("New ServiceClient was not notified of cached instances", notified)
+	}
+}
+
+func TestDuplicateInstanceAddedIgnored(t *testing.T) {
+	defer resetClient()
+
+	setPool(&test.Pool{})
+
+	notified := make(chan int, 3)
+
+	addServiceClient(&test.ServiceClient{
+		MatchesFunc: func(s skynet.ServiceInfo) bool {
+			return true
+		},
+		NotifyFunc: func(n skynet.InstanceNotification) {
+			notified <- n.Type
+		},
+	})
+
+	si := *serviceInfo()
+	si.UUID = config.NewUUID()
+
+	sendInstanceNotification(skynet.InstanceAdded, si)
+	sendInstanceNotification(skynet.InstanceAdded, si)
+
+	si.Weight = 5
+	sendInstanceNotification(skynet.InstanceAdded, si)
+
+	types := map[int]int{}
+	for i := 0; i < 2; i++ {
+		select {
+		case typ := <-notified:
+			types[typ]++
+		case <-time.After(time.Second):
+			t.Fatal("ServiceClient was not notified of the instance")
+		}
+	}
+
+	select {
+	case <-notified:
+		t.Fatal("ServiceClient should only be notified once of an instance added twice")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if types[skynet.InstanceAdded] != 1 || types[skynet.InstanceUpdated] != 1 {
+		t.Fatal("Instance added again with changes should be notified as an update", types)
+	}
+}
+
+func TestClosedWatchBacksOffAndResyncs(t *testing.T) {
+	defer resetClient()
+
+	setPool(&test.Pool{})
+	watchRetryDelay = 20 * time.Millisecond
+	defer func() { watchRetryDelay = time.Second }()
+
+	a, b, c := *serviceInfo(), *serviceInfo(), *serviceInfo()
+	a.UUID, b.UUID, c.UUID = config.NewUUID(), config.NewUUID(), config.NewUUID()
+
+	var closedAt time.Time
+	watches := make(chan time.Time, 2)
+
+	skynet.SetServiceManager(serviceManager)
+	serviceManager.WatchFunc = func(criteria skynet.CriteriaMatcher, ch chan<- skynet.InstanceNotification) []skynet.ServiceInfo {
+		watches <- time.Now()
+
+		if closedAt.IsZero() {
+			closedAt = time.Now()
+			close(ch)
+
+			return []skynet.ServiceInfo{a, b}
+		}
+
+		// a was removed and b updated while we weren't watching
+		b.Registered = false
+		return []skynet.ServiceInfo{b, c}
+	}
+	defer func() { serviceManager.WatchFunc = nil }()
+
+	addServiceClient(&test.ServiceClient{})
+	<-watches
+
+	select {
+	case rewatchedAt := <-watches:
+		if rewatchedAt.Sub(closedAt) < watchRetryDelay {
+			t.Fatal("Watch should back off after being closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ServiceManager was not watched again after watch was closed")
+	}
+
+	criteria := &skynet.Criteria{Instances: []string{a.UUID, b.UUID, c.UUID}}
+
+	waitFor(t, func() bool {
+		instances := cachedInstances(criteria)
+
+		for _, i := range instances {
+			if i.UUID == b.UUID && i.Registered {
+				return false
+			}
+		}
+
+		return len(instances) == 2 && containsInstance(instances, b.UUID) && containsInstance(instances, c.UUID)
+	})
+}
+
+func TestReconcileRepairsMissedRemoval(t *testing.T) {
+	defer resetClient()
+
+	setPool(&test.Pool{})
+
+	reconcileInterval = func() time.Duration {
+		return 10 * time.Millisecond
+	}
+	defer restoreReconcileInterval()
+
+	a, b := *serviceInfo(), *serviceInfo()
+	a.UUID, b.UUID = config.NewUUID(), config.NewUUID()
+
+	skynet.SetServiceManager(serviceManager)
+	serviceManager.WatchFunc = func(criteria skynet.CriteriaMatcher, c chan<- skynet.InstanceNotification) []skynet.ServiceInfo {
+		return []skynet.ServiceInfo{a, b}
+	}
+
+	// b is removed, but the notification is never sent
+	serviceManager.ListInstancesFunc = func(c skynet.CriteriaMatcher) ([]skynet.ServiceInfo, error) {
+		return []skynet.ServiceInfo{a}, nil
+	}
+	defer func() {
+		serviceManager.WatchFunc = nil
+		serviceManager.ListInstancesFunc = nil
+	}()
+
+	addServiceClient(&test.ServiceClient{})
+
+	criteria := &skynet.Criteria{Instances: []string{a.UUID, b.UUID}}
+
+	waitFor(t, func() bool {
+		instances := cachedInstances(criteria)
+		return len(instances) == 1 && containsInstance(instances, a.UUID)
+	})
+}
+
+func TestFirstWatchDelayedByStartupJitter(t *testing.T) {
+	defer restoreStartupJitter()
+
+	setPool(&test.Pool{})
+
+	startupJitter = func() time.Duration {
+		return 50 * time.Millisecond
+	}
+
+	si := *serviceInfo()
+	si.UUID = config.NewUUID()
+
+	watched := make(chan time.Time, 1)
+	skynet.SetServiceManager(serviceManager)
+	serviceManager.WatchFunc = func(criteria skynet.CriteriaMatcher, ch chan<- skynet.InstanceNotification) []skynet.ServiceInfo {
+		watched <- time.Now()
+		return []skynet.ServiceInfo{si}
+	}
+	defer func() { serviceManager.WatchFunc = nil }()
+
+	notified := make(chan skynet.InstanceNotification, 1)
+	start := time.Now()
+
+	addServiceClient(&test.ServiceClient{
+		MatchesFunc: func(s skynet.ServiceInfo) bool {
+			return true
+		},
+		NotifyFunc: func(n skynet.InstanceNotification) {
+			notified <- n
+		},
+	})
+
+	select {
+	case at := <-watched:
+		if at.Sub(start) > 50*time.Millisecond+100*time.Millisecond {
+			t.Fatal("First watch should start within the startup jitter", at.Sub(start))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("First watch should start once its delay passes")
+	}
+
+	select {
+	case n := <-notified:
+		if n.Service.UUID != si.UUID {
+			t.Fatal("ServiceClient added while the watch was delayed notified of the wrong instance", n.Service.UUID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ServiceClient added while the watch was delayed should be populated once it starts")
+	}
+}
+
+func TestCloseDuringStartupJitter(t *testing.T) {
+	defer restoreStartupJitter()
+
+	setPool(&test.Pool{})
+
+	startupJitter = func() time.Duration {
+		return time.Hour
+	}
+
+	watched := make(chan bool, 1)
+	skynet.SetServiceManager(serviceManager)
+	serviceManager.WatchFunc = func(criteria skynet.CriteriaMatcher, ch chan<- skynet.InstanceNotification) []skynet.ServiceInfo {
+		watched <- true
+		return []skynet.ServiceInfo{}
+	}
+	defer func() { serviceManager.WatchFunc = nil }()
+
+	addServiceClient(&test.ServiceClient{})
+
+	closed := make(chan bool)
+	go func() {
+		Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close() should return while the first watch is delayed")
+	}
+
+	select {
+	case <-watched:
+		t.Fatal("Closing should cancel the delayed watch")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestRevisionRegressionResyncs(t *testing.T) {
+	defer resetClient()
+
+	setPool(&test.Pool{})
+
+	a, b, c, replayed := *serviceInfo(), *serviceInfo(), *serviceInfo(), *serviceInfo()
+	a.UUID, b.UUID, c.UUID, replayed.UUID = config.NewUUID(), config.NewUUID(), config.NewUUID(), config.NewUUID()
+
+	watch := make(chan chan<- skynet.InstanceNotification, 1)
+	skynet.SetServiceManager(serviceManager)
+	serviceManager.WatchFunc = func(criteria skynet.CriteriaMatcher, ch chan<- skynet.InstanceNotification) []skynet.ServiceInfo {
+		watch <- ch
+		return []skynet.ServiceInfo{}
+	}
+
+	// the ServiceManager's store failed over, losing b and gaining c
+	serviceManager.ListInstancesFunc = func(criteria skynet.CriteriaMatcher) ([]skynet.ServiceInfo, error) {
+		return []skynet.ServiceInfo{a, c}, nil
+	}
+	defer func() {
+		serviceManager.WatchFunc = nil
+		serviceManager.ListInstancesFunc = nil
+	}()
+
+	addServiceClient(&test.ServiceClient{})
+	ch := <-watch
+
+	criteria := &skynet.Criteria{Instances: []string{a.UUID, b.UUID, c.UUID, replayed.UUID}}
+
+	ch <- skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: a, Revision: 5}
+	ch <- skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: b, Revision: 6}
+
+	waitFor(t, func() bool {
+		return len(cachedInstances(criteria)) == 2
+	})
+
+	ch <- skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: replayed, Revision: 2}
+
+	waitFor(t, func() bool {
+		instances := cachedInstances(criteria)
+		return len(instances) == 2 && containsInstance(instances, a.UUID) && containsInstance(instances, c.UUID)
+	})
+
+	// later revisions follow on from the one we re-synced at
+	ch <- skynet.InstanceNotification{Type: skynet.InstanceRemoved, Service: c, Revision: 3}
+
+	waitFor(t, func() bool {
+		instances := cachedInstances(criteria)
+		return len(instances) == 1 && containsInstance(instances, a.UUID)
+	})
+}
+
+func TestRemovalReasons(t *testing.T) {
+	defer resetClient()
+
+	setPool(&test.Pool{})
+	watchRetryDelay = 10 * time.Millisecond
+	defer func() { watchRetryDelay = time.Second }()
+
+	removed, missing := *serviceInfo(), *serviceInfo()
+	removed.UUID, missing.UUID = config.NewUUID(), config.NewUUID()
+
+	watches := 0
+	skynet.SetServiceManager(serviceManager)
+	serviceManager.WatchFunc = func(criteria skynet.CriteriaMatcher, ch chan<- skynet.InstanceNotification) []skynet.ServiceInfo {
+		// missing is removed while we aren't watching
+		if watches++; watches > 1 {
+			return []skynet.ServiceInfo{}
+		}
+
+		return []skynet.ServiceInfo{removed, missing}
+	}
+	defer func() { serviceManager.WatchFunc = nil }()
+
+	removals := make(chan skynet.InstanceNotification, 10)
+	addServiceClient(&test.ServiceClient{
+		MatchesFunc: func(s skynet.ServiceInfo) bool {
+			return true
+		},
+		NotifyFunc: func(n skynet.InstanceNotification) {
+			if n.Type == skynet.InstanceRemoved {
+				removals <- n
+			}
+		},
+	})
+
+	expectRemoval := func(s skynet.ServiceInfo, reason skynet.RemovalReason) {
+		select {
+		case n := <-removals:
+			if n.Service.UUID != s.UUID || n.Reason != reason {
+				t.Fatalf("Expected %s to be removed as %s, got %s removed as %s", s.UUID, reason, n.Service.UUID, n.Reason)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("ServiceClient was not notified of removal")
+		}
+	}
+
+	sendInstanceNotification(skynet.InstanceRemoved, removed)
+	expectRemoval(removed, skynet.RemovedByServiceManager)
+
+	close(instanceWatcher)
+	expectRemoval(missing, skynet.RemovedMissing)
+}
+
+func TestRewatchRestartsReconcileSchedule(t *testing.T) {
+	defer resetClient()
+
+	setPool(&test.Pool{})
+	watchRetryDelay = 40 * time.Millisecond
+	defer func() { watchRetryDelay = time.Second }()
+
+	// jittered to between 45ms and 75ms
+	reconcileInterval = func() time.Duration {
+		return 60 * time.Millisecond
+	}
+	defer restoreReconcileInterval()
+
+	a := *serviceInfo()
+	a.UUID = config.NewUUID()
+
+	watchCalls := 0
+	watches := make(chan time.Time, 2)
+	listed := make(chan time.Time, 10)
+
+	skynet.SetServiceManager(serviceManager)
+	serviceManager.WatchFunc = func(criteria skynet.CriteriaMatcher, ch chan<- skynet.InstanceNotification) []skynet.ServiceInfo {
+		// only the first watch is closed
+		if watchCalls++; watchCalls == 1 {
+			close(ch)
+		}
+
+		watches <- time.Now()
+		return []skynet.ServiceInfo{a}
+	}
+	serviceManager.ListInstancesFunc = func(c skynet.CriteriaMatcher) ([]skynet.ServiceInfo, error) {
+		listed <- time.Now()
+		return []skynet.ServiceInfo{a}, nil
+	}
+	defer func() {
+		serviceManager.WatchFunc = nil
+		serviceManager.ListInstancesFunc = nil
+	}()
+
+	addServiceClient(&test.ServiceClient{})
+	<-watches
+
+	var rewatchedAt time.Time
+	select {
+	case rewatchedAt = <-watches:
+	case <-time.After(time.Second):
+		t.Fatal("ServiceManager was not watched again after watch was closed")
+	}
+
+	select {
+	case listedAt := <-listed:
+		if listedAt.Sub(rewatchedAt) < 45*time.Millisecond {
+			t.Fatal("Reconcile scheduled before the watch was restarted should be ignored", listedAt.Sub(rewatchedAt))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Reconcile should be scheduled again once the watch is restarted")
+	}
+}
+
+func TestUnknownNotificationTypeIgnored(t *testing.T) {
+	defer resetClient()
+
+	setPool(&test.Pool{})
+	addServiceClient(&test.ServiceClient{})
+
+	a, b := *serviceInfo(), *serviceInfo()
+	a.UUID, b.UUID = config.NewUUID(), config.NewUUID()
+
+	sendInstanceNotification(0, a)
+	sendInstanceNotification(skynet.InstanceAdded, b)
+
+	criteria := &skynet.Criteria{Instances: []string{a.UUID, b.UUID}}
+
+	waitFor(t, func() bool {
+		return containsInstance(cachedInstances(criteria), b.UUID)
+	})
+
+	if containsInstance(cachedInstances(criteria), a.UUID) {
+		t.Fatal("Notification with unknown type should be ignored")
+	}
+}
+
+func TestMalformedInstancesIgnored(t *testing.T) {
+	defer resetClient()
+
+	setPool(&test.Pool{})
+
+	initial, noUUID, noAddr, noName, valid := *serviceInfo(), *serviceInfo(), *serviceInfo(), *serviceInfo(), *serviceInfo()
+	initial.UUID, noAddr.UUID, noName.UUID, valid.UUID = config.NewUUID(), config.NewUUID(), config.NewUUID(), config.NewUUID()
+	noUUID.UUID = ""
+	noAddr.ServiceAddr = skynet.BindAddr{}
+	noName.Name = ""
+	initial.ServiceAddr.Port = 0
+
+	skynet.SetServiceManager(serviceManager)
+	serviceManager.WatchFunc = func(criteria skynet.CriteriaMatcher, c chan<- skynet.InstanceNotification) []skynet.ServiceInfo {
+		return []skynet.ServiceInfo{initial}
+	}
+	defer func() { serviceManager.WatchFunc = nil }()
+
+	before := DiscoveryErrors()
+	addServiceClient(&test.ServiceClient{})
+
+	sendInstanceNotification(skynet.InstanceAdded, noUUID)
+	sendInstanceNotification(skynet.InstanceAdded, noAddr)
+	sendInstanceNotification(skynet.InstanceAdded, noName)
+	sendInstanceNotification(skynet.InstanceAdded, valid)
+
+	criteria := &skynet.Criteria{Instances: []string{initial.UUID, noAddr.UUID, noName.UUID, valid.UUID}}
+
+	waitFor(t, func() bool {
+		return containsInstance(cachedInstances(criteria), valid.UUID)
+	})
+
+	if len(cachedInstances(criteria)) != 1 || containsInstance(cachedInstances(&skynet.Criteria{}), "") {
+		t.Fatal("Malformed instances should not be cached", cachedInstances(criteria))
+	}
+
+	if DiscoveryErrors()-before != 4 {
+		t.Fatal("Each malformed instance should be counted as a discovery error", DiscoveryErrors()-before)
+	}
+}
+
+func TestDiscoveryScriptedThroughFakeServiceManager(t *testing.T) {
+	defer resetClient()
+	defer skynet.SetServiceManager(serviceManager)
+
+	setPool(&test.Pool{})
+
+	initial, discovered := *serviceInfo(), *serviceInfo()
+	initial.UUID, discovered.UUID = config.NewUUID(), config.NewUUID()
+
+	sm := test.NewFakeServiceManager(initial)
+	skynet.SetServiceManager(sm)
+
+	sc := GetService("TestService", "", "", "")
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 1
+	})
+
+	sm.Add(discovered)
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 2
+	})
+
+	sm.Unregister(initial.UUID)
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 1
+	})
+
+	sm.Remove(discovered)
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 0
+	})
+
+	sm.Register(initial.UUID)
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 1
+	})
+}
+
+func containsInstance(instances []skynet.ServiceInfo, uuid string) bool {
+	for _, i := range instances {
+		if i.UUID == uuid {
+			return true
+		}
+	}
+
+	return false
+}
+
 func serviceInfo() *skynet.ServiceInfo {
 	si := skynet.NewServiceInfo("TestService", "1.0.0")
 	si.Registered = true
@@ -197,13 +777,33 @@ func serviceInfo() *skynet.ServiceInfo {
 }
 
 func resetClient() {
-	serviceClients = []ServiceClientProvider{}
+	done := make(chan bool)
+	resetChan <- done
+	<-done
 
 	network = "tcp"
 	knownNetworks = []string{"tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "ip", "ip4", "ip6", "unix", "unixgram", "unixpacket"}
 
-	pool = NewPool()
+	setPool(NewPool())
 	LoadBalancerFactory = roundrobin.New
+	retryAfter = time.After
+	metrics = noopMetrics{}
+	interceptors = nil
+	responseValidator = nil
+	tracer = noopTracer{}
+	SetLogger(nil)
+	idGenerator = config.NewUUID
+}
+
+// resetClient() first so mux() has stopped scheduling passes before the interval is swapped back
+func restoreReconcileInterval() {
+	resetClient()
+	reconcileInterval = noReconcile
+}
+
+func restoreStartupJitter() {
+	resetClient()
+	startupJitter = getStartupJitter
 }
 
 func sendInstanceNotification(typ int, si skynet.ServiceInfo) {
@@ -222,3 +822,37 @@ func receiveOrTimeout(watchChan chan interface{}, respChan chan interface{}, d t
 		return
 	}
 }
+
+func TestDiscoveryAcrossNamespaces(t *testing.T) {
+	defer resetClient()
+	defer skynet.SetServiceManager(serviceManager)
+
+	setPool(&test.Pool{})
+
+	east, west := *serviceInfo(), *serviceInfo()
+	east.UUID, west.UUID = config.NewUUID(), config.NewUUID()
+
+	eastSM, westSM := test.NewFakeServiceManager(east), test.NewFakeServiceManager()
+	skynet.SetServiceManager(skynet.NewNamespacedServiceManager("east", map[string]skynet.ServiceManager{
+		"east": eastSM,
+		"west": westSM,
+	}))
+
+	sc := GetService("TestService", "", "", "")
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 1
+	})
+
+	westSM.Add(west)
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 2
+	})
+
+	for _, i := range cachedInstances(&skynet.Criteria{}) {
+		if (i.UUID == east.UUID && i.Namespace != "east") || (i.UUID == west.UUID && i.Namespace != "west") {
+			t.Fatal("Instances should be tagged with the namespace they were discovered in", i.UUID, i.Namespace)
+		}
+	}
+}
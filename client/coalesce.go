@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/skynetservices/skynet"
+	"reflect"
+	"time"
+)
+
+// requests are only coalesced with others decoding into the same type
+type coalesceKey struct {
+	fn, key string
+	out     reflect.Type
+}
+
+// a request shared by concurrent requests with the same coalesceKey
+type coalescedCall struct {
+	out    interface{}
+	result skynet.SendResult
+	err    error
+
+	// closed once the request completes
+	done chan struct{}
+}
+
+// message sent to mux() to join the in-flight request for key, starting one if there isn't
+type coalesceQuery struct {
+	key  coalesceKey
+	resp chan coalesceResult
+}
+
+type coalesceResult struct {
+	call *coalescedCall
+
+	// true for the request that must send it
+	first bool
+}
+
+// message sent to mux() once a coalesced request has completed
+type coalesceDone struct {
+	key  coalesceKey
+	call *coalescedCall
+}
+
+/*
+client.CoalesceKey() returns a RequestInfo.CoalesceKey from a hash of fn and in, for coalescing requests that are identical.
+It's empty, so the request isn't coalesced, if in can't be encoded
+*/
+func CoalesceKey(fn string, in interface{}) string {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return ""
+	}
+
+	h := sha1.New()
+	h.Write([]byte(fn))
+	h.Write([]byte{0})
+	h.Write(b)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+/*
+Requests with a CoalesceKey join one already in flight to the same method with the same key rather than sending their own,
+its response is copied into each of their outs, sharing any slices, maps or pointers it contains. The first request's
+RequestInfo and timeouts are used, and it's sent even if that request is cancelled, so the others aren't cancelled with it
+*/
+func (c *ServiceClient) sendCoalesced(ctx context.Context, retry, giveup time.Duration, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (result skynet.SendResult, err error) {
+	if err = checkOut(fn, out); err != nil {
+		return
+	}
+
+	key := coalesceKey{fn: fn, key: ri.CoalesceKey, out: reflect.TypeOf(out)}
+
+	resp := make(chan coalesceResult, 1)
+	if !c.sendMux(coalesceQuery{key: key, resp: resp}) {
+		return result, ServiceClientClosed
+	}
+
+	r := <-resp
+	call := r.call
+
+	if r.first {
+		sent := *ri
+		sent.CoalesceKey = ""
+
+		go func() {
+			call.result, call.err = c.sendDetailed(context.Background(), retry, giveup, &sent, fn, in, call.out)
+
+			c.sendMux(coalesceDone{key: key, call: call})
+			close(call.done)
+		}()
+	}
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return result, ctx.Err()
+	}
+
+	if call.err == nil {
+		copyOut(out, call.out)
+	}
+
+	return call.result, call.err
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) joinCoalesced(key coalesceKey) coalesceResult {
+	if call, ok := c.coalesced[key]; ok {
+		return coalesceResult{call: call}
+	}
+
+	call := &coalescedCall{
+		out:  reflect.New(key.out.Elem()).Interface(),
+		done: make(chan struct{}),
+	}
+	c.coalesced[key] = call
+
+	return coalesceResult{call: call, first: true}
+}
+
+// this should only be called by mux(), requests made once it's complete send a request of their own
+func (c *ServiceClient) removeCoalesced(m coalesceDone) {
+	if c.coalesced[m.key] == m.call {
+		delete(c.coalesced, m.key)
+	}
+}
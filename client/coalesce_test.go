@@ -0,0 +1,67 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrentRequestsCoalesced(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+
+	var sent int32
+	release := make(chan bool)
+
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		atomic.AddInt32(&sent, 1)
+		<-release
+
+		*out.(*string) = "value of " + in.(string)
+		return
+	})
+
+	key := CoalesceKey("Get", "popular")
+
+	var started, wait sync.WaitGroup
+	results := make([]string, 20)
+	errs := make([]error, 20)
+
+	for i := range results {
+		started.Add(1)
+		wait.Add(1)
+
+		go func(i int) {
+			defer wait.Done()
+
+			started.Done()
+			errs[i] = sc.Send(&skynet.RequestInfo{CoalesceKey: key}, "Get", "popular", &results[i])
+		}(i)
+	}
+
+	// give the requests time to join the one in flight before it's answered
+	started.Wait()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	wait.Wait()
+
+	if n := atomic.LoadInt32(&sent); n != 1 {
+		t.Fatal("Concurrent requests with the same CoalesceKey should share a single request", n)
+	}
+
+	for i := range results {
+		if errs[i] != nil || results[i] != "value of popular" {
+			t.Fatal("Each coalesced request should receive the shared response", i, errs[i], results[i])
+		}
+	}
+
+	// once it's complete later requests are sent on their own
+	var val string
+	if err := sc.Send(&skynet.RequestInfo{CoalesceKey: key}, "Get", "popular", &val); err != nil || atomic.LoadInt32(&sent) != 2 {
+		t.Fatal("Requests made once the coalesced request completed should be sent", err, atomic.LoadInt32(&sent))
+	}
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"github.com/skynetservices/skynet/config"
+)
+
+/*
+this should only be called before the first attempt of a request
+Waits for a slot for the attempt if client.concurrency.wait is set, otherwise returns ConcurrencyLimitReached
+when client.concurrency.max attempts are already in flight
+*/
+func (c *ServiceClient) acquireSlot(ctx context.Context) error {
+	if c.slots == nil {
+		return nil
+	}
+
+	if !c.waitForSlot {
+		if c.tryAcquireSlot() {
+			return nil
+		}
+
+		return ConcurrencyLimitReached
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Retries and hedges are skipped rather than queued when the limit is reached, as an attempt is already in flight
+func (c *ServiceClient) tryAcquireSlot() bool {
+	if c.slots == nil {
+		return true
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *ServiceClient) releaseSlot() {
+	if c.slots != nil {
+		<-c.slots
+	}
+}
+
+// 0 or less leaves the number of attempts in flight unlimited
+func newSlots(max int) chan struct{} {
+	if max <= 0 {
+		return nil
+	}
+
+	return make(chan struct{}, max)
+}
+
+func getMaxConcurrentRequests(service, version string) int {
+	if n, err := config.Int(service, version, "client.concurrency.max"); err == nil {
+		return n
+	}
+
+	return config.DefaultMaxConcurrentRequests
+}
+
+func getConcurrencyWait(service, version string) bool {
+	if b, err := config.Bool(service, version, "client.concurrency.wait"); err == nil {
+		return b
+	}
+
+	return config.DefaultConcurrencyWait
+}
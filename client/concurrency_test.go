@@ -0,0 +1,114 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"testing"
+	"time"
+)
+
+func TestConcurrentRequestLimitFailsFast(t *testing.T) {
+	defer resetClient()
+
+	sc := limitedServiceClient(2, false)
+
+	entered := make(chan bool, 2)
+	blocked := make(chan bool)
+
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		entered <- true
+		<-blocked
+		return
+	})
+
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			var val string
+			done <- sc.Send(nil, "Foo", val, &val)
+		}()
+	}
+
+	<-entered
+	<-entered
+
+	var val string
+	if err := sc.Send(nil, "Foo", val, &val); err != ConcurrencyLimitReached {
+		t.Fatal("Send() should fail once the concurrent request limit is reached", err)
+	}
+
+	close(blocked)
+
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitFor(t, func() bool {
+		return len(sc.slots) == 0
+	})
+}
+
+func TestConcurrentRequestLimitWaits(t *testing.T) {
+	defer resetClient()
+
+	sc := limitedServiceClient(2, true)
+
+	entered := make(chan bool, 3)
+	blocked := make(chan bool)
+
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		entered <- true
+		<-blocked
+		return
+	})
+
+	done := make(chan error, 3)
+	send := func() {
+		var val string
+		done <- sc.Send(nil, "Foo", val, &val)
+	}
+
+	go send()
+	go send()
+	<-entered
+	<-entered
+
+	var val string
+	if err := sc.SendTimeout(0, 10*time.Millisecond, nil, "Foo", val, &val); err != RequestTimeout {
+		t.Fatal("Send() should time out while waiting for a slot", err)
+	}
+
+	go send()
+
+	select {
+	case err := <-done:
+		t.Fatal("Send() should wait for a slot once the concurrent request limit is reached", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(blocked)
+
+	for i := 0; i < 3; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitFor(t, func() bool {
+		return len(sc.slots) == 0
+	})
+}
+
+// the limit is set before the ServiceClient is shared, as it is when read from config
+func limitedServiceClient(max int, wait bool) *ServiceClient {
+	sc := NewServiceClient(&skynet.Criteria{
+		Services: []skynet.ServiceCriteria{skynet.ServiceCriteria{Name: "TestService"}},
+	}).(*ServiceClient)
+
+	sc.SetDefaultTimeout(0, 0)
+	sc.slots = newSlots(max)
+	sc.waitForSlot = wait
+
+	return sc
+}
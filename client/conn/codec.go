@@ -0,0 +1,121 @@
+package conn
+
+import (
+	"encoding/json"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/rpc/bsonrpc"
+	"io"
+	"labix.org/v2/mgo/bson"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+)
+
+/*
+Codec determines how requests are encoded once the handshake with the service is complete, the handshake itself
+is always BSON so that client and service can agree on a codec before using it.
+*/
+type Codec interface {
+	// Name identifies the codec during the handshake
+	Name() string
+
+	// NewClientCodec encodes the request and response envelopes
+	NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec
+
+	// Marshal and Unmarshal encode the In and Out values nested within the envelopes
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// codec assumed for services that don't advertise the codecs they support
+const defaultCodecName = "bson"
+
+/*
+BSONCodec is the default Codec, used by skynet services
+*/
+type BSONCodec struct{}
+
+func (BSONCodec) Name() string {
+	return defaultCodecName
+}
+
+func (BSONCodec) NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return bsonrpc.NewClientCodec(conn)
+}
+
+func (BSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return bson.Marshal(v)
+}
+
+func (BSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return bson.Unmarshal(data, v)
+}
+
+/*
+BSONFuncCodec is BSONCodec with the In and Out values encoded by MarshalFunc and UnmarshalFunc, for types that need
+custom field handling without changing bson's defaults for the whole process. Requests are still BSON so any skynet
+service supports it, either func left nil uses bson's
+*/
+type BSONFuncCodec struct {
+	MarshalFunc   func(v interface{}) ([]byte, error)
+	UnmarshalFunc func(data []byte, v interface{}) error
+}
+
+func (BSONFuncCodec) Name() string {
+	return defaultCodecName
+}
+
+func (BSONFuncCodec) NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return bsonrpc.NewClientCodec(conn)
+}
+
+func (c BSONFuncCodec) Marshal(v interface{}) ([]byte, error) {
+	if c.MarshalFunc != nil {
+		return c.MarshalFunc(v)
+	}
+
+	return bson.Marshal(v)
+}
+
+func (c BSONFuncCodec) Unmarshal(data []byte, v interface{}) error {
+	if c.UnmarshalFunc != nil {
+		return c.UnmarshalFunc(data, v)
+	}
+
+	return bson.Unmarshal(data, v)
+}
+
+/*
+JSONCodec encodes requests as JSON-RPC, useful for debugging against services that support it
+*/
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string {
+	return "json"
+}
+
+func (JSONCodec) NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return jsonrpc.NewClientCodec(conn)
+}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// services that don't list their codecs only support bson
+func supportsCodec(sh *skynet.ServiceHandshake, name string) bool {
+	if len(sh.Codecs) == 0 {
+		return name == defaultCodecName
+	}
+
+	for _, c := range sh.Codecs {
+		if c == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,26 +1,34 @@
 package conn
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/kr/pretty"
 	"github.com/skynetservices/skynet"
 	"github.com/skynetservices/skynet/log"
 	"github.com/skynetservices/skynet/rpc/bsonrpc"
+	"github.com/skynetservices/skynet/rpc/compress"
+	"io"
 	"labix.org/v2/mgo/bson"
 	"net"
 	"net/rpc"
 	"reflect"
+	"strings"
+	"sync"
 	"time"
 )
 
-// TODO: Abstract out BSON logic into an interface that can be proviced for Encoding/Decoding data to a supplied interface
-// this would allow developers to swap out the RPC logic, maybe implement our own ClientCodec/ServerCodec that have an additional WriteHandshake/ReadHandshake methods on each of them.
-// bson for example we could create a custom type that is composed of our methods and the normal rpc codec
 var (
 	HandshakeFailed     = errors.New("Handshake Failed")
 	ServiceUnregistered = errors.New("Service is unregistered")
 	ConnectionClosed    = errors.New("Connection is closed")
+	TLSRequired         = errors.New("Service requires TLS")
+	HandshakeTimedOut   = errors.New("Handshake timed out")
+	DialTimedOut        = errors.New("Dial timed out")
+	CodecUnsupported    = errors.New("Service does not support codec")
+	StreamsUnsupported  = errors.New("Service does not support streaming requests")
+	ResponseTooLarge    = errors.New("Response is larger than the maximum allowed")
 )
 
 type serviceError struct {
@@ -31,19 +39,135 @@ func (se serviceError) Error() string {
 	return se.msg
 }
 
+/*
+RemoteError is returned when the service's method returns an error, Code is set if the error has an ErrorCode(),
+see skynet.ErrorCoder. The connection remains usable
+*/
+type RemoteError struct {
+	Code    string
+	Message string
+}
+
+func (re RemoteError) Error() string {
+	return re.Message
+}
+
+/*
+TransportError is returned when a request fails because of the underlying connection rather than the service,
+the connection is closed and the request can be sent again over another connection
+*/
+type TransportError struct {
+	Err error
+}
+
+func (te TransportError) Error() string {
+	return "Connection: " + te.Err.Error()
+}
+
+/*
+conn.IsTransportError() determines if err was caused by the connection to the service failing
+*/
+func IsTransportError(err error) bool {
+	if err == ConnectionClosed {
+		return true
+	}
+
+	_, ok := err.(TransportError)
+	return ok
+}
+
+/*
+RequestEncodeError is returned when a request's In can't be encoded with the negotiated codec, the request isn't sent
+*/
+type RequestEncodeError struct {
+	Method string
+	Codec  string
+	Err    error
+}
+
+func (e RequestEncodeError) Error() string {
+	return fmt.Sprintf("Error encoding %s request with %s: %v", e.Method, e.Codec, e.Err)
+}
+
+/*
+ResponseDecodeError is returned when a response can't be decoded into out, usually because out doesn't match the type
+returned by the service. Length is the size in bytes of the encoded response
+*/
+type ResponseDecodeError struct {
+	Method string
+	Codec  string
+	Length int
+	Err    error
+}
+
+func (e ResponseDecodeError) Error() string {
+	return fmt.Sprintf("Error decoding %d byte %s response with %s: %v", e.Length, e.Method, e.Codec, e.Err)
+}
+
+/*
+conn.IsCodecError() determines if err was caused by encoding the request or decoding its response, sending the request
+again would fail the same way
+*/
+func IsCodecError(err error) bool {
+	switch err.(type) {
+	case RequestEncodeError, ResponseDecodeError:
+		return true
+	}
+
+	return err == ResponseTooLarge
+}
+
+// net/rpc only passes on the text of errors reading a response's body
+func isResponseTooLarge(err error) bool {
+	return err == bsonrpc.DocumentTooLarge || strings.HasSuffix(err.Error(), bsonrpc.DocumentTooLarge.Error())
+}
+
+/*
+conn.IsServiceError() determines if err was returned by the service for the request, the connection remains usable
+*/
+func IsServiceError(err error) bool {
+	switch err.(type) {
+	case serviceError, RemoteError:
+		return true
+	}
+
+	return false
+}
+
+// errors returned from rpc.Client that indicate the connection itself is broken
+func isTransportFailure(err error) bool {
+	switch err.(type) {
+	case net.Error:
+		return true
+	}
+
+	return err == io.EOF || err == io.ErrUnexpectedEOF || err == rpc.ErrShutdown
+}
+
+// subset of rpc.Client used by Conn
+type rpcClient interface {
+	Call(serviceMethod string, args interface{}, reply interface{}) error
+	Go(serviceMethod string, args interface{}, reply interface{}, done chan *rpc.Call) *rpc.Call
+	Close() error
+}
+
 /*
 Connection
 */
 
 type Connection interface {
 	SetIdleTimeout(timeout time.Duration)
+	SetHealthCheck(staleAfter time.Duration, pingMethod string)
 	Addr() string
+	ServiceName() string
 
 	Close()
 	IsClosed() bool
+	IsHealthy() bool
 
 	Send(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)
 	SendTimeout(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error)
+	SendOneway(ri *skynet.RequestInfo, fn string, in interface{}) (err error)
 }
 
 /*
@@ -51,44 +175,226 @@ Conn
 Implementation of Connection
 */
 type Conn struct {
-	addr           string
-	conn           net.Conn
-	clientID       string
-	serviceName    string
-	rpcClient      *rpc.Client
-	rpcClientCodec *bsonrpc.ClientCodec
-	closed         bool
+	addr        string
+	conn        net.Conn
+	clientID    string
+	serviceName string
+	rpcClient   rpcClient
+	codec       Codec
+
+	// compressors are in order of preference, compressor is the one agreed with the service
+	compressors []compress.Compressor
+	compressor  compress.Compressor
+
+	// the connection carries a single streaming request, see NewStreamWithOptions()
+	stream bool
+
+	// the connection carries concurrent requests, see Options.Multiplex
+	multiplex bool
+
+	// sent to the service in the ClientHandshake, and what it sent us in the ServiceHandshake
+	identity  Identity
+	handshake skynet.ServiceHandshake
+
+	// responses larger than this are refused without being read, 0 is unlimited
+	maxResponseBytes int
+
+	// requests may be sent concurrently over a Conn
+	stateMutex sync.Mutex
+	closed     bool
+	lastUsed   time.Time
 
 	idleTimeout time.Duration
+	staleAfter  time.Duration
+	pingMethod  string
+}
+
+/*
+DialFunc connects to the service at addr, ex: through a proxy or over an in memory transport
+*/
+type DialFunc func(network, addr string) (net.Conn, error)
+
+/*
+Options for establishing a Connection
+*/
+type Options struct {
+	// TLSConfig if provided the connection will be made over TLS, and the handshake performed over the encrypted connection
+	TLSConfig *tls.Config
+
+	// Codec used for requests once the handshake completes, defaults to BSONCodec
+	Codec Codec
+
+	// Compressors in order of preference, payloads are compressed with the first the service supports. If
+	// the service supports none of them, or none are provided, payloads aren't compressed
+	Compressors []compress.Compressor
+
+	// HandshakeTimeout bounds the whole handshake, including the TLS handshake, 0 waits indefinitely
+	HandshakeTimeout time.Duration
+
+	// Dial if provided is used to connect instead of net.DialTimeout, it's responsible for its own timeout
+	Dial DialFunc
+
+	// Identity is sent to the service in the ClientHandshake
+	Identity Identity
+
+	// MaxResponseBytes is the largest response accepted, larger responses return ResponseTooLarge and close the
+	// connection. Only enforced with BSONCodec, 0 is unlimited
+	MaxResponseBytes int
+
+	// Multiplex lets the connection carry concurrent requests, responses are matched to their requests as they arrive.
+	// A request that times out or can't be decoded then fails alone, rather than closing the connection
+	Multiplex bool
+}
+
+/*
+Identity of the client, sent to services during the handshake
+*/
+type Identity struct {
+	Name     string
+	Version  string
+	Metadata map[string]string
+}
+
+// DialFuncs aren't given the timeout, so one that hangs is abandoned once it passes, closing the connection should it succeed later
+func dialWithin(dial DialFunc, network, addr string, timeout time.Duration) (net.Conn, error) {
+	if timeout <= 0 {
+		return dial(network, addr)
+	}
+
+	type dialed struct {
+		c   net.Conn
+		err error
+	}
+
+	done := make(chan dialed)
+	abandoned := make(chan struct{})
+
+	go func() {
+		c, err := dial(network, addr)
+
+		select {
+		case done <- dialed{c, err}:
+		case <-abandoned:
+			if c != nil {
+				c.Close()
+			}
+		}
+	}()
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+
+	select {
+	case d := <-done:
+		return d.c, d.err
+	case <-t.C:
+		close(abandoned)
+		return nil, DialTimedOut
+	}
 }
 
 /*
 client.NewConnection() Establishes new connection to skynet service specified by addr
 */
 func NewConnection(serviceName, network, addr string, timeout time.Duration) (conn Connection, err error) {
-	c, err := net.DialTimeout(network, addr, timeout)
+	return NewConnectionWithOptions(serviceName, network, addr, timeout, Options{})
+}
 
-	if err != nil {
+/*
+client.NewConnectionWithOptions() Establishes new connection to skynet service specified by addr using the supplied Options
+*/
+func NewConnectionWithOptions(serviceName, network, addr string, timeout time.Duration, opts Options) (conn Connection, err error) {
+	var c net.Conn
+	if c, opts, err = dialConn(network, addr, timeout, opts); err != nil {
 		return
 	}
 
-	conn, err = NewConnectionFromNetConn(serviceName, c)
+	conn, err = NewConnectionFromNetConnWithOptions(serviceName, c, opts)
 
 	return
 }
 
+func dialConn(network, addr string, timeout time.Duration, opts Options) (c net.Conn, _ Options, err error) {
+	if opts.Dial != nil {
+		c, err = dialWithin(opts.Dial, network, addr, timeout)
+	} else {
+		c, err = net.DialTimeout(network, addr, timeout)
+	}
+
+	if err != nil {
+		return c, opts, err
+	}
+
+	// connections dialed by a DialFunc may not have TCP keepalives enabled, without them a service that goes away
+	// without closing the connection isn't noticed
+	if tc, ok := c.(*net.TCPConn); ok {
+		tc.SetKeepAlive(true)
+	}
+
+	// verify the service against the host we dialed, as tls.Dial would
+	if opts.TLSConfig != nil && opts.TLSConfig.ServerName == "" {
+		if host, _, splitErr := net.SplitHostPort(addr); splitErr == nil {
+			opts.TLSConfig = opts.TLSConfig.Clone()
+			opts.TLSConfig.ServerName = host
+		}
+	}
+
+	return c, opts, nil
+}
+
 /*
 client.NewConn() Establishes new connection to skynet service with existing net.Conn
 This is beneficial if you want to communicate over a pipe
 */
 func NewConnectionFromNetConn(serviceName string, c net.Conn) (conn Connection, err error) {
-	cn := &Conn{conn: c}
+	return NewConnectionFromNetConnWithOptions(serviceName, c, Options{})
+}
+
+/*
+client.NewConnectionFromNetConnWithOptions() Establishes new connection to skynet service with existing net.Conn
+using the supplied Options, c is wrapped in TLS if TLSConfig is provided and it isn't a tls.Conn already
+*/
+func NewConnectionFromNetConnWithOptions(serviceName string, c net.Conn, opts Options) (conn Connection, err error) {
+	return newConn(serviceName, c, opts, false)
+}
+
+func newConn(serviceName string, c net.Conn, opts Options, stream bool) (cn *Conn, err error) {
+	cn = &Conn{conn: c, lastUsed: time.Now(), stream: stream}
 	cn.addr = c.RemoteAddr().String()
 	cn.serviceName = serviceName
 
-	cn.rpcClientCodec = bsonrpc.NewClientCodec(cn.conn)
+	cn.codec = opts.Codec
+	if cn.codec == nil {
+		cn.codec = BSONCodec{}
+	}
+
+	cn.compressors = opts.Compressors
+	cn.identity = opts.Identity
+	cn.maxResponseBytes = opts.MaxResponseBytes
+	cn.multiplex = opts.Multiplex
 
-	err = cn.performHandshake()
+	// a service that accepts the connection but never sends its handshake mustn't block us
+	var deadline time.Time
+	if opts.HandshakeTimeout > 0 {
+		deadline = time.Now().Add(opts.HandshakeTimeout)
+		c.SetDeadline(deadline)
+	}
+
+	if opts.TLSConfig != nil {
+		err = cn.startTLS(opts.TLSConfig)
+	}
+
+	if err == nil {
+		err = cn.performHandshake()
+	}
+
+	switch {
+	case deadline.IsZero():
+	case err == nil:
+		cn.conn.SetDeadline(time.Time{})
+	case err == HandshakeFailed && !time.Now().Before(deadline):
+		err = HandshakeTimedOut
+	}
 
 	return cn, err
 }
@@ -97,8 +403,21 @@ func NewConnectionFromNetConn(serviceName string, c net.Conn) (conn Connection,
 Conn.Close() Close network connection
 */
 func (c *Conn) Close() {
+	c.stateMutex.Lock()
+	closed := c.closed
 	c.closed = true
-	c.rpcClient.Close()
+	c.stateMutex.Unlock()
+
+	if closed {
+		return
+	}
+
+	// rpcClient isn't created until the handshake completes
+	if c.rpcClient != nil {
+		c.rpcClient.Close()
+	} else {
+		c.conn.Close()
+	}
 }
 
 /*
@@ -108,20 +427,107 @@ func (c *Conn) SetIdleTimeout(timeout time.Duration) {
 	c.idleTimeout = timeout
 }
 
+/*
+Conn.SetHealthCheck() connections that haven't been used for staleAfter are checked with a request to pingMethod
+before they're reused, if pingMethod is empty stale connections are considered unhealthy. A staleAfter of 0 disables
+the check
+*/
+func (c *Conn) SetHealthCheck(staleAfter time.Duration, pingMethod string) {
+	c.staleAfter = staleAfter
+	c.pingMethod = pingMethod
+}
+
+/*
+Conn.IsHealthy() Specifies if connection can be used, connections that have gone stale are pinged to ensure
+the service is still reachable over them
+*/
+func (c *Conn) IsHealthy() bool {
+	if c.IsClosed() {
+		return false
+	}
+
+	c.stateMutex.Lock()
+	lastUsed := c.lastUsed
+	c.stateMutex.Unlock()
+
+	if c.staleAfter == 0 || time.Since(lastUsed) < c.staleAfter {
+		return true
+	}
+
+	if c.pingMethod == "" {
+		return false
+	}
+
+	return c.ping(c.staleAfter)
+}
+
+/*
+Conn.KeepAlive() checks an idle connection with a request to the ping method, so that a connection the service
+is no longer on the other end of is found before a request is sent over it. Without a ping method the request is for
+an empty method, which the service will answer with an error
+*/
+func (c *Conn) KeepAlive(timeout time.Duration) bool {
+	if c.IsClosed() {
+		return false
+	}
+
+	return c.ping(timeout)
+}
+
+// Any response from the service, even an error, shows the connection is still good
+func (c *Conn) ping(timeout time.Duration) bool {
+	var out struct{}
+	err := c.SendTimeout(&skynet.RequestInfo{}, c.pingMethod, struct{}{}, &out, timeout)
+
+	return !IsTransportError(err) && !c.IsClosed()
+}
+
 /*
 Conn.IsClosed() Specifies if connection is closed
 */
-func (c Conn) IsClosed() bool {
+func (c *Conn) IsClosed() bool {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
 	return c.closed
 }
 
 /*
 Conn.Addr() Specifies the network address
 */
-func (c Conn) Addr() string {
+func (c *Conn) Addr() string {
 	return c.addr
 }
 
+/*
+Conn.ServiceName() Specifies the name of the service the connection was made to
+*/
+func (c *Conn) ServiceName() string {
+	return c.serviceName
+}
+
+/*
+Conn.ServiceHandshake() returns the handshake the service sent as the connection was made, describing what it supports
+*/
+func (c *Conn) ServiceHandshake() skynet.ServiceHandshake {
+	return c.handshake
+}
+
+/*
+Conn.Multiplexed() returns true if the connection carries concurrent requests, see Options.Multiplex
+*/
+func (c *Conn) Multiplexed() bool {
+	return c.multiplex
+}
+
+// requests that fail without breaking the connection close it so it isn't reused with a response outstanding, other
+// requests on a multiplexed connection are matched to their own responses, so it's left open for them
+func (c *Conn) closeAfterFailure() {
+	if !c.multiplex {
+		c.Close()
+	}
+}
+
 /*
 Conn.Send() Sends RPC request to service
 */
@@ -137,21 +543,9 @@ func (c *Conn) SendTimeout(ri *skynet.RequestInfo, fn string, in interface{}, ou
 		return ConnectionClosed
 	}
 
-	sin := skynet.ServiceRPCInWrite{
-		RequestInfo: ri,
-		Method:      fn,
-		ClientID:    c.clientID,
-	}
-
-	var b []byte
-	b, err = bson.Marshal(in)
+	sin, err := c.forwardRequest(ri, fn, in)
 	if err != nil {
-		return serviceError{fmt.Sprintf("Error calling bson.Marshal: %v", err)}
-	}
-
-	sin.In = bson.Binary{
-		0x00,
-		b,
+		return
 	}
 
 	type Resp struct {
@@ -159,7 +553,8 @@ func (c *Conn) SendTimeout(ri *skynet.RequestInfo, fn string, in interface{}, ou
 		Err error
 	}
 
-	respChan := make(chan *Resp)
+	// buffered so the call can finish once we've stopped waiting for it
+	respChan := make(chan *Resp, 1)
 
 	go func() {
 		log.Println(log.TRACE, fmt.Sprintf("Sending Method call %s with ClientID %s to: %s", sin.Method, sin.ClientID, c.addr))
@@ -181,27 +576,63 @@ func (c *Conn) SendTimeout(ri *skynet.RequestInfo, fn string, in interface{}, ou
 
 	select {
 	case r = <-respChan:
+		c.stateMutex.Lock()
+		c.lastUsed = time.Now()
+		c.stateMutex.Unlock()
+
 		if r.Err != nil {
-			err = serviceError{r.Err.Error()}
+			// Errors returned by the service leave the connection usable
+			if _, ok := r.Err.(rpc.ServerError); ok {
+				err = serviceError{r.Err.Error()}
+				return
+			}
+
+			switch {
+			case isResponseTooLarge(r.Err):
+				err = ResponseTooLarge
+			case isTransportFailure(r.Err):
+				err = TransportError{Err: r.Err}
+			default:
+				err = serviceError{r.Err.Error()}
+			}
+
 			c.Close()
 			return
 		}
 	case <-t:
 		err = fmt.Errorf("Connection: timing out request after %s", timeout.String())
-		c.Close()
+		c.closeAfterFailure()
 		return
 	}
 
 	if r.Out.ErrString != "" {
-		err = serviceError{r.Out.ErrString}
+		err = RemoteError{Code: r.Out.ErrCode, Message: r.Out.ErrString}
 		return
 	}
 
-	err = bson.Unmarshal(r.Out.Out, out)
-	if err != nil {
-		log.Println(log.ERROR, "Error unmarshalling nested document")
+	b := r.Out.Out
+	if c.compressor != nil {
+		b, err = c.compressor.Decompress(b)
+
+		// compressed responses are only checked once they're decompressed
+		if err == nil && c.maxResponseBytes > 0 && len(b) > c.maxResponseBytes {
+			log.Println(log.ERROR, fmt.Sprintf("Decompressed %s response of %d bytes is larger than the maximum allowed", fn, len(b)))
+			c.closeAfterFailure()
+			return ResponseTooLarge
+		}
+	}
+
+	if err == nil {
+		if err = c.codec.Unmarshal(b, out); err != nil {
+			err = ResponseDecodeError{Method: fn, Codec: c.codec.Name(), Length: len(b), Err: err}
+		}
+	} else {
 		err = serviceError{err.Error()}
-		c.Close()
+	}
+
+	if err != nil {
+		log.Println(log.ERROR, "Error unmarshalling nested document", err)
+		c.closeAfterFailure()
 	}
 
 	log.Println(log.TRACE, pretty.Sprintf("Method call %s with ClientID %s from: %s returned: %s %+v", sin.Method, sin.ClientID, c.addr, reflect.TypeOf(out), out))
@@ -209,6 +640,91 @@ func (c *Conn) SendTimeout(ri *skynet.RequestInfo, fn string, in interface{}, ou
 	return
 }
 
+/*
+Conn.SendOneway() sends the request and returns once it's written to the connection, without waiting for a response.
+The response the service sends is discarded when it arrives, so its errors aren't seen
+*/
+func (c *Conn) SendOneway(ri *skynet.RequestInfo, fn string, in interface{}) (err error) {
+	if c.IsClosed() {
+		return ConnectionClosed
+	}
+
+	sin, err := c.forwardRequest(ri, fn, in)
+	if err != nil {
+		return
+	}
+
+	log.Println(log.TRACE, fmt.Sprintf("Sending one way Method call %s with ClientID %s to: %s", sin.Method, sin.ClientID, c.addr))
+
+	// the request has been written once Go() returns, it's only done already if writing it failed or the response was quick
+	call := c.rpcClient.Go(c.serviceName+".Forward", sin, &skynet.ServiceRPCOutRead{}, make(chan *rpc.Call, 1))
+
+	select {
+	case <-call.Done:
+		if call.Error == nil {
+			return
+		}
+
+		if _, ok := call.Error.(rpc.ServerError); ok {
+			return serviceError{call.Error.Error()}
+		}
+
+		err = TransportError{Err: call.Error}
+		c.Close()
+	default:
+	}
+
+	return
+}
+
+// builds the request forwarding fn to the service, encoded with the negotiated codec and compressor
+func (c *Conn) forwardRequest(ri *skynet.RequestInfo, fn string, in interface{}) (sin skynet.ServiceRPCInWrite, err error) {
+	sin = skynet.ServiceRPCInWrite{
+		RequestInfo: ri,
+		Method:      fn,
+		ClientID:    c.clientID,
+	}
+
+	b, err := c.codec.Marshal(in)
+	if err != nil {
+		return sin, RequestEncodeError{Method: fn, Codec: c.codec.Name(), Err: err}
+	}
+
+	if c.compressor != nil {
+		if b, err = c.compressor.Compress(b); err != nil {
+			return sin, serviceError{fmt.Sprintf("Error compressing request with %s: %v", c.compressor.Name(), err)}
+		}
+	}
+
+	sin.In = bson.Binary{
+		0x00,
+		b,
+	}
+
+	return
+}
+
+// wraps the connection in TLS before the ServiceHandshake is read, so the whole handshake is encrypted
+func (c *Conn) startTLS(config *tls.Config) error {
+	if _, ok := c.conn.(*tls.Conn); ok {
+		return nil
+	}
+
+	tlsConn := tls.Client(c.conn, config)
+
+	log.Println(log.TRACE, "Performing TLS handshake")
+	if err := tlsConn.Handshake(); err != nil {
+		log.Println(log.ERROR, "TLS handshake failed", err)
+		c.Close()
+
+		return HandshakeFailed
+	}
+
+	c.conn = tlsConn
+
+	return nil
+}
+
 /*
 Conn.performHandshake Responsible for performing handshake with service
 */
@@ -216,7 +732,8 @@ func (c *Conn) performHandshake() (err error) {
 	var sh skynet.ServiceHandshake
 	log.Println(log.TRACE, "Reading ServiceHandshake")
 
-	err = c.rpcClientCodec.Decoder.Decode(&sh)
+	// The handshake is always bson, the negotiated codec takes over once it completes
+	err = bsonrpc.NewDecoder(c.conn).Decode(&sh)
 	if err != nil {
 		log.Println(log.ERROR, "Failed to decode ServiceHandshake", err)
 		c.Close()
@@ -225,6 +742,7 @@ func (c *Conn) performHandshake() (err error) {
 	}
 
 	c.clientID = sh.ClientID
+	c.handshake = sh
 
 	if sh.Name != c.serviceName {
 		log.Println(log.ERROR, "Attempted to send request to incorrect service: "+sh.Name)
@@ -232,12 +750,45 @@ func (c *Conn) performHandshake() (err error) {
 		return HandshakeFailed
 	}
 
+	if _, ok := c.conn.(*tls.Conn); sh.TLSRequired && !ok {
+		log.Println(log.ERROR, "Attempted to send request without TLS to service that requires it: "+sh.Name)
+		c.Close()
+		return TLSRequired
+	}
+
+	if c.stream && !sh.Streams {
+		log.Println(log.ERROR, "Attempted to send streaming request to service that doesn't support it: "+sh.Name)
+		c.Close()
+		return StreamsUnsupported
+	}
+
+	if !supportsCodec(&sh, c.codec.Name()) {
+		log.Println(log.ERROR, "Attempted to send request with codec the service doesn't support: "+c.codec.Name())
+		c.Close()
+		return CodecUnsupported
+	}
+
+	// Fall back to uncompressed payloads if the service doesn't support any of our compressors
+	if !c.stream {
+		c.compressor = compress.Choose(c.compressors, sh.Compressors)
+	}
+
 	ch := skynet.ClientHandshake{
-		ClientID: c.clientID,
+		ClientID:        c.clientID,
+		Codec:           c.codec.Name(),
+		Stream:          c.stream,
+		ProtocolVersion: skynet.ProtocolVersion,
+		ClientName:      c.identity.Name,
+		ClientVersion:   c.identity.Version,
+		Metadata:        c.identity.Metadata,
+	}
+
+	if c.compressor != nil {
+		ch.Compression = c.compressor.Name()
 	}
 
 	log.Println(log.TRACE, "Writing ClientHandshake")
-	err = c.rpcClientCodec.Encoder.Encode(ch)
+	err = bsonrpc.NewEncoder(c.conn).Encode(ch)
 	if err != nil {
 		log.Println(log.ERROR, "Failed to encode ClientHandshake", err)
 		c.Close()
@@ -245,14 +796,26 @@ func (c *Conn) performHandshake() (err error) {
 		return HandshakeFailed
 	}
 
+	// the connection is of no use to the caller, who will try another instance
 	if !sh.Registered {
 		log.Println(log.ERROR, "Attempted to send request to unregistered service")
+		c.Close()
 		return ServiceUnregistered
 	}
 
+	// the Stream reads the connection itself
+	if c.stream {
+		return
+	}
+
 	log.Println(log.TRACE, "Handing connection RPC layer")
 
-	c.rpcClient = rpc.NewClientWithCodec(c.rpcClientCodec)
+	cc := c.codec.NewClientCodec(c.conn)
+	if bc, ok := cc.(*bsonrpc.ClientCodec); ok {
+		bc.Decoder.MaxBytes = c.maxResponseBytes
+	}
+
+	c.rpcClient = rpc.NewClientWithCodec(cc)
 
 	return
 }
@@ -1,12 +1,27 @@
 package conn
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/skynetservices/skynet"
 	"github.com/skynetservices/skynet/rpc/bsonrpc"
+	"github.com/skynetservices/skynet/rpc/compress"
+	"io"
 	"labix.org/v2/mgo/bson"
+	"math/big"
 	"net"
 	"net/rpc"
+	"net/rpc/jsonrpc"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -15,7 +30,7 @@ import (
 func TestHandshake(t *testing.T) {
 	client, server := net.Pipe()
 
-	go doServiceHandshake(server, true, t)
+	go doServiceHandshake(server, "TestService", true, t)
 
 	cn, err := NewConnectionFromNetConn("TestService", client)
 	c := cn.(*Conn)
@@ -37,7 +52,7 @@ func TestErrorOnUnregistered(t *testing.T) {
 	defer client.Close()
 	defer server.Close()
 
-	go doServiceHandshake(server, false, t)
+	go doServiceHandshake(server, "TestService", false, t)
 
 	_, err := NewConnectionFromNetConn("TestService", client)
 
@@ -57,7 +72,7 @@ func TestDialConn(t *testing.T) {
 	go func() {
 		conn, err := ln.Accept()
 		if err == nil {
-			go doServiceHandshake(conn, true, t)
+			go doServiceHandshake(conn, "TestService", true, t)
 		}
 	}()
 
@@ -91,7 +106,7 @@ func TestSendTimeout(t *testing.T) {
 	go func() {
 		conn, err := ln.Accept()
 		if err == nil {
-			doServiceHandshake(conn, true, t)
+			doServiceHandshake(conn, "TestService", true, t)
 			time.Sleep(10 * time.Millisecond)
 		}
 	}()
@@ -112,7 +127,7 @@ func TestSendTimeout(t *testing.T) {
 
 func TestSend(t *testing.T) {
 	client, server := net.Pipe()
-	go doServiceHandshake(server, true, t)
+	go doServiceHandshake(server, "TestRPCService", true, t)
 
 	cn, err := NewConnectionFromNetConn("TestRPCService", client)
 	c := cn.(*Conn)
@@ -128,8 +143,8 @@ func TestSend(t *testing.T) {
 
 	ri := &skynet.RequestInfo{}
 
-	ts.TestMethod = func(in skynet.ServiceRPCIn, out *skynet.ServiceRPCOut) (err error) {
-		out.Out, err = bson.Marshal(&tp)
+	ts.TestMethod = func(in skynet.ServiceRPCInRead, out *skynet.ServiceRPCOutWrite) (err error) {
+		b, err := bson.Marshal(&tp)
 
 		var t TestParam
 
@@ -137,6 +152,8 @@ func TestSend(t *testing.T) {
 			return
 		}
 
+		out.Out = bson.Binary{0x00, b}
+
 		if in.ClientID != c.clientID {
 			return errors.New("Failed to set ClientID on request")
 		}
@@ -145,7 +162,7 @@ func TestSend(t *testing.T) {
 			return errors.New("Failed to set Method on request")
 		}
 
-		if *in.RequestInfo != *ri {
+		if !reflect.DeepEqual(*in.RequestInfo, *ri) {
 			return errors.New("Failed to set RequestInfo on request")
 		}
 
@@ -173,7 +190,7 @@ func TestSend(t *testing.T) {
 
 func TestSendOnClosedConnection(t *testing.T) {
 	client, server := net.Pipe()
-	go doServiceHandshake(server, true, t)
+	go doServiceHandshake(server, "TestService", true, t)
 
 	c, err := NewConnectionFromNetConn("TestService", client)
 	c.Close()
@@ -191,20 +208,937 @@ func TestSendOnClosedConnection(t *testing.T) {
 	}
 }
 
+func TestSendTransportError(t *testing.T) {
+	c := &Conn{codec: BSONCodec{}, rpcClient: &mockRPCClient{
+		CallFunc: func(serviceMethod string, args interface{}, reply interface{}) error {
+			return io.EOF
+		},
+	}}
+
+	var tp TestParam
+	err := c.Send(&skynet.RequestInfo{}, "foo", tp, &tp)
+
+	if !IsTransportError(err) {
+		t.Fatal("EOF from rpc.Client should be a transport error", err)
+	}
+
+	if !c.IsClosed() {
+		t.Fatal("Connection should be closed after a transport error")
+	}
+}
+
+func TestSendServiceErrorPassesThrough(t *testing.T) {
+	c := &Conn{codec: BSONCodec{}, rpcClient: &mockRPCClient{
+		CallFunc: func(serviceMethod string, args interface{}, reply interface{}) error {
+			return rpc.ServerError("Invalid request")
+		},
+	}}
+
+	var tp TestParam
+	err := c.Send(&skynet.RequestInfo{}, "foo", tp, &tp)
+
+	if err == nil || err.Error() != "Invalid request" {
+		t.Fatal("Service errors should be returned to the caller", err)
+	}
+
+	if IsTransportError(err) {
+		t.Fatal("Service errors should not be transport errors")
+	}
+
+	if c.IsClosed() {
+		t.Fatal("Connection should remain open after a service error")
+	}
+}
+
+func TestSendRequestEncodeError(t *testing.T) {
+	called := false
+
+	c := &Conn{codec: BSONCodec{}, rpcClient: &mockRPCClient{
+		CallFunc: func(serviceMethod string, args interface{}, reply interface{}) error {
+			called = true
+			return nil
+		},
+	}}
+
+	// bson can only encode documents
+	var tp TestParam
+	err := c.Send(&skynet.RequestInfo{}, "foo", 1, &tp)
+
+	if e, ok := err.(RequestEncodeError); !ok || e.Method != "foo" || e.Codec != "bson" || !IsCodecError(err) {
+		t.Fatal("Failing to encode In should return a RequestEncodeError", err)
+	}
+
+	if called {
+		t.Fatal("Requests that fail to encode should not be sent")
+	}
+}
+
+func TestSendResponseDecodeError(t *testing.T) {
+	b, _ := bson.Marshal(&TestParam{Val1: "Hello World"})
+
+	c := &Conn{codec: BSONCodec{}, rpcClient: &mockRPCClient{
+		CallFunc: func(serviceMethod string, args interface{}, reply interface{}) error {
+			reply.(*skynet.ServiceRPCOutRead).Out = b
+			return nil
+		},
+	}}
+
+	// out doesn't match the document returned by the service
+	var out []string
+	err := c.Send(&skynet.RequestInfo{}, "foo", TestParam{}, &out)
+
+	e, ok := err.(ResponseDecodeError)
+	if !ok || e.Method != "foo" || e.Length != len(b) || !IsCodecError(err) {
+		t.Fatal("Failing to decode Out should return a ResponseDecodeError", err)
+	}
+
+	if IsTransportError(err) || IsServiceError(err) {
+		t.Fatal("Decode errors should be distinguishable from transport and service errors", err)
+	}
+}
+
+func TestFreshConnectionIsHealthy(t *testing.T) {
+	pinged := false
+
+	c := &Conn{codec: BSONCodec{}, lastUsed: time.Now(), rpcClient: &mockRPCClient{
+		CallFunc: func(serviceMethod string, args interface{}, reply interface{}) error {
+			pinged = true
+			return nil
+		},
+	}}
+	c.SetHealthCheck(time.Minute, "Ping")
+
+	if !c.IsHealthy() || pinged {
+		t.Fatal("Connections that aren't stale should be healthy without a ping")
+	}
+}
+
+func TestStaleConnectionIsPinged(t *testing.T) {
+	var method string
+
+	c := &Conn{codec: BSONCodec{}, serviceName: "TestService", lastUsed: time.Now().Add(-time.Hour), rpcClient: &mockRPCClient{
+		CallFunc: func(serviceMethod string, args interface{}, reply interface{}) error {
+			method = args.(skynet.ServiceRPCInWrite).Method
+			return rpc.ServerError("No such method")
+		},
+	}}
+	c.SetHealthCheck(time.Minute, "Ping")
+
+	if !c.IsHealthy() {
+		t.Fatal("Stale connection should be healthy if the service responds to a ping")
+	}
+
+	if method != "Ping" {
+		t.Fatal("Stale connection was not pinged")
+	}
+}
+
+func TestStaleConnectionFailingPingIsUnhealthy(t *testing.T) {
+	c := &Conn{codec: BSONCodec{}, lastUsed: time.Now().Add(-time.Hour), rpcClient: &mockRPCClient{
+		CallFunc: func(serviceMethod string, args interface{}, reply interface{}) error {
+			return io.EOF
+		},
+	}}
+	c.SetHealthCheck(time.Minute, "Ping")
+
+	if c.IsHealthy() {
+		t.Fatal("Stale connection should be unhealthy if ping fails")
+	}
+}
+
+func TestStaleConnectionWithoutPingIsUnhealthy(t *testing.T) {
+	c := &Conn{codec: BSONCodec{}, lastUsed: time.Now().Add(-time.Hour), rpcClient: &mockRPCClient{}}
+	c.SetHealthCheck(time.Minute, "")
+
+	if c.IsHealthy() {
+		t.Fatal("Stale connection should be unhealthy when no ping method is configured")
+	}
+}
+
+func TestSendReturnsRemoteError(t *testing.T) {
+	client, server := net.Pipe()
+	go doServiceHandshake(server, "TestRPCService", true, t)
+
+	cn, err := NewConnectionFromNetConn("TestRPCService", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := rpc.NewServer()
+	s.Register(&TestRPCService{
+		TestMethod: func(in skynet.ServiceRPCInRead, out *skynet.ServiceRPCOutWrite) (err error) {
+			out.ErrString = "No such widget"
+			out.ErrCode = "NotFound"
+			return
+		},
+	})
+	go s.ServeCodec(bsonrpc.NewServerCodec(server))
+
+	var tp TestParam
+	err = cn.Send(&skynet.RequestInfo{}, "Foo", TestParam{}, &tp)
+
+	re, ok := err.(RemoteError)
+	if !ok || re.Code != "NotFound" || re.Message != "No such widget" {
+		t.Fatal("Error returned by the service's method should be a RemoteError with its code", err)
+	}
+
+	if !IsServiceError(err) || IsTransportError(err) || cn.IsClosed() {
+		t.Fatal("RemoteError should leave the connection usable")
+	}
+}
+
+func TestSendOnewayReturnsBeforeResponse(t *testing.T) {
+	client, server := net.Pipe()
+	go doServiceHandshake(server, "TestRPCService", true, t)
+
+	cn, err := NewConnectionFromNetConn("TestRPCService", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan string, 1)
+	respond := make(chan bool)
+
+	s := rpc.NewServer()
+	s.Register(&TestRPCService{
+		TestMethod: func(in skynet.ServiceRPCInRead, out *skynet.ServiceRPCOutWrite) (err error) {
+			received <- in.Method
+			<-respond
+
+			b, err := bson.Marshal(TestParam{Val1: "Hello"})
+			out.Out = bson.Binary{0x00, b}
+			return
+		},
+	})
+	go s.ServeCodec(bsonrpc.NewServerCodec(server))
+
+	if err := cn.SendOneway(&skynet.RequestInfo{}, "Foo", TestParam{Val1: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case method := <-received:
+		if method != "Foo" {
+			t.Fatal("Failed to set Method on request", method)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("One way request was not delivered")
+	}
+
+	// the response is discarded when it arrives, leaving the connection usable
+	close(respond)
+
+	var tp TestParam
+	if err := cn.Send(&skynet.RequestInfo{}, "Bar", TestParam{Val1: "Hello"}, &tp); err != nil || cn.IsClosed() {
+		t.Fatal("Connection should be usable after a one way request", err)
+	}
+
+	if method := <-received; method != "Bar" || tp.Val1 != "Hello" {
+		t.Fatal("Response to the one way request shouldn't be mistaken for a later request's", method)
+	}
+}
+
+func TestKeepAliveDetectsClosedService(t *testing.T) {
+	client, server := net.Pipe()
+	go doServiceHandshake(server, "TestRPCService", true, t)
+
+	cn, err := NewConnectionFromNetConn("TestRPCService", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := rpc.NewServer()
+	s.Register(&TestRPCService{})
+	go s.ServeCodec(bsonrpc.NewServerCodec(server))
+
+	c := cn.(*Conn)
+
+	if !c.KeepAlive(time.Second) {
+		t.Fatal("KeepAlive() should succeed while the service responds")
+	}
+
+	server.Close()
+
+	if c.KeepAlive(time.Second) {
+		t.Fatal("KeepAlive() should fail once the service has gone")
+	}
+
+	if !c.IsClosed() {
+		t.Fatal("Connection should be closed once KeepAlive() fails")
+	}
+}
+
+func TestHandshakeOverTLS(t *testing.T) {
+	cert, pool := selfSignedCert(t)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal("Failed to bind to port for test", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			sendServiceHandshake(conn, skynet.ServiceHandshake{
+				Name:        "TestService",
+				Registered:  true,
+				ClientID:    "abc",
+				TLSRequired: true,
+			}, t)
+		}
+	}()
+
+	c, err := NewConnectionWithOptions("TestService", "tcp", ln.Addr().String(), 500*time.Millisecond, Options{
+		TLSConfig: &tls.Config{RootCAs: pool},
+	})
+
+	if err != nil {
+		t.Fatal("Failed to perform handshake over TLS", err)
+	}
+
+	if _, ok := c.(*Conn).conn.(*tls.Conn); !ok {
+		t.Fatal("Connection was not made over TLS")
+	}
+
+	c.Close()
+}
+
+func TestPlaintextRejectedWhenTLSRequired(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	// the client closes the connection rather than replying with its handshake
+	go bsonrpc.NewEncoder(server).Encode(skynet.ServiceHandshake{
+		Name:        "TestService",
+		Registered:  true,
+		ClientID:    "abc",
+		TLSRequired: true,
+	})
+
+	_, err := NewConnectionFromNetConn("TestService", client)
+
+	if err != TLSRequired {
+		t.Fatal("Connection without TLS should fail when service requires TLS", err)
+	}
+}
+
+func TestTLSClientRejectsPlaintextService(t *testing.T) {
+	_, pool := selfSignedCert(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to bind to port for test", err)
+	}
+	defer ln.Close()
+
+	// the plaintext ServiceHandshake isn't a valid reply to the client's TLS hello
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+
+			bsonrpc.NewEncoder(conn).Encode(skynet.ServiceHandshake{
+				Name:       "TestService",
+				Registered: true,
+				ClientID:   "abc",
+			})
+		}
+	}()
+
+	_, err = NewConnectionWithOptions("TestService", "tcp", ln.Addr().String(), 500*time.Millisecond, Options{
+		TLSConfig: &tls.Config{RootCAs: pool},
+	})
+
+	if err != HandshakeFailed {
+		t.Fatal("Connection expecting TLS should fail when the service doesn't support it", err)
+	}
+}
+
+func TestDialFuncConnectsOverPipe(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go doServiceHandshake(server, "TestService", true, t)
+
+	var dialed string
+	c, err := NewConnectionWithOptions("TestService", "tcp", "proxied:9000", time.Second, Options{
+		Dial: func(network, addr string) (net.Conn, error) {
+			dialed = network + " " + addr
+			return client, nil
+		},
+	})
+
+	if err != nil {
+		t.Fatal("Failed to perform handshake over the dialed connection", err)
+	}
+	defer c.Close()
+
+	if dialed != "tcp proxied:9000" {
+		t.Fatal("DialFunc should be called with the network and address", dialed)
+	}
+
+	if c.(*Conn).clientID != "abc" {
+		t.Fatal("Handshake should complete over the dialed connection")
+	}
+}
+
+func TestHandshakeTimesOut(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to bind to port for test", err)
+	}
+	defer ln.Close()
+
+	// accepts the connection but never sends the ServiceHandshake
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			defer c.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	start := time.Now()
+
+	_, err = NewConnectionWithOptions("TestService", "tcp", ln.Addr().String(), 500*time.Millisecond, Options{
+		HandshakeTimeout: 50 * time.Millisecond,
+	})
+
+	if err != HandshakeTimedOut {
+		t.Fatal("Handshake with a silent service should time out", err)
+	}
+
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("Handshake should give up once the timeout passes", time.Since(start))
+	}
+}
+
+func TestDialGivesUpOnceTimeoutPasses(t *testing.T) {
+	// the DialFunc doesn't return until the test ends, as though the host were unreachable
+	unblock := make(chan bool)
+	defer close(unblock)
+
+	start := time.Now()
+
+	_, err := NewConnectionWithOptions("TestService", "tcp", "10.255.255.1:9000", 50*time.Millisecond, Options{
+		Dial: func(network, addr string) (net.Conn, error) {
+			<-unblock
+			return nil, errors.New("Unreachable")
+		},
+	})
+
+	if err != DialTimedOut {
+		t.Fatal("Dialing an unreachable host should time out", err)
+	}
+
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("Dial should give up once the timeout passes", time.Since(start))
+	}
+}
+
+func TestHandshakeDeadlineClearedOnceComplete(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go doServiceHandshake(server, "TestRPCService", true, t)
+
+	c, err := NewConnectionFromNetConnWithOptions("TestRPCService", client, Options{HandshakeTimeout: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal("Failed to perform handshake", err)
+	}
+	defer c.Close()
+
+	s := rpc.NewServer()
+	var ts TestRPCService
+	s.Register(&ts)
+	go s.ServeCodec(bsonrpc.NewServerCodec(server))
+
+	ts.TestMethod = func(in skynet.ServiceRPCInRead, out *skynet.ServiceRPCOutWrite) (err error) {
+		b, err := bson.Marshal(&TestParam{Val1: "Hello World"})
+		out.Out = bson.Binary{0x00, b}
+
+		return
+	}
+
+	// requests would fail if the handshake's deadline was still set
+	time.Sleep(40 * time.Millisecond)
+
+	var tp TestParam
+	if err := c.Send(&skynet.RequestInfo{}, "Foo", tp, &tp); err != nil || tp.Val1 != "Hello World" {
+		t.Fatal("Deadline should be cleared once the handshake completes", err)
+	}
+}
+
+func TestSendWithJSONCodec(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	s := rpc.NewServer()
+	var ts TestJSONRPCService
+	s.Register(&ts)
+
+	go func() {
+		ch := sendServiceHandshake(server, skynet.ServiceHandshake{
+			Name:       "TestJSONRPCService",
+			Registered: true,
+			ClientID:   "abc",
+			Codecs:     []string{"bson", "json"},
+		}, t)
+
+		if ch.Codec == "json" {
+			s.ServeCodec(jsonrpc.NewServerCodec(server))
+		}
+	}()
+
+	cn, err := NewConnectionFromNetConnWithOptions("TestJSONRPCService", client, Options{Codec: JSONCodec{}})
+	if err != nil {
+		t.Fatal("Failed to perform handshake with json codec", err)
+	}
+	defer cn.Close()
+
+	var tp TestParam
+
+	ts.TestMethod = func(in skynet.ServiceRPCInWrite, out *skynet.ServiceRPCOutRead) (err error) {
+		if err = json.Unmarshal(in.In.Data, &tp); err != nil {
+			return
+		}
+
+		tp.Val2++
+		out.Out, err = json.Marshal(tp)
+
+		return
+	}
+
+	err = cn.Send(&skynet.RequestInfo{}, "Foo", TestParam{Val1: "Hello World", Val2: 10}, &tp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tp.Val1 != "Hello World" || tp.Val2 != 11 {
+		t.Fatal("Request failed to send proper data over json codec", tp)
+	}
+}
+
+func TestSendWithBSONFuncCodec(t *testing.T) {
+	client, server := net.Pipe()
+	go doServiceHandshake(server, "TestRPCService", true, t)
+
+	// Val1 is prefixed while it's encoded, such as for a type bson can't encode itself
+	codec := BSONFuncCodec{
+		MarshalFunc: func(v interface{}) ([]byte, error) {
+			p := v.(TestParam)
+			p.Val1 = "enc:" + p.Val1
+			return bson.Marshal(p)
+		},
+		UnmarshalFunc: func(data []byte, v interface{}) error {
+			if err := bson.Unmarshal(data, v); err != nil {
+				return err
+			}
+
+			p := v.(*TestParam)
+			p.Val1 = strings.TrimPrefix(p.Val1, "enc:")
+			return nil
+		},
+	}
+
+	cn, err := NewConnectionFromNetConnWithOptions("TestRPCService", client, Options{Codec: codec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cn.Close()
+
+	s := rpc.NewServer()
+	var ts TestRPCService
+	s.Register(&ts)
+	go s.ServeCodec(bsonrpc.NewServerCodec(server))
+
+	ts.TestMethod = func(in skynet.ServiceRPCInRead, out *skynet.ServiceRPCOutWrite) (err error) {
+		var p TestParam
+		if err = bson.Unmarshal(in.In, &p); err != nil {
+			return
+		}
+
+		if p.Val1 != "enc:Hello" {
+			return fmt.Errorf("Request wasn't encoded with MarshalFunc: %q", p.Val1)
+		}
+
+		p.Val1 += " World"
+		p.Val2++
+
+		b, err := bson.Marshal(p)
+		out.Out = bson.Binary{0x00, b}
+		return
+	}
+
+	var tp TestParam
+	if err = cn.Send(&skynet.RequestInfo{}, "Foo", TestParam{Val1: "Hello", Val2: 1}, &tp); err != nil {
+		t.Fatal(err)
+	}
+
+	if tp.Val1 != "Hello World" || tp.Val2 != 2 {
+		t.Fatal("Response wasn't decoded with UnmarshalFunc", tp)
+	}
+}
+
+func TestCodecUnsupportedByService(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	// Services that don't advertise codecs only support bson
+	go bsonrpc.NewEncoder(server).Encode(skynet.ServiceHandshake{
+		Name:       "TestService",
+		Registered: true,
+		ClientID:   "abc",
+	})
+
+	_, err := NewConnectionFromNetConnWithOptions("TestService", client, Options{Codec: JSONCodec{}})
+
+	if err != CodecUnsupported {
+		t.Fatal("Connection should fail when the service doesn't support the codec", err)
+	}
+}
+
+func TestSendWithCompression(t *testing.T) {
+	for _, name := range []string{"snappy", "gzip"} {
+		compressor, _ := compress.Lookup(name)
+
+		client, server := net.Pipe()
+
+		s := rpc.NewServer()
+		var ts TestRPCService
+		s.Register(&ts)
+
+		go func() {
+			ch := sendServiceHandshake(server, skynet.ServiceHandshake{
+				Name:        "TestRPCService",
+				Registered:  true,
+				ClientID:    "abc",
+				Compressors: compress.Names(),
+			}, t)
+
+			if ch.Compression == name {
+				s.ServeCodec(bsonrpc.NewServerCodec(server))
+			}
+		}()
+
+		cn, err := NewConnectionFromNetConnWithOptions("TestRPCService", client, Options{
+			Compressors: []compress.Compressor{compressor},
+		})
+		if err != nil {
+			t.Fatal("Failed to perform handshake with compression", err)
+		}
+
+		ts.TestMethod = func(in skynet.ServiceRPCInRead, out *skynet.ServiceRPCOutWrite) (err error) {
+			var tp TestParam
+
+			b, err := compressor.Decompress(in.In)
+			if err != nil {
+				return
+			}
+
+			if err = bson.Unmarshal(b, &tp); err != nil {
+				return
+			}
+
+			tp.Val2++
+
+			if b, err = bson.Marshal(tp); err != nil {
+				return
+			}
+
+			b, err = compressor.Compress(b)
+			out.Out = bson.Binary{0x00, b}
+
+			return
+		}
+
+		large := strings.Repeat("Hello World ", 10000)
+
+		var tp TestParam
+		err = cn.Send(&skynet.RequestInfo{}, "Foo", TestParam{Val1: large, Val2: 10}, &tp)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if tp.Val1 != large || tp.Val2 != 11 {
+			t.Fatal("Request failed to send proper data with compression", name)
+		}
+
+		cn.Close()
+		server.Close()
+	}
+}
+
+func TestCompressionFallsBackWhenUnsupported(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	chans := make(chan skynet.ClientHandshake, 1)
+
+	// Services that don't advertise compressors expect uncompressed payloads
+	go func() {
+		chans <- sendServiceHandshake(server, skynet.ServiceHandshake{
+			Name:       "TestService",
+			Registered: true,
+			ClientID:   "abc",
+		}, t)
+	}()
+
+	gzip, _ := compress.Lookup("gzip")
+
+	cn, err := NewConnectionFromNetConnWithOptions("TestService", client, Options{
+		Compressors: []compress.Compressor{gzip},
+	})
+	if err != nil {
+		t.Fatal("Handshake should succeed without compression", err)
+	}
+	defer cn.Close()
+
+	if ch := <-chans; ch.Compression != "" {
+		t.Fatal("Client should not request compression the service doesn't support", ch.Compression)
+	}
+
+	if cn.(*Conn).compressor != nil {
+		t.Fatal("Connection should not compress payloads")
+	}
+}
+
+func TestHandshakeExchangesMetadata(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	chans := make(chan skynet.ClientHandshake, 1)
+
+	go func() {
+		chans <- sendServiceHandshake(server, skynet.ServiceHandshake{
+			Name:            "TestService",
+			Registered:      true,
+			ClientID:        "abc",
+			ProtocolVersion: skynet.ProtocolVersion,
+			Version:         "2.1.0",
+			Methods:         []string{"Foo", "Bar"},
+			MaxRequestBytes: 1024,
+			Metadata:        map[string]string{"zone": "a"},
+		}, t)
+	}()
+
+	cn, err := NewConnectionFromNetConnWithOptions("TestService", client, Options{
+		Identity: Identity{Name: "TestClient", Version: "1.0.0", Metadata: map[string]string{"team": "search"}},
+	})
+	if err != nil {
+		t.Fatal("Failed to perform handshake", err)
+	}
+	defer cn.Close()
+
+	ch := <-chans
+	if ch.ProtocolVersion != skynet.ProtocolVersion || ch.ClientName != "TestClient" || ch.ClientVersion != "1.0.0" || ch.Metadata["team"] != "search" {
+		t.Fatalf("Client should identify itself in the ClientHandshake, got %+v", ch)
+	}
+
+	sh := cn.(*Conn).ServiceHandshake()
+	if sh.Version != "2.1.0" || len(sh.Methods) != 2 || sh.MaxRequestBytes != 1024 || sh.Metadata["zone"] != "a" {
+		t.Fatalf("Connection should keep the ServiceHandshake, got %+v", sh)
+	}
+}
+
+func TestResponseLargerThanMaxRejected(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go doServiceHandshake(server, "TestRPCService", true, t)
+
+	cn, err := NewConnectionFromNetConnWithOptions("TestRPCService", client, Options{MaxResponseBytes: 1024})
+	if err != nil {
+		t.Fatal("Failed to perform handshake", err)
+	}
+	c := cn.(*Conn)
+
+	s := rpc.NewServer()
+	var ts TestRPCService
+	s.Register(&ts)
+	go s.ServeCodec(bsonrpc.NewServerCodec(server))
+
+	ts.TestMethod = func(in skynet.ServiceRPCInRead, out *skynet.ServiceRPCOutWrite) (err error) {
+		b, err := bson.Marshal(&TestParam{Val1: strings.Repeat("a", 4096)})
+		out.Out = bson.Binary{0x00, b}
+
+		return
+	}
+
+	var tp TestParam
+	err = c.Send(&skynet.RequestInfo{}, "Foo", tp, &tp)
+	if err != ResponseTooLarge {
+		t.Fatal("Response larger than MaxResponseBytes should be rejected, got", err)
+	}
+
+	if !IsCodecError(err) {
+		t.Fatal("ResponseTooLarge should be a codec error")
+	}
+
+	if !c.IsClosed() {
+		t.Fatal("Connection should be closed once a response is rejected")
+	}
+}
+
+func TestMultiplexedConnectionMatchesConcurrentResponses(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go doServiceHandshake(server, "TestRPCService", true, t)
+
+	cn, err := NewConnectionFromNetConnWithOptions("TestRPCService", client, Options{Multiplex: true})
+	if err != nil {
+		t.Fatal("Failed to perform handshake", err)
+	}
+	c := cn.(*Conn)
+	defer c.Close()
+
+	s := rpc.NewServer()
+	var ts TestRPCService
+	s.Register(&ts)
+	go s.ServeCodec(bsonrpc.NewServerCodec(server))
+
+	blocked := make(chan bool)
+	defer close(blocked)
+
+	ts.TestMethod = func(in skynet.ServiceRPCInRead, out *skynet.ServiceRPCOutWrite) (err error) {
+		var tp TestParam
+		if err = bson.Unmarshal(in.In, &tp); err != nil {
+			return
+		}
+
+		if in.Method == "Block" {
+			<-blocked
+		}
+
+		// answer later requests first, so responses arrive out of order
+		time.Sleep(time.Duration(50-tp.Val2) * time.Millisecond)
+
+		tp.Val1 = in.RequestInfo.RequestID
+		b, err := bson.Marshal(&tp)
+		out.Out = bson.Binary{0x00, b}
+
+		return
+	}
+
+	if !c.Multiplexed() {
+		t.Fatal("Connection should be multiplexed")
+	}
+
+	// a request that times out is abandoned without closing the connection
+	if err := c.SendTimeout(&skynet.RequestInfo{}, "Block", TestParam{}, &TestParam{}, 10*time.Millisecond); err == nil {
+		t.Fatal("Request should have timed out")
+	}
+
+	if c.IsClosed() {
+		t.Fatal("Multiplexed connection should be left open when a request times out")
+	}
+
+	var wait sync.WaitGroup
+	errs := make(chan error, 50)
+
+	for i := 0; i < 50; i++ {
+		wait.Add(1)
+
+		go func(i int) {
+			defer wait.Done()
+
+			ri := &skynet.RequestInfo{RequestID: fmt.Sprintf("request-%d", i)}
+
+			var out TestParam
+			if err := c.Send(ri, "Foo", TestParam{Val2: i}, &out); err != nil {
+				errs <- err
+			} else if out.Val2 != i || out.Val1 != ri.RequestID {
+				errs <- fmt.Errorf("Request %d got the response to %s", i, out.Val1)
+			}
+		}(i)
+	}
+
+	wait.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
 /*
 * Test Helpers
  */
 
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"skynet"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+type mockRPCClient struct {
+	CallFunc func(serviceMethod string, args interface{}, reply interface{}) error
+	GoFunc   func(serviceMethod string, args interface{}, reply interface{}, done chan *rpc.Call) *rpc.Call
+}
+
+func (m *mockRPCClient) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	if m.CallFunc != nil {
+		return m.CallFunc(serviceMethod, args, reply)
+	}
+
+	return nil
+}
+
+func (m *mockRPCClient) Go(serviceMethod string, args interface{}, reply interface{}, done chan *rpc.Call) *rpc.Call {
+	if m.GoFunc != nil {
+		return m.GoFunc(serviceMethod, args, reply, done)
+	}
+
+	call := &rpc.Call{ServiceMethod: serviceMethod, Args: args, Reply: reply, Done: done}
+	done <- call
+
+	return call
+}
+
+func (m *mockRPCClient) Close() error {
+	return nil
+}
+
 type TestParam struct {
 	Val1 string
 	Val2 int
 }
 
 type TestRPCService struct {
-	TestMethod func(in skynet.ServiceRPCIn, out *skynet.ServiceRPCOut) (err error)
+	TestMethod func(in skynet.ServiceRPCInRead, out *skynet.ServiceRPCOutWrite) (err error)
 }
 
-func (ts *TestRPCService) Forward(in skynet.ServiceRPCIn, out *skynet.ServiceRPCOut) (err error) {
+func (ts *TestRPCService) Forward(in skynet.ServiceRPCInRead, out *skynet.ServiceRPCOutWrite) (err error) {
 	if ts.TestMethod != nil {
 		return ts.TestMethod(in, out)
 	}
@@ -218,22 +1152,38 @@ func (ts TestRPCService) Foo(in TestParam, out *TestParam) (err error) {
 	return
 }
 
-func doServiceHandshake(server net.Conn, registered bool, t *testing.T) {
-	sh := skynet.ServiceHandshake{
+type TestJSONRPCService struct {
+	TestMethod func(in skynet.ServiceRPCInWrite, out *skynet.ServiceRPCOutRead) (err error)
+}
+
+func (ts *TestJSONRPCService) Forward(in skynet.ServiceRPCInWrite, out *skynet.ServiceRPCOutRead) (err error) {
+	if ts.TestMethod != nil {
+		return ts.TestMethod(in, out)
+	}
+
+	return errors.New("No Method Supplied")
+}
+
+func doServiceHandshake(server net.Conn, name string, registered bool, t *testing.T) {
+	sendServiceHandshake(server, skynet.ServiceHandshake{
+		Name:       name,
 		Registered: registered,
 		ClientID:   "abc",
-	}
+	}, t)
+}
 
+func sendServiceHandshake(server net.Conn, sh skynet.ServiceHandshake, t *testing.T) (ch skynet.ClientHandshake) {
 	encoder := bsonrpc.NewEncoder(server)
 	err := encoder.Encode(sh)
 	if err != nil {
 		t.Fatal("Failed to encode server handshake", err)
 	}
 
-	var ch skynet.ClientHandshake
 	decoder := bsonrpc.NewDecoder(server)
 	err = decoder.Decode(&ch)
 	if err != nil {
 		t.Fatal("Error calling bsonrpc.NewDecoder: ", err)
 	}
+
+	return
 }
@@ -0,0 +1,146 @@
+package conn
+
+import (
+	"fmt"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/log"
+	"github.com/skynetservices/skynet/rpc/bsonrpc"
+	"labix.org/v2/mgo/bson"
+	"net"
+	"sync"
+	"time"
+)
+
+/*
+Stream is the response to a streaming request, the service sends its results as a series of chunks over a connection
+opened for the request, which are read one at a time with Recv()
+*/
+type Stream struct {
+	conn   *Conn
+	dec    *bsonrpc.Decoder
+	method string
+
+	mutex sync.Mutex
+	done  bool
+	err   error
+
+	onClose   func()
+	closeOnce sync.Once
+}
+
+/*
+conn.NewStreamWithOptions() opens a connection to the skynet service at addr and sends it a streaming request
+*/
+func NewStreamWithOptions(serviceName, network, addr string, timeout time.Duration, opts Options, ri *skynet.RequestInfo, fn string, in interface{}) (s *Stream, err error) {
+	var c net.Conn
+	if c, opts, err = dialConn(network, addr, timeout, opts); err != nil {
+		return
+	}
+
+	return NewStreamFromNetConnWithOptions(serviceName, c, opts, ri, fn, in)
+}
+
+/*
+conn.NewStreamFromNetConnWithOptions() sends a streaming request to the skynet service over an existing net.Conn,
+the connection is closed once the Stream is
+*/
+func NewStreamFromNetConnWithOptions(serviceName string, c net.Conn, opts Options, ri *skynet.RequestInfo, fn string, in interface{}) (s *Stream, err error) {
+	cn, err := newConn(serviceName, c, opts, true)
+	if err != nil {
+		return
+	}
+
+	sin := skynet.ServiceRPCInWrite{
+		RequestInfo: ri,
+		Method:      fn,
+		ClientID:    cn.clientID,
+	}
+
+	b, err := cn.codec.Marshal(in)
+	if err != nil {
+		cn.Close()
+		return nil, RequestEncodeError{Method: fn, Codec: cn.codec.Name(), Err: err}
+	}
+
+	sin.In = bson.Binary{
+		0x00,
+		b,
+	}
+
+	log.Println(log.TRACE, fmt.Sprintf("Sending streaming Method call %s with ClientID %s to: %s", sin.Method, sin.ClientID, cn.addr))
+
+	if err = bsonrpc.NewEncoder(cn.conn).Encode(sin); err != nil {
+		cn.Close()
+		return nil, TransportError{Err: err}
+	}
+
+	return &Stream{conn: cn, dec: bsonrpc.NewDecoder(cn.conn), method: fn}, nil
+}
+
+/*
+Stream.Recv() reads the next chunk into out, returning false once the service has sent every chunk or the stream failed,
+in which case the error is returned
+*/
+func (s *Stream) Recv(out interface{}) (ok bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.done {
+		return false, s.err
+	}
+
+	var chunk skynet.StreamChunkRead
+
+	if err = s.dec.Decode(&chunk); err != nil {
+		// Close() aborts the stream
+		if s.conn.IsClosed() {
+			err = ConnectionClosed
+		} else {
+			err = TransportError{Err: err}
+		}
+
+		return s.finish(err)
+	}
+
+	if chunk.ErrString != "" {
+		return s.finish(serviceError{chunk.ErrString})
+	}
+
+	if chunk.Done {
+		return s.finish(nil)
+	}
+
+	if err = s.conn.codec.Unmarshal(chunk.Out, out); err != nil {
+		return s.finish(ResponseDecodeError{Method: s.method, Codec: s.conn.codec.Name(), Length: len(chunk.Out), Err: err})
+	}
+
+	return true, nil
+}
+
+/*
+Stream.Close() closes the stream's connection, any chunks the service hasn't sent are discarded
+*/
+func (s *Stream) Close() {
+	s.conn.Close()
+
+	s.closeOnce.Do(func() {
+		if s.onClose != nil {
+			s.onClose()
+		}
+	})
+}
+
+/*
+Stream.OnClose() calls f once the stream is first closed, it must be set before the stream is used
+*/
+func (s *Stream) OnClose(f func()) {
+	s.onClose = f
+}
+
+// mutex must be held, nothing more is read once the stream has finished
+func (s *Stream) finish(err error) (bool, error) {
+	s.done, s.err = true, err
+	s.conn.Close()
+
+	return false, err
+}
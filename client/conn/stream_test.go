@@ -0,0 +1,118 @@
+package conn
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/rpc/bsonrpc"
+	"labix.org/v2/mgo/bson"
+	"net"
+	"testing"
+)
+
+func TestStreamReceivesChunks(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	const chunks = 5
+
+	go func() {
+		ch := sendServiceHandshake(server, skynet.ServiceHandshake{Name: "TestService", Registered: true, ClientID: "abc", Streams: true}, t)
+		if !ch.Stream {
+			t.Error("Client should ask for a streaming connection")
+			return
+		}
+
+		var sin skynet.ServiceRPCInRead
+		if err := bsonrpc.NewDecoder(server).Decode(&sin); err != nil {
+			t.Error("Failed to decode streaming request", err)
+			return
+		}
+
+		var in TestParam
+		bson.Unmarshal(sin.In, &in)
+
+		encoder := bsonrpc.NewEncoder(server)
+		for i := 0; i < chunks; i++ {
+			b, _ := bson.Marshal(TestParam{Val1: sin.Method, Val2: in.Val2 + i})
+			encoder.Encode(skynet.StreamChunkWrite{Out: bson.Binary{0x00, b}})
+		}
+
+		encoder.Encode(skynet.StreamChunkWrite{Done: true})
+	}()
+
+	s, err := NewStreamFromNetConnWithOptions("TestService", client, Options{}, &skynet.RequestInfo{}, "List", TestParam{Val2: 10})
+	if err != nil {
+		t.Fatal("Failed to open stream", err)
+	}
+	defer s.Close()
+
+	received := 0
+	for {
+		var out TestParam
+		ok, err := s.Recv(&out)
+
+		if err != nil {
+			t.Fatal("Stream should end without an error", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		if out.Val1 != "List" || out.Val2 != 10+received {
+			t.Fatal("Chunks should be received in order", out)
+		}
+
+		received++
+	}
+
+	if received != chunks {
+		t.Fatal("Expected every chunk the service sent", received)
+	}
+
+	if ok, err := s.Recv(&TestParam{}); ok || err != nil {
+		t.Fatal("A finished stream should have nothing more to receive", ok, err)
+	}
+}
+
+func TestStreamReturnsServiceError(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go func() {
+		sendServiceHandshake(server, skynet.ServiceHandshake{Name: "TestService", Registered: true, ClientID: "abc", Streams: true}, t)
+
+		var sin skynet.ServiceRPCInRead
+		bsonrpc.NewDecoder(server).Decode(&sin)
+		bsonrpc.NewEncoder(server).Encode(skynet.StreamChunkWrite{ErrString: "Failed listing"})
+	}()
+
+	s, err := NewStreamFromNetConnWithOptions("TestService", client, Options{}, &skynet.RequestInfo{}, "List", TestParam{})
+	if err != nil {
+		t.Fatal("Failed to open stream", err)
+	}
+	defer s.Close()
+
+	ok, err := s.Recv(&TestParam{})
+	if ok || !IsServiceError(err) || err.Error() != "Failed listing" {
+		t.Fatal("Expected the error from the service", err)
+	}
+
+	if _, again := s.Recv(&TestParam{}); again != err {
+		t.Fatal("A failed stream should keep returning its error", again)
+	}
+}
+
+func TestStreamsUnsupportedByService(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go bsonrpc.NewEncoder(server).Encode(skynet.ServiceHandshake{
+		Name:       "TestService",
+		Registered: true,
+		ClientID:   "abc",
+	})
+
+	if _, err := NewStreamFromNetConnWithOptions("TestService", client, Options{}, &skynet.RequestInfo{}, "List", TestParam{}); err != StreamsUnsupported {
+		t.Fatal("Expected services that don't advertise streams to be refused", err)
+	}
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"errors"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/config"
+	"sync"
+	"time"
+)
+
+var InstanceUnreachable = errors.New("Instance is unreachable, connecting to it is backing off")
+
+/*
+With client.conn.backoff set, once connecting to an instance fails its pool refuses new connections with InstanceUnreachable
+for a backoff window, doubling after each failure up to client.conn.backoff.max, so an instance that's down isn't dialed
+by every Acquire. Each window is randomized between half and its full length so it's never close to 0. A successful
+connection resets the backoff
+*/
+type connectBackoff struct {
+	sync.Mutex
+	backoff *backoff
+
+	// connections aren't attempted until then
+	until time.Time
+}
+
+// nil if base is 0, leaving connections to be attempted by every Acquire
+func newConnectBackoff(base, max time.Duration) *connectBackoff {
+	if base <= 0 {
+		return nil
+	}
+
+	b := newBackoff(base, max, 2)
+	b.jitter = equalJitter
+
+	return &connectBackoff{backoff: b}
+}
+
+func (cb *connectBackoff) allow() bool {
+	if cb == nil {
+		return true
+	}
+
+	cb.Lock()
+	defer cb.Unlock()
+
+	return !time.Now().Before(cb.until)
+}
+
+func (cb *connectBackoff) failed() {
+	if cb == nil {
+		return
+	}
+
+	cb.Lock()
+	cb.until = time.Now().Add(cb.backoff.next())
+	cb.Unlock()
+}
+
+func (cb *connectBackoff) succeeded() {
+	if cb == nil {
+		return
+	}
+
+	cb.Lock()
+	cb.backoff.reset()
+	cb.until = time.Time{}
+	cb.Unlock()
+}
+
+func getConnectBackoff(s skynet.ServiceInfo) time.Duration {
+	if d, err := config.String(s.Name, s.Version, "client.conn.backoff"); err == nil {
+		if backoff, err := time.ParseDuration(d); err == nil {
+			return backoff
+		}
+
+		logger.Error("Failed to parse client.conn.backoff", err)
+	}
+
+	return config.DefaultConnectBackoff
+}
+
+func getConnectBackoffMax(s skynet.ServiceInfo) time.Duration {
+	if d, err := config.String(s.Name, s.Version, "client.conn.backoff.max"); err == nil {
+		if max, err := time.ParseDuration(d); err == nil {
+			return max
+		}
+
+		logger.Error("Failed to parse client.conn.backoff.max", err)
+	}
+
+	return config.DefaultConnectBackoffMax
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ClientDraining = errors.New("Service client is draining")
+
+// requests being sent by a ServiceClient, so they can be waited on once it's draining
+type requestTracker struct {
+	sync.Mutex
+	pending  int
+	draining bool
+
+	// closed once draining and every pending request is done
+	drained chan struct{}
+}
+
+// returns false without tracking the request if draining
+func (t *requestTracker) start() bool {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.draining {
+		return false
+	}
+
+	t.pending++
+	return true
+}
+
+func (t *requestTracker) finish() {
+	t.Lock()
+	defer t.Unlock()
+
+	t.pending--
+
+	if t.draining && t.pending == 0 {
+		close(t.drained)
+	}
+}
+
+func (t *requestTracker) drain() <-chan struct{} {
+	t.Lock()
+	defer t.Unlock()
+
+	if !t.draining {
+		t.draining = true
+		t.drained = make(chan struct{})
+
+		if t.pending == 0 {
+			close(t.drained)
+		}
+	}
+
+	return t.drained
+}
+
+func (t *requestTracker) isDraining() bool {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.draining
+}
+
+/*
+ServiceClient.Drain() stops the ServiceClient accepting requests ahead of it being closed, new requests fail with
+ClientDraining while those already being sent complete. DrainWait() waits for them
+*/
+func (c *ServiceClient) Drain() {
+	c.requests.drain()
+}
+
+/*
+ServiceClient.DrainWait() drains the ServiceClient if it isn't already, and waits for the requests it was sending to
+complete, returning ctx.Err() should ctx be done first
+*/
+func (c *ServiceClient) DrainWait(ctx context.Context) error {
+	select {
+	case <-c.requests.drain():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// tracks the request until finishRequest() is called, both to drain the ServiceClient and for Close() to wait on
+func (c *ServiceClient) startRequest() error {
+	if !c.requests.start() {
+		return ClientDraining
+	}
+
+	c.waiter.Add(1)
+	return nil
+}
+
+func (c *ServiceClient) finishRequest() {
+	c.waiter.Done()
+	c.requests.finish()
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/rpc/bsonrpc"
+	"github.com/skynetservices/skynet/test"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDrainFinishesRequestsInFlight(t *testing.T) {
+	defer resetClient()
+
+	started := make(chan bool, 1)
+	finish := make(chan bool)
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) error {
+					started <- true
+					<-finish
+					return nil
+				},
+			}, nil
+		},
+	})
+
+	sc := GetService("TestService", "", "", "")
+	sc.SetDefaultTimeout(0, 5*time.Second)
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: *serviceInfo()})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 1
+	})
+
+	sent := make(chan error, 1)
+	go func() {
+		var val string
+		sent <- sc.Send(nil, "Foo", val, &val)
+	}()
+
+	<-started
+	sc.Drain()
+
+	var val string
+	if err := sc.Send(nil, "Foo", val, &val); err != ClientDraining {
+		t.Fatal("Requests should be refused with ClientDraining once the client is draining", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := sc.DrainWait(ctx); err != context.DeadlineExceeded {
+		t.Fatal("DrainWait() should wait for the request in flight until ctx is done", err)
+	}
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- sc.DrainWait(context.Background())
+	}()
+
+	close(finish)
+
+	if err := <-sent; err != nil {
+		t.Fatal("Request in flight should complete while the client is draining", err)
+	}
+
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("DrainWait() should return once the request in flight completes")
+	}
+}
+
+func TestDrainWaitsForOpenStreams(t *testing.T) {
+	defer resetClient()
+	defer SetDialFunc(nil)
+
+	SetDialFunc(func(network, addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+
+		go func() {
+			bsonrpc.NewEncoder(server).Encode(skynet.ServiceHandshake{Name: "TestService", Registered: true, ClientID: "abc", Streams: true})
+
+			decoder := bsonrpc.NewDecoder(server)
+
+			var ch skynet.ClientHandshake
+			decoder.Decode(&ch)
+
+			var sin skynet.ServiceRPCInRead
+			decoder.Decode(&sin)
+		}()
+
+		return client, nil
+	})
+
+	sc := GetService("TestService", "", "", "")
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: *serviceInfo()})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 1
+	})
+
+	stream, err := sc.SendStream(nil, "List", echoParam{})
+	if err != nil {
+		t.Fatal("Failed to open stream", err)
+	}
+
+	sc.Drain()
+
+	if _, err := sc.SendStream(nil, "List", echoParam{}); err != ClientDraining {
+		t.Fatal("Streams should be refused with ClientDraining once the client is draining", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := sc.DrainWait(ctx); err != context.DeadlineExceeded {
+		t.Fatal("DrainWait() should wait for the open stream until ctx is done", err)
+	}
+
+	stream.Close()
+	stream.Close()
+
+	if err := sc.DrainWait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
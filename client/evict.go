@@ -0,0 +1,75 @@
+package client
+
+import (
+	"fmt"
+	"github.com/skynetservices/skynet"
+)
+
+// message sent to mux() to evict or un-evict the instances at an address
+type evictionRequest struct {
+	addr  string
+	evict bool
+	done  chan struct{}
+}
+
+/*
+ServiceClient.EvictInstance() stops requests being sent to instances at addr (host:port) until UnevictInstance() is called,
+even if they're removed and discovered again. Requests already sent to them aren't affected
+*/
+func (c *ServiceClient) EvictInstance(addr string) {
+	c.requestEviction(addr, true)
+}
+
+/*
+ServiceClient.UnevictInstance() returns instances at addr to the LoadBalancer, unless their circuit breaker is open
+*/
+func (c *ServiceClient) UnevictInstance(addr string) {
+	c.requestEviction(addr, false)
+}
+
+// returns once mux() has handled the request, so no instance is chosen from the LoadBalancer until it's applied
+func (c *ServiceClient) requestEviction(addr string, evict bool) {
+	r := evictionRequest{addr: addr, evict: evict, done: make(chan struct{})}
+
+	if c.sendMux(r) {
+		<-r.done
+	}
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) handleEvictionRequest(r evictionRequest) {
+	defer close(r.done)
+
+	if c.evicted[r.addr] == r.evict {
+		return
+	}
+
+	if r.evict {
+		logger.Warn(fmt.Sprintf("Evicting instances at %s", r.addr))
+
+		c.forInstancesAt(r.addr, c.loadBalancer.RemoveInstance)
+		c.evicted[r.addr] = true
+		return
+	}
+
+	logger.Info(fmt.Sprintf("Un-evicting instances at %s", r.addr))
+
+	delete(c.evicted, r.addr)
+	c.forInstancesAt(r.addr, c.loadBalancer.AddInstance)
+}
+
+// this should only be called by mux(), f is called for each instance at addr that isn't excluded
+func (c *ServiceClient) forInstancesAt(addr string, f func(s skynet.ServiceInfo)) {
+	for uuid, s := range c.instances {
+		if s.AddrString() == addr && !c.isExcluded(uuid) {
+			f(s)
+		}
+	}
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) isEvicted(uuid string) bool {
+	s, ok := c.instances[uuid]
+
+	return ok && c.evicted[s.AddrString()]
+}
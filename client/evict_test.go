@@ -0,0 +1,83 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer/roundrobin"
+	"github.com/skynetservices/skynet/config"
+	"testing"
+)
+
+func TestEvictedInstanceNotChosen(t *testing.T) {
+	defer resetClient()
+
+	good, evicted := *serviceInfo(), *serviceInfo()
+	good.UUID, evicted.UUID = config.NewUUID(), config.NewUUID()
+	evicted.ServiceAddr.Port = good.ServiceAddr.Port + 1
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+	sClient := sc.(*ServiceClient)
+	sClient.loadBalancer = roundrobin.New([]skynet.ServiceInfo{})
+
+	counter := stubFailingPool()
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: good})
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: evicted})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 2
+	})
+
+	sc.EvictInstance(evicted.AddrString())
+
+	send := func() {
+		var val string
+		for i := 0; i < 10; i++ {
+			if err := sc.SendOnce(nil, "Foo", val, &val); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	send()
+
+	if counter.count(evicted.UUID) != 0 {
+		t.Fatal("Evicted instance should not be chosen")
+	}
+
+	// the instance is rediscovered at the same address
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceRemoved, Service: evicted})
+	evicted.UUID = config.NewUUID()
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: evicted})
+
+	waitFor(t, func() bool {
+		resp := make(chan []skynet.ServiceInfo, 1)
+		sClient.sendMux(instancesQuery{resp: resp})
+
+		for _, s := range <-resp {
+			if s.UUID == evicted.UUID {
+				return true
+			}
+		}
+
+		return false
+	})
+
+	send()
+
+	if counter.count(evicted.UUID) != 0 {
+		t.Fatal("Evicted instance should not be chosen once it's rediscovered")
+	}
+
+	if sc.InstanceCount() != 1 {
+		t.Fatal("Evicted instance should not be counted as available")
+	}
+
+	sc.UnevictInstance(evicted.AddrString())
+	send()
+
+	if counter.count(evicted.UUID) == 0 {
+		t.Fatal("Instance should be chosen once it's un-evicted")
+	}
+}
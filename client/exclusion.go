@@ -0,0 +1,118 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/config"
+	"sync"
+	"time"
+)
+
+/*
+Instances working on an attempt of a request, so its retries and hedges are sent to other instances while there are any.
+An attempt that's stuck would keep its instance from being chosen for the rest of the request, so instances are only
+excluded for client.attempts.exclusion.ttl after their latest attempt was sent
+*/
+type attemptExclusions struct {
+	sync.Mutex
+	ttl      time.Duration
+	excluded map[string]*exclusion
+}
+
+// an instance stays excluded until every attempt it's working on is done
+type exclusion struct {
+	at       time.Time
+	attempts int
+}
+
+// nil if client.attempts.exclusion.ttl is 0, leaving instances to be chosen however many attempts they're working on
+func newAttemptExclusions(ttl time.Duration) *attemptExclusions {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return &attemptExclusions{ttl: ttl, excluded: make(map[string]*exclusion)}
+}
+
+func (e *attemptExclusions) exclude(uuid string) {
+	if e == nil {
+		return
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	x, ok := e.excluded[uuid]
+	if !ok {
+		x = &exclusion{}
+		e.excluded[uuid] = x
+	}
+
+	x.at = time.Now()
+	x.attempts++
+}
+
+func (e *attemptExclusions) include(uuid string) {
+	if e == nil {
+		return
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	if x, ok := e.excluded[uuid]; ok {
+		if x.attempts--; x.attempts <= 0 {
+			delete(e.excluded, uuid)
+		}
+	}
+}
+
+func (e *attemptExclusions) isExcluded(uuid string) bool {
+	if e == nil {
+		return false
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	x, ok := e.excluded[uuid]
+	return ok && time.Since(x.at) < e.ttl
+}
+
+// the number of instances currently excluded
+func (e *attemptExclusions) count() (n int) {
+	if e == nil {
+		return 0
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	for _, x := range e.excluded {
+		if time.Since(x.at) < e.ttl {
+			n++
+		}
+	}
+
+	return
+}
+
+// chooses from the LoadBalancer again while it chooses excluded instances, settling for one if there's no other
+func (c *ServiceClient) chooseAvoiding(ri *skynet.RequestInfo, ex *attemptExclusions) (s skynet.ServiceInfo, err error) {
+	for tries := ex.count(); ; tries-- {
+		if s, err = c.chooseFromLoadBalancer(ri); err != nil || tries <= 0 || !ex.isExcluded(s.UUID) {
+			return
+		}
+	}
+}
+
+func getAttemptExclusionTTL(service, version string) time.Duration {
+	if d, err := config.String(service, version, "client.attempts.exclusion.ttl"); err == nil {
+		if ttl, err := time.ParseDuration(d); err == nil {
+			return ttl
+		}
+
+		logger.Error("Failed to parse client.attempts.exclusion.ttl", err)
+	}
+
+	return config.DefaultAttemptExclusionTTL
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/config"
+	"github.com/skynetservices/skynet/test"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStalledInstanceChosenAgainAfterExclusionTTL(t *testing.T) {
+	defer resetClient()
+
+	// retries can overlap, so there's a spare instance should both the stalled and another be working on attempts
+	a, b, c := *serviceInfo(), *serviceInfo(), *serviceInfo()
+	a.UUID, b.UUID, c.UUID = config.NewUUID(), config.NewUUID(), config.NewUUID()
+	b.ServiceAddr.Port = a.ServiceAddr.Port + 1
+	c.ServiceAddr.Port = a.ServiceAddr.Port + 2
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+	sc.SetDefaultTimeout(20*time.Millisecond, 400*time.Millisecond)
+
+	ttl := 100 * time.Millisecond
+	sc.(*ServiceClient).exclusionTTL = ttl
+	sc.(*ServiceClient).retryMax = 5 * time.Millisecond
+	sc.(*ServiceClient).retryMultiplier = 2
+
+	// the failing instances mustn't be removed by their breakers, leaving only the stalled one to choose
+	sc.(*ServiceClient).failureThreshold = 0
+
+	stall := make(chan bool)
+	defer close(stall)
+
+	// the instance sent the first attempt stalls, attempts to the others fail
+	var mutex sync.Mutex
+	var stalled string
+	acquired := map[string][]time.Time{}
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if stalled == "" {
+				stalled = s.UUID
+			}
+
+			acquired[s.UUID] = append(acquired[s.UUID], time.Now())
+
+			if s.UUID == stalled {
+				return &test.Connection{
+					SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+						<-stall
+						return
+					},
+				}, nil
+			}
+
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					return conn.RemoteError{Message: "Instance failed"}
+				},
+			}, nil
+		},
+	})
+
+	for _, s := range []skynet.ServiceInfo{a, b, c} {
+		sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: s})
+	}
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 3
+	})
+
+	var val string
+	sc.Send(nil, "Foo", val, &val)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(acquired[stalled]) < 2 {
+		t.Fatal("Stalled instance should be chosen again once its exclusion expires", len(acquired[stalled]))
+	}
+
+	// the instance is excluded a little before it's acquired
+	if d := acquired[stalled][1].Sub(acquired[stalled][0]); d < ttl-ttl/10 {
+		t.Fatal("Instance working on an attempt shouldn't be chosen again until its exclusion expires", d)
+	}
+
+	if len(acquired) != 3 {
+		t.Fatal("Retries should be sent to the other instances while the stalled one is excluded", len(acquired))
+	}
+}
+
+func TestInstanceExcludedUntilEveryAttemptIsDone(t *testing.T) {
+	ex := newAttemptExclusions(time.Minute)
+
+	ex.exclude("a")
+	ex.exclude("a")
+	ex.include("a")
+
+	if !ex.isExcluded("a") {
+		t.Fatal("Instance should stay excluded while it's working on another attempt")
+	}
+
+	ex.include("a")
+
+	if ex.isExcluded("a") || ex.count() != 0 {
+		t.Fatal("Instance should be included once every attempt it was working on is done")
+	}
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+)
+
+/*
+ServiceClient.SendFallback() acts like Send, but if the request fails once its retries are exhausted or it times out,
+fallback is called with the error to populate out with a default instead, and its result returned.
+fallback isn't called if the ServiceClient is closed or draining, or out is invalid.
+*/
+func (c *ServiceClient) SendFallback(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, fallback func(err error) error) (err error) {
+	err = c.Send(ri, fn, in, out)
+
+	if err == nil || err == ServiceClientClosed || err == ClientDraining {
+		return
+	}
+
+	if _, ok := err.(InvalidOutTypeError); ok {
+		return
+	}
+
+	logger.Warn("Request failed, using fallback: ", err)
+
+	return fallback(err)
+}
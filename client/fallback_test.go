@@ -0,0 +1,60 @@
+package client
+
+import (
+	"errors"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer/roundrobin"
+	"github.com/skynetservices/skynet/config"
+	"testing"
+	"time"
+)
+
+func TestSendFallbackWhenAllInstancesFail(t *testing.T) {
+	defer resetClient()
+
+	bad1, bad2 := *serviceInfo(), *serviceInfo()
+	bad1.UUID, bad2.UUID = config.NewUUID(), config.NewUUID()
+
+	criteria := &skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}}
+
+	sc := NewServiceClient(criteria)
+	sClient := sc.(*ServiceClient)
+	sClient.loadBalancer = roundrobin.New([]skynet.ServiceInfo{})
+	sClient.failureThreshold = 0
+	sc.SetDefaultTimeout(10*time.Millisecond, 200*time.Millisecond)
+
+	counter := stubFailingPool(bad1.UUID, bad2.UUID)
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: bad1})
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: bad2})
+	waitFor(t, func() bool { return sc.InstanceCount() == 2 })
+
+	var val string
+	var fallbackErr error
+	fallbacks := 0
+
+	err := sc.SendFallback(nil, "Foo", "", &val, func(err error) error {
+		fallbacks++
+		fallbackErr = err
+		val = "default"
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal("SendFallback() should return the fallback's result", err)
+	}
+
+	if val != "default" || fallbacks != 1 {
+		t.Fatal("Fallback should populate out once", val, fallbacks)
+	}
+
+	if !errors.Is(fallbackErr, RequestTimeout) {
+		t.Fatal("Fallback should be given the request's error", fallbackErr)
+	}
+
+	if counter.count(bad1.UUID) == 0 || counter.count(bad2.UUID) == 0 {
+		t.Fatal("Fallback should only be used once every instance has been tried")
+	}
+}
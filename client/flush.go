@@ -0,0 +1,47 @@
+package client
+
+import (
+	"fmt"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/config"
+)
+
+// message sent to mux() when a connection to an instance breaks and the request is retried on another connection
+type transportFailure struct {
+	service skynet.ServiceInfo
+}
+
+/*
+this should only be called by mux()
+Once client.conn.flush.threshold consecutive requests to an instance find their connection broken, its idle connections
+are likely broken too, ex: after a network partition. They're flushed so that the requests that follow dial new connections
+rather than each finding a broken one first. The instance is only flushed once until a request to it succeeds first time
+*/
+func (c *ServiceClient) recordTransportFailure(s skynet.ServiceInfo) {
+	if _, ok := c.instances[s.UUID]; !ok || c.flushThreshold <= 0 {
+		return
+	}
+
+	c.transportFailures[s.UUID]++
+
+	if c.transportFailures[s.UUID] == c.flushThreshold {
+		logger.Warn(fmt.Sprintf("Connections to %s broke on %d consecutive requests, flushing idle connections", s.AddrString(), c.flushThreshold))
+		go getPool().Flush(s)
+	}
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) resetTransportFailures(r instanceResult) {
+	// A request that succeeded on a fresh connection says nothing of the idle ones
+	if r.err == nil && !r.retried {
+		delete(c.transportFailures, r.service.UUID)
+	}
+}
+
+func getFlushThreshold(service, version string) int {
+	if n, err := config.Int(service, version, "client.conn.flush.threshold"); err == nil {
+		return n
+	}
+
+	return config.DefaultFlushThreshold
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/client/loadbalancer/roundrobin"
+	"github.com/skynetservices/skynet/config"
+	"github.com/skynetservices/skynet/test"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBrokenConnectionsFlushedOnce(t *testing.T) {
+	defer resetClient()
+
+	si := *serviceInfo()
+	si.UUID = config.NewUUID()
+
+	criteria := &skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}}
+
+	sc := NewServiceClient(criteria)
+	sClient := sc.(*ServiceClient)
+	sClient.loadBalancer = roundrobin.New([]skynet.ServiceInfo{})
+	sClient.failureThreshold = 0
+	sClient.flushThreshold = 3
+
+	// Idle connections left broken by a network partition, until they're flushed
+	var lock sync.Mutex
+	idle, flushes := 100, 0
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			c := &test.Connection{}
+
+			if idle > 0 {
+				idle--
+				c.SendTimeoutFunc = func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					return conn.TransportError{Err: io.EOF}
+				}
+			}
+
+			return c, nil
+		},
+		FlushFunc: func(s skynet.ServiceInfo) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			idle = 0
+			flushes++
+		},
+	})
+
+	flushed := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return flushes
+	}
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: si})
+
+	var val string
+
+	waitFor(t, func() bool {
+		sc.SendOnce(nil, "Foo", val, &val)
+		return flushed() > 0
+	})
+
+	for i := 0; i < 10; i++ {
+		if err := sc.SendOnce(nil, "Foo", val, &val); err != nil {
+			t.Fatal("Requests should succeed once broken connections are flushed", err)
+		}
+	}
+
+	if flushed() != 1 {
+		t.Fatal("Broken connections should be flushed once", flushed())
+	}
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"github.com/skynetservices/skynet"
+	"testing"
+	"time"
+)
+
+func TestSendFuture(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		if i, ok := in.(int); ok {
+			*out.(*int) = i * 2
+		}
+
+		return
+	})
+
+	futures := make([]*skynet.Future, 50)
+	results := make([]int, 50)
+
+	for i := range futures {
+		futures[i] = sc.SendFuture(nil, "Double", i, &results[i])
+	}
+
+	for i, f := range futures {
+		select {
+		case <-f.Done():
+		case <-time.After(time.Second):
+			t.Fatal("Future did not complete")
+		}
+
+		if f.Err() != nil {
+			t.Fatal(f.Err())
+		}
+
+		if results[i] != i*2 {
+			t.Fatal("Future result not copied to out", i, results[i])
+		}
+	}
+}
+
+func TestCancelFuture(t *testing.T) {
+	defer resetClient()
+
+	block := make(chan bool)
+	defer close(block)
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		<-block
+		return
+	})
+
+	var val string
+	f := sc.SendFuture(nil, "Foo", val, &val)
+	f.Cancel()
+
+	select {
+	case <-f.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Cancelled Future did not complete")
+	}
+
+	if f.Err() != context.Canceled {
+		t.Fatal("Cancelled Future should return context.Canceled", f.Err())
+	}
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/config"
+	"time"
+)
+
+/*
+ServiceClient.HealthCheck() probes every known instance concurrently, each with a fresh connection that's closed once its
+handshake completes, so the connection pool isn't used. The report is keyed by the instance's address, instances that
+don't complete their handshake within client.health.timeout or before ctx is done are reported with its error
+*/
+func (c *ServiceClient) HealthCheck(ctx context.Context) map[string]skynet.InstanceHealth {
+	report := make(map[string]skynet.InstanceHealth)
+	resp := make(chan []skynet.ServiceInfo, 1)
+
+	if !c.sendMux(instancesQuery{resp: resp}) {
+		return report
+	}
+
+	instances := <-resp
+	results := make(chan skynet.InstanceHealth, len(instances))
+
+	for _, s := range instances {
+		go func(s skynet.ServiceInfo) {
+			results <- probeHealth(ctx, s, getHealthCheckTimeout(s))
+		}(s)
+	}
+
+	for range instances {
+		h := <-results
+		report[h.Instance.AddrString()] = h
+	}
+
+	return report
+}
+
+func probeHealth(ctx context.Context, s skynet.ServiceInfo, timeout time.Duration) skynet.InstanceHealth {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	probed := make(chan error, 1)
+
+	// read before the probe's started, it may outlive the health check
+	network, dialTimeout, opts := GetNetwork(), getDialTimeout(s), connectionOptions(s)
+
+	go func() {
+		cn, err := conn.NewConnectionWithOptions(s.Name, network, s.AddrString(), dialTimeout, opts)
+		if err == nil {
+			cn.Close()
+		}
+
+		probed <- err
+	}()
+
+	h := skynet.InstanceHealth{Instance: s}
+
+	select {
+	case h.Err = <-probed:
+	case <-ctx.Done():
+		h.Err = ctx.Err()
+	}
+
+	h.Healthy, h.Latency = h.Err == nil, time.Since(start)
+
+	return h
+}
+
+func getHealthCheckTimeout(s skynet.ServiceInfo) time.Duration {
+	if d, err := config.String(s.Name, s.Version, "client.health.timeout"); err == nil {
+		if timeout, err := time.ParseDuration(d); err == nil {
+			return timeout
+		}
+
+		logger.Error("Failed to parse client.health.timeout", err)
+	}
+
+	return config.DefaultHealthCheckTimeout
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/config"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckClassifiesInstances(t *testing.T) {
+	defer resetClient()
+
+	healthy, _ := listenForHandshakes(t, "TestService")
+	defer healthy.Close()
+
+	// accepts connections without ever sending its handshake
+	hung, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to bind to port for test", err)
+	}
+	defer hung.Close()
+
+	// connections are kept open until the listener is closed, rather than when they're garbage collected
+	go func() {
+		var accepted []net.Conn
+
+		for {
+			c, err := hung.Accept()
+			if err != nil {
+				break
+			}
+
+			accepted = append(accepted, c)
+		}
+
+		for _, c := range accepted {
+			c.Close()
+		}
+	}()
+
+	// nothing is listening once it's closed
+	refused, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to bind to port for test", err)
+	}
+	refused.Close()
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+	defer sc.Close()
+
+	for _, ln := range []net.Listener{healthy, hung, refused} {
+		s := *serviceInfo()
+		s.UUID = config.NewUUID()
+		s.ServiceAddr.IPAddress = "127.0.0.1"
+		s.ServiceAddr.Port = ln.Addr().(*net.TCPAddr).Port
+
+		sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: s})
+	}
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 3
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	report := sc.HealthCheck(ctx)
+
+	if len(report) != 3 {
+		t.Fatal("Every known instance should be reported", report)
+	}
+
+	if h := report[healthy.Addr().String()]; !h.Healthy || h.Err != nil {
+		t.Fatal("Instance completing its handshake should be healthy", h)
+	}
+
+	if h := report[hung.Addr().String()]; h.Healthy || h.Err != context.DeadlineExceeded {
+		t.Fatal("Instance that doesn't complete its handshake in time should be unhealthy", h)
+	}
+
+	if h := report[refused.Addr().String()]; h.Healthy || h.Err == nil {
+		t.Fatal("Instance refusing connections should be unhealthy", h)
+	}
+
+	if n := getPool().NumConnections(); n != 0 {
+		t.Fatal("Health checks shouldn't use the connection pool", n)
+	}
+}
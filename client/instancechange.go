@@ -0,0 +1,74 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"sync"
+)
+
+// message sent to mux() to replace the instance change callback
+type instanceChangeCallback struct {
+	f    func(n skynet.InstanceNotification)
+	done chan struct{}
+}
+
+/*
+ServiceClient.OnInstanceChange() calls f for each instance notification the ServiceClient handles, as instances are added,
+updated and removed, removals carry the skynet.RemovalReason. f is called from its own goroutine in the order the notifications were handled, so a slow f delays
+later notifications but not requests. Instances already known when it's called aren't reported, a nil f stops notifications
+*/
+func (c *ServiceClient) OnInstanceChange(f func(n skynet.InstanceNotification)) {
+	cb := instanceChangeCallback{f: f, done: make(chan struct{})}
+
+	if c.sendMux(cb) {
+		<-cb.done
+	}
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) setInstanceChangeCallback(cb instanceChangeCallback) {
+	defer close(cb.done)
+
+	c.instanceChange = nil
+	if cb.f != nil {
+		c.instanceChange = &instanceChangeNotifier{f: cb.f}
+	}
+}
+
+// queues notifications for f, so that mux() isn't blocked calling it
+type instanceChangeNotifier struct {
+	f func(n skynet.InstanceNotification)
+
+	mutex   sync.Mutex
+	pending []skynet.InstanceNotification
+	running bool
+}
+
+func (icn *instanceChangeNotifier) notify(n skynet.InstanceNotification) {
+	icn.mutex.Lock()
+	defer icn.mutex.Unlock()
+
+	icn.pending = append(icn.pending, n)
+
+	if !icn.running {
+		icn.running = true
+		go icn.run()
+	}
+}
+
+// calls f with each pending notification, exiting once there are none
+func (icn *instanceChangeNotifier) run() {
+	for {
+		icn.mutex.Lock()
+		if len(icn.pending) == 0 {
+			icn.running = false
+			icn.mutex.Unlock()
+			return
+		}
+
+		n := icn.pending[0]
+		icn.pending = icn.pending[1:]
+		icn.mutex.Unlock()
+
+		icn.f(n)
+	}
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/config"
+	"testing"
+	"time"
+)
+
+func TestOnInstanceChange(t *testing.T) {
+	defer resetClient()
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+
+	events := make(chan skynet.InstanceNotification, 2)
+	sc.OnInstanceChange(func(n skynet.InstanceNotification) {
+		events <- n
+	})
+
+	si := *serviceInfo()
+	si.UUID = config.NewUUID()
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: si})
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceRemoved, Service: si})
+
+	for _, expected := range []int{skynet.InstanceAdded, skynet.InstanceRemoved} {
+		select {
+		case n := <-events:
+			if n.Type != expected || n.Service.UUID != si.UUID {
+				t.Fatal("OnInstanceChange() callback received an unexpected notification", n)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("OnInstanceChange() callback was not called")
+		}
+	}
+}
+
+func TestOnInstanceChangeDoesNotBlockMux(t *testing.T) {
+	defer resetClient()
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+
+	block := make(chan struct{})
+	defer close(block)
+
+	sc.OnInstanceChange(func(n skynet.InstanceNotification) {
+		<-block
+	})
+
+	si := *serviceInfo()
+	si.UUID = config.NewUUID()
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: si})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 1
+	})
+}
@@ -0,0 +1,220 @@
+package client
+
+import (
+	"context"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/pools"
+	"sort"
+)
+
+// message sent to mux() for the number of available instances
+type instanceCountQuery struct {
+	resp chan int
+}
+
+// message sent to mux() for a copy of the known instances
+type instancesQuery struct {
+	resp chan []skynet.ServiceInfo
+}
+
+// message sent to mux() for a snapshot of the known instances, without their pool stats
+type instanceInfoQuery struct {
+	resp chan []skynet.InstanceInfo
+}
+
+// message sent to mux() to be told once at least min instances are available
+type instanceWaiter struct {
+	min   int
+	ready chan struct{}
+}
+
+// message sent to mux() when a caller stops waiting for instances
+type instanceWaiterCancelled struct {
+	ready chan struct{}
+}
+
+/*
+ServiceClient.InstanceCount() returns the number of instances requests can currently be sent to, instances that
+are unregistered, evicted or excluded after repeated failures aren't counted
+*/
+func (c *ServiceClient) InstanceCount() int {
+	resp := make(chan int, 1)
+
+	if !c.sendMux(instanceCountQuery{resp: resp}) {
+		return 0
+	}
+
+	return <-resp
+}
+
+/*
+ServiceClient.PoolStats() returns statistics for the connection pool to each known instance, keyed by the instance's address
+*/
+func (c *ServiceClient) PoolStats() map[string]pools.Stats {
+	resp := make(chan []skynet.ServiceInfo, 1)
+
+	if !c.sendMux(instancesQuery{resp: resp}) {
+		return map[string]pools.Stats{}
+	}
+
+	// taken outside of mux() so sends aren't held up while the pools are queried
+	return getPool().Stats(<-resp...)
+}
+
+/*
+ServiceClient.ListInstances() returns a snapshot of every known instance ordered by address, whether or not requests
+can currently be sent to it, with the state of its connection pool
+*/
+func (c *ServiceClient) ListInstances() []skynet.InstanceInfo {
+	resp := make(chan []skynet.InstanceInfo, 1)
+
+	if !c.sendMux(instanceInfoQuery{resp: resp}) {
+		return []skynet.InstanceInfo{}
+	}
+
+	instances := <-resp
+	services := make([]skynet.ServiceInfo, len(instances))
+
+	for i := range instances {
+		services[i] = instances[i].ServiceInfo
+	}
+
+	// taken outside of mux() so sends aren't held up while the pools are queried
+	stats := getPool().Stats(services...)
+
+	for i := range instances {
+		instances[i].Pool = stats[instances[i].AddrString()]
+	}
+
+	sort.Sort(instancesByAddr(instances))
+
+	return instances
+}
+
+type instancesByAddr []skynet.InstanceInfo
+
+func (s instancesByAddr) Len() int           { return len(s) }
+func (s instancesByAddr) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s instancesByAddr) Less(i, j int) bool { return s[i].AddrString() < s[j].AddrString() }
+
+/*
+ServiceClient.WaitForInstances() blocks until at least min instances are available to send requests to,
+returning ctx.Err() if ctx is done first
+*/
+func (c *ServiceClient) WaitForInstances(ctx context.Context, min int) error {
+	w := instanceWaiter{min: min, ready: make(chan struct{})}
+
+	if !c.sendMux(w) {
+		return ServiceClientClosed
+	}
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-c.done:
+		return ServiceClientClosed
+	case <-ctx.Done():
+		c.sendMux(instanceWaiterCancelled{ready: w.ready})
+		return ctx.Err()
+	}
+}
+
+/*
+ServiceClient.Ready() returns a channel that's closed once the first instance of the service is discovered,
+it stays closed if the instances are removed again. Use WaitForInstances() to wait for more than one
+*/
+func (c *ServiceClient) Ready() <-chan struct{} {
+	return c.ready
+}
+
+// returns a known instance at addr, whether or not requests can currently be sent to it
+func (c *ServiceClient) instanceAt(addr string) (s skynet.ServiceInfo, ok bool) {
+	resp := make(chan []skynet.ServiceInfo, 1)
+
+	if !c.sendMux(instancesQuery{resp: resp}) {
+		return
+	}
+
+	for _, s = range <-resp {
+		if s.AddrString() == addr {
+			return s, true
+		}
+	}
+
+	return skynet.ServiceInfo{}, false
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) availableInstances() (count int) {
+	for uuid, s := range c.instances {
+		if s.Registered && !c.isBreakerOpen(uuid) && !c.isEvicted(uuid) {
+			count++
+		}
+	}
+
+	return
+}
+
+// this should only be called by mux(), after anything that may change the available instances
+func (c *ServiceClient) notifyInstanceWaiters() {
+	if len(c.instanceWaiters) == 0 {
+		return
+	}
+
+	count := c.availableInstances()
+	waiting := c.instanceWaiters[:0]
+
+	for _, w := range c.instanceWaiters {
+		if count >= w.min {
+			close(w.ready)
+		} else {
+			waiting = append(waiting, w)
+		}
+	}
+
+	c.instanceWaiters = waiting
+}
+
+// this should only be called by mux(), after an instance is added
+func (c *ServiceClient) markReady() {
+	select {
+	case <-c.ready:
+	default:
+		close(c.ready)
+	}
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) removeInstanceWaiter(ready chan struct{}) {
+	for i, w := range c.instanceWaiters {
+		if w.ready == ready {
+			c.instanceWaiters = append(c.instanceWaiters[:i], c.instanceWaiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// this should only be called by mux()
+// this should only be called by mux()
+func (c *ServiceClient) instanceInfoList() []skynet.InstanceInfo {
+	instances := make([]skynet.InstanceInfo, 0, len(c.instances))
+
+	for uuid, s := range c.instances {
+		instances = append(instances, skynet.InstanceInfo{
+			ServiceInfo: s,
+			Available:   s.Registered && !c.isBreakerOpen(uuid) && !c.isEvicted(uuid),
+		})
+	}
+
+	return instances
+}
+
+func (c *ServiceClient) instanceList() []skynet.ServiceInfo {
+	instances := make([]skynet.ServiceInfo, 0, len(c.instances))
+
+	for _, s := range c.instances {
+		instances = append(instances, s)
+	}
+
+	return instances
+}
@@ -0,0 +1,249 @@
+package client
+
+import (
+	"context"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/config"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestInstanceCount(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("TestService", "", "", "")
+
+	registered, unregistered := *serviceInfo(), *serviceInfo()
+	registered.UUID, unregistered.UUID = config.NewUUID(), config.NewUUID()
+	unregistered.Registered = false
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: registered})
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: unregistered})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 1
+	})
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceRemoved, Service: registered})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 0
+	})
+}
+
+func TestWaitForInstances(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("TestService", "", "", "")
+
+	ready := make(chan error)
+	go func() {
+		ready <- sc.WaitForInstances(context.Background(), 2)
+	}()
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: *serviceInfo()})
+
+	select {
+	case <-ready:
+		t.Fatal("WaitForInstances() returned before enough instances were available")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	si := serviceInfo()
+	si.UUID = config.NewUUID()
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: *si})
+
+	select {
+	case err := <-ready:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitForInstances() did not return once enough instances were available")
+	}
+}
+
+func TestWaitForInstancesContextDone(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("TestService", "", "", "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := sc.WaitForInstances(ctx, 1); err != context.DeadlineExceeded {
+		t.Fatal("WaitForInstances() should return ctx.Err() when ctx is done", err)
+	}
+
+	// queries are handled in order, so the cancellation has been handled once this returns
+	sc.InstanceCount()
+
+	if n := len(sc.(*ServiceClient).instanceWaiters); n != 0 {
+		t.Fatal("Cancelled waiter should be removed", n)
+	}
+}
+
+func TestReadyClosesOnFirstInstance(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("TestService", "", "", "")
+
+	// queries are handled by mux() too, so it has started without closing Ready()
+	sc.InstanceCount()
+
+	select {
+	case <-sc.Ready():
+		t.Fatal("Ready() should not be closed before an instance is discovered")
+	default:
+	}
+
+	si := serviceInfo()
+	si.UUID = config.NewUUID()
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: *si})
+
+	select {
+	case <-sc.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("Ready() should be closed once an instance is discovered")
+	}
+
+	if sc.InstanceCount() != 1 {
+		t.Fatal("Ready() should only be closed once the instance has been added")
+	}
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceRemoved, Service: *si})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 0
+	})
+
+	select {
+	case <-sc.Ready():
+	default:
+		t.Fatal("Ready() should stay closed once instances are removed")
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	defer resetClient()
+
+	ln, _ := listenForHandshakes(t, "TestService")
+	defer ln.Close()
+
+	p := NewPool()
+	setPool(p)
+	defer p.Close()
+
+	si := serviceInfo()
+	si.ServiceAddr.IPAddress = "127.0.0.1"
+	si.ServiceAddr.Port = ln.Addr().(*net.TCPAddr).Port
+
+	unknown := serviceInfo()
+	unknown.UUID = config.NewUUID()
+
+	sc := GetService("TestService", "", "", "")
+	p.AddInstance(*si)
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: *si})
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: *unknown})
+
+	waitFor(t, func() bool {
+		return p.NumInstances() == 1 && sc.InstanceCount() == 2
+	})
+
+	c1, err := p.Acquire(*si)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c2, err := p.Acquire(*si)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Release(c2)
+
+	p.Release(c1)
+
+	waitFor(t, func() bool {
+		return sc.PoolStats()[si.AddrString()].InUse == 1
+	})
+
+	stats := sc.PoolStats()
+
+	if len(stats) != 1 {
+		t.Fatal("PoolStats() should only report instances known to the pool", stats)
+	}
+
+	if s := stats[si.AddrString()]; s.Resources != 2 || s.Idle != 1 || s.Acquired != 2 || s.Failed != 0 {
+		t.Fatal("PoolStats() reported unexpected values", s)
+	}
+}
+
+func TestListInstances(t *testing.T) {
+	defer resetClient()
+
+	ln, _ := listenForHandshakes(t, "TestService")
+	defer ln.Close()
+
+	p := NewPool()
+	setPool(p)
+	defer p.Close()
+
+	a, b, c := *serviceInfo(), *serviceInfo(), *serviceInfo()
+	a.UUID, b.UUID, c.UUID = config.NewUUID(), config.NewUUID(), config.NewUUID()
+	a.ServiceAddr.IPAddress = "127.0.0.1"
+	a.ServiceAddr.Port = ln.Addr().(*net.TCPAddr).Port
+	b.ServiceAddr.Port = a.ServiceAddr.Port + 1
+	c.ServiceAddr.Port = a.ServiceAddr.Port + 2
+	c.Registered = false
+	c.Labels = map[string]string{"zone": "b"}
+
+	sc := GetService("TestService", "", "", "")
+	p.AddInstance(a)
+
+	for _, s := range []skynet.ServiceInfo{a, b, c} {
+		sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: s})
+	}
+
+	waitFor(t, func() bool {
+		return p.NumInstances() == 1 && len(sc.ListInstances()) == 3
+	})
+
+	conn, err := p.Acquire(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Release(conn)
+
+	instances := sc.ListInstances()
+
+	for i, s := range []skynet.ServiceInfo{a, b, c} {
+		if instances[i].UUID != s.UUID || instances[i].AddrString() != s.AddrString() {
+			t.Fatal("ListInstances() should list every instance ordered by address", instances)
+		}
+
+		if instances[i].Available != s.Registered {
+			t.Fatal("ListInstances() should report whether requests can be sent to the instance", instances[i])
+		}
+	}
+
+	if instances[0].Pool.InUse != 1 || instances[1].Pool.Resources != 0 {
+		t.Fatal("ListInstances() should report the instance's pool stats", instances[0].Pool, instances[1].Pool)
+	}
+
+	if instances[2].Labels["zone"] != "b" {
+		t.Fatal("ListInstances() should report the instance's config", instances[2])
+	}
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceRemoved, Service: b})
+
+	waitFor(t, func() bool {
+		return len(sc.ListInstances()) == 2
+	})
+
+	instances = sc.ListInstances()
+
+	if instances[0].UUID != a.UUID || instances[1].UUID != c.UUID {
+		t.Fatal("ListInstances() shouldn't list removed instances", instances)
+	}
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+)
+
+/*
+client.Interceptor wraps every request sent by a ServiceClient, it's called with the request before it's sent and
+next sends it, including any retries, returning its error. Interceptors may change ri, wrap the error returned
+by next, or return without calling next to fail the request without it being sent.
+ri is a copy made for this request, changes to it aren't seen by the caller.
+Implementations must be safe to call from multiple goroutines.
+*/
+type Interceptor func(ri *skynet.RequestInfo, fn string, in interface{}, next func() error) error
+
+var interceptors []Interceptor
+
+/*
+client.SetInterceptors() wraps requests with interceptors, the first is outermost so it's called first and
+returns last. SendBatch() requests aren't intercepted
+*/
+func SetInterceptors(i ...Interceptor) {
+	interceptors = i
+}
+
+func intercept(chain []Interceptor, ri *skynet.RequestInfo, fn string, in interface{}, send func() error) error {
+	if len(chain) == 0 {
+		return send()
+	}
+
+	return chain[0](ri, fn, in, func() error {
+		return intercept(chain[1:], ri, fn, in, send)
+	})
+}
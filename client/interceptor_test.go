@@ -0,0 +1,100 @@
+package client
+
+import (
+	"errors"
+	"github.com/skynetservices/skynet"
+	"sync"
+	"testing"
+)
+
+func TestInterceptorsWrapSend(t *testing.T) {
+	defer resetClient()
+
+	var mutex sync.Mutex
+	var calls []string
+
+	record := func(s string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		calls = append(calls, s)
+	}
+
+	var sentWith string
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		record("send")
+		sentWith = ri.AffinityKey
+		return
+	})
+
+	SetInterceptors(
+		func(ri *skynet.RequestInfo, fn string, in interface{}, next func() error) error {
+			record("first")
+			defer record("first done")
+
+			return next()
+		},
+		func(ri *skynet.RequestInfo, fn string, in interface{}, next func() error) error {
+			record("second")
+			defer record("second done")
+
+			ri.AffinityKey = "changed"
+			return next()
+		},
+	)
+
+	caller := &skynet.RequestInfo{}
+	var val string
+
+	if err := sc.Send(caller, "Foo", val, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"first", "second", "send", "second done", "first done"}
+	if len(calls) != len(expected) {
+		t.Fatal("Interceptors should wrap the request in order", calls)
+	}
+
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Fatal("Interceptors should wrap the request in order", calls)
+		}
+	}
+
+	if sentWith != "changed" {
+		t.Fatal("Changes to RequestInfo by interceptors should be sent", sentWith)
+	}
+
+	if caller.AffinityKey != "" {
+		t.Fatal("Changes to RequestInfo by interceptors should not be seen by the caller")
+	}
+}
+
+func TestInterceptorShortCircuitsSend(t *testing.T) {
+	defer resetClient()
+
+	sent := false
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		sent = true
+		return
+	})
+
+	unauthorized := errors.New("Unauthorized")
+
+	SetInterceptors(func(ri *skynet.RequestInfo, fn string, in interface{}, next func() error) error {
+		return unauthorized
+	})
+
+	var val string
+	if err := sc.Send(nil, "Foo", val, &val); err != unauthorized {
+		t.Fatal("The interceptor's error should be returned", err)
+	}
+
+	if sent {
+		t.Fatal("Requests should not be sent when an interceptor doesn't call next")
+	}
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"github.com/skynetservices/skynet/config"
+)
+
+// message sent to mux() to choose the instance for a request with a LabelSelector
+type labelQuery struct {
+	ri   *skynet.RequestInfo
+	resp chan labelResult
+}
+
+type labelResult struct {
+	service skynet.ServiceInfo
+	err     error
+}
+
+/*
+this should only be called by mux()
+Requests with a LabelSelector are sent in turn to the registered instances with all of its labels that aren't excluded
+after repeated failures, bypassing the LoadBalancer. If none match they're sent to an instance chosen by the LoadBalancer
+when client.labels.fallback is set or they were routed to canary instances, otherwise NoMatchingInstance is returned
+*/
+func (c *ServiceClient) labelInstance(ri *skynet.RequestInfo) (s skynet.ServiceInfo, err error) {
+	selector := skynet.ParseLabels(ri.LabelSelector)
+
+	var matching []skynet.ServiceInfo
+	for uuid, s := range c.instances {
+		if s.Registered && !c.isExcluded(uuid) && s.MatchesLabels(selector) {
+			matching = append(matching, s)
+		}
+	}
+
+	if len(matching) == 0 {
+		if c.labelFallback || c.canary.routes(ri) {
+			return c.chooseFromLoadBalancer(ri)
+		}
+
+		return s, NoMatchingInstance
+	}
+
+	// ordered so the turns don't depend on map iteration
+	loadbalancer.SortByUUID(matching)
+
+	s = matching[c.labelNext%len(matching)]
+	c.labelNext++
+
+	return
+}
+
+func getLabelFallback(service, version string) bool {
+	if b, err := config.Bool(service, version, "client.labels.fallback"); err == nil {
+		return b
+	}
+
+	return config.DefaultLabelFallback
+}
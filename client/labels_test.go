@@ -0,0 +1,92 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/config"
+	"testing"
+)
+
+func TestLabelSelectorRestrictsInstances(t *testing.T) {
+	defer resetClient()
+
+	gpu, premium, plain := *serviceInfo(), *serviceInfo(), *serviceInfo()
+	gpu.UUID, premium.UUID, plain.UUID = config.NewUUID(), config.NewUUID(), config.NewUUID()
+	gpu.Labels = skynet.ParseLabels("gpu=true")
+	premium.Labels = skynet.ParseLabels("gpu=true, tier=premium")
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+
+	counter := stubFailingPool()
+
+	for _, s := range []skynet.ServiceInfo{gpu, premium, plain} {
+		sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: s})
+	}
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 3
+	})
+
+	send := func(selector string) error {
+		var val string
+		return sc.Send(&skynet.RequestInfo{LabelSelector: selector}, "Foo", val, &val)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := send("gpu=true"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if counter.count(gpu.UUID) != 5 || counter.count(premium.UUID) != 5 || counter.count(plain.UUID) != 0 {
+		t.Fatal("Requests should be spread across only the instances matching the selector", counter.count(gpu.UUID), counter.count(premium.UUID), counter.count(plain.UUID))
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := send("gpu,tier=premium"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if counter.count(premium.UUID) != 8 {
+		t.Fatal("Requests should only be sent to instances with every label in the selector", counter.count(premium.UUID))
+	}
+
+	if err := send("tier=free"); err != NoMatchingInstance {
+		t.Fatal("Requests no instance matches should return NoMatchingInstance", err)
+	}
+
+	if counter.count(gpu.UUID)+counter.count(premium.UUID)+counter.count(plain.UUID) != 13 {
+		t.Fatal("Requests no instance matches shouldn't be sent")
+	}
+}
+
+func TestLabelSelectorFallsBackToAnyInstance(t *testing.T) {
+	defer resetClient()
+
+	si := serviceInfo()
+	si.UUID = config.NewUUID()
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+	sc.(*ServiceClient).labelFallback = true
+
+	counter := stubFailingPool()
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: *si})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 1
+	})
+
+	var val string
+	if err := sc.Send(&skynet.RequestInfo{LabelSelector: "gpu=true"}, "Foo", val, &val); err != nil {
+		t.Fatal("Requests no instance matches should fall back to any instance with client.labels.fallback set", err)
+	}
+
+	if counter.count(si.UUID) != 1 {
+		t.Fatal("Request should have fallen back to the unlabeled instance", counter.count(si.UUID))
+	}
+}
@@ -0,0 +1,33 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"time"
+)
+
+// weight of each response in an instance's average latency, so it follows changes within a few requests
+const latencyWeight = 0.3
+
+/*
+this should only be called by mux()
+Each instance's average latency (an exponentially weighted moving average) is updated from the requests it served,
+and reported to the LoadBalancer if it's a loadbalancer.LatencyObserver
+*/
+func (c *ServiceClient) recordLatency(r instanceResult) {
+	if r.err != nil || r.duration <= 0 {
+		return
+	}
+
+	avg, ok := c.latencies[r.service.UUID]
+	if ok {
+		avg += time.Duration(latencyWeight * float64(r.duration-avg))
+	} else {
+		avg = r.duration
+	}
+
+	c.latencies[r.service.UUID] = avg
+
+	if lo, ok := c.loadBalancer.(loadbalancer.LatencyObserver); ok {
+		lo.ObserveLatency(c.instances[r.service.UUID], avg)
+	}
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/client/loadbalancer/latency"
+	"github.com/skynetservices/skynet/config"
+	"github.com/skynetservices/skynet/test"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLatencyAwareBalancerAvoidsSlowInstance(t *testing.T) {
+	defer resetClient()
+
+	slow, fast := *serviceInfo(), *serviceInfo()
+	slow.UUID, fast.UUID = config.NewUUID(), config.NewUUID()
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+	sClient := sc.(*ServiceClient)
+	sClient.loadBalancer = latency.New([]skynet.ServiceInfo{})
+
+	var mutex sync.Mutex
+	sent := make(map[string]int)
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					mutex.Lock()
+					sent[s.UUID]++
+					mutex.Unlock()
+
+					if s.UUID == slow.UUID {
+						time.Sleep(20 * time.Millisecond)
+					}
+
+					return
+				},
+			}, nil
+		},
+	})
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: slow})
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: fast})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 2
+	})
+
+	const requests = 40
+
+	var val string
+	for i := 0; i < requests; i++ {
+		if err := sc.SendOnce(nil, "Foo", val, &val); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// latencies are recorded after each response, so a request may go out before the last one was
+	if sent[slow.UUID] > requests/10 {
+		t.Fatal("The slow instance should be sent less traffic once its latency is known", sent[slow.UUID], sent[fast.UUID])
+	}
+}
@@ -0,0 +1,166 @@
+package canary
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"sync"
+	"time"
+)
+
+type LoadBalancer struct {
+	selector map[string]string
+
+	// instances with the canary labels are only chosen from canaries while there are no stable instances
+	stable   loadbalancer.LoadBalancer
+	canaries loadbalancer.LoadBalancer
+
+	isCanary      map[string]bool
+	instanceMutex sync.Mutex
+}
+
+/*
+* NewFactory() returns a loadbalancer.Factory that creates Canary LoadBalancers passing over instances with all the labels
+* in selector, instances are balanced by LoadBalancers from factory, ex: canary.NewFactory(skynet.ParseLabels("canary=true"), roundrobin.New)
+ */
+func NewFactory(selector map[string]string, factory loadbalancer.Factory) loadbalancer.Factory {
+	return func(instances []skynet.ServiceInfo) loadbalancer.LoadBalancer {
+		return New(selector, factory, instances)
+	}
+}
+
+/*
+* New() returns a new Canary LoadBalancer, canary instances are only chosen while there are no others. Requests meant for
+* canaries are sent to them by their LabelSelector, so they're kept from the share of requests that aren't
+ */
+func New(selector map[string]string, factory loadbalancer.Factory, instances []skynet.ServiceInfo) loadbalancer.LoadBalancer {
+	lb := &LoadBalancer{
+		selector: selector,
+		stable:   factory([]skynet.ServiceInfo{}),
+		canaries: factory([]skynet.ServiceInfo{}),
+		isCanary: make(map[string]bool),
+	}
+
+	for _, i := range instances {
+		lb.AddInstance(i)
+	}
+
+	return lb
+}
+
+func (lb *LoadBalancer) AddInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.isCanary[s.UUID] = s.MatchesLabels(lb.selector)
+	lb.balancerFor(s.UUID).AddInstance(s)
+}
+
+func (lb *LoadBalancer) UpdateInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	canary, ok := lb.isCanary[s.UUID]
+
+	// The instance has been relabelled
+	if ok && canary != s.MatchesLabels(lb.selector) {
+		lb.balancerFor(s.UUID).RemoveInstance(s)
+		lb.isCanary[s.UUID] = !canary
+		lb.balancerFor(s.UUID).AddInstance(s)
+		return
+	}
+
+	lb.isCanary[s.UUID] = s.MatchesLabels(lb.selector)
+	lb.balancerFor(s.UUID).UpdateInstance(s)
+}
+
+func (lb *LoadBalancer) RemoveInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if _, ok := lb.isCanary[s.UUID]; !ok {
+		return
+	}
+
+	lb.balancerFor(s.UUID).RemoveInstance(s)
+	delete(lb.isCanary, s.UUID)
+}
+
+func (lb *LoadBalancer) Choose() (s skynet.ServiceInfo, err error) {
+	if s, err = lb.stable.Choose(); err == loadbalancer.NoInstances {
+		return lb.canaries.Choose()
+	}
+
+	return
+}
+
+/*
+* LoadBalancer.ChooseKey() routes by key if the underlying LoadBalancers support it
+ */
+func (lb *LoadBalancer) ChooseKey(key string) (s skynet.ServiceInfo, err error) {
+	stable, ok := lb.stable.(loadbalancer.KeyChooser)
+	if !ok {
+		return lb.Choose()
+	}
+
+	if s, err = stable.ChooseKey(key); err == loadbalancer.NoInstances {
+		return lb.canaries.(loadbalancer.KeyChooser).ChooseKey(key)
+	}
+
+	return
+}
+
+/*
+* LoadBalancer.ObserveLatency() passes latencies on if the underlying LoadBalancers observe them
+ */
+func (lb *LoadBalancer) ObserveLatency(s skynet.ServiceInfo, avg time.Duration) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if _, ok := lb.isCanary[s.UUID]; !ok {
+		return
+	}
+
+	if lo, ok := lb.balancerFor(s.UUID).(loadbalancer.LatencyObserver); ok {
+		lo.ObserveLatency(s, avg)
+	}
+}
+
+/*
+* LoadBalancer.Admits() admits canary instances only while there are no others
+ */
+func (lb *LoadBalancer) Admits(s skynet.ServiceInfo) bool {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if !s.MatchesLabels(lb.selector) {
+		return loadbalancer.Admits(lb.stable, s)
+	}
+
+	for _, canary := range lb.isCanary {
+		if !canary {
+			return false
+		}
+	}
+
+	return loadbalancer.Admits(lb.canaries, s)
+}
+
+/*
+* LoadBalancer.Seed() seeds the underlying LoadBalancers if they choose at random
+ */
+func (lb *LoadBalancer) Seed(seed int64) {
+	for _, b := range []loadbalancer.LoadBalancer{lb.stable, lb.canaries} {
+		if sd, ok := b.(loadbalancer.Seeder); ok {
+			sd.Seed(seed)
+		}
+	}
+}
+
+// instanceMutex must be held
+func (lb *LoadBalancer) balancerFor(uuid string) loadbalancer.LoadBalancer {
+	if lb.isCanary[uuid] {
+		return lb.canaries
+	}
+
+	return lb.stable
+}
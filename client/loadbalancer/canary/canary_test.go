@@ -0,0 +1,71 @@
+package canary
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"github.com/skynetservices/skynet/client/loadbalancer/roundrobin"
+	"github.com/skynetservices/skynet/config"
+	"testing"
+)
+
+func TestCanariesNotChosenWhileStableInstancesExist(t *testing.T) {
+	stable, canary := serviceInfo(""), serviceInfo("canary=true")
+	lb := New(skynet.ParseLabels("canary=true"), roundrobin.New, []skynet.ServiceInfo{stable, canary})
+
+	for i := 0; i < 10; i++ {
+		if s, _ := lb.Choose(); s.UUID != stable.UUID {
+			t.Fatal("Canary instances should not be chosen while there are stable instances")
+		}
+	}
+
+	lb.RemoveInstance(stable)
+
+	if s, err := lb.Choose(); err != nil || s.UUID != canary.UUID {
+		t.Fatal("Canary instances should be chosen while there are no stable instances", err)
+	}
+
+	lb.RemoveInstance(canary)
+
+	if _, err := lb.Choose(); err != loadbalancer.NoInstances {
+		t.Fatal("LoadBalancer should fail if no instances exist", err)
+	}
+}
+
+func TestUpdateMovesRelabelledInstance(t *testing.T) {
+	si := serviceInfo("canary=true")
+	lb := New(skynet.ParseLabels("canary=true"), roundrobin.New, []skynet.ServiceInfo{si}).(*LoadBalancer)
+
+	si.Labels = map[string]string{}
+	lb.UpdateInstance(si)
+
+	if _, err := lb.canaries.Choose(); err != loadbalancer.NoInstances {
+		t.Fatal("Instance should be removed from the canaries once it loses the canary labels")
+	}
+
+	if s, err := lb.stable.Choose(); err != nil || s.UUID != si.UUID {
+		t.Fatal("Instance should be stable once it loses the canary labels", err)
+	}
+}
+
+func TestAdmitsCanariesOnlyWithoutStableInstances(t *testing.T) {
+	stable, canary := serviceInfo(""), serviceInfo("canary=true")
+	lb := New(skynet.ParseLabels("canary=true"), roundrobin.New, nil).(*LoadBalancer)
+
+	if !lb.Admits(canary) {
+		t.Fatal("Canary instances should be admitted while there are no stable instances")
+	}
+
+	lb.AddInstance(stable)
+	if lb.Admits(canary) || !lb.Admits(stable) {
+		t.Fatal("Only stable instances should be admitted while there are any")
+	}
+}
+
+func serviceInfo(labels string) skynet.ServiceInfo {
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.UUID = config.NewUUID()
+	si.Registered = true
+	si.Labels = skynet.ParseLabels(labels)
+
+	return *si
+}
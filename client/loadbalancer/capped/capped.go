@@ -0,0 +1,169 @@
+package capped
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"sync"
+	"time"
+)
+
+// Counter returns the number of requests in flight to an instance
+type Counter func(s skynet.ServiceInfo) int
+
+type LoadBalancer struct {
+	max     int
+	counter Counter
+	inner   loadbalancer.LoadBalancer
+
+	instances     map[string]skynet.ServiceInfo
+	instanceMutex sync.Mutex
+}
+
+/*
+* NewFactory() returns a loadbalancer.Factory that creates Capped LoadBalancers passing over instances with max requests
+* in flight, instances are balanced by LoadBalancers from factory, ex: capped.NewFactory(10, client.InFlightRequests, roundrobin.New)
+ */
+func NewFactory(max int, counter Counter, factory loadbalancer.Factory) loadbalancer.Factory {
+	return func(instances []skynet.ServiceInfo) loadbalancer.LoadBalancer {
+		return New(max, counter, factory, instances)
+	}
+}
+
+/*
+* New() returns a new Capped LoadBalancer, loadbalancer.AllInstancesSaturated is returned while every instance has max
+* requests in flight
+ */
+func New(max int, counter Counter, factory loadbalancer.Factory, instances []skynet.ServiceInfo) loadbalancer.LoadBalancer {
+	lb := &LoadBalancer{
+		max:       max,
+		counter:   counter,
+		inner:     factory([]skynet.ServiceInfo{}),
+		instances: make(map[string]skynet.ServiceInfo),
+	}
+
+	for _, i := range instances {
+		lb.AddInstance(i)
+	}
+
+	return lb
+}
+
+func (lb *LoadBalancer) AddInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.instances[s.UUID] = s
+	lb.inner.AddInstance(s)
+}
+
+func (lb *LoadBalancer) UpdateInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.instances[s.UUID] = s
+	lb.inner.UpdateInstance(s)
+}
+
+func (lb *LoadBalancer) RemoveInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	delete(lb.instances, s.UUID)
+	lb.inner.RemoveInstance(s)
+}
+
+/*
+* LoadBalancer.Choose() asks the underlying LoadBalancer again while it chooses saturated instances, once it has had
+* a turn for each instance the least loaded one under the cap is chosen instead
+ */
+func (lb *LoadBalancer) Choose() (s skynet.ServiceInfo, err error) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if len(lb.instances) == 0 {
+		return s, loadbalancer.NoInstances
+	}
+
+	for i := 0; i < len(lb.instances); i++ {
+		if s, err = lb.inner.Choose(); err != nil || !lb.saturated(s) {
+			return
+		}
+	}
+
+	return lb.leastLoaded()
+}
+
+/*
+* LoadBalancer.ChooseKey() routes by key if the underlying LoadBalancer supports it, requests are only sent elsewhere
+* while the instance for their key is saturated
+ */
+func (lb *LoadBalancer) ChooseKey(key string) (s skynet.ServiceInfo, err error) {
+	kc, ok := lb.inner.(loadbalancer.KeyChooser)
+	if !ok {
+		return lb.Choose()
+	}
+
+	lb.instanceMutex.Lock()
+	s, err = kc.ChooseKey(key)
+	saturated := err == nil && lb.saturated(s)
+	lb.instanceMutex.Unlock()
+
+	if saturated {
+		return lb.Choose()
+	}
+
+	return
+}
+
+/*
+* LoadBalancer.ObserveLatency() passes latencies on if the underlying LoadBalancer observes them
+ */
+func (lb *LoadBalancer) ObserveLatency(s skynet.ServiceInfo, avg time.Duration) {
+	if lo, ok := lb.inner.(loadbalancer.LatencyObserver); ok {
+		lo.ObserveLatency(s, avg)
+	}
+}
+
+/*
+* LoadBalancer.Admits() admits instances under the cap that the underlying LoadBalancer admits
+ */
+func (lb *LoadBalancer) Admits(s skynet.ServiceInfo) bool {
+	return !lb.saturated(s) && loadbalancer.Admits(lb.inner, s)
+}
+
+/*
+* LoadBalancer.Seed() seeds the underlying LoadBalancer if it chooses at random
+ */
+func (lb *LoadBalancer) Seed(seed int64) {
+	if sd, ok := lb.inner.(loadbalancer.Seeder); ok {
+		sd.Seed(seed)
+	}
+}
+
+func (lb *LoadBalancer) saturated(s skynet.ServiceInfo) bool {
+	return lb.counter(s) >= lb.max
+}
+
+// instanceMutex must be held
+func (lb *LoadBalancer) leastLoaded() (s skynet.ServiceInfo, err error) {
+	var candidates []skynet.ServiceInfo
+	for _, i := range lb.instances {
+		if i.Registered {
+			candidates = append(candidates, i)
+		}
+	}
+
+	// ordered so ties don't depend on map iteration
+	loadbalancer.SortByUUID(candidates)
+
+	min := lb.max
+	err = loadbalancer.AllInstancesSaturated
+
+	for _, i := range candidates {
+		if n := lb.counter(i); n < min {
+			s, err, min = i, nil, n
+		}
+	}
+
+	return
+}
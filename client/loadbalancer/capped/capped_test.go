@@ -0,0 +1,100 @@
+package capped
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"github.com/skynetservices/skynet/client/loadbalancer/consistenthash"
+	"github.com/skynetservices/skynet/client/loadbalancer/roundrobin"
+	"github.com/skynetservices/skynet/config"
+	"testing"
+)
+
+func TestChooseReturnsErrorWhenEmpty(t *testing.T) {
+	lb := New(1, inFlight{}.count, roundrobin.New, []skynet.ServiceInfo{})
+
+	if _, err := lb.Choose(); err != loadbalancer.NoInstances {
+		t.Fatal("LoadBalancer should fail if no instances exist")
+	}
+}
+
+func TestSaturatedInstancesPassedOver(t *testing.T) {
+	busy, idle, other := serviceInfo(), serviceInfo(), serviceInfo()
+	requests := inFlight{busy.UUID: 2}
+
+	lb := New(2, requests.count, roundrobin.New, []skynet.ServiceInfo{busy, idle, other})
+
+	chosen := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		s, err := lb.Choose()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		chosen[s.UUID]++
+	}
+
+	if chosen[busy.UUID] != 0 || chosen[idle.UUID] == 0 || chosen[other.UUID] == 0 {
+		t.Fatal("Requests should be spread across the instances under the cap", chosen)
+	}
+
+	requests[idle.UUID], requests[other.UUID] = 2, 3
+
+	if _, err := lb.Choose(); err != loadbalancer.AllInstancesSaturated {
+		t.Fatal("LoadBalancer should fail once every instance is at the cap", err)
+	}
+
+	requests[busy.UUID] = 1
+
+	if s, err := lb.Choose(); err != nil || s.UUID != busy.UUID {
+		t.Fatal("Instances should be chosen again once they're back under the cap", err)
+	}
+}
+
+func TestSaturatedKeyRoutedElsewhere(t *testing.T) {
+	a, b := serviceInfo(), serviceInfo()
+	requests := inFlight{}
+
+	lb := New(1, requests.count, consistenthash.New, []skynet.ServiceInfo{a, b})
+
+	owner, err := lb.(loadbalancer.KeyChooser).ChooseKey("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests[owner.UUID] = 1
+
+	if s, err := lb.(loadbalancer.KeyChooser).ChooseKey("key"); err != nil || s.UUID == owner.UUID {
+		t.Fatal("Keys should be routed to another instance while theirs is saturated", err)
+	}
+
+	requests[owner.UUID] = 0
+
+	if s, _ := lb.(loadbalancer.KeyChooser).ChooseKey("key"); s.UUID != owner.UUID {
+		t.Fatal("Keys should return to their instance once it's under the cap")
+	}
+}
+
+func TestAdmitsInstancesUnderCap(t *testing.T) {
+	busy, idle := serviceInfo(), serviceInfo()
+	requests := inFlight{busy.UUID: 1}
+
+	lb := New(1, requests.count, roundrobin.New, nil).(*LoadBalancer)
+
+	if lb.Admits(busy) || !lb.Admits(idle) {
+		t.Fatal("Only instances under the cap should be admitted")
+	}
+}
+
+type inFlight map[string]int
+
+func (f inFlight) count(s skynet.ServiceInfo) int {
+	return f[s.UUID]
+}
+
+func serviceInfo() skynet.ServiceInfo {
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.UUID = config.NewUUID()
+	si.Registered = true
+
+	return *si
+}
@@ -0,0 +1,155 @@
+package consistenthash
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Number of points each instance is given on the ring, more points spread keys more evenly
+const DefaultReplicas = 160
+
+type LoadBalancer struct {
+	instances     map[string]skynet.ServiceInfo
+	instanceMutex sync.Mutex
+	replicas      int
+	rand          *rand.Rand
+
+	// sorted hashes of every point on the ring, and the instance each belongs to
+	ring   []uint32
+	owners map[uint32]string
+}
+
+/*
+* New() returns a new ConsistentHash LoadBalancer, requests with the same key are sent to the same instance.
+* When instances are added or removed only the keys belonging to them move
+ */
+func New(instances []skynet.ServiceInfo) loadbalancer.LoadBalancer {
+	lb := &LoadBalancer{
+		instances: make(map[string]skynet.ServiceInfo),
+		replicas:  DefaultReplicas,
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, i := range instances {
+		lb.instances[i.UUID] = i
+	}
+
+	lb.build()
+
+	return lb
+}
+
+func (lb *LoadBalancer) AddInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.instances[s.UUID] = s
+	lb.build()
+}
+
+func (lb *LoadBalancer) UpdateInstance(s skynet.ServiceInfo) {
+	lb.AddInstance(s)
+}
+
+func (lb *LoadBalancer) RemoveInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if _, ok := lb.instances[s.UUID]; ok {
+		delete(lb.instances, s.UUID)
+		lb.build()
+	}
+}
+
+/*
+* LoadBalancer.Choose() requests without a key are spread across the ring at random
+ */
+func (lb *LoadBalancer) Choose() (s skynet.ServiceInfo, err error) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if len(lb.ring) == 0 {
+		return s, loadbalancer.NoInstances
+	}
+
+	return lb.instances[lb.owners[lb.ring[lb.rand.Intn(len(lb.ring))]]], nil
+}
+
+func (lb *LoadBalancer) Seed(seed int64) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.rand = rand.New(rand.NewSource(seed))
+}
+
+/*
+* LoadBalancer.ChooseKey() returns the instance that owns key on the ring
+ */
+func (lb *LoadBalancer) ChooseKey(key string) (s skynet.ServiceInfo, err error) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if len(lb.ring) == 0 {
+		return s, loadbalancer.NoInstances
+	}
+
+	h := hash(key)
+	i := sort.Search(len(lb.ring), func(i int) bool {
+		return lb.ring[i] >= h
+	})
+
+	// wrap around to the start of the ring
+	if i == len(lb.ring) {
+		i = 0
+	}
+
+	return lb.instances[lb.owners[lb.ring[i]]], nil
+}
+
+// Unregistered instances are left off the ring, their keys move to the next instance until they return
+func (lb *LoadBalancer) build() {
+	lb.ring = lb.ring[:0]
+	lb.owners = make(map[uint32]string)
+
+	for uuid, s := range lb.instances {
+		if !s.Registered {
+			continue
+		}
+
+		for r := 0; r < lb.replicas; r++ {
+			h := hash(uuid + "-" + strconv.Itoa(r))
+
+			// on the rare collision the lowest UUID wins, so every client builds the same ring
+			if owner, ok := lb.owners[h]; ok {
+				if owner < uuid {
+					continue
+				}
+			} else {
+				lb.ring = append(lb.ring, h)
+			}
+
+			lb.owners[h] = uuid
+		}
+	}
+
+	sort.Sort(hashes(lb.ring))
+}
+
+// md5 spreads similar keys, such as an instance's points, far better across the ring than a checksum
+func hash(key string) uint32 {
+	sum := md5.Sum([]byte(key))
+	return binary.LittleEndian.Uint32(sum[:4])
+}
+
+type hashes []uint32
+
+func (h hashes) Len() int           { return len(h) }
+func (h hashes) Less(i, j int) bool { return h[i] < h[j] }
+func (h hashes) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
@@ -0,0 +1,113 @@
+package consistenthash
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"github.com/skynetservices/skynet/config"
+	"strconv"
+	"testing"
+)
+
+func TestChooseReturnsErrorWhenEmpty(t *testing.T) {
+	lb := New([]skynet.ServiceInfo{})
+
+	if _, err := lb.Choose(); err != loadbalancer.NoInstances {
+		t.Fatal("LoadBalancer should fail if no instances exist")
+	}
+
+	if _, err := lb.(loadbalancer.KeyChooser).ChooseKey("foo"); err != loadbalancer.NoInstances {
+		t.Fatal("LoadBalancer should fail if no instances exist")
+	}
+}
+
+func TestUnregisteredNotChosen(t *testing.T) {
+	si := serviceInfo()
+	lb := New([]skynet.ServiceInfo{si})
+
+	si.Registered = false
+	lb.UpdateInstance(si)
+
+	if _, err := lb.(loadbalancer.KeyChooser).ChooseKey("foo"); err != loadbalancer.NoInstances {
+		t.Fatal("Unregistered instances should not be chosen")
+	}
+}
+
+func TestKeysStableAcrossChurn(t *testing.T) {
+	instances := []skynet.ServiceInfo{}
+	for i := 0; i < 5; i++ {
+		instances = append(instances, serviceInfo())
+	}
+
+	lb := New(instances)
+	before := chooseKeys(t, lb, 1000)
+
+	// Only keys owned by the removed instance should move
+	lb.RemoveInstance(instances[0])
+	after := chooseKeys(t, lb, 1000)
+
+	for key, uuid := range before {
+		if uuid != instances[0].UUID && after[key] != uuid {
+			t.Fatal("Key moved though its instance was not removed", key)
+		}
+
+		if after[key] == instances[0].UUID {
+			t.Fatal("Key still sent to removed instance", key)
+		}
+	}
+
+	// Keys only move to a newly added instance
+	added := serviceInfo()
+	lb.AddInstance(added)
+
+	for key, uuid := range chooseKeys(t, lb, 1000) {
+		if uuid != after[key] && uuid != added.UUID {
+			t.Fatal("Key moved to an instance that wasn't added", key)
+		}
+	}
+}
+
+func TestKeysSpreadEvenly(t *testing.T) {
+	instances := []skynet.ServiceInfo{}
+	for i := 0; i < 5; i++ {
+		instances = append(instances, serviceInfo())
+	}
+
+	lb := New(instances)
+	counts := make(map[string]int)
+
+	for _, uuid := range chooseKeys(t, lb, 10000) {
+		counts[uuid]++
+	}
+
+	// each instance should own roughly 20% of the keys
+	for _, i := range instances {
+		if counts[i.UUID] < 1000 || counts[i.UUID] > 3000 {
+			t.Fatal("Keys were not spread evenly across instances", counts)
+		}
+	}
+}
+
+func chooseKeys(t *testing.T, lb loadbalancer.LoadBalancer, n int) map[string]string {
+	owners := make(map[string]string)
+
+	for i := 0; i < n; i++ {
+		key := "key" + strconv.Itoa(i)
+		s, err := lb.(loadbalancer.KeyChooser).ChooseKey(key)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		owners[key] = s.UUID
+	}
+
+	return owners
+}
+
+func serviceInfo() skynet.ServiceInfo {
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.UUID = config.NewUUID()
+	si.Registered = true
+
+	return *si
+}
@@ -0,0 +1,110 @@
+package latency
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+/*
+LoadBalancer chooses the faster of two random instances (power of two choices), by the average latencies reported
+to ObserveLatency(). Instances without a reported latency are preferred so they're measured
+*/
+type LoadBalancer struct {
+	instances     map[string]skynet.ServiceInfo
+	latencies     map[string]time.Duration
+	instanceMutex sync.Mutex
+	rand          *rand.Rand
+
+	// the registered instances ordered by UUID, so the same random numbers choose the same instances
+	registered []skynet.ServiceInfo
+}
+
+/*
+* New() returns a new Latency LoadBalancer, ex: client.SetLoadBalancerFactory(latency.New)
+ */
+func New(instances []skynet.ServiceInfo) loadbalancer.LoadBalancer {
+	lb := &LoadBalancer{
+		instances: make(map[string]skynet.ServiceInfo),
+		latencies: make(map[string]time.Duration),
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, i := range instances {
+		lb.AddInstance(i)
+	}
+
+	return lb
+}
+
+func (lb *LoadBalancer) AddInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.instances[s.UUID] = s
+	lb.registered = loadbalancer.RemoveByUUID(lb.registered, s.UUID)
+
+	if s.Registered {
+		lb.registered = loadbalancer.InsertByUUID(lb.registered, s)
+	}
+}
+
+func (lb *LoadBalancer) UpdateInstance(s skynet.ServiceInfo) {
+	lb.AddInstance(s)
+}
+
+func (lb *LoadBalancer) RemoveInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	delete(lb.instances, s.UUID)
+	delete(lb.latencies, s.UUID)
+	lb.registered = loadbalancer.RemoveByUUID(lb.registered, s.UUID)
+}
+
+func (lb *LoadBalancer) ObserveLatency(s skynet.ServiceInfo, avg time.Duration) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if _, ok := lb.instances[s.UUID]; ok {
+		lb.latencies[s.UUID] = avg
+	}
+}
+
+func (lb *LoadBalancer) Choose() (s skynet.ServiceInfo, err error) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	candidates := lb.registered
+
+	switch len(candidates) {
+	case 0:
+		return s, loadbalancer.NoInstances
+	case 1:
+		return candidates[0], nil
+	}
+
+	i := lb.rand.Intn(len(candidates))
+	j := lb.rand.Intn(len(candidates) - 1)
+
+	// j is chosen from the other candidates
+	if j >= i {
+		j++
+	}
+
+	a, b := candidates[i], candidates[j]
+	if lb.latencies[b.UUID] < lb.latencies[a.UUID] {
+		return b, nil
+	}
+
+	return a, nil
+}
+
+func (lb *LoadBalancer) Seed(seed int64) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.rand = rand.New(rand.NewSource(seed))
+}
@@ -0,0 +1,68 @@
+package latency
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"github.com/skynetservices/skynet/config"
+	"testing"
+	"time"
+)
+
+func TestChooseReturnsErrorWhenEmpty(t *testing.T) {
+	lb := New([]skynet.ServiceInfo{serviceInfo(false)})
+
+	if _, err := lb.Choose(); err != loadbalancer.NoInstances {
+		t.Fatal("LoadBalancer should fail if no registered instances exist")
+	}
+}
+
+func TestChoosePrefersFasterInstances(t *testing.T) {
+	slow, fast, faster := serviceInfo(true), serviceInfo(true), serviceInfo(true)
+	lb := New([]skynet.ServiceInfo{slow, fast, faster}).(*LoadBalancer)
+
+	lb.ObserveLatency(slow, 100*time.Millisecond)
+	lb.ObserveLatency(fast, 10*time.Millisecond)
+	lb.ObserveLatency(faster, time.Millisecond)
+
+	counts := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		s, err := lb.Choose()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		counts[s.UUID]++
+	}
+
+	// the slowest instance always loses, the fastest always wins and the other only wins against the slowest
+	if counts[slow.UUID] != 0 || counts[faster.UUID] <= counts[fast.UUID] {
+		t.Fatal("Faster instances should be chosen more often", counts[slow.UUID], counts[fast.UUID], counts[faster.UUID])
+	}
+}
+
+func TestUnmeasuredInstancesPreferred(t *testing.T) {
+	measured, unmeasured := serviceInfo(true), serviceInfo(true)
+	lb := New([]skynet.ServiceInfo{measured, unmeasured})
+
+	lb.(loadbalancer.LatencyObserver).ObserveLatency(measured, time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		if s, _ := lb.Choose(); s.UUID != unmeasured.UUID {
+			t.Fatal("Instances without a latency should be chosen so they're measured")
+		}
+	}
+
+	lb.RemoveInstance(unmeasured)
+
+	if s, _ := lb.Choose(); s.UUID != measured.UUID {
+		t.Fatal("The remaining instance should be chosen")
+	}
+}
+
+func serviceInfo(registered bool) skynet.ServiceInfo {
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.UUID = config.NewUUID()
+	si.Registered = registered
+
+	return *si
+}
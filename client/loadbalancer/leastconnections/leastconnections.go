@@ -0,0 +1,102 @@
+package leastconnections
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Counter returns the number of outstanding requests against an instance
+type Counter func(s skynet.ServiceInfo) int
+
+type LoadBalancer struct {
+	// ordered by UUID, so ties are broken the same way for the same random number
+	instances     []skynet.ServiceInfo
+	instanceMutex sync.Mutex
+	counter       Counter
+	rand          *rand.Rand
+}
+
+/*
+* NewFactory() returns a loadbalancer.Factory that creates LeastConnections LoadBalancers
+* using the supplied Counter to determine load, ex: leastconnections.NewFactory(client.ActiveConnections)
+ */
+func NewFactory(c Counter) loadbalancer.Factory {
+	return func(instances []skynet.ServiceInfo) loadbalancer.LoadBalancer {
+		return New(c, instances)
+	}
+}
+
+/*
+* New() returns a new LeastConnections LoadBalancer
+ */
+func New(c Counter, instances []skynet.ServiceInfo) loadbalancer.LoadBalancer {
+	lb := &LoadBalancer{
+		counter: c,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, i := range instances {
+		lb.AddInstance(i)
+	}
+
+	return lb
+}
+
+func (lb *LoadBalancer) AddInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.instances = loadbalancer.InsertByUUID(lb.instances, s)
+}
+
+func (lb *LoadBalancer) UpdateInstance(s skynet.ServiceInfo) {
+	lb.AddInstance(s)
+}
+
+func (lb *LoadBalancer) RemoveInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.instances = loadbalancer.RemoveByUUID(lb.instances, s.UUID)
+}
+
+func (lb *LoadBalancer) Choose() (s skynet.ServiceInfo, err error) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	var candidates []skynet.ServiceInfo
+	min := -1
+
+	for _, i := range lb.instances {
+		if !i.Registered {
+			continue
+		}
+
+		n := lb.counter(i)
+
+		switch {
+		case min == -1 || n < min:
+			min = n
+			candidates = append(candidates[:0], i)
+		case n == min:
+			candidates = append(candidates, i)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return s, loadbalancer.NoInstances
+	}
+
+	// Break ties randomly so equally loaded instances share traffic
+	return candidates[lb.rand.Intn(len(candidates))], nil
+}
+
+func (lb *LoadBalancer) Seed(seed int64) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.rand = rand.New(rand.NewSource(seed))
+}
@@ -0,0 +1,101 @@
+package leastconnections
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"github.com/skynetservices/skynet/config"
+	"github.com/skynetservices/skynet/pools"
+	"testing"
+)
+
+func TestChooseReturnsErrorWhenEmpty(t *testing.T) {
+	lb := New(func(s skynet.ServiceInfo) int { return 0 }, []skynet.ServiceInfo{})
+
+	_, err := lb.Choose()
+
+	if err != loadbalancer.NoInstances {
+		t.Fatal("LoadBalancer should fail if no instances exist")
+	}
+}
+
+func TestChooseSkipsUnregistered(t *testing.T) {
+	si := serviceInfo(false)
+
+	lb := New(func(s skynet.ServiceInfo) int { return 0 }, []skynet.ServiceInfo{si})
+
+	if _, err := lb.Choose(); err != loadbalancer.NoInstances {
+		t.Fatal("Unregistered instances should not be chosen")
+	}
+}
+
+func TestChoosePrefersLeastLoaded(t *testing.T) {
+	busy := serviceInfo(true)
+	idle := serviceInfo(true)
+
+	resourcePools := map[string]*pools.ResourcePool{
+		busy.UUID: pools.NewResourcePool(newResource, -1, 10),
+		idle.UUID: pools.NewResourcePool(newResource, -1, 10),
+	}
+
+	// Hold resources on one of the instances
+	for i := 0; i < 3; i++ {
+		if _, err := resourcePools[busy.UUID].Acquire(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lb := NewFactory(func(s skynet.ServiceInfo) int {
+		return resourcePools[s.UUID].InUse()
+	})([]skynet.ServiceInfo{busy, idle})
+
+	for i := 0; i < 3; i++ {
+		s, err := lb.Choose()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s.UUID != idle.UUID {
+			t.Fatal("LoadBalancer chose the more heavily loaded instance")
+		}
+
+		if _, err := resourcePools[s.UUID].Acquire(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Both instances now have the same load, both should be chosen
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		s, _ := lb.Choose()
+		seen[s.UUID] = true
+	}
+
+	if len(seen) != 2 {
+		t.Fatal("LoadBalancer should break ties between equally loaded instances")
+	}
+}
+
+type resource struct {
+	closed bool
+}
+
+func (r *resource) Close() {
+	r.closed = true
+}
+
+func (r *resource) IsClosed() bool {
+	return r.closed
+}
+
+func newResource() (pools.Resource, error) {
+	return &resource{}, nil
+}
+
+func serviceInfo(registered bool) skynet.ServiceInfo {
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.UUID = config.NewUUID()
+	si.Registered = registered
+
+	return *si
+}
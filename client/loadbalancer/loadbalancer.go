@@ -3,10 +3,15 @@ package loadbalancer
 import (
 	"errors"
 	"github.com/skynetservices/skynet"
+	"sort"
+	"time"
 )
 
 var (
 	NoInstances = errors.New("No instances")
+
+	// AllInstancesSaturated is returned by LoadBalancers capping the requests in flight to each instance once all are at the cap
+	AllInstancesSaturated = errors.New("All instances are saturated")
 )
 
 type LoadBalancer interface {
@@ -16,4 +21,89 @@ type LoadBalancer interface {
 	Choose() (skynet.ServiceInfo, error)
 }
 
+/*
+KeyChooser is implemented by LoadBalancers that can route requests sharing a key to the same instance.
+Requests with a RoutingKey are sent to ChooseKey() instead of Choose()
+*/
+type KeyChooser interface {
+	ChooseKey(key string) (skynet.ServiceInfo, error)
+}
+
+/*
+LatencyObserver is implemented by LoadBalancers that choose instances by how quickly they respond, ServiceClients
+report each instance's average latency as its requests complete
+*/
+type LatencyObserver interface {
+	ObserveLatency(s skynet.ServiceInfo, avg time.Duration)
+}
+
+/*
+Seeder is implemented by LoadBalancers that choose instances at random, they're seeded with the time unless Seed() is
+called. LoadBalancers seeded alike make the same choices from the same instances, regardless of the order they were added
+*/
+type Seeder interface {
+	Seed(seed int64)
+}
+
+/*
+Admitter is implemented by LoadBalancers that only choose some of their instances, ex: by version or region. Admits()
+reports whether s would be chosen were it added, instances that wouldn't aren't sent probes while their breaker is half open
+*/
+type Admitter interface {
+	Admits(s skynet.ServiceInfo) bool
+}
+
+// Admits() reports whether lb would choose s were it added, LoadBalancers that aren't Admitters choose any instance
+func Admits(lb LoadBalancer, s skynet.ServiceInfo) bool {
+	if a, ok := lb.(Admitter); ok {
+		return a.Admits(s)
+	}
+
+	return true
+}
+
 type Factory func(instances []skynet.ServiceInfo) LoadBalancer
+
+/*
+SortByUUID() orders instances by UUID, so LoadBalancers choosing at random from instances kept in a map
+choose the same instance for the same random number
+*/
+func SortByUUID(instances []skynet.ServiceInfo) {
+	sort.Sort(byUUID(instances))
+}
+
+/*
+InsertByUUID() adds s to instances ordered by UUID, replacing the instance with the same UUID, so LoadBalancers can
+keep their instances ordered as they change rather than sorting them for every choice
+*/
+func InsertByUUID(instances []skynet.ServiceInfo, s skynet.ServiceInfo) []skynet.ServiceInfo {
+	i := sort.Search(len(instances), func(i int) bool { return instances[i].UUID >= s.UUID })
+
+	if i < len(instances) && instances[i].UUID == s.UUID {
+		instances[i] = s
+		return instances
+	}
+
+	instances = append(instances, skynet.ServiceInfo{})
+	copy(instances[i+1:], instances[i:])
+	instances[i] = s
+
+	return instances
+}
+
+// RemoveByUUID() removes the instance with uuid from instances ordered by UUID
+func RemoveByUUID(instances []skynet.ServiceInfo, uuid string) []skynet.ServiceInfo {
+	i := sort.Search(len(instances), func(i int) bool { return instances[i].UUID >= uuid })
+
+	if i < len(instances) && instances[i].UUID == uuid {
+		instances = append(instances[:i], instances[i+1:]...)
+	}
+
+	return instances
+}
+
+type byUUID []skynet.ServiceInfo
+
+func (s byUUID) Len() int           { return len(s) }
+func (s byUUID) Less(i, j int) bool { return s[i].UUID < s[j].UUID }
+func (s byUUID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,111 @@
+package random
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+type LoadBalancer struct {
+	instances     map[string]skynet.ServiceInfo
+	instanceMutex sync.Mutex
+	registered    []string
+	rand          *rand.Rand
+}
+
+/*
+* New() returns a new Random LoadBalancer
+ */
+func New(instances []skynet.ServiceInfo) loadbalancer.LoadBalancer {
+	lb := &LoadBalancer{
+		instances: make(map[string]skynet.ServiceInfo),
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, i := range instances {
+		lb.AddInstance(i)
+	}
+
+	return lb
+}
+
+func (lb *LoadBalancer) AddInstance(s skynet.ServiceInfo) {
+	if _, ok := lb.instances[s.UUID]; ok {
+		lb.UpdateInstance(s)
+		return
+	}
+
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.instances[s.UUID] = s
+
+	if s.Registered {
+		lb.addRegistered(s.UUID)
+	}
+}
+
+func (lb *LoadBalancer) UpdateInstance(s skynet.ServiceInfo) {
+	if _, ok := lb.instances[s.UUID]; !ok {
+		lb.AddInstance(s)
+		return
+	}
+
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.instances[s.UUID] = s
+	lb.removeRegistered(s.UUID)
+
+	if s.Registered {
+		lb.addRegistered(s.UUID)
+	}
+}
+
+func (lb *LoadBalancer) RemoveInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.removeRegistered(s.UUID)
+	delete(lb.instances, s.UUID)
+}
+
+func (lb *LoadBalancer) Choose() (s skynet.ServiceInfo, err error) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if len(lb.registered) == 0 {
+		return s, loadbalancer.NoInstances
+	}
+
+	return lb.instances[lb.registered[lb.rand.Intn(len(lb.registered))]], nil
+}
+
+func (lb *LoadBalancer) Seed(seed int64) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.rand = rand.New(rand.NewSource(seed))
+}
+
+// must be called while holding instanceMutex, registered is kept sorted so choices don't depend on the order instances were added
+func (lb *LoadBalancer) addRegistered(uuid string) {
+	i := sort.SearchStrings(lb.registered, uuid)
+
+	lb.registered = append(lb.registered, "")
+	copy(lb.registered[i+1:], lb.registered[i:])
+	lb.registered[i] = uuid
+}
+
+// must be called while holding instanceMutex
+func (lb *LoadBalancer) removeRegistered(uuid string) {
+	for i, id := range lb.registered {
+		if id == uuid {
+			lb.registered = append(lb.registered[:i], lb.registered[i+1:]...)
+			return
+		}
+	}
+}
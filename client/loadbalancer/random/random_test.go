@@ -0,0 +1,112 @@
+package random
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"github.com/skynetservices/skynet/config"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	si := serviceInfo(true)
+	si2 := serviceInfo(true)
+
+	lb := New([]skynet.ServiceInfo{si, si2}).(*LoadBalancer)
+
+	if len(lb.instances) != 2 {
+		t.Fatal("Failed to update instances", len(lb.instances))
+	}
+
+	if len(lb.registered) != 2 {
+		t.Fatal("Failed to update registered instances", len(lb.registered))
+	}
+}
+
+func TestAddIgnoresDuplicates(t *testing.T) {
+	lb := New([]skynet.ServiceInfo{}).(*LoadBalancer)
+
+	si := serviceInfo(true)
+
+	lb.AddInstance(si)
+	lb.AddInstance(si)
+
+	if len(lb.instances) != 1 || len(lb.registered) != 1 {
+		t.Fatal("Add did not ignore duplicates", len(lb.instances), len(lb.registered))
+	}
+}
+
+func TestUpdateRemovesUnregistered(t *testing.T) {
+	si := serviceInfo(true)
+
+	lb := New([]skynet.ServiceInfo{si}).(*LoadBalancer)
+
+	si.Registered = false
+	lb.UpdateInstance(si)
+
+	if len(lb.instances) != 1 {
+		t.Fatal("Unregistered instances should still be tracked")
+	}
+
+	if _, err := lb.Choose(); err != loadbalancer.NoInstances {
+		t.Fatal("Unregistered instances should not be chosen")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	si := serviceInfo(true)
+	si2 := serviceInfo(true)
+
+	lb := New([]skynet.ServiceInfo{si, si2}).(*LoadBalancer)
+
+	lb.RemoveInstance(si)
+
+	for i := 0; i < 10; i++ {
+		s, err := lb.Choose()
+
+		if err != nil || s.UUID != si2.UUID {
+			t.Fatal("Removed instance was chosen")
+		}
+	}
+}
+
+func TestChooseReturnsErrorWhenEmpty(t *testing.T) {
+	lb := New([]skynet.ServiceInfo{}).(*LoadBalancer)
+
+	_, err := lb.Choose()
+
+	if err != loadbalancer.NoInstances {
+		t.Fatal("LoadBalancer should fail if no instances exist")
+	}
+}
+
+func TestChooseUsesAllInstances(t *testing.T) {
+	instances := []skynet.ServiceInfo{serviceInfo(true), serviceInfo(true), serviceInfo(true)}
+
+	lb := New(instances).(*LoadBalancer)
+
+	seen := make(map[string]int)
+
+	for i := 0; i < 300; i++ {
+		s, err := lb.Choose()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seen[s.UUID]++
+	}
+
+	for _, i := range instances {
+		if seen[i.UUID] == 0 {
+			t.Fatal("LoadBalancer never chose instance", i.UUID)
+		}
+	}
+}
+
+func serviceInfo(registered bool) skynet.ServiceInfo {
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.UUID = config.NewUUID()
+	si.Registered = registered
+
+	return *si
+}
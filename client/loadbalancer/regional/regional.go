@@ -0,0 +1,165 @@
+package regional
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"sync"
+	"time"
+)
+
+type LoadBalancer struct {
+	region string
+
+	// instances in region are chosen from local, remote is only used when local has no instances
+	local  loadbalancer.LoadBalancer
+	remote loadbalancer.LoadBalancer
+
+	isLocal       map[string]bool
+	instanceMutex sync.Mutex
+}
+
+/*
+* NewFactory() returns a loadbalancer.Factory that creates Regional LoadBalancers preferring instances in region,
+* instances within and outside region are balanced by LoadBalancers from factory, ex: regional.NewFactory("us-east-1a", roundrobin.New)
+ */
+func NewFactory(region string, factory loadbalancer.Factory) loadbalancer.Factory {
+	return func(instances []skynet.ServiceInfo) loadbalancer.LoadBalancer {
+		return New(region, factory, instances)
+	}
+}
+
+/*
+* New() returns a new Regional LoadBalancer, requests are only sent outside region when no instances within it are available
+ */
+func New(region string, factory loadbalancer.Factory, instances []skynet.ServiceInfo) loadbalancer.LoadBalancer {
+	lb := &LoadBalancer{
+		region:  region,
+		local:   factory([]skynet.ServiceInfo{}),
+		remote:  factory([]skynet.ServiceInfo{}),
+		isLocal: make(map[string]bool),
+	}
+
+	for _, i := range instances {
+		lb.AddInstance(i)
+	}
+
+	return lb
+}
+
+func (lb *LoadBalancer) AddInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.isLocal[s.UUID] = s.Region == lb.region
+	lb.balancerFor(s.UUID).AddInstance(s)
+}
+
+func (lb *LoadBalancer) UpdateInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	local, ok := lb.isLocal[s.UUID]
+
+	// The instance has moved regions
+	if ok && local != (s.Region == lb.region) {
+		lb.balancerFor(s.UUID).RemoveInstance(s)
+		lb.isLocal[s.UUID] = !local
+		lb.balancerFor(s.UUID).AddInstance(s)
+		return
+	}
+
+	lb.isLocal[s.UUID] = s.Region == lb.region
+	lb.balancerFor(s.UUID).UpdateInstance(s)
+}
+
+func (lb *LoadBalancer) RemoveInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if _, ok := lb.isLocal[s.UUID]; !ok {
+		return
+	}
+
+	lb.balancerFor(s.UUID).RemoveInstance(s)
+	delete(lb.isLocal, s.UUID)
+}
+
+func (lb *LoadBalancer) Choose() (s skynet.ServiceInfo, err error) {
+	if s, err = lb.local.Choose(); err == loadbalancer.NoInstances {
+		return lb.remote.Choose()
+	}
+
+	return
+}
+
+/*
+* LoadBalancer.ChooseKey() routes by key if the underlying LoadBalancers support it
+ */
+func (lb *LoadBalancer) ChooseKey(key string) (s skynet.ServiceInfo, err error) {
+	local, ok := lb.local.(loadbalancer.KeyChooser)
+	if !ok {
+		return lb.Choose()
+	}
+
+	if s, err = local.ChooseKey(key); err == loadbalancer.NoInstances {
+		return lb.remote.(loadbalancer.KeyChooser).ChooseKey(key)
+	}
+
+	return
+}
+
+/*
+* LoadBalancer.ObserveLatency() passes latencies on if the underlying LoadBalancers observe them
+ */
+func (lb *LoadBalancer) ObserveLatency(s skynet.ServiceInfo, avg time.Duration) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if _, ok := lb.isLocal[s.UUID]; !ok {
+		return
+	}
+
+	if lo, ok := lb.balancerFor(s.UUID).(loadbalancer.LatencyObserver); ok {
+		lo.ObserveLatency(s, avg)
+	}
+}
+
+/*
+* LoadBalancer.Admits() admits instances outside region only while there are none within it
+ */
+func (lb *LoadBalancer) Admits(s skynet.ServiceInfo) bool {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if s.Region == lb.region {
+		return loadbalancer.Admits(lb.local, s)
+	}
+
+	for _, local := range lb.isLocal {
+		if local {
+			return false
+		}
+	}
+
+	return loadbalancer.Admits(lb.remote, s)
+}
+
+/*
+* LoadBalancer.Seed() seeds the underlying LoadBalancers if they choose at random
+ */
+func (lb *LoadBalancer) Seed(seed int64) {
+	for _, b := range []loadbalancer.LoadBalancer{lb.local, lb.remote} {
+		if sd, ok := b.(loadbalancer.Seeder); ok {
+			sd.Seed(seed)
+		}
+	}
+}
+
+// instanceMutex must be held
+func (lb *LoadBalancer) balancerFor(uuid string) loadbalancer.LoadBalancer {
+	if lb.isLocal[uuid] {
+		return lb.local
+	}
+
+	return lb.remote
+}
@@ -0,0 +1,85 @@
+package regional
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"github.com/skynetservices/skynet/client/loadbalancer/roundrobin"
+	"github.com/skynetservices/skynet/config"
+	"testing"
+)
+
+func TestChooseReturnsErrorWhenEmpty(t *testing.T) {
+	lb := New("Local", roundrobin.New, []skynet.ServiceInfo{})
+
+	if _, err := lb.Choose(); err != loadbalancer.NoInstances {
+		t.Fatal("LoadBalancer should fail if no instances exist")
+	}
+}
+
+func TestPrefersLocalRegion(t *testing.T) {
+	local, remote := serviceInfo("Local"), serviceInfo("Remote")
+	lb := New("Local", roundrobin.New, []skynet.ServiceInfo{remote, local})
+
+	for i := 0; i < 10; i++ {
+		if s, _ := lb.Choose(); s.UUID != local.UUID {
+			t.Fatal("Instances outside the local region should not be chosen while local instances are available")
+		}
+	}
+}
+
+func TestFallsBackToOtherRegions(t *testing.T) {
+	local, remote := serviceInfo("Local"), serviceInfo("Remote")
+	lb := New("Local", roundrobin.New, []skynet.ServiceInfo{remote, local})
+
+	// Instances excluded after failing are removed from the LoadBalancer
+	lb.RemoveInstance(local)
+
+	if s, err := lb.Choose(); err != nil || s.UUID != remote.UUID {
+		t.Fatal("Instances in other regions should be chosen when none are available locally", err)
+	}
+
+	lb.AddInstance(local)
+
+	if s, _ := lb.Choose(); s.UUID != local.UUID {
+		t.Fatal("Local instances should be preferred again once available")
+	}
+}
+
+func TestUpdateMovesInstanceBetweenRegions(t *testing.T) {
+	si := serviceInfo("Remote")
+	lb := New("Local", roundrobin.New, []skynet.ServiceInfo{si}).(*LoadBalancer)
+
+	si.Region = "Local"
+	lb.UpdateInstance(si)
+
+	if _, err := lb.remote.Choose(); err != loadbalancer.NoInstances {
+		t.Fatal("Instance should be removed from its previous region")
+	}
+
+	if s, err := lb.local.Choose(); err != nil || s.UUID != si.UUID {
+		t.Fatal("Instance should be added to its new region")
+	}
+}
+
+func TestAdmitsOtherRegionsOnlyWithoutLocalInstances(t *testing.T) {
+	local, remote := serviceInfo("us-east-1a"), serviceInfo("us-west-1a")
+	lb := New("us-east-1a", roundrobin.New, nil).(*LoadBalancer)
+
+	if !lb.Admits(local) || !lb.Admits(remote) {
+		t.Fatal("Instances in any region should be admitted while there are no local instances")
+	}
+
+	lb.AddInstance(local)
+	if lb.Admits(remote) {
+		t.Fatal("Instances in other regions should not be admitted while there are local instances")
+	}
+}
+
+func serviceInfo(region string) skynet.ServiceInfo {
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.UUID = config.NewUUID()
+	si.Region = region
+	si.Registered = true
+
+	return *si
+}
@@ -69,7 +69,17 @@ func (lb *LoadBalancer) RemoveInstance(s skynet.ServiceInfo) {
 	lb.instanceMutex.Lock()
 	defer lb.instanceMutex.Unlock()
 
-	lb.instanceList.Remove(lb.instances[s.UUID])
+	e, ok := lb.instances[s.UUID]
+	if !ok {
+		return
+	}
+
+	// Step back so the next Choose() continues with the instance after the one removed
+	if lb.current == e {
+		lb.current = e.Prev()
+	}
+
+	lb.instanceList.Remove(e)
 	delete(lb.instances, s.UUID)
 
 	// current should be nil if we have no instances
@@ -79,6 +89,9 @@ func (lb *LoadBalancer) RemoveInstance(s skynet.ServiceInfo) {
 }
 
 func (lb *LoadBalancer) Choose() (s skynet.ServiceInfo, err error) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
 	if lb.current == nil {
 		if lb.instanceList.Len() == 0 {
 			return s, loadbalancer.NoInstances
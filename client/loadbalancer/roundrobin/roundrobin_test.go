@@ -3,6 +3,7 @@ package roundrobin
 import (
 	"github.com/skynetservices/skynet"
 	"github.com/skynetservices/skynet/client/loadbalancer"
+	"github.com/skynetservices/skynet/config"
 	"testing"
 )
 
@@ -182,6 +183,59 @@ func TestRemove(t *testing.T) {
 	}
 }
 
+func TestRemoveUnknownInstance(t *testing.T) {
+	si := serviceInfo(true)
+	lb := New([]skynet.ServiceInfo{si}).(*LoadBalancer)
+
+	lb.RemoveInstance(serviceInfo(true))
+
+	if len(lb.instances) != 1 || lb.instanceList.Len() != 1 {
+		t.Fatal("Removing an unknown instance should not modify LoadBalancer")
+	}
+}
+
+func TestChooseAfterExclusions(t *testing.T) {
+	instances := []skynet.ServiceInfo{serviceInfo(true), serviceInfo(true), serviceInfo(true), serviceInfo(true)}
+	lb := New(instances).(*LoadBalancer)
+
+	lb.Choose()
+
+	// Exclude the current instance, one that's already been removed, one that was never added, and one in the middle
+	lb.RemoveInstance(instances[0])
+	lb.RemoveInstance(instances[0])
+	lb.RemoveInstance(serviceInfo(true))
+	lb.RemoveInstance(instances[2])
+
+	for i := 0; i < 4; i++ {
+		s, err := lb.Choose()
+
+		if err != nil || (s.UUID != instances[1].UUID && s.UUID != instances[3].UUID) {
+			t.Fatal("LoadBalancer returned an excluded or invalid instance", s, err)
+		}
+	}
+
+	lb.RemoveInstance(instances[1])
+	lb.RemoveInstance(instances[3])
+
+	if _, err := lb.Choose(); err != loadbalancer.NoInstances {
+		t.Fatal("LoadBalancer should fail once all instances are excluded")
+	}
+}
+
+func TestChooseContinuesAfterRemovingCurrent(t *testing.T) {
+	instances := []skynet.ServiceInfo{serviceInfo(true), serviceInfo(true), serviceInfo(true)}
+	lb := New(instances).(*LoadBalancer)
+
+	lb.Choose()
+	s, _ := lb.Choose()
+
+	lb.RemoveInstance(s)
+
+	if s, _ = lb.Choose(); s.UUID != instances[2].UUID {
+		t.Fatal("LoadBalancer should continue with the instance after the one removed")
+	}
+}
+
 func TestChooseReturnsErrorWhenEmpty(t *testing.T) {
 	lb := New([]skynet.ServiceInfo{}).(*LoadBalancer)
 
@@ -214,8 +268,9 @@ func TestChoose(t *testing.T) {
 }
 
 func serviceInfo(registered bool) skynet.ServiceInfo {
-	si := skynet.NewServiceInfo(nil)
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.UUID = config.NewUUID()
 	si.Registered = registered
 
-	return si
+	return *si
 }
@@ -0,0 +1,197 @@
+package versioned
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var InvalidConstraint = errors.New("Invalid version constraint")
+
+/*
+Constraint matches instance versions. A constraint is either an exact version "1.2.0", or a semver range made of
+comparisons (=, !=, >, >=, <, <=) that must all hold, ex: ">=1.2.0 <2.0.0". Ranges may be joined with || to match
+versions in any of them, ex: "1.2.x || >=2.1.0". An x or * in place of a minor or patch version matches any.
+*/
+type Constraint struct {
+	// any of the sets must match, a set matches if all its comparisons do
+	sets [][]comparison
+}
+
+type comparison struct {
+	op      string
+	version version
+
+	// the version as written, compared as a string when either side isn't semver
+	raw    string
+	semver bool
+}
+
+type version struct {
+	parts      [3]int
+	prerelease string
+
+	// number of parts given before an x or *, 3 when none are wildcards
+	specified int
+}
+
+/*
+versioned.ParseConstraint() parses s, returning InvalidConstraint if it's empty or a range bound isn't a semver version
+*/
+func ParseConstraint(s string) (c Constraint, err error) {
+	for _, alternative := range strings.Split(s, "||") {
+		var set []comparison
+
+		for _, clause := range strings.FieldsFunc(alternative, func(r rune) bool { return r == ' ' || r == ',' }) {
+			cmp, err := parseComparison(clause)
+			if err != nil {
+				return Constraint{}, err
+			}
+
+			set = append(set, cmp)
+		}
+
+		if len(set) == 0 {
+			return Constraint{}, InvalidConstraint
+		}
+
+		c.sets = append(c.sets, set)
+	}
+
+	return
+}
+
+/*
+Constraint.Matches() returns true if v satisfies the constraint, versions that aren't semver only match exactly
+*/
+func (c Constraint) Matches(v string) bool {
+	parsed, ok := parseVersion(v)
+
+	for _, set := range c.sets {
+		matched := true
+
+		for _, cmp := range set {
+			if !cmp.matches(v, parsed, ok) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseComparison(clause string) (cmp comparison, err error) {
+	for _, op := range []string{">=", "<=", "!=", ">", "<", "="} {
+		if strings.HasPrefix(clause, op) {
+			cmp.op = op
+			break
+		}
+	}
+
+	cmp.raw = strings.TrimPrefix(clause, cmp.op)
+	cmp.version, cmp.semver = parseVersion(cmp.raw)
+
+	// ranges need a version that can be ordered
+	if cmp.raw == "" || !cmp.semver && cmp.op != "" && cmp.op != "=" && cmp.op != "!=" {
+		return cmp, InvalidConstraint
+	}
+
+	return
+}
+
+func (cmp comparison) matches(raw string, v version, semver bool) bool {
+	if !cmp.semver || !semver {
+		equal := raw == cmp.raw
+
+		if cmp.op == "!=" {
+			return !equal
+		}
+
+		return equal
+	}
+
+	order := v.compare(cmp.version)
+
+	switch cmp.op {
+	case "!=":
+		return order != 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	}
+
+	return order == 0
+}
+
+// versions may start with a v and leave out the minor or patch version, ex: v1.2
+func parseVersion(s string) (v version, ok bool) {
+	s = strings.TrimPrefix(s, "v")
+
+	if i := strings.Index(s, "-"); i >= 0 {
+		s, v.prerelease = s[:i], s[i+1:]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, false
+	}
+
+	for i, p := range parts {
+		if p == "x" || p == "*" {
+			// nothing after a wildcard is compared
+			v.specified = i
+			return v, v.prerelease == ""
+		}
+
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+
+		v.parts[i] = n
+	}
+
+	v.specified = 3
+
+	return v, true
+}
+
+// compares the parts specified by other, a prerelease is ordered before its release
+func (v version) compare(other version) int {
+	for i := 0; i < other.specified; i++ {
+		if v.parts[i] != other.parts[i] {
+			if v.parts[i] < other.parts[i] {
+				return -1
+			}
+
+			return 1
+		}
+	}
+
+	if other.specified < 3 {
+		return 0
+	}
+
+	switch {
+	case v.prerelease == other.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case other.prerelease == "":
+		return -1
+	case v.prerelease < other.prerelease:
+		return -1
+	}
+
+	return 1
+}
@@ -0,0 +1,171 @@
+package versioned
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"sync"
+	"time"
+)
+
+type LoadBalancer struct {
+	constraint Constraint
+	fallback   bool
+
+	// instances matching the constraint are chosen from matching, others only when fallback is set and none match
+	matching loadbalancer.LoadBalancer
+	others   loadbalancer.LoadBalancer
+
+	isMatching    map[string]bool
+	instanceMutex sync.Mutex
+}
+
+/*
+* NewFactory() returns a loadbalancer.Factory that creates Versioned LoadBalancers only choosing instances whose version
+* satisfies constraint, instances are balanced by LoadBalancers from factory, ex: versioned.NewFactory(c, false, roundrobin.New)
+ */
+func NewFactory(constraint Constraint, fallback bool, factory loadbalancer.Factory) loadbalancer.Factory {
+	return func(instances []skynet.ServiceInfo) loadbalancer.LoadBalancer {
+		return New(constraint, fallback, factory, instances)
+	}
+}
+
+/*
+* New() returns a new Versioned LoadBalancer, if fallback is set instances of any version are chosen while none satisfy constraint
+ */
+func New(constraint Constraint, fallback bool, factory loadbalancer.Factory, instances []skynet.ServiceInfo) loadbalancer.LoadBalancer {
+	lb := &LoadBalancer{
+		constraint: constraint,
+		fallback:   fallback,
+		matching:   factory([]skynet.ServiceInfo{}),
+		others:     factory([]skynet.ServiceInfo{}),
+		isMatching: make(map[string]bool),
+	}
+
+	for _, i := range instances {
+		lb.AddInstance(i)
+	}
+
+	return lb
+}
+
+func (lb *LoadBalancer) AddInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.isMatching[s.UUID] = lb.constraint.Matches(s.Version)
+	lb.balancerFor(s.UUID).AddInstance(s)
+}
+
+func (lb *LoadBalancer) UpdateInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	matching, ok := lb.isMatching[s.UUID]
+
+	// The instance has been redeployed with another version
+	if ok && matching != lb.constraint.Matches(s.Version) {
+		lb.balancerFor(s.UUID).RemoveInstance(s)
+		lb.isMatching[s.UUID] = !matching
+		lb.balancerFor(s.UUID).AddInstance(s)
+		return
+	}
+
+	lb.isMatching[s.UUID] = lb.constraint.Matches(s.Version)
+	lb.balancerFor(s.UUID).UpdateInstance(s)
+}
+
+func (lb *LoadBalancer) RemoveInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if _, ok := lb.isMatching[s.UUID]; !ok {
+		return
+	}
+
+	lb.balancerFor(s.UUID).RemoveInstance(s)
+	delete(lb.isMatching, s.UUID)
+}
+
+func (lb *LoadBalancer) Choose() (s skynet.ServiceInfo, err error) {
+	if s, err = lb.matching.Choose(); err == loadbalancer.NoInstances && lb.fallback {
+		return lb.others.Choose()
+	}
+
+	return
+}
+
+/*
+* LoadBalancer.ChooseKey() routes by key if the underlying LoadBalancers support it
+ */
+func (lb *LoadBalancer) ChooseKey(key string) (s skynet.ServiceInfo, err error) {
+	matching, ok := lb.matching.(loadbalancer.KeyChooser)
+	if !ok {
+		return lb.Choose()
+	}
+
+	if s, err = matching.ChooseKey(key); err == loadbalancer.NoInstances && lb.fallback {
+		return lb.others.(loadbalancer.KeyChooser).ChooseKey(key)
+	}
+
+	return
+}
+
+/*
+* LoadBalancer.ObserveLatency() passes latencies on if the underlying LoadBalancers observe them
+ */
+func (lb *LoadBalancer) ObserveLatency(s skynet.ServiceInfo, avg time.Duration) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if _, ok := lb.isMatching[s.UUID]; !ok {
+		return
+	}
+
+	if lo, ok := lb.balancerFor(s.UUID).(loadbalancer.LatencyObserver); ok {
+		lo.ObserveLatency(s, avg)
+	}
+}
+
+/*
+* LoadBalancer.Admits() admits instances satisfying the constraint, and others only with fallback while none do
+ */
+func (lb *LoadBalancer) Admits(s skynet.ServiceInfo) bool {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if lb.constraint.Matches(s.Version) {
+		return loadbalancer.Admits(lb.matching, s)
+	}
+
+	if !lb.fallback {
+		return false
+	}
+
+	for _, matching := range lb.isMatching {
+		if matching {
+			return false
+		}
+	}
+
+	return loadbalancer.Admits(lb.others, s)
+}
+
+/*
+* LoadBalancer.Seed() seeds the underlying LoadBalancers if they choose at random
+ */
+func (lb *LoadBalancer) Seed(seed int64) {
+	for _, b := range []loadbalancer.LoadBalancer{lb.matching, lb.others} {
+		if sd, ok := b.(loadbalancer.Seeder); ok {
+			sd.Seed(seed)
+		}
+	}
+}
+
+// instanceMutex must be held
+func (lb *LoadBalancer) balancerFor(uuid string) loadbalancer.LoadBalancer {
+	if lb.isMatching[uuid] {
+		return lb.matching
+	}
+
+	return lb.others
+}
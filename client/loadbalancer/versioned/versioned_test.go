@@ -0,0 +1,137 @@
+package versioned
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"github.com/skynetservices/skynet/client/loadbalancer/roundrobin"
+	"github.com/skynetservices/skynet/config"
+	"testing"
+)
+
+func TestParseConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matches    []string
+		rejects    []string
+	}{
+		{"1.2.0", []string{"1.2.0", "v1.2"}, []string{"1.2.1", "1.2.0-beta"}},
+		{">=1.2.0 <2.0.0", []string{"1.2.0", "1.9.9"}, []string{"1.1.9", "2.0.0", "unknown"}},
+		{">=1.0.0, !=1.1.0", []string{"1.0.0", "1.2.0"}, []string{"1.1.0", "0.9.0"}},
+		{"1.2.x || >=2.1.0", []string{"1.2.7", "2.1.0"}, []string{"1.3.0", "2.0.5"}},
+		{"<1.0.0", []string{"1.0.0-beta", "0.9.0"}, []string{"1.0.0"}},
+		{"canary", []string{"canary"}, []string{"1.0.0"}},
+	}
+
+	for _, test := range tests {
+		c, err := ParseConstraint(test.constraint)
+		if err != nil {
+			t.Fatal("Failed to parse", test.constraint, err)
+		}
+
+		for _, v := range test.matches {
+			if !c.Matches(v) {
+				t.Errorf("%q should match %q", test.constraint, v)
+			}
+		}
+
+		for _, v := range test.rejects {
+			if c.Matches(v) {
+				t.Errorf("%q should not match %q", test.constraint, v)
+			}
+		}
+	}
+
+	for _, invalid := range []string{"", ">=", ">=canary", "1.0.0 || "} {
+		if _, err := ParseConstraint(invalid); err != InvalidConstraint {
+			t.Errorf("%q should be an invalid constraint", invalid)
+		}
+	}
+}
+
+func TestOnlyChoosesConstrainedVersion(t *testing.T) {
+	current, canary := serviceInfo("1.0.0"), serviceInfo("1.1.0")
+	lb := New(constraint(t, "1.1.0"), false, roundrobin.New, []skynet.ServiceInfo{current, canary})
+
+	for i := 0; i < 10; i++ {
+		if s, _ := lb.Choose(); s.UUID != canary.UUID {
+			t.Fatal("Instances not satisfying the constraint should not be chosen")
+		}
+	}
+
+	lb.RemoveInstance(canary)
+
+	if _, err := lb.Choose(); err != loadbalancer.NoInstances {
+		t.Fatal("Other versions should not be chosen without fallback", err)
+	}
+}
+
+func TestFallsBackToOtherVersions(t *testing.T) {
+	current := serviceInfo("1.0.0")
+	lb := New(constraint(t, "1.1.0"), true, roundrobin.New, []skynet.ServiceInfo{current})
+
+	if s, err := lb.Choose(); err != nil || s.UUID != current.UUID {
+		t.Fatal("Other versions should be chosen when none satisfy the constraint", err)
+	}
+
+	// the constraint is re-evaluated as instances are discovered
+	canary := serviceInfo("1.1.0")
+	lb.AddInstance(canary)
+
+	for i := 0; i < 10; i++ {
+		if s, _ := lb.Choose(); s.UUID != canary.UUID {
+			t.Fatal("Instances satisfying the constraint should be preferred once discovered")
+		}
+	}
+}
+
+func TestUpdateMovesRedeployedInstance(t *testing.T) {
+	si := serviceInfo("1.0.0")
+	lb := New(constraint(t, ">=1.1.0"), false, roundrobin.New, []skynet.ServiceInfo{si}).(*LoadBalancer)
+
+	si.Version = "1.1.0"
+	lb.UpdateInstance(si)
+
+	if _, err := lb.others.Choose(); err != loadbalancer.NoInstances {
+		t.Fatal("Instance should be removed from its previous version's LoadBalancer")
+	}
+
+	if s, err := lb.Choose(); err != nil || s.UUID != si.UUID {
+		t.Fatal("Instance should be chosen once it satisfies the constraint", err)
+	}
+}
+
+func TestAdmitsOtherVersionsOnlyWhenFallingBack(t *testing.T) {
+	current, other := serviceInfo("1.1.0"), serviceInfo("1.0.0")
+
+	lb := New(constraint(t, "1.1.0"), false, roundrobin.New, nil).(*LoadBalancer)
+	if !lb.Admits(current) || lb.Admits(other) {
+		t.Fatal("Only instances satisfying the constraint should be admitted without fallback")
+	}
+
+	lb = New(constraint(t, "1.1.0"), true, roundrobin.New, nil).(*LoadBalancer)
+	if !lb.Admits(other) {
+		t.Fatal("Other versions should be admitted with fallback while none satisfy the constraint")
+	}
+
+	lb.AddInstance(current)
+	if lb.Admits(other) {
+		t.Fatal("Other versions should not be admitted while instances satisfy the constraint")
+	}
+}
+
+func constraint(t *testing.T, s string) Constraint {
+	c, err := ParseConstraint(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func serviceInfo(version string) skynet.ServiceInfo {
+	si := skynet.NewServiceInfo("TestService", version)
+	si.UUID = config.NewUUID()
+	si.Registered = true
+
+	return *si
+}
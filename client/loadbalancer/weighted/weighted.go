@@ -0,0 +1,104 @@
+package weighted
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type LoadBalancer struct {
+	instances     map[string]skynet.ServiceInfo
+	instanceMutex sync.Mutex
+	totalWeight   int
+	rand          *rand.Rand
+
+	// the instances ordered by UUID, so the same random number chooses the same instance
+	ordered []skynet.ServiceInfo
+}
+
+/*
+* New() returns a new WeightedRandom LoadBalancer, instances are chosen proportionally to their Weight
+ */
+func New(instances []skynet.ServiceInfo) loadbalancer.LoadBalancer {
+	lb := &LoadBalancer{
+		instances: make(map[string]skynet.ServiceInfo),
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, i := range instances {
+		lb.AddInstance(i)
+	}
+
+	return lb
+}
+
+func (lb *LoadBalancer) AddInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if existing, ok := lb.instances[s.UUID]; ok {
+		lb.totalWeight -= weight(existing)
+	}
+
+	lb.instances[s.UUID] = s
+	lb.ordered = loadbalancer.InsertByUUID(lb.ordered, s)
+	lb.totalWeight += weight(s)
+}
+
+func (lb *LoadBalancer) UpdateInstance(s skynet.ServiceInfo) {
+	lb.AddInstance(s)
+}
+
+func (lb *LoadBalancer) RemoveInstance(s skynet.ServiceInfo) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if existing, ok := lb.instances[s.UUID]; ok {
+		lb.totalWeight -= weight(existing)
+		delete(lb.instances, s.UUID)
+		lb.ordered = loadbalancer.RemoveByUUID(lb.ordered, s.UUID)
+	}
+}
+
+func (lb *LoadBalancer) Choose() (s skynet.ServiceInfo, err error) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	if lb.totalWeight == 0 {
+		return s, loadbalancer.NoInstances
+	}
+
+	n := lb.rand.Intn(lb.totalWeight)
+
+	for _, i := range lb.ordered {
+		n -= weight(i)
+
+		if n < 0 {
+			return i, nil
+		}
+	}
+
+	return s, loadbalancer.NoInstances
+}
+
+func (lb *LoadBalancer) Seed(seed int64) {
+	lb.instanceMutex.Lock()
+	defer lb.instanceMutex.Unlock()
+
+	lb.rand = rand.New(rand.NewSource(seed))
+}
+
+// Unregistered instances carry no weight, and unset weights default to 1
+func weight(s skynet.ServiceInfo) int {
+	if !s.Registered {
+		return 0
+	}
+
+	if s.Weight <= 0 {
+		return 1
+	}
+
+	return s.Weight
+}
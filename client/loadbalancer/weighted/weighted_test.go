@@ -0,0 +1,117 @@
+package weighted
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"github.com/skynetservices/skynet/config"
+	"testing"
+)
+
+func TestChooseReturnsErrorWhenEmpty(t *testing.T) {
+	lb := New([]skynet.ServiceInfo{})
+
+	_, err := lb.Choose()
+
+	if err != loadbalancer.NoInstances {
+		t.Fatal("LoadBalancer should fail if no instances exist")
+	}
+}
+
+func TestUnsetWeightDefaultsToOne(t *testing.T) {
+	lb := New([]skynet.ServiceInfo{serviceInfo(0), serviceInfo(0)}).(*LoadBalancer)
+
+	if lb.totalWeight != 2 {
+		t.Fatal("Instances without a weight should default to 1", lb.totalWeight)
+	}
+}
+
+func TestUnregisteredNotChosen(t *testing.T) {
+	si := serviceInfo(5)
+	lb := New([]skynet.ServiceInfo{si})
+
+	si.Registered = false
+	lb.UpdateInstance(si)
+
+	if _, err := lb.Choose(); err != loadbalancer.NoInstances {
+		t.Fatal("Unregistered instances should not be chosen")
+	}
+}
+
+func TestChooseProportionalToWeight(t *testing.T) {
+	light1 := serviceInfo(1)
+	light2 := serviceInfo(1)
+	heavy := serviceInfo(8)
+
+	lb := New([]skynet.ServiceInfo{light1, light2, heavy})
+
+	counts := choose(t, lb, 10000)
+
+	if counts[heavy.UUID] < 7500 || counts[heavy.UUID] > 8500 {
+		t.Fatal("Heavy instance should receive roughly 80% of requests", counts)
+	}
+}
+
+func TestUpdatedWeightIsUsed(t *testing.T) {
+	si := serviceInfo(1)
+	si2 := serviceInfo(1)
+
+	lb := New([]skynet.ServiceInfo{si, si2})
+
+	si2.Weight = 9
+	lb.UpdateInstance(si2)
+
+	counts := choose(t, lb, 10000)
+
+	if counts[si2.UUID] < 8500 {
+		t.Fatal("Updated weight was not applied", counts)
+	}
+}
+
+func TestSeededChoicesDontDependOnInstanceOrder(t *testing.T) {
+	a, b, c := serviceInfo(1), serviceInfo(2), serviceInfo(3)
+
+	lb1 := New([]skynet.ServiceInfo{a, b, c})
+	lb2 := New([]skynet.ServiceInfo{c, a})
+
+	// instances that come and go are kept in order
+	lb2.RemoveInstance(a)
+	lb2.AddInstance(b)
+	lb2.AddInstance(a)
+
+	lb1.(loadbalancer.Seeder).Seed(1)
+	lb2.(loadbalancer.Seeder).Seed(1)
+
+	for i := 0; i < 100; i++ {
+		s1, _ := lb1.Choose()
+		s2, _ := lb2.Choose()
+
+		if s1.UUID != s2.UUID {
+			t.Fatal("Seeded LoadBalancers with the same instances should make the same choices", i)
+		}
+	}
+}
+
+func choose(t *testing.T, lb loadbalancer.LoadBalancer, n int) map[string]int {
+	counts := make(map[string]int)
+
+	for i := 0; i < n; i++ {
+		s, err := lb.Choose()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		counts[s.UUID]++
+	}
+
+	return counts
+}
+
+func serviceInfo(weight int) skynet.ServiceInfo {
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.UUID = config.NewUUID()
+	si.Registered = true
+	si.Weight = weight
+
+	return *si
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet/log"
+	"sync/atomic"
+)
+
+/*
+client.Logger receives the client's log messages by severity: instance discovery at Debug, failed requests at Warn,
+and instances failing persistently or misconfiguration at Error.
+Implementations must be safe to call from multiple goroutines.
+*/
+type Logger interface {
+	Debug(messages ...interface{})
+	Info(messages ...interface{})
+	Warn(messages ...interface{})
+	Error(messages ...interface{})
+}
+
+/*
+client.LevelLogger adapts a function logging at a log.LogLevel, such as log.Println, to a Logger
+*/
+type LevelLogger func(level log.LogLevel, messages ...interface{})
+
+func (l LevelLogger) Debug(messages ...interface{}) { l(log.DEBUG, messages...) }
+
+func (l LevelLogger) Info(messages ...interface{}) { l(log.INFO, messages...) }
+
+func (l LevelLogger) Warn(messages ...interface{}) { l(log.WARN, messages...) }
+
+func (l LevelLogger) Error(messages ...interface{}) { l(log.ERROR, messages...) }
+
+// SetLogger() may be called while other goroutines are logging, so the Logger is swapped atomically
+var currentLogger atomic.Value
+
+type loggerValue struct {
+	Logger
+}
+
+type swappableLogger struct{}
+
+func (swappableLogger) Debug(messages ...interface{}) {
+	currentLogger.Load().(loggerValue).Debug(messages...)
+}
+
+func (swappableLogger) Info(messages ...interface{}) {
+	currentLogger.Load().(loggerValue).Info(messages...)
+}
+
+func (swappableLogger) Warn(messages ...interface{}) {
+	currentLogger.Load().(loggerValue).Warn(messages...)
+}
+
+func (swappableLogger) Error(messages ...interface{}) {
+	currentLogger.Load().(loggerValue).Error(messages...)
+}
+
+var logger Logger = swappableLogger{}
+
+func init() {
+	SetLogger(nil)
+}
+
+/*
+client.SetLogger() provide a Logger for the client to log to, by default messages are logged with log.Println()
+*/
+func SetLogger(l Logger) {
+	if l == nil {
+		l = LevelLogger(log.Println)
+	}
+
+	currentLogger.Store(loggerValue{l})
+}
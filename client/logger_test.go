@@ -0,0 +1,90 @@
+package client
+
+import (
+	"fmt"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/loadbalancer/roundrobin"
+	"github.com/skynetservices/skynet/config"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type recordingLogger struct {
+	sync.Mutex
+	messages map[string][]string
+}
+
+func (rl *recordingLogger) record(level string, messages []interface{}) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	rl.messages[level] = append(rl.messages[level], fmt.Sprint(messages...))
+}
+
+func (rl *recordingLogger) logged(level, substr string) bool {
+	rl.Lock()
+	defer rl.Unlock()
+
+	for _, m := range rl.messages[level] {
+		if strings.Contains(m, substr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (rl *recordingLogger) Debug(messages ...interface{}) { rl.record("DEBUG", messages) }
+
+func (rl *recordingLogger) Info(messages ...interface{}) { rl.record("INFO", messages) }
+
+func (rl *recordingLogger) Warn(messages ...interface{}) { rl.record("WARN", messages) }
+
+func (rl *recordingLogger) Error(messages ...interface{}) { rl.record("ERROR", messages) }
+
+func TestLoggerLevels(t *testing.T) {
+	defer resetClient()
+
+	rl := &recordingLogger{messages: make(map[string][]string)}
+	SetLogger(rl)
+
+	bad := *serviceInfo()
+	bad.UUID = config.NewUUID()
+
+	criteria := &skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}}
+
+	sc := NewServiceClient(criteria)
+	sClient := sc.(*ServiceClient)
+	sClient.loadBalancer = roundrobin.New([]skynet.ServiceInfo{})
+	sClient.failureThreshold = 1
+
+	counter := stubFailingPool(bad.UUID)
+
+	// discovered instances are only logged once they're known to the client
+	addServiceClient(sc)
+	sendInstanceNotification(skynet.InstanceAdded, bad)
+
+	var val string
+
+	waitFor(t, func() bool {
+		sc.SendOnce(nil, "Foo", val, &val)
+		return counter.count(bad.UUID) > 0
+	})
+
+	waitFor(t, func() bool { return rl.logged("ERROR", "consecutive requests") })
+
+	if !rl.logged("DEBUG", bad.UUID+" of TestService at "+bad.AddrString()+" added") {
+		t.Fatal("Discovered instances should be logged at Debug")
+	}
+
+	if !rl.logged("WARN", "Attempt Error") {
+		t.Fatal("Failed requests should be logged at Warn")
+	}
+
+	if rl.logged("ERROR", "Attempt Error") {
+		t.Fatal("Failed requests should not be logged at Error")
+	}
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"errors"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/config"
+)
+
+var MetadataTooLarge = errors.New("Request metadata is larger than client.metadata.max")
+
+// returns a copy of ri carrying the client's metadata merged with its own, the request's values win on conflicts
+func (c *ServiceClient) withMetadata(ri *skynet.RequestInfo) (*skynet.RequestInfo, error) {
+	sent := *ri
+
+	if len(c.metadata) > 0 {
+		sent.Metadata = make(map[string]string, len(c.metadata)+len(ri.Metadata))
+
+		for k, v := range c.metadata {
+			sent.Metadata[k] = v
+		}
+
+		for k, v := range ri.Metadata {
+			sent.Metadata[k] = v
+		}
+	}
+
+	if c.maxMetadata > 0 && sent.MetadataSize() > c.maxMetadata {
+		return ri, MetadataTooLarge
+	}
+
+	return &sent, nil
+}
+
+func getDefaultMetadata(service, version string) map[string]string {
+	s, err := config.String(service, version, "client.metadata")
+	if err != nil {
+		s = config.DefaultMetadata
+	}
+
+	if s == "" {
+		return nil
+	}
+
+	return skynet.ParseLabels(s)
+}
+
+func getMaxMetadataBytes(service, version string) int {
+	if n, err := config.Int(service, version, "client.metadata.max"); err == nil {
+		return n
+	}
+
+	return config.DefaultMaxMetadataBytes
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"labix.org/v2/mgo/bson"
+	"testing"
+)
+
+// answers every request with the metadata it arrived with
+type metadataService struct{}
+
+func (metadataService) Forward(in skynet.ServiceRPCInRead, out *skynet.ServiceRPCOutWrite) error {
+	b, err := bson.Marshal(metadataParam{Metadata: in.RequestInfo.Metadata})
+	out.Out = bson.Binary{0x00, b}
+	return err
+}
+
+type metadataParam struct {
+	Metadata map[string]string
+}
+
+func TestSendMergesClientMetadata(t *testing.T) {
+	defer resetClient()
+
+	ln := listenForService(t, "TestService", metadataService{})
+	defer ln.Close()
+
+	sc, err := GetStaticService("TestService", "1.0.0", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+
+	sc.(*ServiceClient).metadata = map[string]string{"tenant": "acme", "locale": "en"}
+
+	ri := sc.(*ServiceClient).NewRequestInfo()
+	ri.Metadata = map[string]string{"locale": "fr", "scope": "read"}
+
+	var out metadataParam
+	if err := sc.Send(ri, "Metadata", metadataParam{}, &out); err != nil {
+		t.Fatal("Failed to send request with metadata", err)
+	}
+
+	if out.Metadata["tenant"] != "acme" || out.Metadata["scope"] != "read" {
+		t.Fatal("Both the client's and the request's metadata should arrive at the service", out.Metadata)
+	}
+
+	if out.Metadata["locale"] != "fr" {
+		t.Fatal("The request's metadata should win over the client's", out.Metadata)
+	}
+
+	if len(ri.Metadata) != 2 {
+		t.Fatal("The caller's metadata shouldn't be changed by the merge", ri.Metadata)
+	}
+
+	sc.(*ServiceClient).maxMetadata = 8
+
+	if err := sc.Send(ri, "Metadata", metadataParam{}, &out); err != MetadataTooLarge {
+		t.Fatal("Requests with metadata over client.metadata.max should be refused", err)
+	}
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet/config"
+	"strings"
+	"time"
+)
+
+// message sent to mux() to override the giveup time of requests to a method
+type methodTimeout struct {
+	fn     string
+	giveup time.Duration
+}
+
+/*
+ServiceClient.SetMethodTimeout() sets the giveup time of requests to fn in place of the ServiceClient's default, for
+methods expected to take much more or less time than others. Setting giveup to 0 indicates no time out. Send, SendOnce,
+SendTo, SendAll and SendBatch use it, SendTimeout and SendOnceTimeout always use the time they're given
+*/
+func (c *ServiceClient) SetMethodTimeout(fn string, giveup time.Duration) {
+	c.sendMux(methodTimeout{fn: fn, giveup: giveup})
+}
+
+// this should only be called by mux(), the map is replaced rather than changed as requests may be reading it
+func (c *ServiceClient) setMethodTimeout(m methodTimeout) {
+	methods := make(map[string]time.Duration, len(c.methodTimeouts)+1)
+
+	for fn, giveup := range c.methodTimeouts {
+		methods[fn] = giveup
+	}
+
+	methods[m.fn] = m.giveup
+	c.methodTimeouts = methods
+}
+
+// returns the retry and giveup times for requests to fn
+func (c *ServiceClient) timeoutsFor(fn string) (retry, giveup time.Duration) {
+	tl := c.timeouts()
+	return tl.retry, tl.giveupFor(fn)
+}
+
+func (c *ServiceClient) timeouts() (tl timeoutLengths) {
+	select {
+	case tl = <-c.timeoutChan:
+	case <-c.done:
+	}
+
+	return
+}
+
+func (tl timeoutLengths) giveupFor(fn string) time.Duration {
+	if giveup, ok := tl.methods[fn]; ok {
+		return giveup
+	}
+
+	return tl.giveup
+}
+
+// client.timeout.methods lists methods and their giveup times, ex: "Lookup=500ms, Report=5m"
+func getMethodTimeouts(service, version string) map[string]time.Duration {
+	methods := make(map[string]time.Duration)
+
+	s, err := config.String(service, version, "client.timeout.methods")
+	if err != nil {
+		return methods
+	}
+
+	for _, m := range strings.Split(s, ",") {
+		if m = strings.TrimSpace(m); m == "" {
+			continue
+		}
+
+		i := strings.Index(m, "=")
+		if i == -1 {
+			logger.Error("Failed to parse client.timeout.methods, expected method=duration", m)
+			continue
+		}
+
+		giveup, err := time.ParseDuration(strings.TrimSpace(m[i+1:]))
+		if err != nil {
+			logger.Error("Failed to parse client.timeout.methods", err)
+			continue
+		}
+
+		methods[strings.TrimSpace(m[:i])] = giveup
+	}
+
+	return methods
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMethodTimeoutsOverrideDefaultGiveup(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+	sc.SetDefaultTimeout(0, time.Minute)
+	sc.SetMethodTimeout("Lookup", 20*time.Millisecond)
+	sc.SetMethodTimeout("Report", time.Hour)
+
+	blocked := make(chan bool)
+	defer close(blocked)
+
+	var mutex sync.Mutex
+	remaining := make(map[string]time.Duration)
+
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		mutex.Lock()
+		remaining[fn] = ri.Deadline.Sub(time.Now())
+		mutex.Unlock()
+
+		if fn == "Lookup" {
+			<-blocked
+		}
+
+		return
+	})
+
+	err := make(chan error)
+
+	go func() {
+		var val string
+		err <- sc.Send(nil, "Lookup", val, &val)
+	}()
+
+	select {
+	case e := <-err:
+		if e != RequestTimeout {
+			t.Fatal("Send() should return RequestTimeout once the method's giveup passes", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send() should give up after the method's giveup rather than the default")
+	}
+
+	var val string
+	for _, fn := range []string{"Report", "Other"} {
+		if e := sc.Send(nil, fn, val, &val); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if remaining["Report"] < 59*time.Minute {
+		t.Fatal("Send() should use the method's giveup in place of the default", remaining["Report"])
+	}
+
+	if remaining["Other"] > time.Minute || remaining["Other"] < 59*time.Second {
+		t.Fatal("Send() should use the default giveup for methods without their own", remaining["Other"])
+	}
+
+	if _, giveup := sc.GetDefaultTimeout(); giveup != time.Minute {
+		t.Fatal("SetMethodTimeout() should not change the default giveup", giveup)
+	}
+}
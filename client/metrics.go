@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"github.com/skynetservices/skynet/pools"
+	"time"
+)
+
+// Error classes reported to Metrics
+const (
+	ErrorClassNone        = ""
+	ErrorClassTimeout     = "timeout"
+	ErrorClassCancelled   = "cancelled"
+	ErrorClassNoInstances = "no_instances"
+	ErrorClassTransport   = "transport"
+	ErrorClassConnect     = "connect"
+	ErrorClassService     = "service"
+
+	ErrorClassConcurrencyLimit = "concurrency_limit"
+)
+
+/*
+client.SendMetrics describes a completed request
+*/
+type SendMetrics struct {
+	Service string
+	Method  string
+
+	// Total time from the request being sent until it returned to the caller
+	Duration time.Duration
+	Attempts int
+
+	// The instance that served the request, or the last one attempted if the request failed
+	Instance skynet.ServiceInfo
+
+	Err        error
+	ErrorClass string
+
+	// Attempts that failed to open a connection to their instance, and those that failed once sent to it
+	ConnectFailures int
+	CallFailures    int
+}
+
+/*
+client.Metrics is notified of every request sent by a ServiceClient, including those that error or time out.
+Implementations must be safe to call from multiple goroutines.
+*/
+type Metrics interface {
+	RecordSend(m SendMetrics)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) RecordSend(m SendMetrics) {}
+
+var metrics Metrics = noopMetrics{}
+
+/*
+client.SetMetrics() provide a Metrics implementation to record requests to, by default they are discarded
+*/
+func SetMetrics(m Metrics) {
+	if m == nil {
+		m = noopMetrics{}
+	}
+
+	metrics = m
+}
+
+func errorClass(err error) string {
+	switch {
+	case err == nil:
+		return ErrorClassNone
+	case errors.Is(err, RequestTimeout):
+		return ErrorClassTimeout
+	case err == context.Canceled || err == context.DeadlineExceeded:
+		return ErrorClassCancelled
+	case err == loadbalancer.NoInstances || err == NoAvailableInstances || err == NoMatchingInstance:
+		return ErrorClassNoInstances
+	case IsConnectError(err) || err == InstanceUnreachable:
+		return ErrorClassConnect
+	case conn.IsTransportError(err):
+		return ErrorClassTransport
+	case err == ConcurrencyLimitReached || err == loadbalancer.AllInstancesSaturated || err == InstanceRateLimited:
+		return ErrorClassConcurrencyLimit
+	}
+
+	return ErrorClassService
+}
+
+// counts the failures of a request sent in a single attempt, for SendMetrics. Attempts refused by the pool weren't sent
+func attemptFailures(attempts int, err error) (connect, call int) {
+	switch {
+	case attempts == 0 || err == nil:
+	case IsConnectError(err):
+		connect = 1
+	case err == pools.PoolExhausted || err == InstanceRateLimited || err == InstanceDraining || err == UnknownService || err == InstanceUnreachable:
+	default:
+		call = 1
+	}
+
+	return
+}
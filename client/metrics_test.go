@@ -0,0 +1,258 @@
+package client
+
+import (
+	"errors"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/test"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingMetrics struct {
+	sync.Mutex
+	sends []SendMetrics
+}
+
+func (m *recordingMetrics) RecordSend(s SendMetrics) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.sends = append(m.sends, s)
+}
+
+func (m *recordingMetrics) last(t *testing.T) SendMetrics {
+	m.Lock()
+	defer m.Unlock()
+
+	if len(m.sends) != 1 {
+		t.Fatal("Expected a single request to be recorded", len(m.sends))
+	}
+
+	return m.sends[0]
+}
+
+func TestMetricsRecordSuccess(t *testing.T) {
+	defer resetClient()
+
+	m := &recordingMetrics{}
+	SetMetrics(m)
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		return
+	})
+
+	var val string
+	sc.Send(nil, "Foo", val, &val)
+
+	s := m.last(t)
+
+	if s.Method != "Foo" || s.Service != "foo" || s.Attempts != 1 || s.Err != nil || s.ErrorClass != ErrorClassNone {
+		t.Fatal("Successful request not recorded correctly", s)
+	}
+
+	if s.Duration <= 0 {
+		t.Fatal("Request latency not recorded")
+	}
+}
+
+func TestMetricsRecordRetries(t *testing.T) {
+	defer resetClient()
+
+	m := &recordingMetrics{}
+	SetMetrics(m)
+
+	var mutex sync.Mutex
+	calls := 0
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		calls++
+		if calls == 1 {
+			return errors.New("Failed")
+		}
+
+		return
+	})
+
+	sClient := sc.(*ServiceClient)
+	sClient.retryMultiplier = 1
+
+	var val string
+	sClient.SetDefaultTimeout(time.Second, 5*time.Second)
+	sc.Send(nil, "Foo", val, &val)
+
+	if s := m.last(t); s.Attempts != 2 || s.Err != nil {
+		t.Fatal("Retried request not recorded correctly", s)
+	}
+}
+
+func TestMetricsRecordTimeout(t *testing.T) {
+	defer resetClient()
+
+	m := &recordingMetrics{}
+	SetMetrics(m)
+
+	block := make(chan bool)
+	defer close(block)
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		<-block
+		return
+	})
+
+	var val string
+	sc.SetDefaultTimeout(0, 10*time.Millisecond)
+	sc.Send(nil, "Foo", val, &val)
+
+	s := m.last(t)
+
+	if s.Err != RequestTimeout || s.ErrorClass != ErrorClassTimeout || s.Attempts != 1 {
+		t.Fatal("Timed out request not recorded correctly", s)
+	}
+
+	if s.Duration < 10*time.Millisecond {
+		t.Fatal("Request latency not recorded", s.Duration)
+	}
+}
+
+func TestMetricsRecordBatchCalls(t *testing.T) {
+	defer resetClient()
+
+	m := &recordingMetrics{}
+	SetMetrics(m)
+
+	callErr := errors.New("Call failed")
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		if fn == "Fail" {
+			return callErr
+		}
+
+		return
+	})
+
+	var a, b string
+	sc.SendBatch(nil, []skynet.Call{
+		skynet.Call{Fn: "Foo", In: a, Out: &a},
+		skynet.Call{Fn: "Fail", In: b, Out: &b},
+	})
+
+	m.Lock()
+	defer m.Unlock()
+
+	if len(m.sends) != 2 {
+		t.Fatal("Expected each call in the batch to be recorded", len(m.sends))
+	}
+
+	if s := m.sends[0]; s.Method != "Foo" || s.Service != "foo" || s.Attempts != 1 || s.Err != nil || s.ErrorClass != ErrorClassNone {
+		t.Fatal("Successful call not recorded correctly", s)
+	}
+
+	if s := m.sends[1]; s.Method != "Fail" || s.Err != callErr || s.ErrorClass != ErrorClassService {
+		t.Fatal("Failed call not recorded correctly", s)
+	}
+}
+
+func TestMetricsSeparateConnectFailures(t *testing.T) {
+	defer resetClient()
+
+	m := &recordingMetrics{}
+	SetMetrics(m)
+
+	// nothing is listening once it's closed
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to bind to port for test", err)
+	}
+	ln.Close()
+
+	si := serviceInfo()
+	si.ServiceAddr.IPAddress = "127.0.0.1"
+	si.ServiceAddr.Port = ln.Addr().(*net.TCPAddr).Port
+
+	p := NewPool()
+	setPool(p)
+	defer p.Close()
+
+	sc := GetService("foo", "1.0.0", "", "")
+	p.AddInstance(*si)
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: *si})
+
+	waitFor(t, func() bool {
+		return p.NumInstances() == 1 && sc.InstanceCount() == 1
+	})
+
+	var val string
+	err = sc.SendOnce(nil, "Foo", val, &val)
+
+	var ce ConnectError
+	if !errors.As(err, &ce) || ce.Addr != si.AddrString() {
+		t.Fatal("Request that couldn't connect to its instance should fail with ConnectError", err)
+	}
+
+	if s := m.last(t); s.ErrorClass != ErrorClassConnect || s.ConnectFailures != 1 || s.CallFailures != 0 {
+		t.Fatal("Failure to connect should be recorded apart from failed calls", s)
+	}
+}
+
+func TestMetricsCountFailedAttempts(t *testing.T) {
+	defer resetClient()
+
+	m := &recordingMetrics{}
+	SetMetrics(m)
+
+	sc := GetService("foo", "1.0.0", "", "")
+	sc.SetDefaultTimeout(time.Millisecond, time.Second)
+	sc.(*ServiceClient).retryMultiplier = 1
+
+	// the first attempt can't connect, the second's call fails and the third succeeds
+	var mutex sync.Mutex
+	acquired := 0
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if acquired++; acquired == 1 {
+				return nil, ConnectError{Addr: s.AddrString(), Err: conn.HandshakeFailed}
+			}
+
+			failed := acquired == 2
+
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) error {
+					if failed {
+						return conn.RemoteError{Message: "Call failed"}
+					}
+
+					return nil
+				},
+			}, nil
+		},
+	})
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: *serviceInfo()})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 1
+	})
+
+	var val string
+	if err := sc.Send(nil, "Foo", val, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := m.last(t); s.Attempts != 3 || s.ConnectFailures != 1 || s.CallFailures != 1 || s.ErrorClass != ErrorClassNone {
+		t.Fatal("Attempts that couldn't connect should be counted apart from failed calls", s)
+	}
+}
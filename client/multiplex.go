@@ -0,0 +1,85 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/config"
+	"sync"
+)
+
+/*
+With client.conn.multiplex set, concurrent requests to an instance share connections rather than each acquiring its own,
+up to that many requests per connection. A shared connection is acquired from the instance's pool by the first request
+to use it and released back to it by the last, once it's full or closed requests move on to another
+*/
+type sharedConnections struct {
+	mutex sync.Mutex
+	max   int
+
+	// the connection new requests share, and the requests using each shared connection
+	current conn.Connection
+	users   map[conn.Connection]int
+}
+
+func newSharedConnections(max int) *sharedConnections {
+	return &sharedConnections{
+		max:   max,
+		users: make(map[conn.Connection]int),
+	}
+}
+
+func (sc *sharedConnections) acquire(sp *servicePool) (conn.Connection, error) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	if sc.current == nil || sc.current.IsClosed() || sc.users[sc.current] >= sc.max {
+		c, err := sp.acquire()
+		if err != nil {
+			return nil, err
+		}
+
+		sc.current = c
+	}
+
+	sc.users[sc.current]++
+
+	return sc.current, nil
+}
+
+// returns true if c was the last request using the connection, and it should be released to the pool
+func (sc *sharedConnections) release(c conn.Connection) bool {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	if sc.users[c]--; sc.users[c] > 0 {
+		return false
+	}
+
+	delete(sc.users, c)
+
+	if sc.current == c {
+		sc.current = nil
+	}
+
+	return true
+}
+
+// new requests stop sharing the current connection, it's released to the pool by the last request using it
+func (sc *sharedConnections) renew() {
+	sc.mutex.Lock()
+	sc.current = nil
+	sc.mutex.Unlock()
+}
+
+func getMultiplexRequests(s skynet.ServiceInfo) int {
+	if n, err := config.Int(s.Name, s.Version, "client.conn.multiplex"); err == nil {
+		return n
+	}
+
+	return config.DefaultMultiplexRequests
+}
+
+// implemented by conn.Conn
+type multiplexed interface {
+	Multiplexed() bool
+}
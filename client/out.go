@@ -0,0 +1,43 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+)
+
+/*
+InvalidOutTypeError is returned when a request's out isn't a non-nil pointer that its response can be decoded into,
+the request isn't sent. Slices and maps are decoded into through a pointer to them
+*/
+type InvalidOutTypeError struct {
+	Method string
+
+	// nil if out was nil
+	Type reflect.Type
+}
+
+func (e InvalidOutTypeError) Error() string {
+	return fmt.Sprintf("Invalid out type for %s request: %v, out must be a non-nil pointer", e.Method, e.Type)
+}
+
+// responses are decoded into a new value of the type out points to, then copied into out once the request succeeds
+func checkOut(fn string, out interface{}) error {
+	v := reflect.ValueOf(out)
+
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return InvalidOutTypeError{Method: fn, Type: reflect.TypeOf(out)}
+	}
+
+	return nil
+}
+
+/*
+Copies a request's decoded result into the caller's out. out has a single writer, it's only written by the goroutine
+that sent the request, once, after the request succeeded, so attempts that lose never touch it. The caller mustn't read
+out until the request returns.
+The result replaces out's value rather than being merged into it, so a map or slice out that was already populated,
+ex: by a previous request, doesn't keep stale entries
+*/
+func copyOut(out, result interface{}) {
+	reflect.Indirect(reflect.ValueOf(out)).Set(reflect.Indirect(reflect.ValueOf(result)))
+}
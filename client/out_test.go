@@ -0,0 +1,59 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"testing"
+)
+
+func TestSendInvalidOutType(t *testing.T) {
+	defer resetClient()
+
+	type r struct {
+		Bar string
+	}
+
+	sc := GetService("foo", "1.0.0", "", "")
+
+	sent := false
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		sent = true
+		return
+	})
+
+	for _, out := range []interface{}{[]string{}, r{}, nil, (*r)(nil)} {
+		err := sc.Send(nil, "Foo", "bar", out)
+
+		if _, ok := err.(InvalidOutTypeError); !ok {
+			t.Fatalf("Send() with out %#v should return an InvalidOutTypeError, got %v", out, err)
+		}
+	}
+
+	if errs := sc.SendBatch(nil, []skynet.Call{{Fn: "Foo", In: "bar", Out: r{}}}); errs[0] == nil {
+		t.Fatal("SendBatch() should return an InvalidOutTypeError for a call with an invalid out")
+	}
+
+	if sent {
+		t.Fatal("Requests with an invalid out should not be sent")
+	}
+}
+
+func TestSendReplacesPopulatedOut(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		*out.(*map[string]string) = map[string]string{"fresh": "y"}
+		return
+	})
+
+	out := map[string]string{"stale": "x"}
+
+	if err := sc.Send(nil, "Foo", "bar", &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := out["stale"]; ok || out["fresh"] != "y" {
+		t.Fatal("The response should replace out's entries rather than being merged into them", out)
+	}
+}
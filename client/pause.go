@@ -0,0 +1,68 @@
+package client
+
+import (
+	"fmt"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/config"
+)
+
+// messages sent to mux() to pause and resume applying instance notifications
+type discoveryPause struct{}
+type discoveryResume struct{}
+
+/*
+ServiceClient.PauseDiscovery() freezes the instances requests are routed to, such as during a coordinated deploy.
+Instance notifications are held until ResumeDiscovery() is called, then applied in the order they arrived. Should more
+than client.discovery.pause.max arrive discovery resumes, so the client doesn't fall too far behind.
+Connection pools aren't paused, requests to an instance removed in the meantime are retried on another
+*/
+func (c *ServiceClient) PauseDiscovery() {
+	c.sendMux(discoveryPause{})
+}
+
+/*
+ServiceClient.ResumeDiscovery() applies the instance notifications held since PauseDiscovery() was called
+*/
+func (c *ServiceClient) ResumeDiscovery() {
+	c.sendMux(discoveryResume{})
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) pauseDiscovery() {
+	c.discoveryPaused = true
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) resumeDiscovery() {
+	c.discoveryPaused = false
+
+	for _, n := range c.heldNotifications {
+		c.handleInstanceNotification(n)
+	}
+
+	c.heldNotifications = nil
+}
+
+// this should only be called by mux(), returns false if n should be applied now
+func (c *ServiceClient) holdNotification(n skynet.InstanceNotification) bool {
+	if !c.discoveryPaused {
+		return false
+	}
+
+	c.heldNotifications = append(c.heldNotifications, n)
+
+	if c.maxHeldNotifications > 0 && len(c.heldNotifications) > c.maxHeldNotifications {
+		logger.Warn(fmt.Sprintf("Resuming discovery of %s, more than %d instance notifications were held", c.criteria.Services[0].Name, c.maxHeldNotifications))
+		c.resumeDiscovery()
+	}
+
+	return true
+}
+
+func getMaxHeldNotifications(service, version string) int {
+	if n, err := config.Int(service, version, "client.discovery.pause.max"); err == nil {
+		return n
+	}
+
+	return config.DefaultMaxHeldNotifications
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/config"
+	"testing"
+)
+
+// waits for mux() to have applied or held every notification sent to sc
+func waitForNotifications(t *testing.T, sc *ServiceClient) {
+	waitFor(t, func() bool {
+		return len(sc.instanceNotifications) == 0
+	})
+
+	// a round trip through mux() so the last notification received has been handled
+	sc.ListInstances()
+}
+
+func uuids(instances []skynet.InstanceInfo) map[string]bool {
+	m := make(map[string]bool)
+	for _, s := range instances {
+		m[s.UUID] = true
+	}
+
+	return m
+}
+
+func TestPauseDiscoveryHoldsNotificationsUntilResumed(t *testing.T) {
+	defer resetClient()
+
+	a, b := *serviceInfo(), *serviceInfo()
+	a.UUID, b.UUID = config.NewUUID(), config.NewUUID()
+	b.ServiceAddr.Port = a.ServiceAddr.Port + 1
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}}).(*ServiceClient)
+	defer sc.Close()
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: a})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 1
+	})
+
+	sc.PauseDiscovery()
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: b})
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceRemoved, Service: a})
+	waitForNotifications(t, sc)
+
+	if instances := uuids(sc.ListInstances()); len(instances) != 1 || !instances[a.UUID] {
+		t.Fatal("Instances shouldn't change while discovery is paused", instances)
+	}
+
+	sc.ResumeDiscovery()
+
+	if instances := uuids(sc.ListInstances()); len(instances) != 1 || !instances[b.UUID] {
+		t.Fatal("Notifications held while discovery was paused should be applied once it resumes", instances)
+	}
+
+	// discovery resumes once more than client.discovery.pause.max notifications are held
+	sc.maxHeldNotifications = 1
+
+	sc.PauseDiscovery()
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: a})
+	waitForNotifications(t, sc)
+
+	if instances := uuids(sc.ListInstances()); len(instances) != 1 {
+		t.Fatal("Instances shouldn't change while discovery is paused", instances)
+	}
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceRemoved, Service: b})
+	waitForNotifications(t, sc)
+
+	if instances := uuids(sc.ListInstances()); len(instances) != 1 || !instances[a.UUID] {
+		t.Fatal("Discovery should resume once more than client.discovery.pause.max notifications are held", instances)
+	}
+}
@@ -2,13 +2,55 @@ package client
 
 import (
 	"errors"
+	"fmt"
 	"github.com/skynetservices/skynet"
 	"github.com/skynetservices/skynet/client/conn"
 	"github.com/skynetservices/skynet/pools"
+	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var UnknownService = errors.New("Service not known to connection pool")
+var InstanceDraining = errors.New("Instance is draining, no new connections can be acquired")
+
+/*
+client.ConnectError is returned when a connection to an instance couldn't be opened, such as the dial being refused or
+the handshake rejected, so the request wasn't sent to it. Err is the error from opening the connection
+*/
+type ConnectError struct {
+	Addr string
+	Err  error
+}
+
+func (ce ConnectError) Error() string {
+	return "Failed to connect to " + ce.Addr + ": " + ce.Err.Error()
+}
+
+// ConnectError.Unwrap() returns Err, so errors.Is() matches it, ex: errors.Is(err, conn.HandshakeFailed)
+func (ce ConnectError) Unwrap() error {
+	return ce.Err
+}
+
+// client.IsConnectError() determines if err was caused by failing to open a connection to an instance
+func IsConnectError(err error) bool {
+	_, ok := err.(ConnectError)
+	return ok
+}
+
+/*
+client.InstanceKey() identifies the connection pool for s, instances of different services that share an address
+(ex: behind a proxy) are kept apart by name. The version isn't included as an instance may be updated to a new version in place
+*/
+func InstanceKey(s skynet.ServiceInfo) string {
+	return instanceKey(s.Name, s.AddrString())
+}
+
+// addresses can't contain an @, so keys are unique even if names do
+func instanceKey(name, addr string) string {
+	return name + "@" + addr
+}
 
 type ConnectionPooler interface {
 	AddInstance(s skynet.ServiceInfo)
@@ -17,22 +59,42 @@ type ConnectionPooler interface {
 
 	Acquire(s skynet.ServiceInfo) (conn.Connection, error)
 	Release(conn.Connection)
+	Flush(s skynet.ServiceInfo)
 
 	Close()
 	NumInstances() int
 	NumConnections() int
+	NumActiveConnections(s skynet.ServiceInfo) int
+	NumInFlight(s skynet.ServiceInfo) int
+	Stats(instances ...skynet.ServiceInfo) map[string]pools.Stats
 }
 
 /*
 client.Pool Manages connection pools to services
 */
 type Pool struct {
+	// keyed by InstanceKey(), only written from mux(), Acquire and Release read it from the caller's goroutine
 	servicePools       map[string]*servicePool
+	servicePoolsMutex  sync.RWMutex
 	addInstanceChan    chan skynet.ServiceInfo
 	updateInstanceChan chan skynet.ServiceInfo
 	removeInstanceChan chan skynet.ServiceInfo
+	drainedChan        chan *servicePool
+	statsChan          chan statsRequest
 	closeChan          chan bool
 	closeWait          sync.WaitGroup
+	done               chan struct{}
+
+	// replaced in tests to warm pools without changing config
+	warmConnections func(s skynet.ServiceInfo) int
+
+	// replaced in tests to multiplex connections without changing config
+	multiplexRequests func(s skynet.ServiceInfo) int
+}
+
+type statsRequest struct {
+	instances []skynet.ServiceInfo
+	resp      chan map[string]pools.Stats
 }
 
 /*
@@ -44,7 +106,12 @@ func NewPool() *Pool {
 		addInstanceChan:    make(chan skynet.ServiceInfo, 10),
 		updateInstanceChan: make(chan skynet.ServiceInfo, 10),
 		removeInstanceChan: make(chan skynet.ServiceInfo, 10),
+		drainedChan:        make(chan *servicePool, 10),
+		statsChan:          make(chan statsRequest),
 		closeChan:          make(chan bool),
+		done:               make(chan struct{}),
+		warmConnections:    getWarmConnections,
+		multiplexRequests:  getMultiplexRequests,
 	}
 
 	go p.mux()
@@ -55,6 +122,60 @@ func NewPool() *Pool {
 type servicePool struct {
 	service skynet.ServiceInfo
 	pool    *pools.ResourcePool
+
+	// how long Acquire waits for a connection once the pool is at client.conn.max, see getAcquireTimeout()
+	acquireTimeout time.Duration
+
+	// nil unless client.instance.rate is set
+	limiter     *rateLimiter
+	limiterWait time.Duration
+
+	// nil unless client.conn.multiplex is set
+	shared *sharedConnections
+
+	// nil unless client.conn.backoff is set
+	connectBackoff *connectBackoff
+
+	// connections acquired through the Pool and not yet released, once an instance is removed the
+	// servicePool drains, refusing new acquires and closing when the last of them is released
+	outstanding int32
+	draining    int32
+
+	// another instance discovered at the address, its servicePool is created once this one has drained,
+	// only accessed from mux()
+	replacement *skynet.ServiceInfo
+}
+
+// reserve must be called before acquiring a connection, if it returns false the pool is draining and
+// the reservation must be undone
+func (sp *servicePool) reserve() bool {
+	atomic.AddInt32(&sp.outstanding, 1)
+
+	return !sp.isDraining()
+}
+
+// unreserve returns true if this was the last outstanding connection of a draining pool
+func (sp *servicePool) unreserve() bool {
+	return atomic.AddInt32(&sp.outstanding, -1) == 0 && sp.isDraining()
+}
+
+func (sp *servicePool) isDraining() bool {
+	return atomic.LoadInt32(&sp.draining) == 1
+}
+
+// returns true if there are no outstanding connections and the pool can be closed immediately
+func (sp *servicePool) drain() bool {
+	atomic.StoreInt32(&sp.draining, 1)
+
+	return atomic.LoadInt32(&sp.outstanding) == 0
+}
+
+func (sp *servicePool) renew() {
+	if sp.shared != nil {
+		sp.shared.renew()
+	}
+
+	sp.pool.Renew()
 }
 
 func (sp *servicePool) Close() {
@@ -65,6 +186,10 @@ func (sp *servicePool) NumResources() int {
 	return sp.pool.NumResources()
 }
 
+func (sp *servicePool) NumActiveResources() int {
+	return sp.pool.InUse()
+}
+
 func (p *Pool) mux() {
 	for {
 		select {
@@ -74,6 +199,10 @@ func (p *Pool) mux() {
 			p.removeInstanceMux(i)
 		case i := <-p.updateInstanceChan:
 			p.updateInstanceMux(i)
+		case sp := <-p.drainedChan:
+			p.drainedMux(sp)
+		case r := <-p.statsChan:
+			r.resp <- p.statsMux(r.instances)
 		case <-p.closeChan:
 			p.closeMux()
 			return
@@ -90,27 +219,135 @@ func (p *Pool) AddInstance(s skynet.ServiceInfo) {
 	}()
 }
 
+// options connections to s are made with
+func connectionOptions(s skynet.ServiceInfo) conn.Options {
+	return conn.Options{
+		TLSConfig:        tlsConfig,
+		Codec:            codec,
+		Compressors:      compressors,
+		HandshakeTimeout: getHandshakeTimeout(s),
+		Dial:             dial,
+		Identity:         identity,
+		MaxResponseBytes: getMaxResponseBytes(s),
+	}
+}
+
+/*
+Instances discovered again at an address are given its existing servicePool, if another instance has taken
+the address its connections are to a process that's gone, so the servicePool is drained and closed before
+one is created for the new instance
+*/
 func (p *Pool) addInstanceMux(s skynet.ServiceInfo) {
-	if _, ok := p.servicePools[s.AddrString()]; !ok {
-		sp := &servicePool{
-			service: s,
-			pool: pools.NewResourcePool(func() (pools.Resource, error) {
-				c, err := conn.NewConnection(s.Name, GetNetwork(), s.AddrString(), DIAL_TIMEOUT)
-
-				if err == nil {
-					c.SetIdleTimeout(getIdleTimeout(s))
-				}
-
-				return c, err
-			},
-				getIdleConnectionsToInstance(s),
-				getMaxConnectionsToInstance(s)),
+	sp, ok := p.servicePools[InstanceKey(s)]
+
+	switch {
+	case !ok:
+		p.newServicePool(s)
+	case sameInstance(sp.service, s):
+		// the instance came back before it finished draining, or was discovered twice
+		sp.replacement = nil
+		atomic.StoreInt32(&sp.draining, 0)
+		p.refreshServicePool(sp, s)
+	default:
+		p.replaceServicePool(sp, s)
+	}
+}
+
+func (p *Pool) newServicePool(s skynet.ServiceInfo) {
+	multiplex := p.multiplexRequests(s)
+
+	var sp *servicePool
+
+	sp = &servicePool{
+		service: s,
+		pool: pools.NewResourcePool(func() (pools.Resource, error) {
+			// the backoff is shared by every connection to the instance, so it isn't dialed by every Acquire while it's down
+			if !sp.connectBackoff.allow() {
+				return nil, InstanceUnreachable
+			}
+
+			opts := connectionOptions(s)
+			opts.Multiplex = multiplex > 0
+
+			c, err := conn.NewConnectionWithOptions(s.Name, GetNetwork(), s.AddrString(), getDialTimeout(s), opts)
+			if err != nil {
+				sp.connectBackoff.failed()
+				return nil, ConnectError{Addr: s.AddrString(), Err: err}
+			}
+
+			sp.connectBackoff.succeeded()
+
+			c.SetIdleTimeout(getIdleTimeout(s))
+			c.SetHealthCheck(getStaleTimeout(s), getPingMethod(s))
+
+			return c, nil
+		},
+			getIdleConnectionsToInstance(s),
+			getMaxConnectionsToInstance(s)),
+		acquireTimeout: getAcquireTimeout(s),
+		limiter:        newRateLimiter(getInstanceRate(s), getInstanceBurst(s)),
+		limiterWait:    getInstanceRateWait(s),
+		connectBackoff: newConnectBackoff(getConnectBackoff(s), getConnectBackoffMax(s)),
+	}
+
+	if multiplex > 0 {
+		sp.shared = newSharedConnections(multiplex)
+	}
+
+	sp.pool.SetExpiration(getIdleTimeout(s), getMaxLifetime(s))
+	sp.pool.SetMinResources(getMinConnectionsToInstance(s))
+	sp.pool.SetKeepAlive(getKeepAliveInterval(s))
+
+	p.servicePoolsMutex.Lock()
+	p.servicePools[InstanceKey(s)] = sp
+	p.servicePoolsMutex.Unlock()
+
+	// Connections beyond the idle capacity would be closed as soon as they're released
+	if n := p.warmConnections(s); n > 0 {
+		if idle := getIdleConnectionsToInstance(s); n > idle {
+			n = idle
 		}
 
-		p.servicePools[s.AddrString()] = sp
-	} else {
-		p.UpdateInstance(s)
+		go p.warm(sp, n)
+	}
+}
+
+// only call from mux(), Acquire fails with InstanceDraining until the connections to the old instance are released
+func (p *Pool) replaceServicePool(sp *servicePool, s skynet.ServiceInfo) {
+	logger.Debug(fmt.Sprintf("Instance %s replaced %s at %s, closing its connections", s.UUID, sp.service.UUID, s.AddrString()))
+
+	if !sp.drain() {
+		sp.replacement = &s
+		return
+	}
+
+	sp.Close()
+	p.deleteServicePool(InstanceKey(s))
+	p.newServicePool(s)
+}
+
+/*
+only call from mux(), connections handshaked with the instance as it was defined before a change that the service
+advertises in its handshake are renewed, idle ones are closed now and those in use once they're released, so new
+connections are made that handshake again rather than requests using negotiated settings that are out of date
+*/
+func (p *Pool) refreshServicePool(sp *servicePool, s skynet.ServiceInfo) {
+	if handshakeChanged(sp.service, s) {
+		logger.Debug(fmt.Sprintf("Instance %s at %s changed, renewing its connections", s.UUID, s.AddrString()))
+		sp.renew()
 	}
+
+	sp.service = s
+}
+
+// the service's registration and capabilities, as advertised by its labels, are part of its handshake
+func handshakeChanged(a, b skynet.ServiceInfo) bool {
+	return a.Registered != b.Registered || !reflect.DeepEqual(a.Labels, b.Labels)
+}
+
+// instances are the same if connections to one can be used for the other, their settings are read by name and version
+func sameInstance(a, b skynet.ServiceInfo) bool {
+	return a.UUID == b.UUID && a.Version == b.Version
 }
 
 /*
@@ -123,16 +360,27 @@ func (p *Pool) UpdateInstance(s skynet.ServiceInfo) {
 }
 
 func (p *Pool) updateInstanceMux(s skynet.ServiceInfo) {
-	if _, ok := p.servicePools[s.AddrString()]; !ok {
+	sp, ok := p.servicePools[InstanceKey(s)]
+	if !ok {
 		p.AddInstance(s)
 		return
 	}
 
-	p.servicePools[s.AddrString()].service = s
+	switch {
+	case sp.replacement != nil:
+		if sp.replacement.UUID == s.UUID {
+			sp.replacement = &s
+		}
+	case sameInstance(sp.service, s):
+		p.refreshServicePool(sp, s)
+	case !sp.isDraining():
+		p.replaceServicePool(sp, s)
+	}
 }
 
 /*
-Pool.RemoveInstance removes this instance from the pool and closes all it's connections
+Pool.RemoveInstance removes this instance from the pool and closes all it's connections. Connections that are
+currently acquired are allowed to finish, the instance is drained and closed once they've all been released
 */
 func (p *Pool) RemoveInstance(s skynet.ServiceInfo) {
 	go func() {
@@ -141,19 +389,141 @@ func (p *Pool) RemoveInstance(s skynet.ServiceInfo) {
 }
 
 func (p *Pool) removeInstanceMux(s skynet.ServiceInfo) {
-	delete(p.servicePools, s.AddrString())
+	sp, ok := p.servicePools[InstanceKey(s)]
+	if !ok {
+		return
+	}
+
+	// the servicePool is already draining to be replaced, only the replacement may need cancelling
+	if sp.replacement != nil {
+		if sp.replacement.UUID == s.UUID {
+			sp.replacement = nil
+		}
+
+		return
+	}
+
+	// the instance was already replaced at the address
+	if sp.service.UUID != s.UUID {
+		return
+	}
+
+	if !sp.drain() {
+		logger.Debug(fmt.Sprintf("Draining %d connections to %s", atomic.LoadInt32(&sp.outstanding), s.AddrString()))
+		return
+	}
+
+	sp.Close()
+	p.deleteServicePool(InstanceKey(s))
+}
+
+func (p *Pool) drainedMux(sp *servicePool) {
+	// the instance may have been added again, or already closed
+	if p.servicePools[InstanceKey(sp.service)] != sp || !sp.isDraining() || atomic.LoadInt32(&sp.outstanding) != 0 {
+		return
+	}
+
+	sp.Close()
+	p.deleteServicePool(InstanceKey(sp.service))
+
+	if sp.replacement != nil {
+		p.newServicePool(*sp.replacement)
+	}
+}
+
+// Opens connections ahead of the first request, failures are logged and left to be retried when a request needs them.
+// Connections are reserved like any other so that a removed instance isn't closed until warming finishes
+func (p *Pool) warm(sp *servicePool, n int) {
+	resources := []pools.Resource{}
+
+	for i := 0; i < n; i++ {
+		if !sp.reserve() {
+			p.unreserve(sp)
+			break
+		}
+
+		r, err := sp.pool.Acquire()
+		if err != nil {
+			p.unreserve(sp)
+			logger.Warn(fmt.Sprintf("Failed to warm connection to %s: %v", sp.service.AddrString(), err))
+			break
+		}
+
+		resources = append(resources, r)
+	}
+
+	for _, r := range resources {
+		// the instance was removed while warming, there's no one to use the connection
+		if sp.isDraining() {
+			r.Close()
+		}
+
+		sp.pool.Release(r)
+		p.unreserve(sp)
+	}
+}
+
+func (p *Pool) deleteServicePool(key string) {
+	p.servicePoolsMutex.Lock()
+	delete(p.servicePools, key)
+	p.servicePoolsMutex.Unlock()
+}
+
+// servicePool is safe to call outside of mux()
+func (p *Pool) servicePool(key string) (sp *servicePool, ok bool) {
+	p.servicePoolsMutex.RLock()
+	sp, ok = p.servicePools[key]
+	p.servicePoolsMutex.RUnlock()
+
+	return
+}
+
+// the last connection to be released from a draining pool closes it
+func (p *Pool) unreserve(sp *servicePool) {
+	if sp.unreserve() {
+		go func() {
+			p.drainedChan <- sp
+		}()
+	}
 }
 
 /*
-Pool.Acquire will return an idle connection or a new one
+Pool.Acquire will return an idle connection or a new one. If the instance already has its maximum number of connections
+and none is released within client.conn.acquire.timeout pools.PoolExhausted is returned, so another instance can be tried.
+Likewise InstanceRateLimited is returned if the instance is over client.instance.rate for longer than client.instance.rate.wait
 */
 func (p *Pool) Acquire(s skynet.ServiceInfo) (c conn.Connection, err error) {
-	if _, ok := p.servicePools[s.AddrString()]; !ok {
+	sp, ok := p.servicePool(InstanceKey(s))
+	if !ok {
 		return nil, UnknownService
 	}
 
-	r, err := p.servicePools[s.AddrString()].pool.Acquire()
+	if !sp.reserve() {
+		p.unreserve(sp)
+		return nil, InstanceDraining
+	}
+
+	if !sp.limiter.wait(sp.limiterWait) {
+		p.unreserve(sp)
+		return nil, InstanceRateLimited
+	}
+
+	if sp.shared != nil {
+		c, err = sp.shared.acquire(sp)
+	} else {
+		c, err = sp.acquire()
+	}
 
+	if err != nil {
+		p.unreserve(sp)
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (sp *servicePool) acquire() (conn.Connection, error) {
+	r, err := sp.pool.AcquireTimeout(sp.acquireTimeout)
 	if err != nil {
 		return nil, err
 	}
@@ -166,12 +536,33 @@ Pool.Release will release a resource for use by others. If the idle queue is
 full, the resource will be closed.
 */
 func (p *Pool) Release(c conn.Connection) {
-	if _, ok := p.servicePools[c.Addr()]; !ok {
+	sp, ok := p.servicePool(instanceKey(c.ServiceName(), c.Addr()))
+	if !ok {
 		c.Close()
 		return
 	}
 
-	p.servicePools[c.Addr()].pool.Release(c)
+	// a shared connection goes back to the pool once the last request using it releases it
+	if sp.shared == nil || sp.shared.release(c) {
+		// draining pools don't hand out connections again, close it rather than leave it idle
+		if sp.isDraining() {
+			c.Close()
+		}
+
+		sp.pool.Release(c)
+	}
+
+	p.unreserve(sp)
+}
+
+/*
+Pool.Flush will close the idle connections to the instance, so requests are sent over new connections rather than
+ones that may have broken together, ex: during a network partition
+*/
+func (p *Pool) Flush(s skynet.ServiceInfo) {
+	if sp, ok := p.servicePool(InstanceKey(s)); ok {
+		sp.pool.Flush()
+	}
 }
 
 /*
@@ -187,9 +578,10 @@ func (p *Pool) Close() {
 func (p *Pool) closeMux() {
 	for k, sp := range p.servicePools {
 		sp.Close()
-		delete(p.servicePools, k)
+		p.deleteServicePool(k)
 	}
 
+	close(p.done)
 	p.closeWait.Done()
 }
 
@@ -198,6 +590,9 @@ Pool.NumConnections will return the total number of connections across all insta
 as many connections could be opening and closing this is an estimate
 */
 func (p *Pool) NumConnections() (count int) {
+	p.servicePoolsMutex.RLock()
+	defer p.servicePoolsMutex.RUnlock()
+
 	for _, sp := range p.servicePools {
 		count += sp.NumResources()
 	}
@@ -205,9 +600,62 @@ func (p *Pool) NumConnections() (count int) {
 	return count
 }
 
+/*
+Pool.NumActiveConnections will return the number of connections to the instance that are currently acquired
+*/
+func (p *Pool) NumActiveConnections(s skynet.ServiceInfo) int {
+	if sp, ok := p.servicePool(InstanceKey(s)); ok {
+		return sp.NumActiveResources()
+	}
+
+	return 0
+}
+
+/*
+Pool.NumInFlight will return the number of requests to the instance that have acquired a connection, or are waiting for one,
+and not yet released it. Requests sharing a multiplexed connection are counted separately
+*/
+func (p *Pool) NumInFlight(s skynet.ServiceInfo) int {
+	if sp, ok := p.servicePool(InstanceKey(s)); ok {
+		return int(atomic.LoadInt32(&sp.outstanding))
+	}
+
+	return 0
+}
+
+/*
+Pool.Stats returns a snapshot of the connection pools to each of the instances, keyed by their address.
+Instances unknown to the pool are omitted
+*/
+func (p *Pool) Stats(instances ...skynet.ServiceInfo) map[string]pools.Stats {
+	r := statsRequest{instances: instances, resp: make(chan map[string]pools.Stats, 1)}
+
+	select {
+	case p.statsChan <- r:
+		return <-r.resp
+	case <-p.done:
+		return map[string]pools.Stats{}
+	}
+}
+
+func (p *Pool) statsMux(instances []skynet.ServiceInfo) map[string]pools.Stats {
+	stats := make(map[string]pools.Stats)
+
+	for _, s := range instances {
+		if sp, ok := p.servicePools[InstanceKey(s)]; ok {
+			stats[s.AddrString()] = sp.pool.Stats()
+		}
+	}
+
+	return stats
+}
+
 /*
 Pool.NumInstances will return the number of unique instances it's maintaining connections too
 */
 func (p *Pool) NumInstances() int {
+	p.servicePoolsMutex.RLock()
+	defer p.servicePoolsMutex.RUnlock()
+
 	return len(p.servicePools)
 }
@@ -1,8 +1,15 @@
 package client
 
 import (
+	"errors"
 	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/config"
+	"github.com/skynetservices/skynet/rpc/bsonrpc"
+	"net"
+	"sync"
 	"testing"
+	"time"
 )
 
 // TODO: need tests
@@ -22,3 +29,642 @@ func TestPoolClose(t *testing.T) {
 		t.Fatal("Close() did not close all service pools")
 	}
 }
+
+func TestPoolWarmsConnectionsOnDiscovery(t *testing.T) {
+	ln, accepted := listenForHandshakes(t, "TestService")
+	defer ln.Close()
+
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.Registered = true
+	si.ServiceAddr.IPAddress = "127.0.0.1"
+	si.ServiceAddr.Port = ln.Addr().(*net.TCPAddr).Port
+
+	p := NewPool()
+	p.warmConnections = func(s skynet.ServiceInfo) int {
+		return 2
+	}
+	defer p.Close()
+
+	p.AddInstance(*si)
+
+	waitFor(t, func() bool {
+		return accepted.count() == 2
+	})
+
+	waitFor(t, func() bool {
+		return p.Stats(*si)[si.AddrString()].Resources == 2
+	})
+
+	if p.NumActiveConnections(*si) != 0 {
+		t.Fatal("Warmed connections should be left idle in the pool")
+	}
+}
+
+func TestPoolDrainsRemovedInstance(t *testing.T) {
+	ln, _ := listenForHandshakes(t, "TestService")
+	defer ln.Close()
+
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.Registered = true
+	si.ServiceAddr.IPAddress = "127.0.0.1"
+	si.ServiceAddr.Port = ln.Addr().(*net.TCPAddr).Port
+
+	p := NewPool()
+	defer p.Close()
+
+	p.AddInstance(*si)
+
+	waitFor(t, func() bool {
+		return p.NumInstances() == 1
+	})
+
+	c, err := p.Acquire(*si)
+	if err != nil {
+		t.Fatal("Failed to acquire connection", err)
+	}
+
+	p.RemoveInstance(*si)
+
+	waitFor(t, func() bool {
+		c, err := p.Acquire(*si)
+		if err == nil {
+			p.Release(c)
+		}
+
+		return err == InstanceDraining
+	})
+
+	if c.IsClosed() || p.NumInstances() != 1 {
+		t.Fatal("Outstanding connection should be allowed to finish before the instance is closed")
+	}
+
+	p.Release(c)
+
+	waitFor(t, func() bool {
+		return p.NumInstances() == 0
+	})
+
+	if !c.IsClosed() {
+		t.Fatal("Connection should be closed once released from a draining instance")
+	}
+}
+
+func TestPoolKeepsRediscoveredInstance(t *testing.T) {
+	ln, accepted := listenForHandshakes(t, "TestService")
+	defer ln.Close()
+
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.Registered = true
+	si.ServiceAddr.IPAddress = "127.0.0.1"
+	si.ServiceAddr.Port = ln.Addr().(*net.TCPAddr).Port
+
+	p := NewPool()
+	p.warmConnections = func(s skynet.ServiceInfo) int {
+		return 2
+	}
+	defer p.Close()
+
+	p.AddInstance(*si)
+
+	waitFor(t, func() bool {
+		return p.Stats(*si)[si.AddrString()].Resources == 2
+	})
+
+	sp, _ := p.servicePool(InstanceKey(*si))
+
+	p.AddInstance(*si)
+	p.UpdateInstance(*si)
+
+	// give the duplicates time to be handled, a new servicePool would warm its own connections
+	time.Sleep(20 * time.Millisecond)
+
+	if current, _ := p.servicePool(InstanceKey(*si)); current != sp || p.NumInstances() != 1 {
+		t.Fatal("Instance discovered again should keep its servicePool")
+	}
+
+	if accepted.count() != 2 || p.Stats(*si)[si.AddrString()].Resources != 2 {
+		t.Fatal("Instance discovered again shouldn't open more connections", accepted.count())
+	}
+}
+
+func TestPoolReplacesChangedInstance(t *testing.T) {
+	ln, _ := listenForHandshakes(t, "TestService")
+	defer ln.Close()
+
+	old := skynet.NewServiceInfo("TestService", "1.0.0")
+	old.UUID = config.NewUUID()
+	old.Registered = true
+	old.ServiceAddr.IPAddress = "127.0.0.1"
+	old.ServiceAddr.Port = ln.Addr().(*net.TCPAddr).Port
+
+	replacement := *old
+	replacement.UUID = config.NewUUID()
+
+	p := NewPool()
+	defer p.Close()
+
+	p.AddInstance(*old)
+
+	waitFor(t, func() bool {
+		return p.NumInstances() == 1
+	})
+
+	c, err := p.Acquire(*old)
+	if err != nil {
+		t.Fatal("Failed to acquire connection", err)
+	}
+
+	sp, _ := p.servicePool(InstanceKey(*old))
+
+	p.AddInstance(replacement)
+
+	waitFor(t, sp.isDraining)
+
+	if c.IsClosed() {
+		t.Fatal("Outstanding connection to the old instance should be allowed to finish")
+	}
+
+	p.Release(c)
+
+	waitFor(t, func() bool {
+		current, ok := p.servicePool(InstanceKey(replacement))
+		return ok && current != sp
+	})
+
+	if !c.IsClosed() {
+		t.Fatal("Connections to the old instance should be closed before it's replaced")
+	}
+
+	if current, _ := p.servicePool(InstanceKey(replacement)); current.service.UUID != replacement.UUID || p.NumInstances() != 1 {
+		t.Fatal("Only the replacement's servicePool should remain")
+	}
+
+	// a late removal of the old instance leaves the replacement alone
+	p.RemoveInstance(*old)
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := p.Acquire(replacement); err != nil {
+		t.Fatal("Removing the old instance shouldn't remove its replacement", err)
+	}
+}
+
+func TestPoolRenewsConnectionsWhenInstanceChanges(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to bind to port for test", err)
+	}
+	defer ln.Close()
+
+	// the capability the service advertises in its handshake
+	var mutex sync.Mutex
+	capability := "v1"
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			mutex.Lock()
+			h := skynet.ServiceHandshake{Name: "TestService", Registered: true, ClientID: "abc", Metadata: map[string]string{"capability": capability}}
+			mutex.Unlock()
+
+			if bsonrpc.NewEncoder(c).Encode(h) == nil {
+				var ch skynet.ClientHandshake
+				bsonrpc.NewDecoder(c).Decode(&ch)
+			}
+		}
+	}()
+
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.Registered = true
+	si.Labels = map[string]string{"capability": "v1"}
+	si.ServiceAddr.IPAddress = "127.0.0.1"
+	si.ServiceAddr.Port = ln.Addr().(*net.TCPAddr).Port
+
+	p := NewPool()
+	defer p.Close()
+
+	p.AddInstance(*si)
+
+	waitFor(t, func() bool {
+		return p.NumInstances() == 1
+	})
+
+	inUse, err := p.Acquire(*si)
+	if err != nil {
+		t.Fatal("Failed to acquire connection", err)
+	}
+
+	idle, err := p.Acquire(*si)
+	if err != nil {
+		t.Fatal("Failed to acquire connection", err)
+	}
+
+	p.Release(idle)
+
+	mutex.Lock()
+	capability = "v2"
+	mutex.Unlock()
+
+	changed := *si
+	changed.Labels = map[string]string{"capability": "v2"}
+	p.UpdateInstance(changed)
+
+	waitFor(t, idle.IsClosed)
+
+	if inUse.IsClosed() {
+		t.Fatal("Connections in use should be allowed to finish before they're renewed")
+	}
+
+	p.Release(inUse)
+
+	waitFor(t, inUse.IsClosed)
+
+	c, err := p.Acquire(changed)
+	if err != nil {
+		t.Fatal("Failed to acquire connection", err)
+	}
+	defer p.Release(c)
+
+	if h := c.(*conn.Conn).ServiceHandshake(); h.Metadata["capability"] != "v2" {
+		t.Fatal("Connections acquired after the instance changed should use its new handshake", h.Metadata)
+	}
+}
+
+func TestPoolRemovedWhileWarming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to bind to port for test", err)
+	}
+	defer ln.Close()
+
+	// the handshake is held until the instance has been removed, then we watch for the client closing the connection
+	accepted := make(chan bool, 1)
+	handshake := make(chan bool)
+	closed := make(chan bool, 1)
+
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		accepted <- true
+		<-handshake
+
+		bsonrpc.NewEncoder(c).Encode(skynet.ServiceHandshake{Name: "TestService", Registered: true, ClientID: "abc"})
+
+		var ch skynet.ClientHandshake
+		bsonrpc.NewDecoder(c).Decode(&ch)
+
+		c.Read(make([]byte, 1))
+		closed <- true
+	}()
+
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.Registered = true
+	si.ServiceAddr.IPAddress = "127.0.0.1"
+	si.ServiceAddr.Port = ln.Addr().(*net.TCPAddr).Port
+
+	p := NewPool()
+	p.warmConnections = func(s skynet.ServiceInfo) int {
+		return 1
+	}
+	defer p.Close()
+
+	p.AddInstance(*si)
+	<-accepted
+
+	p.RemoveInstance(*si)
+
+	// warming holds a reservation, so the instance drains rather than closing under it
+	waitFor(t, func() bool {
+		sp, ok := p.servicePool(InstanceKey(*si))
+		return !ok || sp.isDraining()
+	})
+
+	if p.NumInstances() != 1 {
+		t.Fatal("Instance should drain until warming finishes")
+	}
+
+	close(handshake)
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Connection warmed for a removed instance should be closed")
+	}
+
+	waitFor(t, func() bool {
+		return p.NumInstances() == 0
+	})
+}
+
+func TestPoolKeepsServicesSharingAddressApart(t *testing.T) {
+	ln, _ := listenForHandshakes(t, "TestService")
+	defer ln.Close()
+
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.Registered = true
+	si.ServiceAddr.IPAddress = "127.0.0.1"
+	si.ServiceAddr.Port = ln.Addr().(*net.TCPAddr).Port
+
+	other := *si
+	other.Name = "OtherService"
+
+	p := NewPool()
+	defer p.Close()
+
+	p.AddInstance(*si)
+	p.AddInstance(other)
+
+	waitFor(t, func() bool {
+		return p.NumInstances() == 2
+	})
+
+	c, err := p.Acquire(*si)
+	if err != nil {
+		t.Fatal("Failed to acquire connection", err)
+	}
+
+	if p.NumActiveConnections(other) != 0 {
+		t.Fatal("Connections should be counted against the service they were acquired for")
+	}
+
+	p.Release(c)
+	p.RemoveInstance(other)
+
+	waitFor(t, func() bool {
+		return p.NumInstances() == 1
+	})
+
+	if _, ok := p.servicePool(InstanceKey(*si)); !ok {
+		t.Fatal("Removing a service should leave others at the same address")
+	}
+
+	if c.IsClosed() {
+		t.Fatal("Released connection should be returned to its service's pool")
+	}
+}
+
+func TestPoolSharesMultiplexedConnections(t *testing.T) {
+	ln, accepted := listenForHandshakes(t, "TestService")
+	defer ln.Close()
+
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.Registered = true
+	si.ServiceAddr.IPAddress = "127.0.0.1"
+	si.ServiceAddr.Port = ln.Addr().(*net.TCPAddr).Port
+
+	p := NewPool()
+	p.multiplexRequests = func(s skynet.ServiceInfo) int {
+		return 3
+	}
+	defer p.Close()
+
+	p.AddInstance(*si)
+
+	waitFor(t, func() bool {
+		return p.NumInstances() == 1
+	})
+
+	acquired := []conn.Connection{}
+	for i := 0; i < 4; i++ {
+		c, err := p.Acquire(*si)
+		if err != nil {
+			t.Fatal("Failed to acquire connection", err)
+		}
+
+		acquired = append(acquired, c)
+	}
+
+	if acquired[0] != acquired[1] || acquired[0] != acquired[2] || acquired[0] == acquired[3] {
+		t.Fatal("Up to client.conn.multiplex requests should share a connection before another is opened")
+	}
+
+	if !acquired[0].(*conn.Conn).Multiplexed() {
+		t.Fatal("Shared connections should be multiplexed")
+	}
+
+	waitFor(t, func() bool {
+		return accepted.count() == 2
+	})
+
+	if p.NumActiveConnections(*si) != 2 {
+		t.Fatal("Shared connections should only be acquired from the pool once", p.NumActiveConnections(*si))
+	}
+
+	if p.NumInFlight(*si) != 4 {
+		t.Fatal("Requests sharing a connection should each be counted in flight", p.NumInFlight(*si))
+	}
+
+	for _, c := range acquired[:2] {
+		p.Release(c)
+	}
+
+	if p.NumActiveConnections(*si) != 2 {
+		t.Fatal("Shared connection should stay acquired while a request is using it")
+	}
+
+	p.Release(acquired[2])
+	p.Release(acquired[3])
+
+	waitFor(t, func() bool {
+		return p.NumActiveConnections(*si) == 0
+	})
+
+	if acquired[0].IsClosed() {
+		t.Fatal("Shared connections should be released to the pool by the last request using them")
+	}
+}
+
+func TestPoolAcquireFromUnregisteredInstance(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to bind to port for test", err)
+	}
+	defer ln.Close()
+
+	// every connection is refused as unregistered, then we watch for the client closing it
+	closed := make(chan bool, 10)
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				bsonrpc.NewEncoder(c).Encode(skynet.ServiceHandshake{Name: "TestService", Registered: false, ClientID: "abc"})
+
+				var ch skynet.ClientHandshake
+				bsonrpc.NewDecoder(c).Decode(&ch)
+
+				c.Read(make([]byte, 1))
+				closed <- true
+			}()
+		}
+	}()
+
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.Registered = true
+	si.ServiceAddr.IPAddress = "127.0.0.1"
+	si.ServiceAddr.Port = ln.Addr().(*net.TCPAddr).Port
+
+	p := NewPool()
+	defer p.Close()
+
+	p.AddInstance(*si)
+
+	waitFor(t, func() bool {
+		return p.NumInstances() == 1
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := p.Acquire(*si); !errors.Is(err, conn.ServiceUnregistered) {
+			t.Fatal("Acquire() should return ServiceUnregistered for an unregistered instance", err)
+		}
+
+		select {
+		case <-closed:
+		case <-time.After(time.Second):
+			t.Fatal("Connection to an unregistered instance should be closed")
+		}
+	}
+
+	if s := p.Stats(*si)[si.AddrString()]; s.Resources != 0 || s.Failed != 3 {
+		t.Fatal("Connections to an unregistered instance should not be kept", s)
+	}
+}
+
+type connectionCounter struct {
+	sync.Mutex
+	n int
+}
+
+func (cc *connectionCounter) count() int {
+	cc.Lock()
+	defer cc.Unlock()
+
+	return cc.n
+}
+
+// Accepts connections and performs the service side of the handshake, leaving them open
+func listenForHandshakes(t *testing.T, name string) (net.Listener, *connectionCounter) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to bind to port for test", err)
+	}
+
+	cc := &connectionCounter{}
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			err = bsonrpc.NewEncoder(c).Encode(skynet.ServiceHandshake{Name: name, Registered: true, ClientID: "abc"})
+
+			var ch skynet.ClientHandshake
+			if err == nil && bsonrpc.NewDecoder(c).Decode(&ch) == nil {
+				cc.Lock()
+				cc.n++
+				cc.Unlock()
+			}
+		}
+	}()
+
+	return ln, cc
+}
+
+func TestPoolBacksOffConnectingToUnreachableInstance(t *testing.T) {
+	ln, _ := listenForHandshakes(t, "TestService")
+	defer ln.Close()
+
+	var mutex sync.Mutex
+	var dials []time.Time
+	up := false
+
+	SetDialFunc(func(network, addr string) (net.Conn, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		dials = append(dials, time.Now())
+
+		if up {
+			return net.Dial(network, addr)
+		}
+
+		return nil, errors.New("connection refused")
+	})
+	defer SetDialFunc(nil)
+
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.Registered = true
+	si.ServiceAddr.IPAddress = "127.0.0.1"
+	si.ServiceAddr.Port = ln.Addr().(*net.TCPAddr).Port
+
+	p := NewPool()
+	defer p.Close()
+
+	p.AddInstance(*si)
+
+	waitFor(t, func() bool {
+		return p.NumInstances() == 1
+	})
+
+	base, max := 20*time.Millisecond, 80*time.Millisecond
+	sp, _ := p.servicePool(InstanceKey(*si))
+	sp.connectBackoff = newConnectBackoff(base, max)
+
+	acquires := 0
+	for deadline := time.Now().Add(300 * time.Millisecond); time.Now().Before(deadline); acquires++ {
+		if _, err := p.Acquire(*si); err == nil {
+			t.Fatal("Acquire() shouldn't connect to an unreachable instance")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	mutex.Lock()
+
+	if len(dials) > 9 || acquires < 10*len(dials) {
+		t.Fatal("Acquire() shouldn't dial an unreachable instance while connecting to it backs off", len(dials), acquires)
+	}
+
+	// each window is at least half its length
+	window := base
+	for i := 1; i < len(dials); i++ {
+		if d := dials[i].Sub(dials[i-1]); d < window/2 {
+			t.Fatal("Dials to an unreachable instance should be spaced by the growing backoff", i, d, window)
+		}
+
+		if window *= 2; window > max {
+			window = max
+		}
+	}
+
+	up = true
+	mutex.Unlock()
+
+	// once the instance can be reached again the backoff is reset
+	var c conn.Connection
+	waitFor(t, func() bool {
+		var err error
+		c, err = p.Acquire(*si)
+		return err == nil
+	})
+	p.Release(c)
+
+	sp.connectBackoff.Lock()
+	defer sp.connectBackoff.Unlock()
+
+	if sp.connectBackoff.backoff.interval != 0 || !sp.connectBackoff.until.IsZero() {
+		t.Fatal("Connecting to the instance should reset its backoff")
+	}
+}
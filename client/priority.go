@@ -0,0 +1,58 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/config"
+	"time"
+)
+
+// attempts sent for a request including hedges, 0 is unlimited, and the delay before it's hedged, 0 disables hedging
+type priorityPolicy struct {
+	maxAttempts int
+	hedgeDelay  time.Duration
+}
+
+func (c *ServiceClient) priorityPolicy(p skynet.Priority) priorityPolicy {
+	switch {
+	case p < skynet.PriorityNormal:
+		return c.lowPriority
+	case p > skynet.PriorityNormal:
+		return c.highPriority
+	}
+
+	return priorityPolicy{maxAttempts: c.maxAttempts, hedgeDelay: c.hedgeDelay}
+}
+
+// low priority requests are sent once by default
+func getLowPriorityPolicy(service, version string) priorityPolicy {
+	return getPriorityPolicy(service, version, "low", priorityPolicy{
+		maxAttempts: config.DefaultLowPriorityMaxAttempts,
+		hedgeDelay:  config.DefaultLowPriorityHedgeDuration,
+	})
+}
+
+// high priority requests are treated like any other unless configured otherwise
+func getHighPriorityPolicy(service, version string) priorityPolicy {
+	return getPriorityPolicy(service, version, "high", priorityPolicy{
+		maxAttempts: getMaxAttempts(service, version),
+		hedgeDelay:  getHedgeDelay(service, version),
+	})
+}
+
+func getPriorityPolicy(service, version, priority string, p priorityPolicy) priorityPolicy {
+	prefix := "client.priority." + priority
+
+	if n, err := config.Int(service, version, prefix+".attempts.max"); err == nil {
+		p.maxAttempts = n
+	}
+
+	if d, err := config.String(service, version, prefix+".timeout.hedge"); err == nil {
+		if timeout, err := time.ParseDuration(d); err == nil {
+			p.hedgeDelay = timeout
+		} else {
+			logger.Error("Failed to parse "+prefix+".timeout.hedge", err)
+		}
+	}
+
+	return p
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"errors"
+	"github.com/skynetservices/skynet"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPriorityLimitsAttempts(t *testing.T) {
+	defer resetClient()
+
+	var attempts int32
+	failed := errors.New("Failed")
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		atomic.AddInt32(&attempts, 1)
+		return failed
+	})
+
+	// failures are retried straight away
+	sClient := sc.(*ServiceClient)
+	sClient.retryMultiplier = 1
+	sClient.highPriority = priorityPolicy{maxAttempts: 3}
+
+	send := func(p skynet.Priority) int32 {
+		atomic.StoreInt32(&attempts, 0)
+
+		var val string
+		err := sClient.SendTimeout(time.Minute, time.Minute, &skynet.RequestInfo{Priority: p}, "Foo", val, &val)
+
+		if err != failed {
+			t.Fatal("The last attempt's error should be returned once max attempts have failed", err)
+		}
+
+		return atomic.LoadInt32(&attempts)
+	}
+
+	if n := send(skynet.PriorityLow); n != 1 {
+		t.Fatal("Low priority requests should be sent once", n)
+	}
+
+	if n := send(skynet.PriorityHigh); n != 3 {
+		t.Fatal("High priority requests should be retried", n)
+	}
+}
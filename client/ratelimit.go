@@ -0,0 +1,104 @@
+package client
+
+import (
+	"errors"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/config"
+	"sync"
+	"time"
+)
+
+var InstanceRateLimited = errors.New("Instance is over its request rate limit")
+
+/*
+With client.instance.rate set, requests to an instance are limited to that many a second, with bursts of up to
+client.instance.burst. A request over the limit waits up to client.instance.rate.wait for its turn, should it be any
+later Acquire fails with InstanceRateLimited so the request can be retried on another instance
+*/
+type rateLimiter struct {
+	mutex sync.Mutex
+	rate  float64
+	burst float64
+
+	// tokens may go negative, a request that waits for its token takes it ahead of time
+	tokens float64
+	last   time.Time
+}
+
+// nil if rate is 0, leaving requests to the instance unlimited
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// returns false without taking a token if the next one isn't due within maxWait
+func (rl *rateLimiter) wait(maxWait time.Duration) bool {
+	if rl == nil {
+		return true
+	}
+
+	rl.mutex.Lock()
+
+	now := time.Now()
+	rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+	rl.last = now
+
+	if rl.tokens > rl.burst {
+		rl.tokens = rl.burst
+	}
+
+	d := time.Duration((1 - rl.tokens) / rl.rate * float64(time.Second))
+	if d > maxWait {
+		rl.mutex.Unlock()
+		return false
+	}
+
+	rl.tokens--
+	rl.mutex.Unlock()
+
+	if d > 0 {
+		time.Sleep(d)
+	}
+
+	return true
+}
+
+func getInstanceRate(s skynet.ServiceInfo) float64 {
+	if r, err := config.Float(s.Name, s.Version, "client.instance.rate"); err == nil {
+		return r
+	}
+
+	return config.DefaultInstanceRate
+}
+
+func getInstanceBurst(s skynet.ServiceInfo) int {
+	if n, err := config.Int(s.Name, s.Version, "client.instance.burst"); err == nil {
+		return n
+	}
+
+	return config.DefaultInstanceBurst
+}
+
+func getInstanceRateWait(s skynet.ServiceInfo) time.Duration {
+	if d, err := config.String(s.Name, s.Version, "client.instance.rate.wait"); err == nil {
+		if wait, err := time.ParseDuration(d); err == nil {
+			return wait
+		}
+
+		logger.Error("Failed to parse client.instance.rate.wait", err)
+	}
+
+	return config.DefaultInstanceRateWait
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPoolLimitsRequestRatePerInstance(t *testing.T) {
+	ln, _ := listenForHandshakes(t, "TestService")
+	defer ln.Close()
+
+	si := skynet.NewServiceInfo("TestService", "1.0.0")
+	si.Registered = true
+	si.ServiceAddr.IPAddress = "127.0.0.1"
+	si.ServiceAddr.Port = ln.Addr().(*net.TCPAddr).Port
+
+	p := NewPool()
+	defer p.Close()
+
+	p.AddInstance(*si)
+
+	waitFor(t, func() bool {
+		return p.NumInstances() == 1
+	})
+
+	rate := 100.0
+	sp, _ := p.servicePool(InstanceKey(*si))
+	sp.limiter, sp.limiterWait = newRateLimiter(rate, 1), time.Second
+
+	// requests are driven as fast as they can be acquired, each waiting its turn
+	var mutex sync.Mutex
+	acquired := 0
+
+	period := 500 * time.Millisecond
+	deadline := time.Now().Add(period)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for time.Now().Before(deadline) {
+				c, err := p.Acquire(*si)
+				if err != nil {
+					t.Error("Requests under client.instance.rate.wait should wait for their turn", err)
+					return
+				}
+
+				mutex.Lock()
+				if time.Now().Before(deadline) {
+					acquired++
+				}
+				mutex.Unlock()
+
+				p.Release(c)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// the burst, plus the requests at the rate over the period
+	if max := int(rate*period.Seconds()) + 1; acquired > max {
+		t.Fatal("Requests to the instance should be limited to client.instance.rate", acquired, max)
+	}
+
+	if min := int(rate * period.Seconds() / 2); acquired < min {
+		t.Fatal("Requests to the instance should be sent at up to client.instance.rate", acquired, min)
+	}
+
+	// requests that would wait longer than client.instance.rate.wait are refused, so they can try another instance
+	sp.limiterWait = 0
+	time.Sleep(20 * time.Millisecond)
+
+	c, err := p.Acquire(*si)
+	if err != nil {
+		t.Fatal("Request under client.instance.rate should be sent", err)
+	}
+	defer p.Release(c)
+
+	if _, err := p.Acquire(*si); err != InstanceRateLimited {
+		t.Fatal("Requests over client.instance.rate should fail with InstanceRateLimited", err)
+	}
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet/config"
+	"sync"
+	"time"
+)
+
+// replaced in tests to control how the retry budget's reserve refills
+var retryBudgetNow = time.Now
+
+// caps the retries saved up while requests succeed, so a long healthy run can't fund a retry storm
+const maxRetryBalance = 100
+
+/*
+retryBudget limits retries to a share of the requests that succeed, so that during an outage requests give up
+rather than multiplying the load on a failing cluster. Each successful request deposits ratio of a retry and each
+retry or hedge withdraws one. A reserve refilling at minPerSec lets a trickle of retries through when few
+requests have succeeded, such as just after the client starts.
+The budget is shared by every request sent through a ServiceClient.
+*/
+type retryBudget struct {
+	mutex sync.Mutex
+
+	ratio     float64
+	minPerSec float64
+
+	balance  float64
+	reserve  float64
+	refilled time.Time
+}
+
+// a ratio of 0 or less leaves retries unlimited
+func newRetryBudget(ratio, minPerSec float64) *retryBudget {
+	if ratio <= 0 {
+		return nil
+	}
+
+	return &retryBudget{
+		ratio:     ratio,
+		minPerSec: minPerSec,
+		reserve:   minPerSec,
+		refilled:  retryBudgetNow(),
+	}
+}
+
+/*
+retryBudget.deposit() is called once a request succeeds
+*/
+func (b *retryBudget) deposit() {
+	if b == nil {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.balance += b.ratio
+
+	if b.balance > maxRetryBalance {
+		b.balance = maxRetryBalance
+	}
+}
+
+/*
+retryBudget.withdraw() returns false if the budget can't afford another retry
+*/
+func (b *retryBudget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := retryBudgetNow()
+	b.reserve += now.Sub(b.refilled).Seconds() * b.minPerSec
+	b.refilled = now
+
+	if b.reserve > b.minPerSec {
+		b.reserve = b.minPerSec
+	}
+
+	switch {
+	case b.balance >= 1:
+		b.balance--
+	case b.reserve >= 1:
+		b.reserve--
+	default:
+		return false
+	}
+
+	return true
+}
+
+func getRetryBudgetRatio(service, version string) float64 {
+	if r, err := config.Float(service, version, "client.retry.budget.ratio"); err == nil {
+		return r
+	}
+
+	return config.DefaultRetryBudgetRatio
+}
+
+func getMinRetriesPerSec(service, version string) float64 {
+	if n, err := config.Float(service, version, "client.retry.budget.min"); err == nil {
+		return n
+	}
+
+	return config.DefaultMinRetriesPerSec
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"errors"
+	"github.com/skynetservices/skynet"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryBudgetThrottlesRetries(t *testing.T) {
+	defer resetClient()
+
+	clock := time.Now()
+	retryBudgetNow = func() time.Time {
+		return clock
+	}
+	defer func() { retryBudgetNow = time.Now }()
+
+	// retries are only sent straight after a failure
+	retryAfter = func(d time.Duration) <-chan time.Time {
+		return nil
+	}
+
+	var failing int32
+	var calls int32
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		atomic.AddInt32(&calls, 1)
+
+		if atomic.LoadInt32(&failing) == 1 {
+			return errors.New("Failed")
+		}
+
+		return
+	})
+
+	sClient := sc.(*ServiceClient)
+	sClient.retryMultiplier = 1
+	sClient.retryBudget = newRetryBudget(0.5, 1)
+
+	var val string
+	for i := 0; i < 4; i++ {
+		if err := sClient.SendTimeout(time.Second, time.Second, nil, "Foo", val, &val); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 4 successes earn 2 retries, on top of the reserve of 1
+	atomic.StoreInt32(&failing, 1)
+
+	sends := func() int32 {
+		atomic.StoreInt32(&calls, 0)
+		sClient.SendTimeout(time.Second, 20*time.Millisecond, nil, "Foo", val, &val)
+
+		return atomic.LoadInt32(&calls)
+	}
+
+	if n := sends(); n != 4 {
+		t.Fatal("Expected the first attempt and 3 retries within the budget", n)
+	}
+
+	if n := sends(); n != 1 {
+		t.Fatal("Retries should be throttled once the budget is exhausted", n)
+	}
+
+	clock = clock.Add(time.Second)
+
+	if n := sends(); n != 2 {
+		t.Fatal("The reserve should allow a retry once it refills", n)
+	}
+}
@@ -0,0 +1,16 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet/client/loadbalancer"
+)
+
+/*
+ServiceClient.SeedLoadBalancer() seeds the LoadBalancer's random choices, by default seeded with the time. ServiceClients
+seeded alike choose the same sequence of instances from the same instances, so routing can be reproduced in tests or
+while debugging. It has no effect if the LoadBalancer doesn't choose at random
+*/
+func (c *ServiceClient) SeedLoadBalancer(seed int64) {
+	if sd, ok := c.loadBalancer.(loadbalancer.Seeder); ok {
+		sd.Seed(seed)
+	}
+}
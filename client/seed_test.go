@@ -0,0 +1,96 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"github.com/skynetservices/skynet/client/loadbalancer/random"
+	"github.com/skynetservices/skynet/config"
+	"github.com/skynetservices/skynet/test"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestSeededLoadBalancerRepeatsChoices(t *testing.T) {
+	defer resetClient()
+
+	instances := make([]skynet.ServiceInfo, 3)
+	for i := range instances {
+		instances[i] = *serviceInfo()
+		instances[i].UUID = config.NewUUID()
+	}
+
+	var mutex sync.Mutex
+	var chosen []string
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			mutex.Lock()
+			chosen = append(chosen, s.UUID)
+			mutex.Unlock()
+
+			return &test.Connection{}, nil
+		},
+	})
+
+	const requests = 20
+
+	// choices shouldn't depend on the order instances are discovered in
+	sequence := func(order []skynet.ServiceInfo) []string {
+		sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+			skynet.ServiceCriteria{Name: "TestService"},
+		}})
+		defer sc.Close()
+
+		sc.(*ServiceClient).loadBalancer = random.New([]skynet.ServiceInfo{})
+		sc.SeedLoadBalancer(42)
+
+		for _, s := range order {
+			sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: s})
+		}
+
+		waitFor(t, func() bool { return sc.InstanceCount() == len(order) })
+
+		mutex.Lock()
+		chosen = nil
+		mutex.Unlock()
+
+		var val string
+		for i := 0; i < requests; i++ {
+			if err := sc.SendOnce(nil, "Foo", val, &val); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return append([]string(nil), chosen...)
+	}
+
+	sorted := append([]skynet.ServiceInfo(nil), instances...)
+	loadbalancer.SortByUUID(sorted)
+
+	r := rand.New(rand.NewSource(42))
+	expected := make([]string, requests)
+	for i := range expected {
+		expected[i] = sorted[r.Intn(len(sorted))].UUID
+	}
+
+	reversed := []skynet.ServiceInfo{instances[2], instances[1], instances[0]}
+
+	for _, order := range [][]skynet.ServiceInfo{instances, reversed} {
+		got := sequence(order)
+
+		if len(got) != requests {
+			t.Fatal("Expected a choice for every request", len(got))
+		}
+
+		for i := range expected {
+			if got[i] != expected[i] {
+				t.Fatalf("Choice %d was %s, expected %s", i, got[i], expected[i])
+			}
+		}
+	}
+}
@@ -1,25 +1,84 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
 	"github.com/skynetservices/skynet/client/loadbalancer"
+	"github.com/skynetservices/skynet/client/loadbalancer/canary"
+	"github.com/skynetservices/skynet/client/loadbalancer/capped"
+	"github.com/skynetservices/skynet/client/loadbalancer/regional"
+	"github.com/skynetservices/skynet/client/loadbalancer/versioned"
 	"github.com/skynetservices/skynet/config"
-	"github.com/skynetservices/skynet/log"
+	"github.com/skynetservices/skynet/pools"
 	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// TODO: Implement SendTimeout()
-// TODO: Implement SendOnceTimeout()
-
 var (
 	ServiceClientClosed = errors.New("Service client shutdown")
 	RequestTimeout      = errors.New("Request timed out")
+
+	// NoAvailableInstances is returned when every known instance has been excluded after repeated failures,
+	// or is unregistered
+	NoAvailableInstances = errors.New("No available instances, all known instances are failing")
+
+	// ConcurrencyLimitReached is returned when client.concurrency.max requests are in flight and client.concurrency.wait is false
+	ConcurrencyLimitReached = errors.New("Too many concurrent requests")
+
+	// InstanceNotFound is returned by SendTo() when no known instance is at the address
+	InstanceNotFound = errors.New("No known instance at address")
+
+	// NoMatchingInstance is returned when no available instance has the labels in a request's LabelSelector,
+	// and client.labels.fallback is false
+	NoMatchingInstance = errors.New("No available instance matches the label selector")
 )
 
+/*
+client.TimeoutError is returned in place of RequestTimeout when attempts failed before the giveup time passed,
+listing each of their errors in the order they failed. errors.Is(err, RequestTimeout) is true for it
+*/
+type TimeoutError struct {
+	Attempts []AttemptError
+}
+
+// AttemptError is the error from a failed attempt, Instance is empty if one couldn't be chosen
+type AttemptError struct {
+	Instance skynet.ServiceInfo
+	Err      error
+}
+
+func (te TimeoutError) Error() string {
+	failures := make([]string, len(te.Attempts))
+
+	for i, a := range te.Attempts {
+		addr := "no instance"
+		if a.Instance.UUID != "" {
+			addr = a.Instance.AddrString()
+		}
+
+		failures[i] = addr + ": " + a.Err.Error()
+	}
+
+	return fmt.Sprintf("%s after %d failed attempts: %s", RequestTimeout.Error(), len(te.Attempts), strings.Join(failures, "; "))
+}
+
+// TimeoutError.Unwrap() returns RequestTimeout followed by each attempt's error, so errors.Is() matches any of them
+func (te TimeoutError) Unwrap() []error {
+	errs := []error{RequestTimeout}
+
+	for _, a := range te.Attempts {
+		errs = append(errs, a.Err)
+	}
+
+	return errs
+}
+
 /*
 ServiceSender Responsible for sending requests to the cluster.
 This is mostly used as way to test that clients make appropriate requests to services without the need to run those services
@@ -27,11 +86,40 @@ This is mostly used as way to test that clients make appropriate requests to ser
 type ServiceClientProvider interface {
 	SetDefaultTimeout(retry, giveup time.Duration)
 	GetDefaultTimeout() (retry, giveup time.Duration)
+	SetMethodTimeout(fn string, giveup time.Duration)
 
 	Close()
+	Drain()
+	DrainWait(ctx context.Context) error
 
 	Send(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)
+	SendContext(ctx context.Context, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)
+	SendTimeout(retry, giveup time.Duration, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)
 	SendOnce(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)
+	SendOnceTimeout(giveup time.Duration, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)
+	SendBatch(ri *skynet.RequestInfo, calls []skynet.Call) (errs []error)
+	SendDetailed(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (result skynet.SendResult, err error)
+	SendFuture(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) *skynet.Future
+	SendStream(ri *skynet.RequestInfo, fn string, in interface{}) (*conn.Stream, error)
+	SendTo(addr string, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)
+	SendAll(ri *skynet.RequestInfo, fn string, in interface{}) (errs map[string]error)
+	SendOneway(ri *skynet.RequestInfo, fn string, in interface{}) (err error)
+	SendFallback(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, fallback func(err error) error) (err error)
+
+	InstanceCount() int
+	WaitForInstances(ctx context.Context, min int) error
+	Ready() <-chan struct{}
+	PoolStats() map[string]pools.Stats
+	ListInstances() []skynet.InstanceInfo
+	HealthCheck(ctx context.Context) map[string]skynet.InstanceHealth
+
+	EvictInstance(addr string)
+	UnevictInstance(addr string)
+	SeedLoadBalancer(seed int64)
+
+	OnInstanceChange(f func(n skynet.InstanceNotification))
+	PauseDiscovery()
+	ResumeDiscovery()
 
 	Notify(n skynet.InstanceNotification)
 	Matches(n skynet.ServiceInfo) bool
@@ -40,19 +128,115 @@ type ServiceClientProvider interface {
 type ServiceClient struct {
 	loadBalancer loadbalancer.LoadBalancer
 	criteria     *skynet.Criteria
-	shutdown     bool
-	closed       bool
 
 	retryTimeout  time.Duration
 	giveupTimeout time.Duration
 
+	// only replaced from mux(), see setMethodTimeout()
+	methodTimeouts map[string]time.Duration
+
+	retryMax        time.Duration
+	retryMultiplier float64
+	hedgeDelay      time.Duration
+
+	// nil unless client.retry.budget.ratio is set
+	retryBudget *retryBudget
+
+	// attempts sent per request including hedges, 0 is unlimited
+	maxAttempts int
+
+	// codes of errors returned by the service that aren't retried, see getRetrySkipCodes()
+	retrySkipCodes map[string]bool
+
+	// methods whose requests are sent as skynet.RequestInfo.NotIdempotent, see getNonIdempotentMethods()
+	nonIdempotent map[string]bool
+
+	// times an attempt the service fails is sent again on its connection, see getSameConnRetries()
+	sameConnRetries int
+
+	// requests fail with loadbalancer.NoInstances while no instances are known, rather than retrying until one is
+	failFast bool
+
+	// sent with every request, see withMetadata()
+	metadata    map[string]string
+	maxMetadata int
+
+	// how long an instance working on an attempt is passed over by the request's other attempts, see attemptExclusions
+	exclusionTTL time.Duration
+
+	// requests routed to canary instances by their ExperimentKey, see canaryPolicy
+	canary canaryPolicy
+
+	// attempts and hedging for low and high priority requests, normal priority requests use maxAttempts and hedgeDelay
+	lowPriority  priorityPolicy
+	highPriority priorityPolicy
+
+	// limits the attempts in flight when client.concurrency.max is set
+	slots       chan struct{}
+	waitForSlot bool
+
+	// only accessed from mux()
+	instances        map[string]skynet.ServiceInfo
+	breakers         map[string]*circuitBreaker
+	failureThreshold int
+	cooldown         time.Duration
+	instanceWaiters  []instanceWaiter
+	ready            chan struct{}
+	affinity         map[string]affinityEntry
+	affinityTTL      time.Duration
+	affinitySwept    time.Time
+	labelFallback    bool
+	labelNext        int
+
+	// average latency of each instance, see recordLatency()
+	latencies map[string]time.Duration
+
+	// when each instance last answered a request successfully, and those logged as stale, see checkStale()
+	lastServed     map[string]time.Time
+	staleWarned    map[string]bool
+	staleThreshold time.Duration
+	staleProbe     bool
+
+	// replaced in tests to control when instances become stale
+	staleNow func() time.Time
+
+	// consecutive requests to each instance that found their connection broken, see recordTransportFailure()
+	transportFailures map[string]int
+	flushThreshold    int
+
+	// requests in flight with a CoalesceKey, see sendCoalesced()
+	coalesced map[coalesceKey]*coalescedCall
+
+	// addresses of instances evicted by EvictInstance(), kept as instances are removed and rediscovered
+	evicted map[string]bool
+
+	// nil unless OnInstanceChange() has been called
+	instanceChange *instanceChangeNotifier
+
+	// instance notifications arriving while discovery is paused are held, see PauseDiscovery()
+	discoveryPaused      bool
+	heldNotifications    []skynet.InstanceNotification
+	maxHeldNotifications int
+
+	// instances of a ServiceClient from GetStaticService(), removed from the pool once it's closed
+	static []skynet.ServiceInfo
+
+	// number of half open breakers, read outside of mux() so requests only ask for a probe when one may be due
+	halfOpen int32
+
 	waiter sync.WaitGroup
 
+	// requests being sent, see Drain()
+	requests requestTracker
+
 	// mux channels
 	muxChan               chan interface{}
 	instanceNotifications chan skynet.InstanceNotification
 	timeoutChan           chan timeoutLengths
 	shutdownChan          chan bool
+
+	// closed once mux() exits after Close()
+	done chan struct{}
 }
 
 /*
@@ -64,72 +248,621 @@ func NewServiceClient(c *skynet.Criteria) ServiceClientProvider {
 		instanceNotifications: make(chan skynet.InstanceNotification, 100),
 		timeoutChan:           make(chan timeoutLengths),
 		shutdownChan:          make(chan bool),
+		done:                  make(chan struct{}),
 		muxChan:               make(chan interface{}),
-		loadBalancer:          LoadBalancerFactory([]skynet.ServiceInfo{}),
+		loadBalancer:          newLoadBalancer(c.Services[0].Name, c.Services[0].Version),
+		instances:             make(map[string]skynet.ServiceInfo),
+		ready:                 make(chan struct{}),
+		breakers:              make(map[string]*circuitBreaker),
+		affinity:              make(map[string]affinityEntry),
+		evicted:               make(map[string]bool),
+		latencies:             make(map[string]time.Duration),
+		transportFailures:     make(map[string]int),
+		lastServed:            make(map[string]time.Time),
+		staleWarned:           make(map[string]bool),
+		coalesced:             make(map[coalesceKey]*coalescedCall),
+
+		retryTimeout:     getRetryTimeout(c.Services[0].Name, c.Services[0].Version),
+		giveupTimeout:    getGiveupTimeout(c.Services[0].Name, c.Services[0].Version),
+		methodTimeouts:   getMethodTimeouts(c.Services[0].Name, c.Services[0].Version),
+		retryMax:         getRetryMax(c.Services[0].Name, c.Services[0].Version),
+		retryMultiplier:  getRetryMultiplier(c.Services[0].Name, c.Services[0].Version),
+		hedgeDelay:       getHedgeDelay(c.Services[0].Name, c.Services[0].Version),
+		failureThreshold: getFailureThreshold(c.Services[0].Name, c.Services[0].Version),
+		cooldown:         getCooldownDuration(c.Services[0].Name, c.Services[0].Version),
+		slots:            newSlots(getMaxConcurrentRequests(c.Services[0].Name, c.Services[0].Version)),
+		waitForSlot:      getConcurrencyWait(c.Services[0].Name, c.Services[0].Version),
+		affinityTTL:      getAffinityTTL(c.Services[0].Name, c.Services[0].Version),
+		maxAttempts:      getMaxAttempts(c.Services[0].Name, c.Services[0].Version),
+		retrySkipCodes:   getRetrySkipCodes(c.Services[0].Name, c.Services[0].Version),
+		nonIdempotent:    getNonIdempotentMethods(c.Services[0].Name, c.Services[0].Version),
+		sameConnRetries:  getSameConnRetries(c.Services[0].Name, c.Services[0].Version),
+		exclusionTTL:     getAttemptExclusionTTL(c.Services[0].Name, c.Services[0].Version),
+		failFast:         getFailFastWhenEmpty(c.Services[0].Name, c.Services[0].Version),
+		metadata:         getDefaultMetadata(c.Services[0].Name, c.Services[0].Version),
+		maxMetadata:      getMaxMetadataBytes(c.Services[0].Name, c.Services[0].Version),
+		retryBudget:      newRetryBudget(getRetryBudgetRatio(c.Services[0].Name, c.Services[0].Version), getMinRetriesPerSec(c.Services[0].Name, c.Services[0].Version)),
+		lowPriority:      getLowPriorityPolicy(c.Services[0].Name, c.Services[0].Version),
+		highPriority:     getHighPriorityPolicy(c.Services[0].Name, c.Services[0].Version),
+		flushThreshold:   getFlushThreshold(c.Services[0].Name, c.Services[0].Version),
+		labelFallback:    getLabelFallback(c.Services[0].Name, c.Services[0].Version),
+		canary:           getCanaryPolicy(c.Services[0].Name, c.Services[0].Version),
+		staleThreshold:   getStaleThreshold(c.Services[0].Name, c.Services[0].Version),
+		staleProbe:       getStaleProbe(c.Services[0].Name, c.Services[0].Version),
+		staleNow:         time.Now,
 
-		retryTimeout:  getRetryTimeout(c.Services[0].Name, c.Services[0].Version),
-		giveupTimeout: getGiveupTimeout(c.Services[0].Name, c.Services[0].Version),
+		maxHeldNotifications: getMaxHeldNotifications(c.Services[0].Name, c.Services[0].Version),
 	}
 
+	sc.scheduleStaleCheck()
+
 	go sc.mux()
 
 	return sc
 }
 
 /*
-ServiceClient.Send() will send a request to one of the available instances. In intervals of retry time,
-it will send additional requests to other known instances. If no response is heard after
-the giveup time has passed, it will return an error.
+ServiceClient.Send() will send a request to one of the available instances. In intervals of the retry time, or starting
+at it and backing off exponentially with jitter when client.timeout.retry.multiplier is above 1, it will send additional
+requests to other known instances. If no response is heard after
+the giveup time has passed, it will return RequestTimeout, or a TimeoutError listing the attempts that failed. With a hedge delay (client.timeout.hedge) shorter than the retry time
+a second request is sent once it passes, the first response to succeed is used and the other attempt is aborted.
+Retries and hedges are skipped once the retry budget (client.retry.budget.ratio) is exhausted, or client.attempts.max
+attempts have been sent, in which case the last attempt's error is returned once they've all failed. Requests with a low
+or high RequestInfo.Priority use client.priority.low.* or client.priority.high.* in place of the attempts and hedge delay.
+The response is decoded into out, which must be a non-nil pointer, otherwise an InvalidOutTypeError is returned.
 */
 func (c *ServiceClient) Send(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
-	if c.closed {
+	return c.SendContext(context.Background(), ri, fn, in, out)
+}
+
+/*
+ServiceClient.SendContext() acts like Send but will also stop once ctx is done, returning ctx.Err().
+Any attempts still in flight are aborted.
+*/
+func (c *ServiceClient) SendContext(ctx context.Context, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+	if c.isClosed() {
+		return ServiceClientClosed
+	}
+
+	retry, giveup := c.timeoutsFor(fn)
+	return c.send(ctx, retry, giveup, ri, fn, in, out)
+}
+
+/*
+ServiceClient.SendFuture() sends a request with SendContext() in the background, returning immediately.
+out must not be read until the Future's Done() channel is closed.
+*/
+func (c *ServiceClient) SendFuture(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) *skynet.Future {
+	return skynet.NewFuture(func(ctx context.Context) error {
+		return c.SendContext(ctx, ri, fn, in, out)
+	})
+}
+
+/*
+ServiceClient.SendTimeout() acts like Send but uses the supplied retry and giveup times for this request instead of
+the ServiceClient's defaults. Setting retry or giveup to 0 indicates no retry or time out.
+*/
+func (c *ServiceClient) SendTimeout(retry, giveup time.Duration, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+	if c.isClosed() {
+		return ServiceClientClosed
+	}
+
+	return c.send(context.Background(), retry, giveup, ri, fn, in, out)
+}
+
+/*
+ServiceClient.SendDetailed() acts like Send but also reports which instance answered, how many attempts
+were sent and how long the request took
+*/
+func (c *ServiceClient) SendDetailed(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (result skynet.SendResult, err error) {
+	if c.isClosed() {
+		return result, ServiceClientClosed
+	}
+
+	retry, giveup := c.timeoutsFor(fn)
+	return c.sendDetailed(context.Background(), retry, giveup, ri, fn, in, out)
+}
+
+/*
+ServiceClient.SendOnce() will send a request to one of the available instances. If no response is heard after
+the giveup time has passed, it will return an error.
+*/
+func (c *ServiceClient) SendOnce(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+	if c.isClosed() {
+		return ServiceClientClosed
+	}
+	_, giveup := c.timeoutsFor(fn)
+	return c.send(context.Background(), 0, giveup, ri, fn, in, out)
+}
+
+/*
+ServiceClient.SendOnceTimeout() acts like SendOnce but gives up after the supplied giveup time instead of the
+ServiceClient's default. The attempt is aborted once giveup passes, and its connection released.
+Setting giveup to 0 indicates no time out.
+*/
+func (c *ServiceClient) SendOnceTimeout(giveup time.Duration, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+	if c.isClosed() {
+		return ServiceClientClosed
+	}
+
+	return c.send(context.Background(), 0, giveup, ri, fn, in, out)
+}
+
+/*
+ServiceClient.SendStream() sends a streaming request to one of the available instances over a connection opened for it,
+its results are read from the returned Stream which must be closed once it's no longer needed, the ServiceClient
+drains and closes only once it has been. Streaming requests aren't
+retried, intercepted or traced and the instance must support them, returning conn.StreamsUnsupported otherwise
+*/
+func (c *ServiceClient) SendStream(ri *skynet.RequestInfo, fn string, in interface{}) (*conn.Stream, error) {
+	if c.isClosed() {
+		return nil, ServiceClientClosed
+	}
+
+	if err := c.startRequest(); err != nil {
+		return nil, err
+	}
+
+	if ri == nil {
+		ri = c.NewRequestInfo()
+	}
+
+	s, err := c.chooseInstance(ri, nil)
+	if err != nil {
+		c.finishRequest()
+		return nil, err
+	}
+
+	stream, err := conn.NewStreamWithOptions(s.Name, GetNetwork(), s.AddrString(), getDialTimeout(s), connectionOptions(s), ri, fn, in)
+	c.sendMux(instanceResult{service: s, err: instanceFailure(err)})
+
+	if err != nil {
+		c.finishRequest()
+		return nil, err
+	}
+
+	// the stream is in flight until it's closed, draining waits for it
+	stream.OnClose(c.finishRequest)
+
+	return stream, nil
+}
+
+/*
+ServiceClient.SendTo() sends a request to the known instance at addr (host:port) rather than one chosen by the LoadBalancer,
+returning InstanceNotFound if there isn't one. The request isn't retried, and is sent even if the instance is evicted or
+excluded after repeated failures. If no response is heard after the giveup time has passed, it will return RequestTimeout.
+*/
+func (c *ServiceClient) SendTo(addr string, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+	if c.isClosed() {
 		return ServiceClientClosed
 	}
 
-	retry, giveup := c.GetDefaultTimeout()
-	return c.send(retry, giveup, ri, fn, in, out)
+	if err = c.startRequest(); err != nil {
+		return
+	}
+	defer c.finishRequest()
+
+	if ri == nil {
+		ri = c.NewRequestInfo()
+	}
+
+	_, giveup := c.timeoutsFor(fn)
+
+	start := time.Now()
+	var s skynet.ServiceInfo
+	attempts := 0
+
+	defer func() {
+		connectFailures, callFailures := attemptFailures(attempts, err)
+
+		metrics.RecordSend(SendMetrics{
+			Service:    c.criteria.Services[0].Name,
+			Method:     fn,
+			Duration:   time.Since(start),
+			Attempts:   attempts,
+			Instance:   s,
+			Err:        err,
+			ErrorClass: errorClass(err),
+
+			ConnectFailures: connectFailures,
+			CallFailures:    callFailures,
+		})
+	}()
+
+	if err = checkOut(fn, out); err != nil {
+		return
+	}
+
+	s, ok := c.instanceAt(addr)
+	if !ok {
+		return InstanceNotFound
+	}
+
+	attempts = 1
+
+	var cancel context.CancelFunc
+	ctx := context.Background()
+	if giveup > 0 {
+		ctx, cancel = context.WithTimeout(ctx, giveup)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	// as with Send, the caller's RequestInfo isn't stamped with this request's deadline
+	sent := *ri
+	ri = &sent
+
+	if d, ok := ctx.Deadline(); ok && (ri.Deadline.IsZero() || d.Before(ri.Deadline)) {
+		ri.Deadline = d
+	}
+
+	return c.sendInstance(ctx, giveup, s, ri, fn, in, out)
+}
+
+/*
+ServiceClient.SendOneway() sends a request to an instance chosen as for Send() and returns once it's written to the
+connection, without waiting for a response, such as for notifications. Requests aren't retried, and errors returned
+by the service aren't seen.
+*/
+func (c *ServiceClient) SendOneway(ri *skynet.RequestInfo, fn string, in interface{}) (err error) {
+	if c.isClosed() {
+		return ServiceClientClosed
+	}
+
+	if err = c.startRequest(); err != nil {
+		return
+	}
+	defer c.finishRequest()
+
+	if ri == nil {
+		ri = c.NewRequestInfo()
+	}
+
+	start := time.Now()
+	var s skynet.ServiceInfo
+	attempts := 0
+
+	defer func() {
+		connectFailures, callFailures := attemptFailures(attempts, err)
+
+		metrics.RecordSend(SendMetrics{
+			Service:    c.criteria.Services[0].Name,
+			Method:     fn,
+			Duration:   time.Since(start),
+			Attempts:   attempts,
+			Instance:   s,
+			Err:        err,
+			ErrorClass: errorClass(err),
+
+			ConnectFailures: connectFailures,
+			CallFailures:    callFailures,
+		})
+	}()
+
+	if ri, err = c.withMetadata(ri); err != nil {
+		return
+	}
+
+	c.routeCanary(ri)
+
+	if s, err = c.chooseInstance(ri, nil); err != nil {
+		return
+	}
+
+	attempts = 1
+
+	cn, err := acquire(s)
+	if err != nil {
+		c.sendMux(instanceResult{service: s, err: instanceFailure(err)})
+		return
+	}
+
+	// released before the response arrives, it's still matched to this request and discarded once it does
+	defer release(cn)
+
+	err = cn.SendOneway(ri, fn, in)
+	c.sendMux(instanceResult{service: s, err: instanceFailure(err)})
+
+	return
+}
+
+/*
+ServiceClient.SendAll() sends a request to every registered instance at once, such as to invalidate their caches, returning
+each instance's error keyed by its address (host:port). Responses are discarded and requests aren't retried, they're
+sent even to instances that are evicted or excluded after repeated failures. Requests still unanswered once the giveup
+time has passed return RequestTimeout. The map is empty if the ServiceClient is closed, draining or knows of no instances.
+*/
+func (c *ServiceClient) SendAll(ri *skynet.RequestInfo, fn string, in interface{}) (errs map[string]error) {
+	errs = make(map[string]error)
+
+	if c.isClosed() {
+		return
+	}
+
+	if c.startRequest() != nil {
+		return
+	}
+	defer c.finishRequest()
+
+	if ri == nil {
+		ri = c.NewRequestInfo()
+	}
+
+	_, giveup := c.timeoutsFor(fn)
+
+	resp := make(chan []skynet.ServiceInfo, 1)
+	if !c.sendMux(instancesQuery{resp: resp}) {
+		return
+	}
+
+	// the giveup time bounds the requests together
+	var cancel context.CancelFunc
+	ctx := context.Background()
+	if giveup > 0 {
+		ctx, cancel = context.WithTimeout(ctx, giveup)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	sent := *ri
+	ri = &sent
+
+	if d, ok := ctx.Deadline(); ok && (ri.Deadline.IsZero() || d.Before(ri.Deadline)) {
+		ri.Deadline = d
+	}
+
+	var mutex sync.Mutex
+	var wait sync.WaitGroup
+
+	for _, s := range <-resp {
+		if !s.Registered {
+			continue
+		}
+
+		wait.Add(1)
+
+		go func(s skynet.ServiceInfo) {
+			defer wait.Done()
+
+			start := time.Now()
+
+			var out map[string]interface{}
+			err := c.sendInstance(ctx, giveup, s, ri, fn, in, &out)
+			connectFailures, callFailures := attemptFailures(1, err)
+
+			metrics.RecordSend(SendMetrics{
+				Service:    c.criteria.Services[0].Name,
+				Method:     fn,
+				Duration:   time.Since(start),
+				Attempts:   1,
+				Instance:   s,
+				Err:        err,
+				ErrorClass: errorClass(err),
+
+				ConnectFailures: connectFailures,
+				CallFailures:    callFailures,
+			})
+
+			mutex.Lock()
+			errs[s.AddrString()] = err
+			mutex.Unlock()
+		}(s)
+	}
+
+	wait.Wait()
+
+	return
+}
+
+// sends a single request to s, reporting its result to mux(), out is only set if the request succeeds
+func (c *ServiceClient) sendInstance(ctx context.Context, giveup time.Duration, s skynet.ServiceInfo, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+	cn, err := acquire(s)
+	if err != nil {
+		c.sendMux(instanceResult{service: s, err: instanceFailure(err)})
+		return
+	}
+
+	defer release(cn)
+
+	// unmarshal into a new value so a failed request leaves out untouched
+	result := reflect.New(reflect.Indirect(reflect.ValueOf(out)).Type()).Interface()
+
+	sentAt := time.Now()
+	err = sendOnConnection(ctx, cn, giveup, ri, fn, in, result)
+
+	if ctx.Err() != nil {
+		logger.Warn(fmt.Sprintf("Timing out request to %s within %s", s.AddrString(), giveup.String()))
+		return RequestTimeout
+	}
+
+	c.sendMux(instanceResult{service: s, err: instanceFailure(err), duration: time.Since(sentAt)})
+
+	if err == nil {
+		copyOut(out, result)
+	}
+
+	return
+}
+
+/*
+ServiceClient.SendBatch() sends all of the calls to a single instance, pipelining them over one connection.
+The returned errors correspond to each call, calls are not retried. If no response is heard for a call after
+the giveup time has passed, its error will be RequestTimeout.
+*/
+func (c *ServiceClient) SendBatch(ri *skynet.RequestInfo, calls []skynet.Call) (errs []error) {
+	errs = make([]error, len(calls))
+
+	if c.isClosed() {
+		return batchError(errs, ServiceClientClosed)
+	}
+
+	if err := c.startRequest(); err != nil {
+		return batchError(errs, err)
+	}
+	defer c.finishRequest()
+
+	if ri == nil {
+		ri = c.NewRequestInfo()
+	}
+
+	timeouts := c.timeouts()
+
+	// each call is recorded as a request of its own, calls that were never sent share the batch's duration
+	start := time.Now()
+	durations := make([]time.Duration, len(calls))
+	var s skynet.ServiceInfo
+	attempts := 0
+
+	defer func() {
+		for i, call := range calls {
+			if durations[i] == 0 {
+				durations[i] = time.Since(start)
+			}
+
+			connectFailures, callFailures := attemptFailures(attempts, errs[i])
+
+			metrics.RecordSend(SendMetrics{
+				Service:    c.criteria.Services[0].Name,
+				Method:     call.Fn,
+				Duration:   durations[i],
+				Attempts:   attempts,
+				Instance:   s,
+				Err:        errs[i],
+				ErrorClass: errorClass(errs[i]),
+
+				ConnectFailures: connectFailures,
+				CallFailures:    callFailures,
+			})
+		}
+	}()
+
+	// calls with an invalid out fail without being sent
+	for i, call := range calls {
+		errs[i] = checkOut(call.Fn, call.Out)
+	}
+
+	s, err := c.chooseInstance(ri, nil)
+	if err != nil {
+		return batchError(errs, err)
+	}
+
+	attempts = 1
+
+	cn, err := acquire(s)
+	if err != nil {
+		c.sendMux(instanceResult{service: s, err: instanceFailure(err)})
+		return batchError(errs, err)
+	}
+
+	defer release(cn)
+
+	var wait sync.WaitGroup
+
+	for i, call := range calls {
+		if errs[i] != nil {
+			continue
+		}
+
+		wait.Add(1)
+
+		go func(i int, call skynet.Call) {
+			defer wait.Done()
+
+			// unmarshal into a new value so a failed call leaves Out untouched
+			result := reflect.New(reflect.Indirect(reflect.ValueOf(call.Out)).Type()).Interface()
+
+			callStart := time.Now()
+			if errs[i] = cn.SendTimeout(ri, call.Fn, call.In, result, timeouts.giveupFor(call.Fn)); errs[i] == nil {
+				copyOut(call.Out, result)
+			}
+			durations[i] = time.Since(callStart)
+		}(i, call)
+	}
+
+	wait.Wait()
+
+	// Only failures of the connection reflect on the instance, the calls themselves may fail independently
+	err = nil
+	for _, e := range errs {
+		if conn.IsTransportError(e) {
+			err = e
+			break
+		}
+	}
+
+	c.sendMux(instanceResult{service: s, err: err})
+
+	return
 }
 
-/*
-ServiceClient.SendOnce() will send a request to one of the available instances. If no response is heard after
-the giveup time has passed, it will return an error.
-*/
-func (c *ServiceClient) SendOnce(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
-	if c.closed {
-		return ServiceClientClosed
+func batchError(errs []error, err error) []error {
+	for i := range errs {
+		errs[i] = err
 	}
-	_, giveup := c.GetDefaultTimeout()
-	return c.send(0, giveup, ri, fn, in, out)
+
+	return errs
 }
 
 /*
 ServiceClient.SetTimeout() sets the time before ServiceClient.Send() retries requests, and
 the time before ServiceClient.Send() and ServiceClient.SendOnce() give up. Setting retry
-or giveup to 0 indicates no retry or time out.
+or giveup to 0 indicates no retry or time out. Retries are sent every retry unless client.timeout.retry.multiplier
+backs them off.
 */
 func (c *ServiceClient) SetDefaultTimeout(retry, giveup time.Duration) {
-	c.muxChan <- timeoutLengths{
+	c.sendMux(timeoutLengths{
 		retry:  retry,
 		giveup: giveup,
-	}
+	})
 }
 
 /*
 ServiceClient.GetTimeout() returns current timeout values
 */
 func (c *ServiceClient) GetDefaultTimeout() (retry, giveup time.Duration) {
-	tls := <-c.timeoutChan
-	retry, giveup = tls.retry, tls.giveup
-
-	return
+	tl := c.timeouts()
+	return tl.retry, tl.giveup
 }
 
 /*
-ServiceClient.Close() refuses any new requests, and waits for active requests to finish
+ServiceClient.Close() refuses any new requests, and waits for active requests to finish. The ServiceClient
+stops receiving instance notifications, and connections to instances no other ServiceClient uses are closed
 */
 func (c *ServiceClient) Close() {
-	c.shutdownChan <- true
+	select {
+	case c.shutdownChan <- true:
+	case <-c.done:
+		return
+	}
+
+	removeServiceClient(c)
 	c.waiter.Wait()
+
+	for _, i := range c.static {
+		getPool().RemoveInstance(i)
+	}
+}
+
+func (c *ServiceClient) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// sends m to mux(), returning false if the ServiceClient has been closed
+func (c *ServiceClient) sendMux(m interface{}) bool {
+	select {
+	case c.muxChan <- m:
+		return true
+	case <-c.done:
+		return false
+	}
 }
 
 /*
@@ -138,7 +871,7 @@ ServiceClient.NewRequestInfo() create a new RequestInfo object specific to this
 func (c *ServiceClient) NewRequestInfo() (ri *skynet.RequestInfo) {
 	// TODO: Set
 	ri = &skynet.RequestInfo{
-		RequestID: config.NewUUID(),
+		RequestID: idGenerator(),
 	}
 
 	return
@@ -155,64 +888,302 @@ func (c *ServiceClient) Matches(s skynet.ServiceInfo) bool {
 ServiceClient.Notify() Update available instances based off provided InstanceNotification
 */
 func (c *ServiceClient) Notify(n skynet.InstanceNotification) {
-	c.instanceNotifications <- n
+	select {
+	case c.instanceNotifications <- n:
+	case <-c.done:
+	}
+}
+
+func (c *ServiceClient) send(ctx context.Context, retry, giveup time.Duration, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+	_, err = c.sendDetailed(ctx, retry, giveup, ri, fn, in, out)
+	return
 }
 
-func (c *ServiceClient) send(retry, giveup time.Duration, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+func (c *ServiceClient) sendDetailed(ctx context.Context, retry, giveup time.Duration, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (result skynet.SendResult, err error) {
+	if ri != nil && ri.CoalesceKey != "" {
+		return c.sendCoalesced(ctx, retry, giveup, ri, fn, in, out)
+	}
+
+	// Close() and DrainWait() wait for requests that are already being sent
+	if err = c.startRequest(); err != nil {
+		return
+	}
+	defer c.finishRequest()
+
 	if ri == nil {
 		ri = c.NewRequestInfo()
 	}
 
+	start := time.Now()
+
+	defer func() {
+		result.Duration = time.Since(start)
+
+		metrics.RecordSend(SendMetrics{
+			Service:    c.criteria.Services[0].Name,
+			Method:     fn,
+			Duration:   result.Duration,
+			Attempts:   result.Attempts,
+			Instance:   result.Instance,
+			Err:        err,
+			ErrorClass: errorClass(err),
+
+			ConnectFailures: result.ConnectFailures,
+			CallFailures:    result.CallFailures,
+		})
+	}()
+
+	// attempts decode into a copy of out, a panic there would take down the process
+	if err = checkOut(fn, out); err != nil {
+		return
+	}
+
+	// Attempts are abandoned once the request finishes, and aborted if the caller cancels
+	// or we reach the giveup time
+	var cancel context.CancelFunc
+	attemptCtx := ctx
+	if giveup > 0 {
+		attemptCtx, cancel = context.WithTimeout(ctx, giveup)
+	} else {
+		attemptCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	// Attempts are sent with a copy of ri, so a caller reusing it doesn't carry over this request's
+	// deadline, retries or metadata
+	if ri, err = c.withMetadata(ri); err != nil {
+		return
+	}
+
+	if c.nonIdempotent[fn] {
+		ri.NotIdempotent = true
+	}
+
+	c.routeCanary(ri)
+
+	// Let the service know when we'll stop waiting, unless the caller already has an earlier deadline
+	if d, ok := attemptCtx.Deadline(); ok && (ri.Deadline.IsZero() || d.Before(ri.Deadline)) {
+		ri.Deadline = d
+	}
+
+	if ri.TraceID == "" {
+		ri.TraceID = config.NewUUID()
+	}
+
+	err = intercept(interceptors, ri, fn, in, func() (err error) {
+		result, err = c.sendAttempts(ctx, attemptCtx, retry, giveup, ri, fn, in, out)
+		return
+	})
+
+	return
+}
+
+// Sends attempts until one succeeds or attemptCtx is done, retrying and hedging as configured
+func (c *ServiceClient) sendAttempts(ctx, attemptCtx context.Context, retry, giveup time.Duration, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (result skynet.SendResult, err error) {
+	requestSpan := SpanInfo{
+		TraceID:      ri.TraceID,
+		SpanID:       config.NewUUID(),
+		ParentSpanID: ri.ParentSpanID,
+		Service:      c.criteria.Services[0].Name,
+		Method:       fn,
+	}
+
+	// attempts can outlive the request, so they're traced by the Tracer it started with
+	tr := tracer
+	validator := responseValidator
+	span := tr.StartSpan(requestSpan)
+	defer func() { span.Finish(err) }()
+
 	attempts := make(chan sendAttempt)
 
-	var retryTicker <-chan time.Time
-	retryChan := make(chan bool, 1)
+	// A request that isn't idempotent is only retried once an attempt fails without reaching an instance,
+	// so it's never sent while an earlier attempt may still be applied
+	idempotent := !ri.NotIdempotent
+
+	var b *backoff
+	var retryTimer <-chan time.Time
 	if retry > 0 {
-		retryTicker = time.Tick(retry)
+		b = newBackoff(retry, c.retryMax, c.retryMultiplier)
+
+		if idempotent {
+			retryTimer = retryAfter(b.next())
+		}
 	}
 
-	var timeoutTimer <-chan time.Time
-	if giveup > 0 {
-		timeoutTimer = time.NewTimer(giveup).C
+	policy := c.priorityPolicy(ri.Priority)
+
+	// A hedge races the first attempt against another instance rather than waiting for it to fail,
+	// it's only sent if it would go out before the first retry
+	var hedgeTimer <-chan time.Time
+	if retry > 0 && idempotent && policy.hedgeDelay > 0 && policy.hedgeDelay < retry {
+		hedgeTimer = time.After(policy.hedgeDelay)
+	}
+
+	// attempts that may still be applied aren't timed out by the retry timeout, only by the giveup timeout
+	attemptTimeout := retry
+	if !idempotent {
+		attemptTimeout = giveup
+	}
+
+	if err = c.acquireSlot(attemptCtx); err != nil {
+		if err == context.DeadlineExceeded && ctx.Err() == nil {
+			err = RequestTimeout
+		}
+
+		logger.Warn("Request not sent: ", err)
+		return
+	}
+
+	// every attempt started delivers its result unless attemptCtx is done
+	pending := 0
+
+	// reported if the request times out
+	var failures []AttemptError
+
+	// retries and hedges are sent to other instances than those working on earlier attempts
+	exclusions := newAttemptExclusions(c.exclusionTTL)
+
+	// each attempt is sent with a copy of ri, carrying its own span
+	startAttempt := func() {
+		pending++
+		attemptRI := *ri
+		go c.attemptSendWithSlot(attemptCtx, attemptTimeout, attempts, exclusions, &attemptRI, tr, requestSpan, result.Attempts, fn, in, out)
 	}
 
-	attemptCount := 1
-	go c.attemptSend(retry, attempts, ri, fn, in, out)
+	result.Attempts = 1
+	startAttempt()
+
+	attemptsExhausted := func() bool {
+		return policy.maxAttempts > 0 && result.Attempts >= policy.maxAttempts
+	}
+
+	retryNow := func() {
+		if attemptsExhausted() {
+			logger.Debug("Skipping retry, max attempts reached")
+			return
+		}
+
+		if !c.tryAcquireSlot() {
+			logger.Debug("Skipping retry, concurrent request limit reached")
+			return
+		}
+
+		if !c.retryBudget.withdraw() {
+			c.releaseSlot()
+			logger.Debug("Skipping retry, retry budget exhausted")
+			return
+		}
+
+		result.Attempts++
+		ri.RetryCount++
+		logger.Debug(fmt.Sprintf("Sending Attempt# %d with RequestInfo %+v", result.Attempts, ri))
+		startAttempt()
+	}
 
 	for {
 		select {
-		case <-retryTicker:
-			retryChan <- true
+		case <-retryTimer:
+			retryNow()
+			hedgeTimer = nil
+
+			if idempotent {
+				retryTimer = retryAfter(b.next())
+				continue
+			}
 
-		case <-retryChan:
-			attemptCount++
-			ri.RetryCount++
-			log.Println(log.TRACE, fmt.Sprintf("Sending Attempt# %d with RequestInfo %+v", attemptCount, ri))
-			go c.attemptSend(retry, attempts, ri, fn, in, out)
+			// only armed once an attempt failed, should the retry have been skipped there's nothing left to wait for
+			retryTimer = nil
+			if pending == 0 {
+				return result, failures[len(failures)-1].Err
+			}
+
+		case <-hedgeTimer:
+			logger.Debug(fmt.Sprintf("Hedging request after %s", policy.hedgeDelay.String()))
+			retryNow()
+			hedgeTimer = nil
+
+		case <-attemptCtx.Done():
+			if ctx.Err() != nil {
+				logger.Warn(fmt.Sprintf("Request cancelled after %d attempts: %v", result.Attempts, ctx.Err()))
+				return result, ctx.Err()
+			}
 
-		case <-timeoutTimer:
 			err = RequestTimeout
-			log.Println(log.WARN, fmt.Sprintf("Timing out request after %d attempts within %s ", attemptCount, giveup.String()))
+			if len(failures) > 0 {
+				err = TimeoutError{Attempts: failures}
+			}
+
+			logger.Warn(fmt.Sprintf("Timing out request after %d attempts within %s ", result.Attempts, giveup.String()))
 			return
 
 		case attempt := <-attempts:
+			pending--
+			result.Instance = attempt.service
+
+			// a response the ResponseValidator rejects fails the attempt, so it's retried rather than copied to out
+			if attempt.err == nil {
+				attempt.err = validateResponse(validator, fn, attempt.result)
+			}
+
 			if attempt.err != nil {
-				log.Println(log.ERROR, "Attempt Error: ", attempt.err)
-
-				// If there is no retry timer we need to exit as retries were disabled
-				if retryTicker == nil {
-					return err
-				} else {
-					// Don't wait for next retry tick retry now
-					retryChan <- true
+				logger.Warn("Attempt Error: ", attempt.err)
+				failures = append(failures, AttemptError{Instance: attempt.service, Err: attempt.err})
+
+				if IsConnectError(attempt.err) {
+					result.ConnectFailures++
+				} else if !attempt.unsent {
+					result.CallFailures++
+				}
+
+				// If there is no retry timer we need to exit as retries were disabled, there's nobody
+				// to retry against until a cooldown passes or requests to them complete, or one is discovered
+				// with client.instances.failfast set, or a retry would fail to encode or decode again, or be
+				// refused by the service again
+				if b == nil || attempt.err == NoAvailableInstances || attempt.err == NoMatchingInstance ||
+					attempt.err == loadbalancer.AllInstancesSaturated || (attempt.err == loadbalancer.NoInstances && c.failFast) ||
+					conn.IsCodecError(attempt.err) || c.skipsRetry(attempt.err) {
+					return result, attempt.err
+				}
+
+				if !idempotent {
+					// The instance may have applied the request, it mustn't be sent again
+					if !attempt.unsent {
+						return result, attempt.err
+					}
+
+					// there's no retry timer, the retry is sent now or once the backoff passes
+					if !b.backingOff() {
+						retryNow()
+					} else if retryTimer == nil && !attemptsExhausted() {
+						retryTimer = retryAfter(b.next())
+					}
+
+					if pending == 0 && retryTimer == nil {
+						return result, attempt.err
+					}
+
+					continue
+				}
+
+				// At a fixed interval don't wait for next retry tick retry now,
+				// otherwise wait for the backoff so we don't pile onto a failing cluster
+				if !b.backingOff() {
+					retryNow()
+				}
+
+				// Every attempt allowed by client.attempts.max has failed, there's nothing left to wait for
+				if attemptsExhausted() && pending == 0 {
+					return result, attempt.err
 				}
 
 				continue
 			}
 
-			// copy into the caller's value
-			v := reflect.Indirect(reflect.ValueOf(out))
-			v.Set(reflect.Indirect(reflect.ValueOf(attempt.result)))
+			c.retryBudget.deposit()
+
+			// Returning cancels attemptCtx, aborting the attempts that lost by closing their connections
+
+			copyOut(out, attempt.result)
 
 			return
 		}
@@ -220,42 +1191,225 @@ func (c *ServiceClient) send(retry, giveup time.Duration, ri *skynet.RequestInfo
 }
 
 type sendAttempt struct {
-	err    error
-	result interface{}
+	err     error
+	result  interface{}
+	service skynet.ServiceInfo
+
+	// the attempt failed before it was sent to an instance, so it can't have been applied
+	unsent bool
 }
 
-func (c *ServiceClient) attemptSend(timeout time.Duration, attempts chan sendAttempt, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) {
-	s, err := c.loadBalancer.Choose()
+// the slot is released however the attempt ends, the attempt is traced as a child of the request's span
+func (c *ServiceClient) attemptSendWithSlot(ctx context.Context, timeout time.Duration, attempts chan sendAttempt, ex *attemptExclusions, ri *skynet.RequestInfo, tr Tracer, parent SpanInfo, attempt int, fn string, in interface{}, out interface{}) {
+	defer c.releaseSlot()
+
+	s := parent
+	s.SpanID, s.ParentSpanID, s.Attempt = config.NewUUID(), parent.SpanID, attempt
+
+	// the service's span is a child of the attempt's
+	ri.ParentSpanID = s.SpanID
+
+	span := tr.StartSpan(s)
+	span.Finish(c.attemptSend(ctx, timeout, attempts, ex, ri, fn, in, out))
+}
+
+// returns the attempt's error, even once nobody is waiting on it
+func (c *ServiceClient) attemptSend(ctx context.Context, timeout time.Duration, attempts chan sendAttempt, ex *attemptExclusions, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) error {
+	s, err := c.chooseInstance(ri, ex)
 
 	if err != nil {
-		attempts <- sendAttempt{err: err}
-		return
+		deliverAttempt(ctx, attempts, sendAttempt{err: err, unsent: true})
+		return err
+	}
+
+	// The request may have finished while we were choosing an instance
+	if ctx.Err() != nil {
+		return ctx.Err()
 	}
 
-	conn, err := acquire(s)
-	defer release(conn)
+	ex.exclude(s.UUID)
+
+	cn, err := acquire(s)
 
 	if err != nil {
-		attempts <- sendAttempt{err: err}
-		return
+		c.sendMux(instanceResult{service: s, err: instanceFailure(err)})
+		ex.include(s.UUID)
+		deliverAttempt(ctx, attempts, sendAttempt{err: err, service: s, unsent: true})
+		return err
 	}
 
+	defer func() {
+		if cn != nil {
+			release(cn)
+		}
+	}()
+
 	// Create a new instance of the type, we dont want race conditions where 2 connections are unmarshalling to the same object
 	res := sendAttempt{
-		result: reflect.New(reflect.Indirect(reflect.ValueOf(out)).Type()).Interface(),
+		result:  reflect.New(reflect.Indirect(reflect.ValueOf(out)).Type()).Interface(),
+		service: s,
+	}
+
+	sent := time.Now()
+	err = sendOnConnection(ctx, cn, timeout, ri, fn, in, res.result)
+	retried := false
+
+	// The connection broke rather than the instance failing the request, try once more on a fresh connection
+	// unless the request isn't idempotent, it may have reached the instance before the connection broke
+	if conn.IsTransportError(err) && ctx.Err() == nil && !ri.NotIdempotent {
+		retried = true
+		logger.Warn(fmt.Sprintf("Connection to %s failed, retrying on a new connection: %v", s.AddrString(), err))
+		release(cn)
+		c.sendMux(transportFailure{service: s})
+
+		if cn, err = acquire(s); err == nil {
+			sent = time.Now()
+			err = sendOnConnection(ctx, cn, timeout, ri, fn, in, res.result)
+		}
+	}
+
+	// The service failed the request rather than the connection breaking, it's retried on the same connection
+	// before another attempt is sent, for services that keep state for each connection
+	for tries := 0; tries < c.sameConnRetries && conn.IsServiceError(err) && !c.skipsRetry(err) && !ri.NotIdempotent && ctx.Err() == nil; tries++ {
+		logger.Debug(fmt.Sprintf("Retrying request to %s on the same connection: %v", s.AddrString(), err))
+		ri.RetryCount++
+		sent = time.Now()
+		err = sendOnConnection(ctx, cn, timeout, ri, fn, in, res.result)
 	}
 
-	err = conn.SendTimeout(ri, fn, in, res.result, timeout)
+	// Nobody is waiting on this attempt, and any error was caused by aborting it
+	if ctx.Err() != nil {
+		ex.include(s.UUID)
+		return ctx.Err()
+	}
 
 	if err != nil {
 		res.err = err
 	}
 
-	attempts <- res
+	c.sendMux(instanceResult{service: s, err: instanceFailure(err), duration: time.Since(sent), retried: retried})
+
+	// the instance is done with the attempt before it's delivered, so the next attempt can be sent to it
+	ex.include(s.UUID)
+	deliverAttempt(ctx, attempts, res)
+
+	return err
+}
+
+// Chooses an instance from the LoadBalancer, distinguishing between no instances being known and
+// all of them being excluded by their circuit breakers. Instances excluded by ex are passed over while others are available
+func (c *ServiceClient) chooseInstance(ri *skynet.RequestInfo, ex *attemptExclusions) (s skynet.ServiceInfo, err error) {
+	// probes could go to an instance other than the one for the request's key
+	if atomic.LoadInt32(&c.halfOpen) > 0 && ri.RoutingKey == "" && ri.AffinityKey == "" {
+		resp := make(chan probeResult, 1)
+
+		if c.sendMux(probeQuery{ri: ri, resp: resp}) {
+			if r := <-resp; r.ok {
+				return r.service, nil
+			}
+		}
+	}
+
+	if ri.LabelSelector != "" {
+		resp := make(chan labelResult, 1)
+
+		if !c.sendMux(labelQuery{ri: ri, resp: resp}) {
+			return s, ServiceClientClosed
+		}
+
+		r := <-resp
+		s, err = r.service, r.err
+	} else if ri.AffinityKey != "" {
+		resp := make(chan affinityResult, 1)
+
+		if !c.sendMux(affinityQuery{ri: ri, resp: resp}) {
+			return s, ServiceClientClosed
+		}
+
+		r := <-resp
+		s, err = r.service, r.err
+	} else {
+		s, err = c.chooseAvoiding(ri, ex)
+	}
+
+	if err == loadbalancer.NoInstances {
+		resp := make(chan bool, 1)
+
+		if !c.sendMux(excludedQuery{resp: resp}) {
+			err = ServiceClientClosed
+		} else if <-resp {
+			err = NoAvailableInstances
+		}
+	}
+
+	return
+}
+
+func (c *ServiceClient) chooseFromLoadBalancer(ri *skynet.RequestInfo) (s skynet.ServiceInfo, err error) {
+	if kc, ok := c.loadBalancer.(loadbalancer.KeyChooser); ok && ri.RoutingKey != "" {
+		return kc.ChooseKey(ri.RoutingKey)
+	}
+
+	return c.loadBalancer.Choose()
+}
+
+// Sends the request over cn, closing cn to abort the request if ctx is done before it completes
+// The connection is closed if ctx is done before the response arrives, that's only the case for attempts
+// that were cancelled, timed out, or lost to another attempt
+func sendOnConnection(ctx context.Context, cn conn.Connection, timeout time.Duration, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) error {
+	// closing a multiplexed connection would abort the other requests on it, so the request is abandoned instead
+	// leaving its response to be discarded when it arrives
+	if m, ok := cn.(multiplexed); ok && m.Multiplexed() && ctx.Done() != nil {
+		sent := make(chan error, 1)
+
+		go func() {
+			sent <- cn.SendTimeout(ri, fn, in, out, timeout)
+		}()
+
+		select {
+		case err := <-sent:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if ctx.Done() != nil {
+		sent := make(chan struct{})
+		watched := make(chan struct{})
+
+		// wait for the watcher, so it can't close the connection once we've returned and it's released
+		defer func() {
+			close(sent)
+			<-watched
+		}()
+
+		go func() {
+			defer close(watched)
+
+			select {
+			case <-ctx.Done():
+				cn.Close()
+			case <-sent:
+			}
+		}()
+	}
+
+	return cn.SendTimeout(ri, fn, in, out, timeout)
+}
+
+func deliverAttempt(ctx context.Context, attempts chan sendAttempt, a sendAttempt) {
+	select {
+	case attempts <- a:
+	case <-ctx.Done():
+	}
 }
 
 type timeoutLengths struct {
 	retry, giveup time.Duration
+
+	// giveup times of methods set by SetMethodTimeout() or client.timeout.methods
+	methods map[string]time.Duration
 }
 
 func (c *ServiceClient) mux() {
@@ -266,19 +1420,68 @@ func (c *ServiceClient) mux() {
 			case timeoutLengths:
 				c.retryTimeout = m.retry
 				c.giveupTimeout = m.giveup
+			case methodTimeout:
+				c.setMethodTimeout(m)
+			case instanceResult:
+				c.recordInstanceResult(m)
+			case transportFailure:
+				c.recordTransportFailure(m.service)
+			case breakerCooldown:
+				c.halfOpenBreaker(m.uuid)
+			case staleCheck:
+				c.checkStale()
+			case coalesceQuery:
+				m.resp <- c.joinCoalesced(m.key)
+			case coalesceDone:
+				c.removeCoalesced(m)
+			case excludedQuery:
+				m.resp <- c.allInstancesExcluded()
+			case probeQuery:
+				s, ok := c.nextProbe(m.ri)
+				m.resp <- probeResult{service: s, ok: ok}
+			case instanceCountQuery:
+				m.resp <- c.availableInstances()
+			case affinityQuery:
+				s, err := c.affinityInstance(m.ri)
+				m.resp <- affinityResult{service: s, err: err}
+			case labelQuery:
+				s, err := c.labelInstance(m.ri)
+				m.resp <- labelResult{service: s, err: err}
+			case instancesQuery:
+				m.resp <- c.instanceList()
+			case instanceInfoQuery:
+				m.resp <- c.instanceInfoList()
+			case instanceWaiter:
+				c.instanceWaiters = append(c.instanceWaiters, m)
+			case instanceWaiterCancelled:
+				c.removeInstanceWaiter(m.ready)
+			case evictionRequest:
+				c.handleEvictionRequest(m)
+			case instanceChangeCallback:
+				c.setInstanceChangeCallback(m)
+			case discoveryPause:
+				c.pauseDiscovery()
+			case discoveryResume:
+				c.resumeDiscovery()
 			}
+
+			c.notifyInstanceWaiters()
 		case n := <-c.instanceNotifications:
-			c.handleInstanceNotification(n)
+			if !c.holdNotification(n) {
+				c.handleInstanceNotification(n)
+			}
+
+			c.notifyInstanceWaiters()
 
 		case c.timeoutChan <- timeoutLengths{
-			retry:  c.retryTimeout,
-			giveup: c.giveupTimeout,
+			retry:   c.retryTimeout,
+			giveup:  c.giveupTimeout,
+			methods: c.methodTimeouts,
 		}:
 
 		case shutdown := <-c.shutdownChan:
-			// TODO: Close out all channels, and this goroutine after waiting for requests to finish
 			if shutdown {
-				c.closed = true
+				close(c.done)
 				return
 			}
 		}
@@ -287,38 +1490,195 @@ func (c *ServiceClient) mux() {
 
 // this should only be called by mux()
 func (c *ServiceClient) handleInstanceNotification(n skynet.InstanceNotification) {
+	// Instances with an open circuit breaker have been removed from the LoadBalancer
+	// we track changes to them, they will be re-added once their cooldown has passed.
+	// Evicted instances are tracked too, but only re-added once they're un-evicted
+	excluded := c.isExcluded(n.Service.UUID)
+
 	// TODO: ensure LoadBalancer is thread safe and call these as goroutines
 	switch n.Type {
 	case skynet.InstanceAdded:
-		c.loadBalancer.AddInstance(n.Service)
+		c.instances[n.Service.UUID] = n.Service
+		c.recordServed(n.Service.UUID)
+
+		if !c.isExcluded(n.Service.UUID) {
+			c.loadBalancer.AddInstance(n.Service)
+		}
 	case skynet.InstanceUpdated:
-		c.loadBalancer.UpdateInstance(n.Service)
+		c.instances[n.Service.UUID] = n.Service
+
+		// The instance may have moved to or from an evicted address
+		switch nowExcluded := c.isExcluded(n.Service.UUID); {
+		case !excluded && !nowExcluded:
+			c.loadBalancer.UpdateInstance(n.Service)
+		case !excluded:
+			c.loadBalancer.RemoveInstance(n.Service)
+		case !nowExcluded:
+			c.loadBalancer.AddInstance(n.Service)
+		}
 	case skynet.InstanceRemoved:
-		c.loadBalancer.RemoveInstance(n.Service)
+		delete(c.instances, n.Service.UUID)
+		c.removeBreaker(n.Service.UUID)
+		c.removeAffinity(n.Service.UUID)
+		delete(c.latencies, n.Service.UUID)
+		delete(c.transportFailures, n.Service.UUID)
+		c.removeServed(n.Service.UUID)
+
+		if !excluded {
+			c.loadBalancer.RemoveInstance(n.Service)
+		}
+	}
+
+	if len(c.instances) > 0 {
+		c.markReady()
+	}
+
+	if c.instanceChange != nil {
+		c.instanceChange.notify(n)
+	}
+}
+
+// Instances in the local region (client.region.local) are preferred when it's set, only instances
+// satisfying client.version.constraint are chosen, and canaries only while there are no other instances
+func newLoadBalancer(service, version string) loadbalancer.LoadBalancer {
+	factory := LoadBalancerFactory
+
+	if r, err := config.String(service, version, "client.region.local"); err == nil && r != "" {
+		factory = regional.NewFactory(r, factory)
+	}
+
+	if v, err := config.String(service, version, "client.version.constraint"); err == nil && v != "" {
+		if constraint, err := versioned.ParseConstraint(v); err == nil {
+			factory = versioned.NewFactory(constraint, getVersionFallback(service, version), factory)
+		} else {
+			logger.Error("Failed to parse client.version.constraint", err)
+		}
+	}
+
+	// requests routed to the canaries are sent to them by their LabelSelector, see canaryPolicy
+	if p := getCanaryPolicy(service, version); p.selector != "" && p.percent > 0 {
+		factory = canary.NewFactory(skynet.ParseLabels(p.selector), factory)
+	}
+
+	if max := getMaxInFlightPerInstance(service, version); max > 0 {
+		factory = capped.NewFactory(max, InFlightRequests, factory)
+	}
+
+	return factory([]skynet.ServiceInfo{})
+}
+
+func getMaxInFlightPerInstance(service, version string) int {
+	if n, err := config.Int(service, version, "client.instance.inflight.max"); err == nil {
+		return n
+	}
+
+	return config.DefaultMaxInFlightPerInstance
+}
+
+func getVersionFallback(service, version string) bool {
+	if b, err := config.Bool(service, version, "client.version.fallback"); err == nil {
+		return b
 	}
+
+	return config.DefaultVersionFallback
 }
 
 func getRetryTimeout(service, version string) time.Duration {
 	if d, err := config.String(service, version, "client.timeout.retry"); err == nil {
 		if timeout, err := time.ParseDuration(d); err == nil {
-			log.Println(log.TRACE, fmt.Sprintf("Using custom retry duration %q for %q %q", timeout.String(), service, version))
+			logger.Debug(fmt.Sprintf("Using custom retry duration %q for %q %q", timeout.String(), service, version))
 			return timeout
 		}
 
-		log.Println(log.ERROR, "Failed to parse client.timeout.total", err)
+		logger.Error("Failed to parse client.timeout.total", err)
 	}
 
 	return config.DefaultRetryDuration
 }
 
+// errors the service returned with a code in client.retry.skip
+func (c *ServiceClient) skipsRetry(err error) bool {
+	re, ok := err.(conn.RemoteError)
+	return ok && re.Code != "" && c.retrySkipCodes[re.Code]
+}
+
+func getRetrySkipCodes(service, version string) map[string]bool {
+	s, err := config.String(service, version, "client.retry.skip")
+	if err != nil {
+		s = config.DefaultRetrySkipCodes
+	}
+
+	codes := make(map[string]bool)
+	for _, code := range strings.Split(s, ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			codes[code] = true
+		}
+	}
+
+	return codes
+}
+
+// methods in client.retry.nonidempotent
+func getNonIdempotentMethods(service, version string) map[string]bool {
+	s, err := config.String(service, version, "client.retry.nonidempotent")
+	if err != nil {
+		s = config.DefaultNonIdempotentMethods
+	}
+
+	methods := make(map[string]bool)
+	for _, fn := range strings.Split(s, ",") {
+		if fn = strings.TrimSpace(fn); fn != "" {
+			methods[fn] = true
+		}
+	}
+
+	return methods
+}
+
+func getSameConnRetries(service, version string) int {
+	if n, err := config.Int(service, version, "client.retry.sameconn"); err == nil {
+		return n
+	}
+
+	return config.DefaultSameConnRetries
+}
+
+func getMaxAttempts(service, version string) int {
+	if n, err := config.Int(service, version, "client.attempts.max"); err == nil {
+		return n
+	}
+
+	return config.DefaultMaxAttempts
+}
+
+func getFailFastWhenEmpty(service, version string) bool {
+	if b, err := config.Bool(service, version, "client.instances.failfast"); err == nil {
+		return b
+	}
+
+	return config.DefaultFailFastWhenEmpty
+}
+
+func getHedgeDelay(service, version string) time.Duration {
+	if d, err := config.String(service, version, "client.timeout.hedge"); err == nil {
+		if timeout, err := time.ParseDuration(d); err == nil {
+			return timeout
+		}
+
+		logger.Error("Failed to parse client.timeout.hedge", err)
+	}
+
+	return config.DefaultHedgeDuration
+}
+
 func getGiveupTimeout(service, version string) time.Duration {
 	if d, err := config.String(service, version, "client.timeout.total"); err == nil {
 		if timeout, err := time.ParseDuration(d); err == nil {
-			log.Println(log.TRACE, fmt.Sprintf("Using custom giveup duration %q for %q %q", timeout.String(), service, version))
+			logger.Debug(fmt.Sprintf("Using custom giveup duration %q for %q %q", timeout.String(), service, version))
 			return timeout
 		}
 
-		log.Println(log.ERROR, "Failed to parse client.timeout.total", err)
+		logger.Error("Failed to parse client.timeout.total", err)
 	}
 
 	return config.DefaultTimeoutDuration
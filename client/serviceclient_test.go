@@ -1,10 +1,22 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"github.com/skynetservices/skynet"
 	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/client/loadbalancer"
+	"github.com/skynetservices/skynet/client/loadbalancer/consistenthash"
+	"github.com/skynetservices/skynet/client/loadbalancer/roundrobin"
+	"github.com/skynetservices/skynet/config"
 	"github.com/skynetservices/skynet/test"
+	"io"
 	"labix.org/v2/mgo/bson"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -12,7 +24,6 @@ import (
 // TODO: Test Send()
 // TODO: Test SendOnce()
 // TODO: Test SendTimeout()
-// TODO: Test SendOnceTimeout()
 // TODO: Test Timeout/Retry logic
 // TODO: Test Close() closes connections
 // TODO: Test that NewServiceClient gets a LoadBalancer from the factory
@@ -50,7 +61,7 @@ func TestInstanceNotificationsUpdateLoadBalancer(t *testing.T) {
 	sClient := sc.(*ServiceClient)
 
 	// Use stub pool, we dont want real connections being made
-	pool = &test.Pool{}
+	setPool(&test.Pool{})
 
 	addServiceClient(sc)
 
@@ -121,6 +132,230 @@ func TestCloseRefusesNewRequests(t *testing.T) {
 	}
 }
 
+func TestSendFailsFastWithoutInstances(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+	sc.SetDefaultTimeout(10*time.Millisecond, 5*time.Second)
+	sc.(*ServiceClient).failFast = true
+
+	start := time.Now()
+
+	var val string
+	if err := sc.Send(nil, "Foo", val, &val); err != loadbalancer.NoInstances {
+		t.Fatal("Requests should fail with NoInstances while no instances are known", err)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Fatal("Requests shouldn't wait for an instance to be discovered with client.instances.failfast set")
+	}
+}
+
+func TestNonIdempotentRequestsOnlyRetriedUntilSent(t *testing.T) {
+	defer resetClient()
+
+	a, b := *serviceInfo(), *serviceInfo()
+	a.UUID, b.UUID = config.NewUUID(), config.NewUUID()
+	b.ServiceAddr.Port = a.ServiceAddr.Port + 1
+
+	sc := GetService("TestService", "", "", "")
+	sc.SetDefaultTimeout(10*time.Millisecond, time.Second)
+	sc.(*ServiceClient).nonIdempotent = map[string]bool{"Charge": true}
+
+	var acquired, sent int32
+	var connectFailures int32
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			atomic.AddInt32(&acquired, 1)
+
+			if atomic.AddInt32(&connectFailures, -1) >= 0 {
+				return nil, errors.New("connection refused")
+			}
+
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) error {
+					atomic.AddInt32(&sent, 1)
+
+					// the request reached the instance, but its response was lost
+					return conn.TransportError{Err: io.EOF}
+				},
+			}, nil
+		},
+	})
+
+	for _, s := range []skynet.ServiceInfo{a, b} {
+		sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: s})
+	}
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 2
+	})
+
+	send := func(ri *skynet.RequestInfo, fn string, failures int32) error {
+		atomic.StoreInt32(&acquired, 0)
+		atomic.StoreInt32(&sent, 0)
+		atomic.StoreInt32(&connectFailures, failures)
+
+		var val string
+		return sc.Send(ri, fn, val, &val)
+	}
+
+	if err := send(nil, "Charge", 0); !conn.IsTransportError(err) || atomic.LoadInt32(&sent) != 1 {
+		t.Fatal("Method in client.retry.nonidempotent shouldn't be retried once it may have reached an instance", err, sent)
+	}
+
+	ri := sc.(*ServiceClient).NewRequestInfo()
+	ri.NotIdempotent = true
+
+	if err := send(ri, "Foo", 0); !conn.IsTransportError(err) || atomic.LoadInt32(&sent) != 1 {
+		t.Fatal("Request with RequestInfo.NotIdempotent shouldn't be retried once it may have reached an instance", err, sent)
+	}
+
+	if send(nil, "Charge", 2); atomic.LoadInt32(&acquired) != 3 || atomic.LoadInt32(&sent) != 1 {
+		t.Fatal("Request that isn't idempotent should be retried while it fails to connect", acquired, sent)
+	}
+
+	if send(nil, "Foo", 0); atomic.LoadInt32(&sent) < 2 {
+		t.Fatal("Idempotent requests should still be retried", sent)
+	}
+}
+
+func TestServiceFailureRetriedOnSameConnection(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("TestService", "", "", "")
+	sc.SetDefaultTimeout(10*time.Millisecond, time.Second)
+	sc.(*ServiceClient).sameConnRetries = 2
+
+	var mutex sync.Mutex
+	var connections, sends int
+	var failure error
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			connections++
+
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) error {
+					mutex.Lock()
+					defer mutex.Unlock()
+
+					// the first two sends fail
+					if sends++; sends <= 2 {
+						return failure
+					}
+
+					return nil
+				},
+			}, nil
+		},
+	})
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: *serviceInfo()})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 1
+	})
+
+	send := func(err error) (int, int) {
+		mutex.Lock()
+		connections, sends, failure = 0, 0, err
+		mutex.Unlock()
+
+		var val string
+		if err := sc.Send(nil, "Foo", val, &val); err != nil {
+			t.Fatal(err)
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return connections, sends
+	}
+
+	if connections, sends := send(conn.RemoteError{Message: "Session busy"}); connections != 1 || sends != 3 {
+		t.Fatal("Request the service failed should be retried on the same connection", connections, sends)
+	}
+
+	if connections, sends := send(conn.TransportError{Err: io.EOF}); connections != 3 || sends != 3 {
+		t.Fatal("Request whose connection broke should be retried on a new connection", connections, sends)
+	}
+}
+
+func TestCloseStopsGoroutines(t *testing.T) {
+	defer resetClient()
+
+	setPool(&test.Pool{})
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		s := GetService("foo", "1.0.0", "", "")
+		s.SetDefaultTimeout(time.Second, time.Minute)
+		s.Close()
+
+		// calls after Close should not block on the stopped mux
+		s.SetDefaultTimeout(time.Second, time.Minute)
+		s.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: *serviceInfo()})
+		s.Close()
+	}
+
+	waitFor(t, func() bool {
+		return runtime.NumGoroutine() <= before
+	})
+}
+
+func TestCloseRemovesUnusedInstancesFromPool(t *testing.T) {
+	defer resetClient()
+
+	shared, exclusive := *serviceInfo(), *serviceInfo()
+	shared.UUID, exclusive.UUID = config.NewUUID(), config.NewUUID()
+	shared.Name, exclusive.Name = "TestSharedService", "TestExclusiveService"
+
+	var mutex sync.Mutex
+	removed := []skynet.ServiceInfo{}
+
+	setPool(&test.Pool{
+		RemoveInstanceFunc: func(s skynet.ServiceInfo) {
+			mutex.Lock()
+			removed = append(removed, s)
+			mutex.Unlock()
+		},
+	})
+
+	other := GetService("TestSharedService", "", "", "")
+	defer other.Close()
+
+	s := GetService("", "", "", "")
+
+	sendInstanceNotification(skynet.InstanceAdded, shared)
+	sendInstanceNotification(skynet.InstanceAdded, exclusive)
+
+	criteria := &skynet.Criteria{Instances: []string{shared.UUID, exclusive.UUID}}
+	waitFor(t, func() bool {
+		return len(cachedInstances(criteria)) == 2
+	})
+
+	s.Close()
+
+	waitFor(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return containsInstance(removed, exclusive.UUID)
+	})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if containsInstance(removed, shared.UUID) {
+		t.Fatal("Close() should not remove instances still used by other ServiceClients")
+	}
+}
+
 func TestSend(t *testing.T) {
 	called := false
 
@@ -165,28 +400,1041 @@ func TestSend(t *testing.T) {
 	}
 }
 
+func TestSendRoundRobinDistribution(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+	sClient := sc.(*ServiceClient)
+
+	instances := []skynet.ServiceInfo{*serviceInfo(), *serviceInfo(), *serviceInfo()}
+	for i := range instances {
+		instances[i].UUID = config.NewUUID()
+	}
+
+	sClient.loadBalancer = roundrobin.New(instances)
+
+	var mutex sync.Mutex
+	counts := make(map[string]int)
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			mutex.Lock()
+			counts[s.UUID]++
+			mutex.Unlock()
+
+			return &test.Connection{}, nil
+		},
+	})
+
+	var response string
+
+	for i := 0; i < 300; i++ {
+		if err := sc.Send(nil, "Foo", "bar", &response); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, i := range instances {
+		if counts[i.UUID] < 90 || counts[i.UUID] > 110 {
+			t.Fatal("Requests were not distributed evenly across instances", counts)
+		}
+	}
+}
+
 // Helper for validating and testing send logic
 // stubs ServiceManager, Pool, Connection, LoadBalancer
-func stubForSend(sc ServiceClientProvider, f func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)) {
-	sm := &test.ServiceManager{}
-	skynet.SetServiceManager(skynet.ServiceManager(sm))
+func TestRemoteErrorWithSkippedCodeNotRetried(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+	sc.SetDefaultTimeout(time.Millisecond, 50*time.Millisecond)
+	sc.(*ServiceClient).retrySkipCodes = map[string]bool{"NotFound": true}
 
-	pool = &test.Pool{
+	var sent int32
+	code := "NotFound"
+
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		atomic.AddInt32(&sent, 1)
+		return conn.RemoteError{Code: code, Message: "No such widget"}
+	})
+
+	var val string
+	err := sc.Send(nil, "Foo", val, &val)
+
+	if re, ok := err.(conn.RemoteError); !ok || re.Code != "NotFound" || re.Message != "No such widget" {
+		t.Fatal("Error returned by the service should be passed on with its code", err)
+	}
+
+	if n := atomic.LoadInt32(&sent); n != 1 {
+		t.Fatal("Errors with a code in client.retry.skip shouldn't be retried", n)
+	}
+
+	code = "Internal"
+	atomic.StoreInt32(&sent, 0)
+
+	sc.Send(nil, "Foo", val, &val)
+
+	if n := atomic.LoadInt32(&sent); n < 2 {
+		t.Fatal("Errors with other codes should be retried", n)
+	}
+}
+
+func TestSendRetriesTransportErrorOnNewConnection(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, nil)
+
+	acquired := 0
+	var released int32
+
+	setPool(&test.Pool{
 		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
-			c := &test.Connection{
-				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
-					return f(ri, fn, in, out)
-				},
+			acquired++
+
+			// first connection is broken
+			if acquired == 1 {
+				return &test.Connection{
+					SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+						return conn.TransportError{Err: io.EOF}
+					},
+				}, nil
 			}
 
-			return c, nil
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					*out.(*string) = "Foo"
+					return
+				},
+			}, nil
 		},
+		ReleaseFunc: func(c conn.Connection) {
+			atomic.AddInt32(&released, 1)
+		},
+	})
+
+	var val string
+	err := sc.SendOnce(nil, "Foo", val, &val)
+
+	if err != nil {
+		t.Fatal("Request should succeed on a fresh connection", err)
+	}
+
+	if val != "Foo" {
+		t.Fatal("response value failed to copy")
+	}
+
+	if acquired != 2 {
+		t.Fatal("Expected a second connection to be acquired", acquired)
+	}
+
+	// connections are released once the attempt returns, which can be after SendOnce()
+	waitFor(t, func() bool {
+		return atomic.LoadInt32(&released) == 2
+	})
+}
+
+func TestSendDoesNotRetryServiceError(t *testing.T) {
+	defer resetClient()
+
+	acquired := 0
+	serviceErr := errors.New("Invalid request")
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		acquired++
+		return serviceErr
+	})
+
+	var val string
+	err := sc.SendOnce(nil, "Foo", val, &val)
+
+	if err != serviceErr {
+		t.Fatal("Service errors should be returned to the caller untouched", err)
+	}
+
+	if acquired != 1 {
+		t.Fatal("Service errors should not be retried on a new connection")
 	}
+}
+
+func TestSendDoesNotRetryDecodeError(t *testing.T) {
+	defer resetClient()
+
+	var attempts int32
+	decodeErr := conn.ResponseDecodeError{Method: "Foo", Codec: "bson", Err: errors.New("Mismatched type")}
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		atomic.AddInt32(&attempts, 1)
+		return decodeErr
+	})
 
 	sClient := sc.(*ServiceClient)
-	sClient.loadBalancer = &test.LoadBalancer{
+	sClient.retryMultiplier = 1
+
+	var val string
+	err := sc.SendTimeout(time.Millisecond, time.Second, nil, "Foo", val, &val)
+
+	if err != decodeErr {
+		t.Fatal("Decode errors should be returned to the caller", err)
+	}
+
+	if n := atomic.LoadInt32(&attempts); n != 1 {
+		t.Fatal("Decode errors should not be retried", n)
+	}
+}
+
+func TestSendContextCancelled(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, nil)
+
+	sending := make(chan bool)
+	aborted := make(chan bool)
+	released := make(chan bool)
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			closed := make(chan bool)
+
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					sending <- true
+					<-closed
+					return conn.ConnectionClosed
+				},
+				CloseFunc: func() {
+					close(closed)
+					aborted <- true
+				},
+			}, nil
+		},
+		ReleaseFunc: func(c conn.Connection) {
+			released <- true
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := make(chan error)
+
+	go func() {
+		var val string
+		err <- sc.SendContext(ctx, nil, "Foo", val, &val)
+	}()
+
+	<-sending
+	cancel()
+
+	select {
+	case e := <-err:
+		if e != context.Canceled {
+			t.Fatal("SendContext() should return context.Canceled", e)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("SendContext() did not return promptly after cancel")
+	}
+
+	// The in-flight attempt should be aborted and its goroutine should finish
+	for _, c := range []chan bool{aborted, released} {
+		select {
+		case <-c:
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("In-flight attempt was not cleaned up after cancel")
+		}
+	}
+}
+
+func TestSendTimeoutOverridesDefaultGiveup(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+	sc.SetDefaultTimeout(0, time.Minute)
+
+	blocked := make(chan bool)
+	defer close(blocked)
+
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		<-blocked
+		return
+	})
+
+	err := make(chan error)
+
+	go func() {
+		var val string
+		err <- sc.SendTimeout(0, 10*time.Millisecond, nil, "Foo", val, &val)
+	}()
+
+	select {
+	case e := <-err:
+		if e != RequestTimeout {
+			t.Fatal("SendTimeout() should return RequestTimeout once its giveup passes", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SendTimeout() should give up after the supplied giveup rather than the default")
+	}
+
+	if retry, giveup := sc.GetDefaultTimeout(); retry != 0 || giveup != time.Minute {
+		t.Fatal("SendTimeout() should not change the default timeouts")
+	}
+}
+
+func TestSendOnceTimeoutGivesUp(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+	sc.SetDefaultTimeout(0, time.Minute)
+
+	var released int32
+	blocked := make(chan bool)
+	defer close(blocked)
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					<-blocked
+					return
+				},
+			}, nil
+		},
+		ReleaseFunc: func(c conn.Connection) {
+			atomic.AddInt32(&released, 1)
+		},
+	})
+
+	sc.(*ServiceClient).loadBalancer = &test.LoadBalancer{
 		ChooseFunc: func() (s skynet.ServiceInfo, err error) {
 			return
 		},
 	}
+
+	err := make(chan error)
+
+	go func() {
+		var val string
+		err <- sc.SendOnceTimeout(10*time.Millisecond, nil, "Foo", val, &val)
+	}()
+
+	select {
+	case e := <-err:
+		if e != RequestTimeout {
+			t.Fatal("SendOnceTimeout() should return RequestTimeout once its giveup passes", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SendOnceTimeout() should give up after the supplied giveup")
+	}
+
+	// the slow attempt still holds its connection, it's released once the attempt returns
+	blocked <- true
+
+	waitFor(t, func() bool {
+		return atomic.LoadInt32(&released) == 1
+	})
+}
+
+func TestSendStampsDeadline(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+
+	var deadline time.Time
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		deadline = ri.Deadline
+		return
+	})
+
+	var val string
+	before := time.Now()
+
+	if err := sc.SendTimeout(0, time.Minute, nil, "Foo", val, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	if deadline.Before(before.Add(time.Minute)) || deadline.After(time.Now().Add(time.Minute)) {
+		t.Fatal("Deadline should be when the request gives up", deadline.Sub(before))
+	}
+
+	// an earlier deadline from the caller is kept
+	ri := sc.(*ServiceClient).NewRequestInfo()
+	ri.Deadline = before.Add(time.Second)
+
+	if err := sc.SendTimeout(0, time.Minute, ri, "Foo", val, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	if !deadline.Equal(before.Add(time.Second)) {
+		t.Fatal("Deadline should not be extended past the caller's", deadline.Sub(before))
+	}
+
+	if err := sc.SendTimeout(0, 0, nil, "Foo", val, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	if !deadline.IsZero() {
+		t.Fatal("Deadline should be zero when the request never gives up", deadline)
+	}
+}
+
+func TestSendDoesNotStampCallersRequestInfo(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+
+	var deadline time.Time
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		deadline = ri.Deadline
+		return
+	})
+
+	var val string
+	ri := sc.(*ServiceClient).NewRequestInfo()
+
+	if err := sc.SendTimeout(0, time.Second, ri, "Foo", val, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	if !ri.Deadline.IsZero() {
+		t.Fatal("Deadline should only be stamped on the request sent, not the caller's RequestInfo", ri.Deadline)
+	}
+
+	// reusing ri for a request with a longer giveup shouldn't keep the first request's deadline
+	before := time.Now()
+
+	if err := sc.SendTimeout(0, time.Minute, ri, "Foo", val, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	if deadline.Before(before.Add(time.Minute)) {
+		t.Fatal("Reused RequestInfo kept a stale deadline", deadline.Sub(before))
+	}
+}
+
+func TestSendRoutingKeyUsesKeyChooser(t *testing.T) {
+	defer resetClient()
+
+	LoadBalancerFactory = consistenthash.New
+	sc := GetService("TestService", "", "", "")
+
+	instances := []skynet.ServiceInfo{*serviceInfo(), *serviceInfo(), *serviceInfo()}
+	for i := range instances {
+		instances[i].UUID = config.NewUUID()
+		sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: instances[i]})
+	}
+
+	counter := stubFailingPool()
+	var val string
+
+	waitFor(t, func() bool {
+		sc.SendOnce(nil, "Foo", val, &val)
+		return counter.count(instances[0].UUID) > 0 && counter.count(instances[1].UUID) > 0 && counter.count(instances[2].UUID) > 0
+	})
+
+	counter.reset()
+
+	for i := 0; i < 20; i++ {
+		sc.SendOnce(&skynet.RequestInfo{RoutingKey: "foo"}, "Foo", val, &val)
+	}
+
+	for _, i := range instances {
+		if n := counter.count(i.UUID); n != 0 && n != 20 {
+			t.Fatal("Requests with the same RoutingKey should be sent to the same instance", n)
+		}
+	}
+}
+
+func TestSendDetailedReportsAnsweringInstance(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+	sc.SetDefaultTimeout(time.Millisecond, time.Second)
+
+	bad, good := *serviceInfo(), *serviceInfo()
+	bad.UUID, good.UUID = config.NewUUID(), config.NewUUID()
+
+	stubFailingPool(bad.UUID)
+
+	var mutex sync.Mutex
+	chosen := 0
+
+	sc.(*ServiceClient).loadBalancer = &test.LoadBalancer{
+		ChooseFunc: func() (s skynet.ServiceInfo, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			chosen++
+			if chosen == 1 {
+				return bad, nil
+			}
+
+			return good, nil
+		},
+	}
+
+	var val string
+	result, err := sc.SendDetailed(nil, "Foo", val, &val)
+
+	if err != nil {
+		t.Fatal("SendDetailed() should succeed once retried", err)
+	}
+
+	if result.Instance.UUID != good.UUID {
+		t.Fatal("SendDetailed() should report the instance that answered")
+	}
+
+	// a further retry may have been sent before the good instance answered
+	if result.Attempts < 2 {
+		t.Fatal("SendDetailed() should report the number of attempts including retries", result.Attempts)
+	}
+
+	if result.Duration <= 0 {
+		t.Fatal("SendDetailed() should report how long the request took")
+	}
+}
+
+func TestSendTimeoutReportsFailedAttempts(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+
+	instances := make([]skynet.ServiceInfo, 3)
+	failures := make(map[string]error)
+
+	for i := range instances {
+		instances[i] = *serviceInfo()
+		instances[i].UUID = config.NewUUID()
+		instances[i].ServiceAddr.Port += i
+		failures[instances[i].UUID] = errors.New("Failure " + instances[i].AddrString())
+	}
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					return failures[s.UUID]
+				},
+			}, nil
+		},
+	})
+
+	var mutex sync.Mutex
+	chosen := 0
+
+	sc.(*ServiceClient).loadBalancer = &test.LoadBalancer{
+		ChooseFunc: func() (s skynet.ServiceInfo, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			chosen++
+			return instances[chosen%len(instances)], nil
+		},
+	}
+
+	var val string
+	err := sc.SendTimeout(5*time.Millisecond, 100*time.Millisecond, nil, "Foo", val, &val)
+
+	if !errors.Is(err, RequestTimeout) {
+		t.Fatal("Giving up should still be a RequestTimeout", err)
+	}
+
+	te, ok := err.(TimeoutError)
+	if !ok {
+		t.Fatal("Expected the failed attempts to be reported", err)
+	}
+
+	for _, s := range instances {
+		reported := false
+		for _, a := range te.Attempts {
+			if a.Instance.UUID == s.UUID && a.Err == failures[s.UUID] {
+				reported = true
+			}
+		}
+
+		if !reported || !errors.Is(err, failures[s.UUID]) || !strings.Contains(err.Error(), failures[s.UUID].Error()) {
+			t.Fatal("Each instance's failure should be reported", s.AddrString(), err)
+		}
+	}
+}
+
+func TestSendHedgesSlowInstance(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+	sc.SetDefaultTimeout(time.Second, 5*time.Second)
+
+	sClient := sc.(*ServiceClient)
+	sClient.hedgeDelay = 10 * time.Millisecond
+
+	// only the hedge should be sent
+	retryAfter = func(d time.Duration) <-chan time.Time {
+		return nil
+	}
+
+	slow, fast := *serviceInfo(), *serviceInfo()
+	slow.UUID, fast.UUID = config.NewUUID(), config.NewUUID()
+
+	var mutex sync.Mutex
+	chosen := 0
+
+	sClient.loadBalancer = &test.LoadBalancer{
+		ChooseFunc: func() (s skynet.ServiceInfo, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			chosen++
+			if chosen == 1 {
+				return slow, nil
+			}
+
+			return fast, nil
+		},
+	}
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					if s.UUID == slow.UUID {
+						time.Sleep(500 * time.Millisecond)
+						*out.(*string) = "slow"
+						return
+					}
+
+					*out.(*string) = "fast"
+					return
+				},
+			}, nil
+		},
+	})
+
+	start := time.Now()
+
+	var val string
+	result, err := sc.SendDetailed(nil, "Foo", val, &val)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if val != "fast" || result.Instance.UUID != fast.UUID {
+		t.Fatal("Hedged request should return the first response", val)
+	}
+
+	if time.Since(start) > 250*time.Millisecond {
+		t.Fatal("Hedged request should not wait for the slow instance")
+	}
+}
+
+func TestSendHedgeAbortsLosingAttempt(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+	sc.SetDefaultTimeout(time.Second, 5*time.Second)
+
+	sClient := sc.(*ServiceClient)
+	sClient.hedgeDelay = 10 * time.Millisecond
+
+	retryAfter = func(d time.Duration) <-chan time.Time {
+		return nil
+	}
+
+	slow, fast := *serviceInfo(), *serviceInfo()
+	slow.UUID, fast.UUID = config.NewUUID(), config.NewUUID()
+
+	var mutex sync.Mutex
+	chosen := 0
+
+	sClient.loadBalancer = &test.LoadBalancer{
+		ChooseFunc: func() (s skynet.ServiceInfo, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			chosen++
+			if chosen == 1 {
+				return slow, nil
+			}
+
+			return fast, nil
+		},
+	}
+
+	// the slow instance only answers once its connection is closed
+	aborted := make(chan bool)
+	var fastClosed int32
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			if s.UUID == fast.UUID {
+				return &test.Connection{
+					CloseFunc: func() {
+						atomic.StoreInt32(&fastClosed, 1)
+					},
+					SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+						*out.(*string) = "fast"
+						return
+					},
+				}, nil
+			}
+
+			var once sync.Once
+			return &test.Connection{
+				CloseFunc: func() {
+					once.Do(func() { close(aborted) })
+				},
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					<-aborted
+					return conn.TransportError{Err: io.EOF}
+				},
+			}, nil
+		},
+	})
+
+	var val string
+	if err := sc.Send(nil, "Foo", val, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatal("The attempt that lost to the hedge should be aborted")
+	}
+
+	if atomic.LoadInt32(&fastClosed) != 0 {
+		t.Fatal("The winning attempt's connection should be left open")
+	}
+}
+
+func TestSendLosingAttemptDoesNotLeak(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+	sc.SetDefaultTimeout(time.Second, 5*time.Second)
+
+	sClient := sc.(*ServiceClient)
+	sClient.hedgeDelay = 10 * time.Millisecond
+
+	retryAfter = func(d time.Duration) <-chan time.Time {
+		return nil
+	}
+
+	slow, fast := *serviceInfo(), *serviceInfo()
+	slow.UUID, fast.UUID = config.NewUUID(), config.NewUUID()
+
+	var mutex sync.Mutex
+	chosen := 0
+
+	sClient.loadBalancer = &test.LoadBalancer{
+		ChooseFunc: func() (s skynet.ServiceInfo, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			chosen++
+			if chosen == 1 {
+				return slow, nil
+			}
+
+			return fast, nil
+		},
+	}
+
+	// the slow instance ignores being aborted, answering successfully once the request has returned
+	answer := make(chan bool)
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			if s.UUID == fast.UUID {
+				return &test.Connection{
+					SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+						*out.(*string) = "fast"
+						return
+					},
+				}, nil
+			}
+
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					<-answer
+					*out.(*string) = "slow"
+					return
+				},
+			}, nil
+		},
+	})
+
+	before := runtime.NumGoroutine()
+
+	var val string
+	if err := sc.Send(nil, "Foo", val, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	if val != "fast" {
+		t.Fatal("Hedged request should return the first response", val)
+	}
+
+	close(answer)
+
+	waitFor(t, func() bool {
+		return runtime.NumGoroutine() <= before
+	})
+}
+
+func TestSendBatch(t *testing.T) {
+	defer resetClient()
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, nil)
+
+	var mutex sync.Mutex
+	acquired := 0
+	callErr := errors.New("Call failed")
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			mutex.Lock()
+			acquired++
+			mutex.Unlock()
+
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					if fn == "Fail" {
+						return callErr
+					}
+
+					*out.(*int) = in.(int) * 2
+					return
+				},
+			}, nil
+		},
+	})
+
+	results := make([]int, 4)
+	results[2] = -1
+
+	calls := []skynet.Call{
+		skynet.Call{Fn: "Double", In: 1, Out: &results[0]},
+		skynet.Call{Fn: "Double", In: 2, Out: &results[1]},
+		skynet.Call{Fn: "Fail", In: 3, Out: &results[2]},
+		skynet.Call{Fn: "Double", In: 4, Out: &results[3]},
+	}
+
+	errs := sc.SendBatch(nil, calls)
+
+	if len(errs) != len(calls) {
+		t.Fatal("SendBatch() should return an error for each call")
+	}
+
+	for i, expected := range []int{2, 4, -1, 8} {
+		if results[i] != expected {
+			t.Fatal("Batch result not copied to the correct call", i, results[i])
+		}
+	}
+
+	if errs[0] != nil || errs[1] != nil || errs[3] != nil || errs[2] != callErr {
+		t.Fatal("Batch errors not reported for individual calls", errs)
+	}
+
+	if acquired != 1 {
+		t.Fatal("Batch should be sent over a single connection", acquired)
+	}
+}
+
+func TestSendToTargetsInstance(t *testing.T) {
+	defer resetClient()
+
+	first, second := *serviceInfo(), *serviceInfo()
+	first.UUID, second.UUID = config.NewUUID(), config.NewUUID()
+	second.ServiceAddr.Port = first.ServiceAddr.Port + 1
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+	sc.(*ServiceClient).loadBalancer = roundrobin.New([]skynet.ServiceInfo{})
+
+	counter := stubFailingPool()
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: first})
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: second})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 2
+	})
+
+	var val string
+	for i := 0; i < 10; i++ {
+		if err := sc.SendTo(second.AddrString(), nil, "Foo", val, &val); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if counter.count(first.UUID) != 0 || counter.count(second.UUID) != 10 {
+		t.Fatal("SendTo() should only send requests to the instance at the address", counter.count(first.UUID), counter.count(second.UUID))
+	}
+
+	second.ServiceAddr.Port++
+	if err := sc.SendTo(second.AddrString(), nil, "Foo", val, &val); err != InstanceNotFound {
+		t.Fatal("SendTo() should return InstanceNotFound when no instance is at the address", err)
+	}
+}
+
+func TestSendAllReachesEveryInstance(t *testing.T) {
+	defer resetClient()
+
+	instances := []skynet.ServiceInfo{*serviceInfo(), *serviceInfo(), *serviceInfo()}
+	for i := range instances {
+		instances[i].UUID = config.NewUUID()
+		instances[i].ServiceAddr.Port = instances[0].ServiceAddr.Port + i
+	}
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+
+	counter := stubFailingPool(instances[1].UUID)
+
+	for _, s := range instances {
+		sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: s})
+	}
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 3
+	})
+
+	errs := sc.SendAll(nil, "Invalidate", "key")
+
+	if len(errs) != 3 {
+		t.Fatal("SendAll() should return a result for every instance", errs)
+	}
+
+	for i, s := range instances {
+		if counter.count(s.UUID) != 1 {
+			t.Fatal("SendAll() should send one request to each instance", i, counter.count(s.UUID))
+		}
+
+		if err, ok := errs[s.AddrString()]; !ok || (err != nil) != (i == 1) {
+			t.Fatal("SendAll() should report each instance's error by its address", s.AddrString(), errs)
+		}
+	}
+}
+
+func TestSendOnewayDoesNotWaitForResponse(t *testing.T) {
+	defer resetClient()
+
+	si := serviceInfo()
+	si.UUID = config.NewUUID()
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+
+	sent := make(chan string, 1)
+	released := make(chan bool, 1)
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					t.Error("SendOneway() shouldn't wait for a response")
+					return
+				},
+				SendOnewayFunc: func(ri *skynet.RequestInfo, fn string, in interface{}) (err error) {
+					sent <- fn
+					return
+				},
+			}, nil
+		},
+		ReleaseFunc: func(c conn.Connection) {
+			released <- true
+		},
+	})
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: *si})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 1
+	})
+
+	if err := sc.SendOneway(nil, "Notify", "event"); err != nil {
+		t.Fatal(err)
+	}
+
+	if fn := <-sent; fn != "Notify" {
+		t.Fatal("One way request was sent to the wrong method", fn)
+	}
+
+	select {
+	case <-released:
+	default:
+		t.Fatal("Connection should be released once the request is written")
+	}
+}
+
+func stubForSend(sc ServiceClientProvider, f func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)) {
+	sm := &test.ServiceManager{}
+	skynet.SetServiceManager(skynet.ServiceManager(sm))
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			c := &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					return f(ri, fn, in, out)
+				},
+			}
+
+			return c, nil
+		},
+	})
+
+	sClient := sc.(*ServiceClient)
+	sClient.loadBalancer = &test.LoadBalancer{
+		ChooseFunc: func() (s skynet.ServiceInfo, err error) {
+			return
+		},
+	}
+}
+
+func TestIDGeneratorMintsRequestID(t *testing.T) {
+	defer resetClient()
+
+	var mutex sync.Mutex
+	minted := 0
+
+	SetIDGenerator(func() string {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		minted++
+		return "request-" + strconv.Itoa(minted)
+	})
+
+	var received skynet.RequestInfo
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		// RequestInfo is sent to the service as bson
+		b, err := bson.Marshal(skynet.ServiceRPCInWrite{RequestInfo: ri})
+		if err != nil {
+			return err
+		}
+
+		var sin skynet.ServiceRPCInRead
+		if err = bson.Unmarshal(b, &sin); err != nil {
+			return err
+		}
+
+		received = *sin.RequestInfo
+		return
+	})
+
+	var val string
+	if err := sc.Send(nil, "Foo", val, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	if received.RequestID != "request-1" {
+		t.Fatal("The generated RequestID should be sent to the service", received.RequestID)
+	}
 }
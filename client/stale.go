@@ -0,0 +1,93 @@
+package client
+
+import (
+	"fmt"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/config"
+	"time"
+)
+
+// message sent to mux() to check for stale instances
+type staleCheck struct{}
+
+/*
+this should only be called by mux()
+Instances can linger after they've stopped serving should their removal be missed, so registered instances that
+haven't answered a request successfully within client.stale.threshold of being discovered or their last success are
+logged. With client.stale.probe set a connection is opened to them, its handshake recorded as a request to the instance
+*/
+func (c *ServiceClient) checkStale() {
+	now := c.staleNow()
+
+	for uuid, s := range c.instances {
+		served, ok := c.lastServed[uuid]
+		if !ok || !s.Registered || c.staleWarned[uuid] || now.Sub(served) < c.staleThreshold {
+			continue
+		}
+
+		logger.Warn(fmt.Sprintf("Instance %s of %s at %s hasn't served a request successfully in %s", uuid, s.Name, s.AddrString(), now.Sub(served)))
+		c.staleWarned[uuid] = true
+
+		if c.staleProbe {
+			go c.probeStale(s)
+		}
+	}
+
+	c.scheduleStaleCheck()
+}
+
+// this should only be called by mux() or before it starts, checks twice per threshold so instances are logged within 1.5 times it
+func (c *ServiceClient) scheduleStaleCheck() {
+	if c.staleThreshold <= 0 {
+		return
+	}
+
+	time.AfterFunc(c.staleThreshold/2, func() {
+		c.sendMux(staleCheck{})
+	})
+}
+
+// this should only be called by mux(), for a request that succeeded or an instance that was just discovered
+func (c *ServiceClient) recordServed(uuid string) {
+	c.lastServed[uuid] = c.staleNow()
+	delete(c.staleWarned, uuid)
+}
+
+// this should only be called by mux()
+func (c *ServiceClient) removeServed(uuid string) {
+	delete(c.lastServed, uuid)
+	delete(c.staleWarned, uuid)
+}
+
+// the handshake only succeeds if the instance is serving and registered
+func (c *ServiceClient) probeStale(s skynet.ServiceInfo) {
+	cn, err := conn.NewConnectionWithOptions(s.Name, GetNetwork(), s.AddrString(), getDialTimeout(s), connectionOptions(s))
+	if err == nil {
+		cn.Close()
+	} else {
+		logger.Warn(fmt.Sprintf("Probe of stale instance %s at %s failed: %v", s.UUID, s.AddrString(), err))
+	}
+
+	c.sendMux(instanceResult{service: s, err: err})
+}
+
+func getStaleThreshold(service, version string) time.Duration {
+	if d, err := config.String(service, version, "client.stale.threshold"); err == nil {
+		if threshold, err := time.ParseDuration(d); err == nil {
+			return threshold
+		}
+
+		logger.Error("Failed to parse client.stale.threshold", err)
+	}
+
+	return config.DefaultStaleThreshold
+}
+
+func getStaleProbe(service, version string) bool {
+	if b, err := config.Bool(service, version, "client.stale.probe"); err == nil {
+		return b
+	}
+
+	return config.DefaultStaleProbe
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStaleInstanceLogged(t *testing.T) {
+	defer resetClient()
+
+	var mutex sync.Mutex
+	now := time.Now()
+
+	rl := &recordingLogger{messages: make(map[string][]string)}
+	SetLogger(rl)
+
+	good, bad := *serviceInfo(), *serviceInfo()
+	good.UUID, bad.UUID = config.NewUUID(), config.NewUUID()
+	bad.ServiceAddr.Port = good.ServiceAddr.Port + 1
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+	sc.(*ServiceClient).staleThreshold = time.Minute
+	sc.(*ServiceClient).staleNow = func() time.Time {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return now
+	}
+
+	stubFailingPool(bad.UUID)
+
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: good})
+	sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: bad})
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 2
+	})
+
+	mutex.Lock()
+	now = now.Add(2 * time.Minute)
+	mutex.Unlock()
+
+	var val string
+	if err := sc.SendTo(good.AddrString(), nil, "Foo", val, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sc.SendTo(bad.AddrString(), nil, "Foo", val, &val); err == nil {
+		t.Fatal("Request to the failing instance should fail")
+	}
+
+	sc.(*ServiceClient).sendMux(staleCheck{})
+
+	// answered once the check has been made
+	sc.InstanceCount()
+
+	if !rl.logged("WARN", bad.UUID) {
+		t.Fatal("Registered instance that hasn't served within the threshold should be logged")
+	}
+
+	if rl.logged("WARN", good.UUID) {
+		t.Fatal("Instance that served within the threshold shouldn't be logged")
+	}
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/config"
+	"strings"
+)
+
+/*
+client.GetStaticService() returns a client sending requests to the instances of the service at addrs, rather than
+those found by the ServiceManager, so it can be used without one for development, tests and single node setups.
+Without addrs the instances are read from client.static.instances, ex: "127.0.0.1:9000, 127.0.0.1:9001"
+
+Static ServiceClients aren't kept up to date by the ServiceManager or closed by client.Close(), their connections are
+released once they're closed themselves
+*/
+func GetStaticService(name, version string, addrs ...string) (ServiceClientProvider, error) {
+	if len(addrs) == 0 {
+		addrs = getStaticInstances(name, version)
+	}
+
+	instances := make([]skynet.ServiceInfo, 0, len(addrs))
+
+	for _, addr := range addrs {
+		ba, err := skynet.BindAddrFromString(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		instances = append(instances, skynet.ServiceInfo{
+			Name:        name,
+			Version:     version,
+			UUID:        config.NewUUID(),
+			ServiceAddr: ba,
+			Registered:  true,
+		})
+	}
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: name, Version: version},
+	}}).(*ServiceClient)
+
+	sc.static = instances
+
+	for _, i := range instances {
+		getPool().AddInstance(i)
+		sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: i})
+	}
+
+	return sc, nil
+}
+
+func getStaticInstances(service, version string) (addrs []string) {
+	if s, err := config.String(service, version, "client.static.instances"); err == nil {
+		for _, addr := range strings.Split(s, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/rpc/bsonrpc"
+	"labix.org/v2/mgo/bson"
+	"net"
+	"net/rpc"
+	"testing"
+)
+
+func TestStaticServiceSendsWithoutServiceManager(t *testing.T) {
+	defer resetClient()
+
+	skynet.SetServiceManager(serviceManager)
+	serviceManager.WatchFunc = func(criteria skynet.CriteriaMatcher, c chan<- skynet.InstanceNotification) []skynet.ServiceInfo {
+		t.Error("Static ServiceClients shouldn't watch the ServiceManager")
+		return nil
+	}
+	defer func() { serviceManager.WatchFunc = nil }()
+
+	ln := listenForEcho(t, "TestService")
+	defer ln.Close()
+
+	sc, err := GetStaticService("TestService", "1.0.0", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+
+	var out echoParam
+	if err := sc.Send(nil, "Echo", echoParam{Val: "hello"}, &out); err != nil {
+		t.Fatal("Failed to send to static instance", err)
+	}
+
+	if out.Val != "hello" {
+		t.Fatal("Response from static instance wasn't decoded", out.Val)
+	}
+}
+
+func TestStaticServiceRejectsMalformedAddress(t *testing.T) {
+	defer resetClient()
+
+	if _, err := GetStaticService("TestService", "1.0.0", "127.0.0.1"); err == nil {
+		t.Fatal("Addresses without a port should be rejected")
+	}
+}
+
+type echoParam struct {
+	Val string
+}
+
+// answers every request with its own input
+type echoService struct{}
+
+func (echoService) Forward(in skynet.ServiceRPCInRead, out *skynet.ServiceRPCOutWrite) error {
+	out.Out = bson.Binary{0x00, in.In}
+	return nil
+}
+
+// Accepts connections as the named service, serving requests with echoService
+func listenForEcho(t *testing.T, name string) net.Listener {
+	return listenForService(t, name, echoService{})
+}
+
+// Accepts connections as the named service, serving requests with rcvr's Forward method
+func listenForService(t *testing.T, name string, rcvr interface{}) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to bind to port for test", err)
+	}
+
+	s := rpc.NewServer()
+	s.RegisterName(name, rcvr)
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			err = bsonrpc.NewEncoder(c).Encode(skynet.ServiceHandshake{Name: name, Registered: true, ClientID: "abc"})
+
+			var ch skynet.ClientHandshake
+			if err == nil && bsonrpc.NewDecoder(c).Decode(&ch) == nil {
+				go s.ServeCodec(bsonrpc.NewServerCodec(c))
+			}
+		}
+	}()
+
+	return ln
+}
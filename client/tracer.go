@@ -0,0 +1,52 @@
+package client
+
+/*
+client.SpanInfo identifies a span of a trace, ServiceClients start a span for each request and a child of it
+for each attempt (including retries and hedges) sent to an instance
+*/
+type SpanInfo struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+
+	Service string
+	Method  string
+
+	// 0 for the request's span, otherwise the number of the attempt
+	Attempt int
+}
+
+/*
+client.Span is finished with the error the request or attempt returned
+*/
+type Span interface {
+	Finish(err error)
+}
+
+/*
+client.Tracer starts spans as requests and their attempts are sent. The TraceID, and the SpanID of the attempt
+as the ParentSpanID, are sent to the service in RequestInfo.
+Implementations must be safe to call from multiple goroutines.
+*/
+type Tracer interface {
+	StartSpan(s SpanInfo) Span
+}
+
+type noopTracer struct{}
+
+func (noopTracer) StartSpan(s SpanInfo) Span { return noopTracer{} }
+
+func (noopTracer) Finish(err error) {}
+
+var tracer Tracer = noopTracer{}
+
+/*
+client.SetTracer() provide a Tracer to start spans with, by default they are discarded
+*/
+func SetTracer(t Tracer) {
+	if t == nil {
+		t = noopTracer{}
+	}
+
+	tracer = t
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"errors"
+	"github.com/skynetservices/skynet"
+	"labix.org/v2/mgo/bson"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingTracer struct {
+	mutex    sync.Mutex
+	started  []SpanInfo
+	finished map[string]error
+}
+
+type recordingSpan struct {
+	tracer *recordingTracer
+	id     string
+}
+
+func (rt *recordingTracer) StartSpan(s SpanInfo) Span {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	rt.started = append(rt.started, s)
+
+	return recordingSpan{tracer: rt, id: s.SpanID}
+}
+
+func (s recordingSpan) Finish(err error) {
+	s.tracer.mutex.Lock()
+	defer s.tracer.mutex.Unlock()
+
+	s.tracer.finished[s.id] = err
+}
+
+func TestTracerRecordsRequestAndAttemptSpans(t *testing.T) {
+	defer resetClient()
+
+	rt := &recordingTracer{finished: make(map[string]error)}
+	SetTracer(rt)
+
+	var mutex sync.Mutex
+	var received []skynet.RequestInfo
+	failed := errors.New("Failed")
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		// RequestInfo is sent to the service as bson
+		b, err := bson.Marshal(skynet.ServiceRPCInWrite{RequestInfo: ri})
+		if err != nil {
+			return err
+		}
+
+		var sin skynet.ServiceRPCInRead
+		if err = bson.Unmarshal(b, &sin); err != nil {
+			return err
+		}
+
+		received = append(received, *sin.RequestInfo)
+		if len(received) == 1 {
+			return failed
+		}
+
+		return
+	})
+
+	sClient := sc.(*ServiceClient)
+	sClient.retryMultiplier = 1
+
+	var val string
+	if err := sc.SendTimeout(time.Second, 5*time.Second, &skynet.RequestInfo{TraceID: "trace", ParentSpanID: "caller"}, "Foo", val, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	rt.mutex.Lock()
+	started := rt.started
+	finished, ok := rt.finished[started[0].SpanID]
+	rt.mutex.Unlock()
+
+	if len(started) != 3 {
+		t.Fatal("Expected a span for the request and each attempt", started)
+	}
+
+	request := started[0]
+	if request.Attempt != 0 || request.TraceID != "trace" || request.ParentSpanID != "caller" || request.Method != "Foo" {
+		t.Fatal("Request span should continue the caller's trace", request)
+	}
+
+	if !ok || finished != nil {
+		t.Fatal("Request span should be finished with the request's error", finished)
+	}
+
+	for i, attempt := range started[1:] {
+		if attempt.Attempt != i+1 || attempt.TraceID != "trace" || attempt.ParentSpanID != request.SpanID {
+			t.Fatal("Attempt spans should be children of the request's span", attempt)
+		}
+
+		if received[i].TraceID != "trace" || received[i].ParentSpanID != attempt.SpanID {
+			t.Fatal("Each attempt should carry the trace and its span to the service", received[i])
+		}
+	}
+
+	// attempts finish their spans once they've delivered their result
+	waitFor(t, func() bool {
+		rt.mutex.Lock()
+		defer rt.mutex.Unlock()
+
+		return rt.finished[started[1].SpanID] == failed
+	})
+}
+
+func TestSendPopulatesTraceID(t *testing.T) {
+	defer resetClient()
+
+	var traceID string
+
+	sc := GetService("foo", "1.0.0", "", "")
+	stubForSend(sc, func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+		traceID = ri.TraceID
+		return
+	})
+
+	var val string
+	if err := sc.Send(nil, "Foo", val, &val); err != nil {
+		t.Fatal(err)
+	}
+
+	if traceID == "" {
+		t.Fatal("Requests without a TraceID should be given one")
+	}
+}
@@ -0,0 +1,49 @@
+package client
+
+/*
+client.ResponseValidator checks the response to a request to fn once it's been decoded into out, returning an error
+for responses that are well formed but failed, ex: a status field set to "error". The attempt then fails with the error
+wrapped in an InvalidResponseError and is retried like any other failed attempt, so soft errors fail over to another
+instance. Implementations must be safe to call from multiple goroutines
+*/
+type ResponseValidator func(fn string, out interface{}) error
+
+var responseValidator ResponseValidator
+
+/*
+client.SetResponseValidator() checks the responses to requests sent by Send and its variants that retry with v, nil
+accepts every response the service returns without an error. SendTo, SendAll and SendOneway responses aren't checked
+*/
+func SetResponseValidator(v ResponseValidator) {
+	responseValidator = v
+}
+
+/*
+client.InvalidResponseError is returned when the ResponseValidator rejects the response to a request to Fn, Err is the
+error it returned. It doesn't count towards the instance's circuit breaker, the instance handled the request
+*/
+type InvalidResponseError struct {
+	Fn  string
+	Err error
+}
+
+func (ie InvalidResponseError) Error() string {
+	return "Invalid response to " + ie.Fn + ": " + ie.Err.Error()
+}
+
+// InvalidResponseError.Unwrap() returns Err, so errors.Is() matches it
+func (ie InvalidResponseError) Unwrap() error {
+	return ie.Err
+}
+
+func validateResponse(v ResponseValidator, fn string, out interface{}) error {
+	if v == nil {
+		return nil
+	}
+
+	if err := v(fn, out); err != nil {
+		return InvalidResponseError{Fn: fn, Err: err}
+	}
+
+	return nil
+}
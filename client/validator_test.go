@@ -0,0 +1,105 @@
+package client
+
+import (
+	"errors"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/config"
+	"github.com/skynetservices/skynet/test"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRejectedResponseRetriedOnAnotherInstance(t *testing.T) {
+	defer resetClient()
+
+	a, b := *serviceInfo(), *serviceInfo()
+	a.UUID, b.UUID = config.NewUUID(), config.NewUUID()
+	b.ServiceAddr.Port = a.ServiceAddr.Port + 1
+
+	sc := NewServiceClient(&skynet.Criteria{Services: []skynet.ServiceCriteria{
+		skynet.ServiceCriteria{Name: "TestService"},
+	}})
+	sc.SetDefaultTimeout(time.Second, 2*time.Second)
+	sc.(*ServiceClient).retryMax = 5 * time.Millisecond
+	sc.(*ServiceClient).retryMultiplier = 2
+
+	// the instance sent the first attempt responds with a soft error, the other succeeds
+	var mutex sync.Mutex
+	var failing string
+	sentTo := []string{}
+
+	setPool(&test.Pool{
+		AcquireFunc: func(s skynet.ServiceInfo) (conn.Connection, error) {
+			return &test.Connection{
+				SendTimeoutFunc: func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error) {
+					mutex.Lock()
+					defer mutex.Unlock()
+
+					if failing == "" {
+						failing = s.UUID
+					}
+
+					sentTo = append(sentTo, s.UUID)
+
+					if s.UUID == failing {
+						*out.(*string) = "error"
+					} else {
+						*out.(*string) = "ok"
+					}
+
+					return
+				},
+			}, nil
+		},
+	})
+
+	rejected := errors.New("Response has an error status")
+	SetResponseValidator(func(fn string, out interface{}) error {
+		if *out.(*string) == "error" {
+			return rejected
+		}
+
+		return nil
+	})
+
+	for _, s := range []skynet.ServiceInfo{a, b} {
+		sc.Notify(skynet.InstanceNotification{Type: skynet.InstanceAdded, Service: s})
+	}
+
+	waitFor(t, func() bool {
+		return sc.InstanceCount() == 2
+	})
+
+	var val string
+	if err := sc.Send(nil, "Foo", val, &val); err != nil {
+		t.Fatal("Request should succeed once it's retried on another instance", err)
+	}
+
+	if val != "ok" {
+		t.Fatal("Rejected response should not be copied to out", val)
+	}
+
+	mutex.Lock()
+	if len(sentTo) != 2 || sentTo[1] == failing {
+		t.Fatal("Request with a rejected response should be retried on another instance", sentTo)
+	}
+	mutex.Unlock()
+
+	// once every response is rejected the request fails with the validator's error
+	SetResponseValidator(func(fn string, out interface{}) error {
+		return rejected
+	})
+
+	sc.SetDefaultTimeout(10*time.Millisecond, 50*time.Millisecond)
+
+	err := sc.Send(nil, "Foo", val, &val)
+	if !errors.Is(err, rejected) {
+		t.Fatal("Request should fail with the ResponseValidator's error", err)
+	}
+
+	if sc.InstanceCount() != 2 {
+		t.Fatal("Rejected responses should not count towards the instances' breakers", sc.InstanceCount())
+	}
+}
@@ -105,6 +105,12 @@ func Int(service, version, option string) (int, error) {
 	return conf.Int(s, option)
 }
 
+func Float(service, version, option string) (float64, error) {
+	s := getSection(service, version)
+
+	return conf.Float(s, option)
+}
+
 func RawString(service, version, option string) (string, error) {
 	s := getSection(service, version)
 
@@ -10,12 +10,110 @@ import (
 const (
 	// DefaultRetryDuration is how long a client.ServiceClient waits before sending a new request.
 	DefaultRetryDuration = 2 * time.Second
+	// DefaultRetryMaxDuration is the longest a client.ServiceClient will wait between retries as they back off.
+	DefaultRetryMaxDuration = 8 * time.Second
+	// DefaultRetryMultiplier is the factor the retry interval grows by after each retry, 1 retries at a fixed interval.
+	DefaultRetryMultiplier = 1
+	// DefaultRetryBudgetRatio is the share of a retry each successful request earns a client.ServiceClient, 0 leaves retries unlimited.
+	DefaultRetryBudgetRatio = 0
+	// DefaultMinRetriesPerSec is the rate retries are allowed at regardless of DefaultRetryBudgetRatio.
+	DefaultMinRetriesPerSec = 10
+	// DefaultMaxAttempts is the number of attempts, including retries and hedges, a client.ServiceClient sends for a request, 0 is unlimited.
+	DefaultMaxAttempts = 0
+	// DefaultRetrySkipCodes are the comma separated codes of errors returned by services that aren't retried.
+	DefaultRetrySkipCodes = ""
+	// DefaultNonIdempotentMethods are the comma separated methods that are only retried if their attempt never reached an instance.
+	DefaultNonIdempotentMethods = ""
+	// DefaultSameConnRetries is how many times an attempt the service fails is retried on the same connection before another attempt is sent.
+	DefaultSameConnRetries = 0
+	// DefaultFailFastWhenEmpty fails requests while no instances of their service are known, rather than retrying until one is discovered.
+	DefaultFailFastWhenEmpty = false
+	// DefaultMetadata is the metadata sent with every request, ex: "tenant=acme, locale=en".
+	DefaultMetadata = ""
+	// DefaultMaxMetadataBytes is the largest a request's metadata may be, counting its keys and values, 0 is unlimited.
+	DefaultMaxMetadataBytes = 4096
+	// DefaultTLSHandshakeTimeout is how long a service with a TLSConfig waits for a client to complete the TLS handshake, 0 waits indefinitely.
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+	// DefaultHealthCheckTimeout is how long client.ServiceClient.HealthCheck() waits for each instance's handshake, 0 waits for ctx.
+	DefaultHealthCheckTimeout = 1 * time.Second
+	// DefaultAttemptExclusionTTL is how long an instance working on an attempt of a request is passed over by its retries and hedges, 0 doesn't.
+	DefaultAttemptExclusionTTL = 5 * time.Second
+	// DefaultHedgeDuration is how long a client.ServiceClient waits before racing a request against another instance, 0 disables hedging.
+	DefaultHedgeDuration = 0
+	// DefaultLowPriorityMaxAttempts is the number of attempts a client.ServiceClient sends for a low priority request, 0 is unlimited.
+	DefaultLowPriorityMaxAttempts = 1
+	// DefaultLowPriorityHedgeDuration is how long a client.ServiceClient waits before hedging a low priority request, 0 disables hedging.
+	DefaultLowPriorityHedgeDuration = 0
 	// DefaultTimeoutDuration is how long a client.ServiceClient will wait before giving up.
 	DefaultTimeoutDuration = 10 * time.Second
+	// DefaultHandshakeTimeout is how long a client.ServiceClient waits for a new connection's handshake, 0 waits indefinitely.
+	DefaultHandshakeTimeout = 5 * time.Second
 	// DefaultIdleConnectionsToInstance is the number of connections to a particular instance that may sit idle.
 	DefaultIdleConnectionsToInstance = 2
+	// DefaultMinConnectionsToInstance is the number of connections to a particular instance that are kept open however long they sit idle.
+	DefaultMinConnectionsToInstance = 0
 	// DefaultMaxConnectionsToInstance is the maximum number of concurrent connections to a particular instance.
 	DefaultMaxConnectionsToInstance = 20
+	// DefaultWarmConnections is the number of connections opened to an instance as soon as it's discovered, limited by the idle connections.
+	DefaultWarmConnections = 0
+	// DefaultMaxLifetime is how long a connection is kept before it's closed and replaced, 0 keeps connections indefinitely.
+	DefaultMaxLifetime = 0
+	// DefaultStaleTimeout is how long a connection can sit idle before it's checked before reuse, 0 disables the check.
+	DefaultStaleTimeout = 0
+	// DefaultKeepAliveInterval is how often idle connections are checked with a request to the ping method, 0 disables the check.
+	DefaultKeepAliveInterval = 0
+	// DefaultPingMethod is the method called to check stale connections, if empty stale connections are replaced.
+	DefaultPingMethod = ""
+	// DefaultMultiplexRequests is the number of concurrent requests to an instance that share a connection, 0 gives each request its own.
+	DefaultMultiplexRequests = 0
+	// DefaultDialTimeout is how long connecting to an instance may take before it fails, and another instance is tried.
+	DefaultDialTimeout = 500 * time.Millisecond
+	// DefaultAcquireTimeout is how long a request waits for a connection to an instance that has its maximum number open, 0 waits indefinitely.
+	DefaultAcquireTimeout = 0
+	// DefaultFlushThreshold is the number of consecutive requests to an instance that fail as their connection broke before its idle connections are closed, 0 disables it.
+	DefaultFlushThreshold = 3
+	// DefaultConnectBackoff is how long connections to an instance are refused after connecting to it fails, doubling with each failure, 0 disables it.
+	DefaultConnectBackoff = 0
+	// DefaultConnectBackoffMax is the longest connections to an instance are refused after connecting to it fails.
+	DefaultConnectBackoffMax = 30 * time.Second
+	// DefaultMaxResponseBytes is the largest response a client accepts from an instance, larger responses are refused without being read, 0 is unlimited.
+	DefaultMaxResponseBytes = 0
+	// DefaultFailureThreshold is the number of consecutive failures before a client.ServiceClient stops sending requests to an instance.
+	DefaultFailureThreshold = 5
+	// DefaultCooldownDuration is how long a client.ServiceClient waits before sending requests to an instance that hit the DefaultFailureThreshold.
+	DefaultCooldownDuration = 30 * time.Second
+	// DefaultStaleThreshold is how long a registered instance can go without answering a request successfully before it's logged as stale, 0 disables it.
+	DefaultStaleThreshold = 0
+	// DefaultStaleProbe opens a connection to instances logged as stale, recording whether it succeeds as a request to them.
+	DefaultStaleProbe = false
+	// DefaultStartupJitter is the most the client's first watch of the ServiceManager is delayed by, spreading out clients started together. 0 watches immediately.
+	DefaultStartupJitter = 0
+	// DefaultReconcileInterval is how often the client re-syncs the instances it knows of with the ServiceManager, 0 disables it.
+	DefaultReconcileInterval = 1 * time.Minute
+	// DefaultMaxHeldNotifications is the number of instance notifications held while discovery is paused before it resumes, 0 is unlimited.
+	DefaultMaxHeldNotifications = 1000
+	// DefaultMaxConcurrentRequests is the number of attempts a client.ServiceClient may have in flight at once, 0 is unlimited.
+	DefaultMaxConcurrentRequests = 0
+	// DefaultConcurrencyWait queues requests once DefaultMaxConcurrentRequests is reached, rather than failing them.
+	DefaultConcurrencyWait = true
+	// DefaultVersionFallback chooses instances of any version when none satisfy client.version.constraint.
+	DefaultVersionFallback = false
+	// DefaultLabelFallback sends requests whose LabelSelector no instance matches to any instance rather than failing them.
+	DefaultLabelFallback = false
+	// DefaultCanaryLabels are the labels of the instances requests chosen by their ExperimentKey are sent to, ex: "canary=true".
+	DefaultCanaryLabels = ""
+	// DefaultCanaryPercent is the percentage of requests with an ExperimentKey sent to the instances with DefaultCanaryLabels.
+	DefaultCanaryPercent = 0
+	// DefaultMaxInFlightPerInstance is the number of requests in flight to an instance before others are chosen, 0 is unlimited.
+	DefaultMaxInFlightPerInstance = 0
+	// DefaultInstanceRate is the number of requests a second sent to an instance, 0 is unlimited.
+	DefaultInstanceRate = 0
+	// DefaultInstanceBurst is the number of requests that can be sent to an instance at once while it's under DefaultInstanceRate.
+	DefaultInstanceBurst = 1
+	// DefaultInstanceRateWait is how long a request over DefaultInstanceRate waits for its turn before trying another instance.
+	DefaultInstanceRateWait = 10 * time.Millisecond
+	// DefaultAffinityTTL is how long an AffinityKey stays with its instance after its last request, 0 keeps it until the instance is removed.
+	DefaultAffinityTTL = 10 * time.Minute
 )
 
 // skynet
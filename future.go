@@ -0,0 +1,48 @@
+package skynet
+
+import (
+	"context"
+)
+
+// Future is the result of a request sent in the background by a client's SendFuture().
+type Future struct {
+	done   chan struct{}
+	err    error
+	cancel context.CancelFunc
+}
+
+// NewFuture runs send in the background, the context it's given is cancelled by Future.Cancel().
+func NewFuture(send func(ctx context.Context) error) *Future {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	f := &Future{
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+
+	go func() {
+		defer cancel()
+
+		f.err = send(ctx)
+		close(f.done)
+	}()
+
+	return f
+}
+
+// Done returns a channel that's closed once the request completes.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Err waits for the request to complete and returns its error.
+func (f *Future) Err() error {
+	<-f.done
+
+	return f.err
+}
+
+// Cancel aborts the request if it hasn't completed, Err() will return context.Canceled.
+func (f *Future) Cancel() {
+	f.cancel()
+}
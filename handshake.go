@@ -1,5 +1,9 @@
 package skynet
 
+// ProtocolVersion is sent in both handshakes, it's incremented when the protocol changes in a way the other side
+// needs to know about. Fields that either side doesn't know are ignored, so new ones can be added without it
+const ProtocolVersion = 1
+
 // ServiceHandshake is data sent by the service to the client immediately once the connection
 // is opened.
 type ServiceHandshake struct {
@@ -12,9 +16,62 @@ type ServiceHandshake struct {
 
 	// ClientID is a UUID that is used by the client to identify itself in RPC requests.
 	ClientID string
+
+	// TLSRequired indicates the service only accepts connections over TLS, clients that
+	// aren't using TLS should close the connection.
+	TLSRequired bool
+
+	// Codecs lists the codecs the service accepts requests in, if empty only bson is supported.
+	Codecs []string
+
+	// Compressors lists the compressors the service can decompress requests with, if empty
+	// requests must not be compressed.
+	Compressors []string
+
+	// Streams indicates the service accepts streaming requests, on connections the client
+	// opens for them.
+	Streams bool
+
+	// ProtocolVersion the service speaks, 0 for services predating it.
+	ProtocolVersion int
+
+	// Version of the service.
+	Version string
+
+	// Methods lists the methods the service accepts requests for.
+	Methods []string
+
+	// MaxRequestBytes is the largest request payload the service accepts, 0 if it's unlimited.
+	MaxRequestBytes int
+
+	// Metadata is free-form information about the service.
+	Metadata map[string]string
 }
 
 // ClientHandshake is sent by the client to the service after receipt of the ServiceHandshake.
 type ClientHandshake struct {
 	ClientID string
+
+	// Codec the client will encode requests with, one of those offered by the service. Empty
+	// means bson.
+	Codec string
+
+	// Compression is the compressor the client will use for request and response payloads,
+	// one of those offered by the service. Empty means payloads aren't compressed.
+	Compression string
+
+	// Stream indicates the connection carries a single streaming request rather than RPCs. It's
+	// sent as a bson ServiceRPCIn, and answered with bson StreamChunks until one is Done.
+	// Payloads are never compressed.
+	Stream bool
+
+	// ProtocolVersion the client speaks, 0 for clients predating it.
+	ProtocolVersion int
+
+	// ClientName and ClientVersion identify the client to the service, ex: for logging or authorization.
+	ClientName    string
+	ClientVersion string
+
+	// Metadata is free-form information about the client.
+	Metadata map[string]string
 }
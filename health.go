@@ -0,0 +1,16 @@
+package skynet
+
+import (
+	"time"
+)
+
+// InstanceHealth is the result of probing an instance with a fresh connection, outside of the client's connection pool.
+type InstanceHealth struct {
+	Instance ServiceInfo
+	// Healthy is true if the connection was made and the instance's handshake accepted it.
+	Healthy bool
+	// Latency is how long the connection and handshake took.
+	Latency time.Duration
+	// Err is why the probe failed, it's nil for healthy instances.
+	Err error
+}
@@ -0,0 +1,17 @@
+package skynet
+
+import (
+	"github.com/skynetservices/skynet/pools"
+)
+
+// InstanceInfo is a snapshot of an instance as a client sees it, for admin and debugging tools.
+type InstanceInfo struct {
+	ServiceInfo
+
+	// Available is true if requests can currently be sent to the instance, it's registered and hasn't been evicted or
+	// excluded after repeated failures.
+	Available bool
+
+	// Pool is the state of the client's connection pool to the instance.
+	Pool pools.Stats
+}
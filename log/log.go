@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"log/syslog"
 	"strconv"
+	"sync/atomic"
 )
 
 type LogLevel int8
@@ -16,7 +17,8 @@ type LogLevel int8
 var syslogHost string
 var syslogPort int = 0
 
-var minLevel LogLevel
+// minLevel is changed at runtime while other goroutines are logging, it's only accessed atomically
+var minLevel int32
 var logger *syslog.Writer
 
 const (
@@ -60,78 +62,78 @@ func Panicf(format string, messages ...interface{}) {
 }
 
 func Fatal(messages ...interface{}) {
-	if minLevel <= FATAL {
+	if GetLogLevel() <= FATAL {
 		logger.Crit(fromMulti(messages))
 	}
 }
 
 func Fatalf(format string, messages ...interface{}) {
-	if minLevel <= FATAL {
+	if GetLogLevel() <= FATAL {
 		m := fmt.Sprintf(format, messages...)
 		logger.Crit(m)
 	}
 }
 
 func Error(messages ...interface{}) {
-	if minLevel <= ERROR {
+	if GetLogLevel() <= ERROR {
 		logger.Err(fromMulti(messages))
 	}
 }
 
 func Errorf(format string, messages ...interface{}) {
-	if minLevel <= ERROR {
+	if GetLogLevel() <= ERROR {
 		m := fmt.Sprintf(format, messages...)
 		logger.Err(m)
 	}
 }
 
 func Warn(messages ...interface{}) {
-	if minLevel <= WARN {
+	if GetLogLevel() <= WARN {
 		logger.Warning(fromMulti(messages))
 	}
 }
 
 func Warnf(format string, messages ...interface{}) {
-	if minLevel <= WARN {
+	if GetLogLevel() <= WARN {
 		m := fmt.Sprintf(format, messages...)
 		logger.Warning(m)
 	}
 }
 
 func Info(messages ...interface{}) {
-	if minLevel <= INFO {
+	if GetLogLevel() <= INFO {
 		logger.Info(fromMulti(messages))
 	}
 }
 
 func Infof(format string, messages ...interface{}) {
-	if minLevel <= INFO {
+	if GetLogLevel() <= INFO {
 		m := fmt.Sprintf(format, messages...)
 		logger.Info(m)
 	}
 }
 
 func Debug(messages ...interface{}) {
-	if minLevel <= DEBUG {
+	if GetLogLevel() <= DEBUG {
 		logger.Debug(fromMulti(messages))
 	}
 }
 
 func Debugf(format string, messages ...interface{}) {
-	if minLevel <= DEBUG {
+	if GetLogLevel() <= DEBUG {
 		m := fmt.Sprintf(format, messages...)
 		logger.Debug(m)
 	}
 }
 
 func Trace(messages ...interface{}) {
-	if minLevel <= TRACE {
+	if GetLogLevel() <= TRACE {
 		logger.Debug(fromMulti(messages))
 	}
 }
 
 func Tracef(format string, messages ...interface{}) {
-	if minLevel <= TRACE {
+	if GetLogLevel() <= TRACE {
 		m := fmt.Sprintf(format, messages...)
 		logger.Debug(m)
 	}
@@ -190,11 +192,11 @@ func SetSyslogPort(port int) {
 }
 
 func SetLogLevel(level LogLevel) {
-	minLevel = level
+	atomic.StoreInt32(&minLevel, int32(level))
 }
 
 func GetLogLevel() LogLevel {
-	return minLevel
+	return LogLevel(atomic.LoadInt32(&minLevel))
 }
 
 func fromMulti(messages ...interface{}) string{
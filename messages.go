@@ -40,9 +40,31 @@ type ServiceRPCInWrite struct {
 type ServiceRPCOutRead struct {
 	Out       []byte
 	ErrString string
+	ErrCode   string
 }
 
 type ServiceRPCOutWrite struct {
 	Out       bson.Binary
 	ErrString string
+	ErrCode   string
+}
+
+// ErrorCoder may be implemented by errors returned from service methods to tell clients what kind of error it is,
+// ex: "NotFound". The code is sent as ErrCode alongside ErrString.
+type ErrorCoder interface {
+	ErrorCode() string
+}
+
+// StreamChunkRead is one of the documents a service sends in response to a streaming request, the last has Done set,
+// or ErrString if the stream failed, and carries no Out.
+type StreamChunkRead struct {
+	Out       []byte
+	ErrString string
+	Done      bool
+}
+
+type StreamChunkWrite struct {
+	Out       bson.Binary
+	ErrString string
+	Done      bool
 }
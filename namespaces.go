@@ -0,0 +1,246 @@
+package skynet
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// how long a namespace whose watch was closed waits before watching it again
+var namespaceRewatchDelay = time.Second
+
+/*
+NamespacedServiceManager discovers instances across several ServiceManagers, one for each namespace, merging their
+instances and tagging each with the namespace it was found in, so a client can use instances of a service from more
+than one namespace, ex: skynet.SetServiceManager(skynet.NewNamespacedServiceManager("prod", namespaces))
+Instances added or registered through it are kept in its home namespace
+*/
+type NamespacedServiceManager struct {
+	home       string
+	namespaces []string
+	managers   map[string]ServiceManager
+
+	// closed by Shutdown() so closed watches aren't watched again
+	shutdown     chan bool
+	shutdownOnce sync.Once
+}
+
+/*
+skynet.NewNamespacedServiceManager() returns a NamespacedServiceManager across managers, keyed by namespace, home must
+be one of them
+*/
+func NewNamespacedServiceManager(home string, managers map[string]ServiceManager) *NamespacedServiceManager {
+	sm := &NamespacedServiceManager{
+		home:     home,
+		managers: managers,
+		shutdown: make(chan bool),
+	}
+
+	for ns := range managers {
+		sm.namespaces = append(sm.namespaces, ns)
+	}
+
+	sort.Strings(sm.namespaces)
+
+	return sm
+}
+
+func (sm *NamespacedServiceManager) Add(s ServiceInfo) error {
+	return sm.managers[sm.home].Add(s)
+}
+
+func (sm *NamespacedServiceManager) Update(s ServiceInfo) error {
+	return sm.managers[sm.home].Update(s)
+}
+
+func (sm *NamespacedServiceManager) Remove(s ServiceInfo) error {
+	return sm.managers[sm.home].Remove(s)
+}
+
+func (sm *NamespacedServiceManager) Register(uuid string) error {
+	return sm.managers[sm.home].Register(uuid)
+}
+
+func (sm *NamespacedServiceManager) Unregister(uuid string) error {
+	return sm.managers[sm.home].Unregister(uuid)
+}
+
+// NamespacedServiceManager.Shutdown() shuts down every namespace's ServiceManager, returning the first error
+func (sm *NamespacedServiceManager) Shutdown() (err error) {
+	sm.shutdownOnce.Do(func() { close(sm.shutdown) })
+
+	for _, ns := range sm.namespaces {
+		if e := sm.managers[ns].Shutdown(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return
+}
+
+func (sm *NamespacedServiceManager) ListHosts(c CriteriaMatcher) ([]string, error) {
+	return sm.list(func(m ServiceManager) ([]string, error) { return m.ListHosts(c) })
+}
+
+func (sm *NamespacedServiceManager) ListRegions(c CriteriaMatcher) ([]string, error) {
+	return sm.list(func(m ServiceManager) ([]string, error) { return m.ListRegions(c) })
+}
+
+func (sm *NamespacedServiceManager) ListServices(c CriteriaMatcher) ([]string, error) {
+	return sm.list(func(m ServiceManager) ([]string, error) { return m.ListServices(c) })
+}
+
+func (sm *NamespacedServiceManager) ListVersions(c CriteriaMatcher) ([]string, error) {
+	return sm.list(func(m ServiceManager) ([]string, error) { return m.ListVersions(c) })
+}
+
+func (sm *NamespacedServiceManager) ListInstances(c CriteriaMatcher) ([]ServiceInfo, error) {
+	instances := []ServiceInfo{}
+
+	for _, ns := range sm.namespaces {
+		found, err := sm.managers[ns].ListInstances(c)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range found {
+			s.Namespace = ns
+			instances = append(instances, s)
+		}
+	}
+
+	return instances, nil
+}
+
+/*
+NamespacedServiceManager.Watch() watches every namespace, notifications are sent to c tagged with their namespace.
+Revisions of different ServiceManagers can't be compared so they're sent without one.
+Should a namespace close its watch only that namespace is watched again, the others keep sending to c, and what
+changed in it meanwhile is sent as notifications. c is closed once the NamespacedServiceManager is shut down
+*/
+func (sm *NamespacedServiceManager) Watch(criteria CriteriaMatcher, c chan<- InstanceNotification) (instances []ServiceInfo) {
+	w := &namespacedWatch{sm: sm, criteria: criteria, c: c, rewatchDelay: namespaceRewatchDelay}
+	instances = []ServiceInfo{}
+
+	for _, ns := range sm.namespaces {
+		nc := make(chan InstanceNotification, 100)
+		known := make(map[string]ServiceInfo)
+
+		for _, s := range sm.managers[ns].Watch(criteria, nc) {
+			s.Namespace = ns
+			known[s.UUID] = s
+			instances = append(instances, s)
+		}
+
+		go w.forward(ns, nc, known)
+	}
+
+	return
+}
+
+func (sm *NamespacedServiceManager) list(list func(m ServiceManager) ([]string, error)) ([]string, error) {
+	seen := make(map[string]bool)
+	values := []string{}
+
+	for _, ns := range sm.namespaces {
+		found, err := list(sm.managers[ns])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range found {
+			if !seen[v] {
+				seen[v] = true
+				values = append(values, v)
+			}
+		}
+	}
+
+	return values, nil
+}
+
+type namespacedWatch struct {
+	sync.Mutex
+	sm           *NamespacedServiceManager
+	criteria     CriteriaMatcher
+	rewatchDelay time.Duration
+	c            chan<- InstanceNotification
+	closed       bool
+}
+
+/*
+forwards ns's notifications to c, keeping track of its instances so that when its watch is closed and it's watched
+again the instances that changed meanwhile can be sent. Keeps receiving after c is closed so the namespace's
+ServiceManager isn't blocked sending to a watch that's gone
+*/
+func (w *namespacedWatch) forward(ns string, nc <-chan InstanceNotification, known map[string]ServiceInfo) {
+	for {
+		for n := range nc {
+			n.Service.Namespace = ns
+			n.Revision = 0
+
+			if n.Type == InstanceRemoved {
+				delete(known, n.Service.UUID)
+			} else {
+				known[n.Service.UUID] = n.Service
+			}
+
+			w.send(n)
+		}
+
+		select {
+		case <-w.sm.shutdown:
+			w.close()
+			return
+		case <-time.After(w.rewatchDelay):
+		}
+
+		nc = w.rewatch(ns, known)
+	}
+}
+
+// watches ns again, sending the instances added, updated and removed since its last watch was closed
+func (w *namespacedWatch) rewatch(ns string, known map[string]ServiceInfo) <-chan InstanceNotification {
+	nc := make(chan InstanceNotification, 100)
+	current := make(map[string]ServiceInfo)
+
+	for _, s := range w.sm.managers[ns].Watch(w.criteria, nc) {
+		s.Namespace = ns
+		current[s.UUID] = s
+
+		if _, ok := known[s.UUID]; ok {
+			w.send(InstanceNotification{Type: InstanceUpdated, Service: s})
+		} else {
+			w.send(InstanceNotification{Type: InstanceAdded, Service: s})
+		}
+
+		known[s.UUID] = s
+	}
+
+	for uuid, s := range known {
+		if _, ok := current[uuid]; !ok {
+			delete(known, uuid)
+			w.send(InstanceNotification{Type: InstanceRemoved, Service: s, Reason: RemovedMissing})
+		}
+	}
+
+	return nc
+}
+
+// sent while holding the mutex so c isn't closed under a send
+func (w *namespacedWatch) send(n InstanceNotification) {
+	w.Lock()
+	if !w.closed {
+		w.c <- n
+	}
+	w.Unlock()
+}
+
+func (w *namespacedWatch) close() {
+	w.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.c)
+	}
+	w.Unlock()
+}
@@ -0,0 +1,105 @@
+package skynet
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// watchManager is a ServiceManager that only supports watching, its watches are closed by closeWatches()
+type watchManager struct {
+	ServiceManager
+	sync.Mutex
+	instances []ServiceInfo
+	watches   []chan<- InstanceNotification
+}
+
+func (m *watchManager) Watch(criteria CriteriaMatcher, c chan<- InstanceNotification) []ServiceInfo {
+	m.Lock()
+	defer m.Unlock()
+
+	m.watches = append(m.watches, c)
+
+	return append([]ServiceInfo{}, m.instances...)
+}
+
+func (m *watchManager) Shutdown() error {
+	m.closeWatches()
+	return nil
+}
+
+func (m *watchManager) closeWatches() {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, c := range m.watches {
+		close(c)
+	}
+
+	m.watches = nil
+}
+
+func (m *watchManager) watchCount() int {
+	m.Lock()
+	defer m.Unlock()
+
+	return len(m.watches)
+}
+
+func TestClosedNamespaceWatchedAlone(t *testing.T) {
+	defer func(d time.Duration) { namespaceRewatchDelay = d }(namespaceRewatchDelay)
+	namespaceRewatchDelay = 10 * time.Millisecond
+
+	east := &watchManager{instances: []ServiceInfo{ServiceInfo{UUID: "east"}}}
+	west := &watchManager{instances: []ServiceInfo{ServiceInfo{UUID: "gone"}}}
+
+	sm := NewNamespacedServiceManager("east", map[string]ServiceManager{"east": east, "west": west})
+
+	c := make(chan InstanceNotification, 10)
+	if instances := sm.Watch(&Criteria{}, c); len(instances) != 2 {
+		t.Fatal("Expected the instances of both namespaces, got", len(instances))
+	}
+
+	// west changes while its watch is closed
+	west.Lock()
+	west.instances = []ServiceInfo{ServiceInfo{UUID: "added"}}
+	west.Unlock()
+	west.closeWatches()
+
+	got := map[string]InstanceNotification{}
+	for len(got) < 2 {
+		select {
+		case n, ok := <-c:
+			if !ok {
+				t.Fatal("Closing one namespace's watch shouldn't close the watch")
+			}
+
+			got[n.Service.UUID] = n
+		case <-time.After(time.Second):
+			t.Fatal("Expected west's changes after it was watched again, got", got)
+		}
+	}
+
+	if n := got["added"]; n.Type != InstanceAdded || n.Service.Namespace != "west" {
+		t.Error("Expected the instance added to west while it wasn't watched to be added", n)
+	}
+
+	if n := got["gone"]; n.Type != InstanceRemoved || n.Reason != RemovedMissing {
+		t.Error("Expected the instance removed from west while it wasn't watched to be removed", n)
+	}
+
+	if east.watchCount() != 1 || west.watchCount() != 1 {
+		t.Error("Only the closed namespace should be watched again", east.watchCount(), west.watchCount())
+	}
+
+	sm.Shutdown()
+
+	select {
+	case _, ok := <-c:
+		if ok {
+			t.Fatal("Expected no more notifications")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The watch should be closed when the NamespacedServiceManager shuts down")
+	}
+}
@@ -2,6 +2,8 @@ package pools
 
 import (
 	"errors"
+	"sync/atomic"
+	"time"
 )
 
 type Resource interface {
@@ -9,22 +11,90 @@ type Resource interface {
 	IsClosed() bool
 }
 
+/*
+HealthChecker may be implemented by a Resource to have it validated before it's handed out by Acquire(),
+unhealthy resources are closed and replaced
+*/
+type HealthChecker interface {
+	IsHealthy() bool
+}
+
+/*
+KeepAliver may be implemented by a Resource to have it checked periodically while it sits idle, see SetKeepAlive().
+Resources that fail the check are closed and replaced
+*/
+type KeepAliver interface {
+	KeepAlive(timeout time.Duration) bool
+}
+
 type Factory func() (Resource, error)
 
+// PoolExhausted is returned by AcquireTimeout() when no resource became available before the timeout
+var PoolExhausted = errors.New("Timed out waiting for a resource, the pool is exhausted")
+
+/*
+Stats is a snapshot of a ResourcePool
+*/
+type Stats struct {
+	// Resources currently open, whether idle or in use
+	Resources int
+	Idle      int
+	InUse     int
+
+	// Waiting is the number of acquires queued for a resource, while the pool is at its maximum
+	Waiting int
+
+	// Acquired is the total number of resources handed out, Failed the number of times a resource couldn't be created
+	Acquired uint64
+	Failed   uint64
+}
+
 type ResourcePool struct {
 	factory       Factory
 	idleResources ring
 	idleCapacity  int
 	maxResources  int
 	numResources  int
+	inUse         int32
+
+	// only accessed from mux()
+	idleTimeout  time.Duration
+	maxLifetime  time.Duration
+	minResources int
+	resources    map[Resource]*resourceInfo
+	sweeper      *time.Ticker
+	keepAlive    time.Duration
+	keepAliver   *time.Ticker
+	acquired     uint64
+	failed       uint64
+
+	acqchan  chan acquireMessage
+	cachan   chan acquireMessage
+	rchan    chan releaseMessage
+	cchan    chan closeMessage
+	expchan  chan expirationMessage
+	minchan  chan int
+	statchan chan chan Stats
+	kachan   chan time.Duration
+	keptchan chan Resource
+	fchan    chan struct{}
+	rnchan   chan struct{}
 
-	acqchan chan acquireMessage
-	rchan   chan releaseMessage
-	cchan   chan closeMessage
+	// closed once mux() exits, so resources being kept alive aren't returned to it
+	done chan struct{}
 
+	// acquires waiting on a resource, oldest first, so each resource released goes to whoever has waited longest
 	activeWaits []acquireMessage
 }
 
+type resourceInfo struct {
+	created  time.Time
+	lastUsed time.Time
+
+	// the pool was renewed while the resource was in use, it's closed rather than reused
+	retired bool
+}
+
 func NewSourcelessPool() (rp *ResourcePool) {
 	return NewResourcePool(func() (Resource, error) { return nil, nil }, -1, 0)
 }
@@ -35,9 +105,20 @@ func NewResourcePool(factory Factory, idleCapacity, maxResources int) (rp *Resou
 		idleCapacity: idleCapacity,
 		maxResources: maxResources,
 
-		acqchan: make(chan acquireMessage),
-		rchan:   make(chan releaseMessage, 1),
-		cchan:   make(chan closeMessage, 1),
+		resources: make(map[Resource]*resourceInfo),
+
+		acqchan:  make(chan acquireMessage),
+		cachan:   make(chan acquireMessage),
+		rchan:    make(chan releaseMessage, 1),
+		cchan:    make(chan closeMessage, 1),
+		expchan:  make(chan expirationMessage),
+		minchan:  make(chan int),
+		statchan: make(chan chan Stats),
+		kachan:   make(chan time.Duration),
+		keptchan: make(chan Resource),
+		fchan:    make(chan struct{}),
+		rnchan:   make(chan struct{}),
+		done:     make(chan struct{}),
 	}
 
 	go rp.mux()
@@ -52,43 +133,98 @@ type releaseMessage struct {
 type acquireMessage struct {
 	rch chan Resource
 	ech chan error
+
+	// an unhealthy resource the acquirer was handed, it's discarded and replaced without the acquirer losing its place
+	replaced Resource
 }
 
 type closeMessage struct {
 }
 
+type expirationMessage struct {
+	idleTimeout time.Duration
+	maxLifetime time.Duration
+}
+
 func (rp *ResourcePool) mux() {
+	var sweep, keepAlive <-chan time.Time
+
 loop:
 	for {
 		select {
 		case acq := <-rp.acqchan:
 			rp.acquire(acq)
+		case acq := <-rp.cachan:
+			rp.cancelAcquire(acq)
 		case rel := <-rp.rchan:
 			if len(rp.activeWaits) != 0 {
 				// someone is waiting - give them the resource if we can
-				if !rel.r.IsClosed() {
+				if rel.r != nil && !rel.r.IsClosed() && !rp.lifetimeExpired(rel.r) {
+					rp.used(rel.r)
+					rp.acquired++
 					rp.activeWaits[0].rch <- rel.r
 				} else {
 					// if we can't, discard the released resource and create a new one
+					rp.discard(rel.r)
+
 					r, err := rp.factory()
 					if err != nil {
 						// reflect the smaller number of existant resources
 						rp.numResources--
+						atomic.AddInt32(&rp.inUse, -1)
+						rp.failed++
 						rp.activeWaits[0].ech <- err
 					} else {
+						rp.track(r)
+						rp.acquired++
 						rp.activeWaits[0].rch <- r
 					}
 				}
 				rp.activeWaits = rp.activeWaits[1:]
 			} else {
 				// if no one is waiting, release it for idling or closing
+				atomic.AddInt32(&rp.inUse, -1)
 				rp.release(rel.r)
 			}
 
+		case exp := <-rp.expchan:
+			sweep = rp.setExpiration(exp)
+
+		case min := <-rp.minchan:
+			rp.minResources = min
+
+		case sch := <-rp.statchan:
+			sch <- rp.stats()
+
+		case <-sweep:
+			rp.sweep()
+
+		case interval := <-rp.kachan:
+			keepAlive = rp.setKeepAlive(interval)
+
+		case <-keepAlive:
+			rp.checkIdle()
+
+		case r := <-rp.keptchan:
+			rp.kept(r)
+
+		case <-rp.fchan:
+			rp.flush()
+
+		case <-rp.rnchan:
+			rp.renew()
+
 		case _ = <-rp.cchan:
 			break loop
 		}
 	}
+	if rp.sweeper != nil {
+		rp.sweeper.Stop()
+	}
+	if rp.keepAliver != nil {
+		rp.keepAliver.Stop()
+	}
+	close(rp.done)
 	for !rp.idleResources.Empty() {
 		rp.idleResources.Dequeue().Close()
 	}
@@ -98,58 +234,331 @@ loop:
 }
 
 func (rp *ResourcePool) acquire(acq acquireMessage) {
+	if acq.replaced != nil {
+		atomic.AddInt32(&rp.inUse, -1)
+		rp.discard(acq.replaced)
+		rp.numResources--
+	}
+
 	for !rp.idleResources.Empty() {
 		r := rp.idleResources.Dequeue()
-		if !r.IsClosed() {
+		if !r.IsClosed() && !rp.expired(r) {
+			atomic.AddInt32(&rp.inUse, 1)
+			rp.acquired++
 			acq.rch <- r
 			return
 		}
-		// discard closed resources
+		// discard closed and expired resources
+		rp.discard(r)
 		rp.numResources--
 	}
 	if rp.maxResources != -1 && rp.numResources >= rp.maxResources {
-		// we need to wait until something comes back in
-		rp.activeWaits = append(rp.activeWaits, acq)
+		// we need to wait until something comes back in, an acquire replacing a resource was already first in line
+		if acq.replaced != nil {
+			rp.activeWaits = append([]acquireMessage{acq}, rp.activeWaits...)
+		} else {
+			rp.activeWaits = append(rp.activeWaits, acq)
+		}
 		return
 	}
 
 	r, err := rp.factory()
 	if err != nil {
+		rp.failed++
 		acq.ech <- err
 	} else {
 		rp.numResources++
+		atomic.AddInt32(&rp.inUse, 1)
+		rp.track(r)
+		rp.acquired++
 		acq.rch <- r
 	}
 
 	return
 }
 
+// only call from mux(), stops waiting on a resource for an acquire that timed out
+func (rp *ResourcePool) cancelAcquire(acq acquireMessage) {
+	for i, aw := range rp.activeWaits {
+		if aw.rch == acq.rch {
+			rp.activeWaits = append(rp.activeWaits[:i], rp.activeWaits[i+1:]...)
+			return
+		}
+	}
+}
+
 func (rp *ResourcePool) release(resource Resource) {
-	if resource == nil || resource.IsClosed() {
+	if resource == nil || resource.IsClosed() || rp.lifetimeExpired(resource) {
 		// don't put it back in the pool.
+		rp.discard(resource)
 		rp.numResources--
 		return
 	}
 	if rp.idleCapacity != -1 && rp.idleResources.Size() == rp.idleCapacity {
-		resource.Close()
+		rp.discard(resource)
 		rp.numResources--
 		return
 	}
 
+	rp.used(resource)
 	rp.idleResources.Enqueue(resource)
 }
 
+// only call from mux()
+func (rp *ResourcePool) stats() Stats {
+	return Stats{
+		Resources: rp.numResources,
+		Idle:      rp.idleResources.Size(),
+		InUse:     rp.InUse(),
+		Waiting:   len(rp.activeWaits),
+		Acquired:  rp.acquired,
+		Failed:    rp.failed,
+	}
+}
+
+// only call from mux()
+func (rp *ResourcePool) setExpiration(exp expirationMessage) <-chan time.Time {
+	rp.idleTimeout, rp.maxLifetime = exp.idleTimeout, exp.maxLifetime
+
+	if rp.sweeper != nil {
+		rp.sweeper.Stop()
+		rp.sweeper = nil
+	}
+
+	interval := rp.idleTimeout
+	if interval == 0 || (rp.maxLifetime > 0 && rp.maxLifetime < interval) {
+		interval = rp.maxLifetime
+	}
+
+	if interval <= 0 {
+		return nil
+	}
+
+	// sweep often enough that resources don't live much past their expiration
+	rp.sweeper = time.NewTicker(interval / 2)
+
+	return rp.sweeper.C
+}
+
+// only call from mux(), closes idle resources that have expired
+func (rp *ResourcePool) sweep() {
+	for n := rp.idleResources.Size(); n > 0; n-- {
+		r := rp.idleResources.Dequeue()
+
+		if r.IsClosed() || rp.expired(r) {
+			rp.discard(r)
+			rp.numResources--
+			continue
+		}
+
+		rp.idleResources.Enqueue(r)
+	}
+}
+
+// only call from mux(), closes every idle resource
+func (rp *ResourcePool) flush() {
+	for !rp.idleResources.Empty() {
+		rp.discard(rp.idleResources.Dequeue())
+		rp.numResources--
+	}
+}
+
+// only call from mux()
+func (rp *ResourcePool) setKeepAlive(interval time.Duration) <-chan time.Time {
+	rp.keepAlive = interval
+
+	if rp.keepAliver != nil {
+		rp.keepAliver.Stop()
+		rp.keepAliver = nil
+	}
+
+	if interval <= 0 {
+		return nil
+	}
+
+	rp.keepAliver = time.NewTicker(interval)
+
+	return rp.keepAliver.C
+}
+
+// only call from mux(), idle resources that are KeepAlivers are taken out of the idle queue while they're checked,
+// checks may be slow so they're done outside of mux() and returned through keptchan
+func (rp *ResourcePool) checkIdle() {
+	for n := rp.idleResources.Size(); n > 0; n-- {
+		r := rp.idleResources.Dequeue()
+
+		if r.IsClosed() || rp.expired(r) {
+			rp.discard(r)
+			rp.numResources--
+			continue
+		}
+
+		ka, ok := r.(KeepAliver)
+		if !ok {
+			rp.idleResources.Enqueue(r)
+			continue
+		}
+
+		go func(r Resource, ka KeepAliver, timeout time.Duration) {
+			if !ka.KeepAlive(timeout) {
+				r.Close()
+			}
+
+			select {
+			case rp.keptchan <- r:
+			case <-rp.done:
+				r.Close()
+			}
+		}(r, ka, rp.keepAlive)
+	}
+}
+
+// only call from mux(), returns a checked resource to the idle queue, or to someone waiting on it. Being kept
+// alive doesn't count as use, so it will still expire once it's been idle for the idle timeout
+func (rp *ResourcePool) kept(r Resource) {
+	if r.IsClosed() || rp.lifetimeExpired(r) || (rp.idleCapacity != -1 && rp.idleResources.Size() == rp.idleCapacity) {
+		rp.discard(r)
+		rp.numResources--
+
+		// there's room for a new resource now
+		if len(rp.activeWaits) != 0 {
+			acq := rp.activeWaits[0]
+			rp.activeWaits = rp.activeWaits[1:]
+			rp.acquire(acq)
+		}
+
+		return
+	}
+
+	if len(rp.activeWaits) != 0 {
+		atomic.AddInt32(&rp.inUse, 1)
+		rp.used(r)
+		rp.acquired++
+		rp.activeWaits[0].rch <- r
+		rp.activeWaits = rp.activeWaits[1:]
+		return
+	}
+
+	rp.idleResources.Enqueue(r)
+}
+
+// only call from mux(), closes every idle resource and retires those in use so they're closed once released
+func (rp *ResourcePool) renew() {
+	rp.flush()
+
+	for _, i := range rp.resources {
+		i.retired = true
+	}
+}
+
+// only call from mux()
+func (rp *ResourcePool) track(r Resource) {
+	if r == nil {
+		return
+	}
+
+	now := time.Now()
+	rp.resources[r] = &resourceInfo{created: now, lastUsed: now}
+}
+
+// only call from mux()
+func (rp *ResourcePool) used(r Resource) {
+	if i, ok := rp.resources[r]; ok {
+		i.lastUsed = time.Now()
+	}
+}
+
+// only call from mux(), closes the resource and stops tracking it
+func (rp *ResourcePool) discard(r Resource) {
+	if r == nil {
+		return
+	}
+
+	if !r.IsClosed() {
+		r.Close()
+	}
+
+	delete(rp.resources, r)
+}
+
+// only call from mux(), retired resources are treated as having reached their max lifetime
+func (rp *ResourcePool) lifetimeExpired(r Resource) bool {
+	i, ok := rp.resources[r]
+
+	return ok && (i.retired || (rp.maxLifetime > 0 && time.Since(i.created) > rp.maxLifetime))
+}
+
+// only call from mux(), resources aren't closed for being idle once only minResources remain
+func (rp *ResourcePool) expired(r Resource) bool {
+	i, ok := rp.resources[r]
+	if !ok {
+		return false
+	}
+
+	if rp.idleTimeout > 0 && time.Since(i.lastUsed) > rp.idleTimeout && rp.numResources > rp.minResources {
+		return true
+	}
+
+	return rp.lifetimeExpired(r)
+}
+
 // Acquire() will get one of the idle resources, or create a new one.
+// Resources that implement HealthChecker and report being unhealthy are discarded and replaced.
+// While the pool is at its maximum, acquires wait their turn and released resources go to the one that's waited longest.
 func (rp *ResourcePool) Acquire() (resource Resource, err error) {
+	return rp.AcquireTimeout(0)
+}
+
+// AcquireTimeout() acts like Acquire() but returns PoolExhausted if the pool is at its maximum number of resources
+// and none is released within timeout. 0 waits indefinitely.
+func (rp *ResourcePool) AcquireTimeout(timeout time.Duration) (resource Resource, err error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+
+		expired = t.C
+	}
+
+	var replaced Resource
+
+	for {
+		resource, err = rp.acquireResource(expired, replaced)
+
+		if err != nil {
+			return
+		}
+
+		// health checks may be slow, so we do them here rather than blocking the mux
+		if hc, ok := resource.(HealthChecker); ok && !hc.IsHealthy() {
+			resource.Close()
+			replaced = resource
+			continue
+		}
+
+		return
+	}
+}
+
+func (rp *ResourcePool) acquireResource(expired <-chan time.Time, replaced Resource) (resource Resource, err error) {
 	acq := acquireMessage{
-		rch: make(chan Resource),
-		ech: make(chan error),
+		rch:      make(chan Resource),
+		ech:      make(chan error),
+		replaced: replaced,
 	}
 	rp.acqchan <- acq
 
 	select {
 	case resource = <-acq.rch:
 	case err = <-acq.ech:
+	case <-expired:
+		// mux() may be handing us a resource as we give up, in which case we take it
+		select {
+		case rp.cachan <- acq:
+			err = PoolExhausted
+		case resource = <-acq.rch:
+		case err = <-acq.ech:
+		}
 	}
 
 	return
@@ -164,12 +573,58 @@ func (rp *ResourcePool) Release(resource Resource) {
 	rp.rchan <- rel
 }
 
+// SetExpiration() closes resources that have been idle longer than idleTimeout, or that
+// were created more than maxLifetime ago. Resources in use are closed once released. 0 disables either check.
+func (rp *ResourcePool) SetExpiration(idleTimeout, maxLifetime time.Duration) {
+	rp.expchan <- expirationMessage{
+		idleTimeout: idleTimeout,
+		maxLifetime: maxLifetime,
+	}
+}
+
+// SetMinResources() keeps at least min resources open, rather than closing them once they've been idle
+// for the idle timeout. Resources are still closed once they reach their max lifetime.
+func (rp *ResourcePool) SetMinResources(min int) {
+	rp.minchan <- min
+}
+
+// SetKeepAlive() checks idle resources that implement KeepAliver every interval, closing those that fail the check.
+// The check is given interval to complete. 0 disables it.
+func (rp *ResourcePool) SetKeepAlive(interval time.Duration) {
+	rp.kachan <- interval
+}
+
+// Flush() closes the idle resources, so that they're replaced by new ones as they're acquired. Resources in use
+// aren't affected.
+func (rp *ResourcePool) Flush() {
+	rp.fchan <- struct{}{}
+}
+
+// Renew() closes the idle resources, and those in use once they're released, so that only resources created after
+// it's called are handed out, ex: once they've been set up with settings that have since changed.
+func (rp *ResourcePool) Renew() {
+	rp.rnchan <- struct{}{}
+}
+
 // Close() closes all the pools resources.
 func (rp *ResourcePool) Close() {
 	rp.cchan <- closeMessage{}
 }
 
+// Stats() returns a consistent snapshot of the pool, it must not be called once the pool is closed
+func (rp *ResourcePool) Stats() Stats {
+	sch := make(chan Stats)
+	rp.statchan <- sch
+
+	return <-sch
+}
+
 // NumResources() the number of resources known at this time
 func (rp *ResourcePool) NumResources() int {
 	return rp.numResources
 }
+
+// InUse() the number of resources currently acquired and not yet released
+func (rp *ResourcePool) InUse() int {
+	return int(atomic.LoadInt32(&rp.inUse))
+}
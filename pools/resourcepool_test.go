@@ -0,0 +1,406 @@
+package pools
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// resources are closed and checked from the pool's mux, so their state is kept atomically
+type testResource struct {
+	closed    int32
+	unhealthy int32
+	dead      int32
+}
+
+func (r *testResource) Close() {
+	atomic.StoreInt32(&r.closed, 1)
+}
+
+func (r *testResource) IsClosed() bool {
+	return atomic.LoadInt32(&r.closed) == 1
+}
+
+func (r *testResource) IsHealthy() bool {
+	return atomic.LoadInt32(&r.unhealthy) == 0
+}
+
+func (r *testResource) KeepAlive(timeout time.Duration) bool {
+	return atomic.LoadInt32(&r.dead) == 0
+}
+
+func newTestResource() (Resource, error) {
+	return &testResource{}, nil
+}
+
+func TestInUse(t *testing.T) {
+	rp := NewResourcePool(newTestResource, 2, 5)
+	defer rp.Close()
+
+	r1, _ := rp.Acquire()
+	r2, _ := rp.Acquire()
+
+	if rp.InUse() != 2 {
+		t.Fatal("InUse() should report acquired resources", rp.InUse())
+	}
+
+	rp.Release(r1)
+	rp.Release(r2)
+
+	// Releases are processed asynchronously
+	waitFor(t, func() bool { return rp.InUse() == 0 })
+}
+
+func TestAcquireReplacesUnhealthyResource(t *testing.T) {
+	rp := NewResourcePool(newTestResource, 2, 1)
+	defer rp.Close()
+
+	r1, _ := rp.Acquire()
+	rp.Release(r1)
+
+	atomic.StoreInt32(&r1.(*testResource).unhealthy, 1)
+
+	r2, err := rp.Acquire()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r2 == r1 {
+		t.Fatal("Acquire() should not return an unhealthy resource")
+	}
+
+	if !r1.IsClosed() {
+		t.Fatal("Unhealthy resource should be closed")
+	}
+
+	// the unhealthy resource should not count against maxResources
+	waitFor(t, func() bool { return rp.Stats().Resources == 1 })
+}
+
+func TestIdleResourcesEvicted(t *testing.T) {
+	rp := NewResourcePool(newTestResource, 2, 5)
+	defer rp.Close()
+
+	rp.SetExpiration(20*time.Millisecond, 0)
+
+	r, _ := rp.Acquire()
+	rp.Release(r)
+
+	waitFor(t, func() bool { return r.IsClosed() })
+	waitFor(t, func() bool { return rp.Stats().Resources == 0 })
+}
+
+func TestIdleResourcesEvictedDownToMin(t *testing.T) {
+	rp := NewResourcePool(newTestResource, 5, 5)
+	defer rp.Close()
+
+	rp.SetMinResources(2)
+	rp.SetExpiration(10*time.Millisecond, 0)
+
+	var acquired []Resource
+	for i := 0; i < 4; i++ {
+		r, _ := rp.Acquire()
+		acquired = append(acquired, r)
+	}
+
+	for _, r := range acquired {
+		rp.Release(r)
+	}
+
+	waitFor(t, func() bool { return rp.Stats().Resources == 2 })
+
+	// the remaining resources stay open however long they're idle
+	time.Sleep(30 * time.Millisecond)
+
+	if s := rp.Stats(); s.Resources != 2 || s.Idle != 2 {
+		t.Fatal("Idle resources should not be evicted below the minimum", s)
+	}
+
+	r, _ := rp.Acquire()
+	if r.IsClosed() {
+		t.Fatal("Resources kept by the minimum should be handed out")
+	}
+
+	rp.Release(r)
+}
+
+func TestPoolGrowsUnderConcurrentAcquires(t *testing.T) {
+	rp := NewResourcePool(newTestResource, 2, 5)
+	defer rp.Close()
+
+	acquired := make(chan Resource, 5)
+	for i := 0; i < 5; i++ {
+		go func() {
+			r, _ := rp.Acquire()
+			acquired <- r
+		}()
+	}
+
+	seen := make(map[Resource]bool)
+	for i := 0; i < 5; i++ {
+		seen[<-acquired] = true
+	}
+
+	if s := rp.Stats(); len(seen) != 5 || s.Resources != 5 || s.InUse != 5 {
+		t.Fatal("Resources should be created on demand up to the max", len(seen), s)
+	}
+
+	for r := range seen {
+		rp.Release(r)
+	}
+
+	// resources beyond the idle capacity are closed as they're released
+	waitFor(t, func() bool { return rp.Stats().Resources == 2 })
+}
+
+func TestAcquiredResourcesNotEvictedForIdle(t *testing.T) {
+	rp := NewResourcePool(newTestResource, 2, 5)
+	defer rp.Close()
+
+	rp.SetExpiration(10*time.Millisecond, 0)
+
+	r, _ := rp.Acquire()
+	time.Sleep(30 * time.Millisecond)
+
+	if r.IsClosed() {
+		t.Fatal("Resources in use should not be evicted")
+	}
+
+	rp.Release(r)
+}
+
+func TestExpiredResourcesClosedOnRelease(t *testing.T) {
+	rp := NewResourcePool(newTestResource, 2, 5)
+	defer rp.Close()
+
+	rp.SetExpiration(0, 20*time.Millisecond)
+
+	r, _ := rp.Acquire()
+	time.Sleep(30 * time.Millisecond)
+	rp.Release(r)
+
+	waitFor(t, func() bool { return r.IsClosed() })
+
+	r2, _ := rp.Acquire()
+
+	if r2 == r || r2.IsClosed() {
+		t.Fatal("Acquire() should create a new resource to replace an expired one")
+	}
+}
+
+func TestIdleResourcesEvictedAfterMaxLifetime(t *testing.T) {
+	rp := NewResourcePool(newTestResource, 2, 5)
+	defer rp.Close()
+
+	rp.SetExpiration(time.Hour, 20*time.Millisecond)
+
+	r, _ := rp.Acquire()
+	rp.Release(r)
+
+	waitFor(t, func() bool { return r.IsClosed() })
+}
+
+func TestKeepAliveClosesDeadIdleResources(t *testing.T) {
+	rp := NewResourcePool(newTestResource, 2, 5)
+	defer rp.Close()
+
+	alive, _ := rp.Acquire()
+	dead, _ := rp.Acquire()
+	rp.Release(alive)
+	rp.Release(dead)
+
+	atomic.StoreInt32(&dead.(*testResource).dead, 1)
+
+	rp.SetKeepAlive(10 * time.Millisecond)
+
+	waitFor(t, func() bool { return dead.IsClosed() })
+	waitFor(t, func() bool { return rp.Stats().Resources == 1 })
+
+	// stop checking so alive isn't taken from the idle queue as it's acquired
+	rp.SetKeepAlive(0)
+	waitFor(t, func() bool { return rp.Stats().Idle == 1 })
+
+	if alive.IsClosed() {
+		t.Fatal("Resources passing the keepalive should not be closed")
+	}
+
+	r, _ := rp.Acquire()
+	if r != alive {
+		t.Fatal("Resources passing the keepalive should be returned to the idle queue")
+	}
+
+	rp.Release(r)
+}
+
+func TestFlushClosesIdleResources(t *testing.T) {
+	rp := NewResourcePool(newTestResource, 2, 5)
+	defer rp.Close()
+
+	idle, _ := rp.Acquire()
+	inUse, _ := rp.Acquire()
+	rp.Release(idle)
+
+	waitFor(t, func() bool { return rp.InUse() == 1 })
+	rp.Flush()
+
+	waitFor(t, func() bool { return idle.IsClosed() })
+
+	if inUse.IsClosed() {
+		t.Fatal("Flush() should not close resources in use")
+	}
+
+	if rp.Stats().Resources != 1 {
+		t.Fatal("Flushed resources should no longer be counted", rp.Stats().Resources)
+	}
+
+	rp.Release(inUse)
+}
+
+func TestRenewClosesResourcesInUseOnceReleased(t *testing.T) {
+	rp := NewResourcePool(newTestResource, 2, 5)
+	defer rp.Close()
+
+	idle, _ := rp.Acquire()
+	inUse, _ := rp.Acquire()
+	rp.Release(idle)
+
+	waitFor(t, func() bool { return rp.InUse() == 1 })
+	rp.Renew()
+
+	waitFor(t, func() bool { return idle.IsClosed() })
+
+	if inUse.IsClosed() {
+		t.Fatal("Renew() should not close resources in use")
+	}
+
+	rp.Release(inUse)
+
+	waitFor(t, func() bool { return inUse.IsClosed() })
+
+	if r, _ := rp.Acquire(); r == inUse || r == idle {
+		t.Fatal("Resources created before Renew() should not be handed out again")
+	}
+}
+
+func TestAcquireTimesOutWhenExhausted(t *testing.T) {
+	rp := NewResourcePool(newTestResource, 1, 1)
+	defer rp.Close()
+
+	busy, _ := rp.Acquire()
+
+	done := make(chan error)
+	go func() {
+		_, err := rp.AcquireTimeout(20 * time.Millisecond)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != PoolExhausted {
+			t.Fatal("AcquireTimeout() should return PoolExhausted while the pool is exhausted", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("AcquireTimeout() should not wait beyond its timeout")
+	}
+
+	rp.Release(busy)
+
+	// the timed out acquire must not be handed the released resource
+	r, err := rp.AcquireTimeout(20 * time.Millisecond)
+	if err != nil || r != busy {
+		t.Fatal("Released resources should be acquired once the timed out acquire has given up", err)
+	}
+
+	rp.Release(r)
+}
+
+func TestWaitersGrantedInArrivalOrder(t *testing.T) {
+	rp := NewResourcePool(newTestResource, 1, 1)
+	defer rp.Close()
+
+	busy, _ := rp.Acquire()
+
+	// each acquire is queued before the next is made, so the order they arrive in is known
+	waiters := 5
+	granted := make(chan int, waiters)
+
+	for i := 0; i < waiters; i++ {
+		go func(i int) {
+			r, err := rp.Acquire()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			granted <- i
+			rp.Release(r)
+		}(i)
+
+		waitFor(t, func() bool { return rp.Stats().Waiting == i+1 })
+	}
+
+	rp.Release(busy)
+
+	for i := 0; i < waiters; i++ {
+		select {
+		case w := <-granted:
+			if w != i {
+				t.Fatal("Waiters should be granted the resource in the order they arrived", w, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Waiters should be granted the resource as it's released")
+		}
+	}
+
+	waitFor(t, func() bool { return rp.Stats().Waiting == 0 })
+}
+
+func TestStats(t *testing.T) {
+	fail := false
+	rp := NewResourcePool(func() (Resource, error) {
+		if fail {
+			return nil, errors.New("Failed to create resource")
+		}
+
+		return newTestResource()
+	}, 1, 5)
+	defer rp.Close()
+
+	r1, _ := rp.Acquire()
+	r2, _ := rp.Acquire()
+	rp.Release(r1)
+
+	waitFor(t, func() bool { return rp.InUse() == 1 })
+
+	fail = true
+	rp.Acquire() // r1 is idle, so this reuses it
+
+	if _, err := rp.Acquire(); err == nil {
+		t.Fatal("Acquire() should fail when a resource can't be created")
+	}
+
+	rp.Release(r2)
+
+	waitFor(t, func() bool { return rp.InUse() == 1 })
+
+	stats := rp.Stats()
+	expected := Stats{Resources: 2, Idle: 1, InUse: 1, Acquired: 3, Failed: 1}
+
+	if stats != expected {
+		t.Fatal("Stats() reported unexpected values", stats)
+	}
+}
+
+func waitFor(t *testing.T, f func() bool) {
+	timeout := time.After(time.Second)
+
+	for !f() {
+		select {
+		case <-timeout:
+			t.Fatal("Timed out waiting for condition")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -1,5 +1,18 @@
 package skynet
 
+import (
+	"time"
+)
+
+// Priority of a request, the client sends more attempts for higher priority requests.
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
 // RequestInfo is information about a request, and is provided to every skynet RPC call.
 type RequestInfo struct {
 	// OriginAddress is the reported address of the originating client, typically from outside the service cluster.
@@ -10,4 +23,38 @@ type RequestInfo struct {
 	RequestID string
 	// RetryCount indicates how many times this request has been tried before.
 	RetryCount int
+	// RoutingKey sends requests with the same key to the same instance, when the client's load balancer supports it.
+	RoutingKey string
+	// AffinityKey sends requests with the same key to the same instance for as long as it's available, regardless of the load balancer.
+	AffinityKey string
+	// CoalesceKey has concurrent requests to the same method with the same key share a single request and its response.
+	CoalesceKey string
+	// Deadline is when the client will stop waiting for a response, work on the request can be abandoned after it.
+	// It's zero if the client will wait indefinitely.
+	Deadline time.Time
+	// TraceID is shared by every request made on behalf of the same originating request, it's set by the client if absent.
+	TraceID string
+	// ParentSpanID is the span this request was made from, services should parent their own spans to it.
+	ParentSpanID string
+	// Priority selects how many attempts the client sends for the request, and whether it's hedged.
+	Priority Priority
+	// LabelSelector restricts the request to instances with all of the labels, ex: "gpu=true,tier=premium", see ParseLabels().
+	LabelSelector string
+	// ExperimentKey chooses whether the request is routed to canary instances, requests with the same key are routed alike.
+	ExperimentKey string
+	// Metadata is request-scoped information for the service, such as a tenant or locale, merged with the client's
+	// client.metadata, the request's own values win. Services refuse requests whose metadata is over service.metadata.max
+	Metadata map[string]string
+	// NotIdempotent marks a request that mustn't be applied twice, such as a charge. It's neither retried nor hedged once
+	// an attempt may have reached an instance, the client also treats methods in client.retry.nonidempotent this way.
+	NotIdempotent bool
+}
+
+// MetadataSize returns the number of bytes of the request's metadata keys and values
+func (ri *RequestInfo) MetadataSize() (n int) {
+	for k, v := range ri.Metadata {
+		n += len(k) + len(v)
+	}
+
+	return
 }
@@ -1,12 +1,16 @@
 package bsonrpc
 
 import (
+	"errors"
 	"fmt"
 	"github.com/skynetservices/skynet/log"
 	"io"
 	"labix.org/v2/mgo/bson"
 )
 
+// DocumentTooLarge is returned by a Decoder when a document is larger than its MaxBytes, the document isn't read
+var DocumentTooLarge = errors.New("BSON document is larger than the maximum allowed")
+
 type Encoder struct {
 	w io.Writer
 }
@@ -37,6 +41,9 @@ func (e *Encoder) Encode(v interface{}) (err error) {
 
 type Decoder struct {
 	r io.Reader
+
+	// MaxBytes is the largest document that will be read, 0 is unlimited
+	MaxBytes int
 }
 
 func NewDecoder(r io.Reader) *Decoder {
@@ -65,6 +72,12 @@ func (d *Decoder) Decode(pv interface{}) (err error) {
 
 	log.Println(log.TRACE, "Message length parsed as: ", length)
 
+	// refuse before allocating for it, the rest of the document is left unread
+	if d.MaxBytes > 0 && length > d.MaxBytes {
+		err = DocumentTooLarge
+		return
+	}
+
 	buf := make([]byte, length)
 	copy(buf[0:4], lbuf[:])
 
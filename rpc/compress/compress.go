@@ -0,0 +1,113 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/golang/snappy"
+	"io/ioutil"
+	"sort"
+)
+
+/*
+Compressor compresses the In and Out payloads of requests, the compressor used for a connection is agreed
+during the handshake
+*/
+type Compressor interface {
+	// Name identifies the compressor during the handshake
+	Name() string
+
+	Compress(b []byte) ([]byte, error)
+	Decompress(b []byte) ([]byte, error)
+}
+
+var compressors = map[string]Compressor{
+	Gzip{}.Name():   Gzip{},
+	Snappy{}.Name(): Snappy{},
+}
+
+/*
+compress.Lookup() returns the Compressor with the supplied name
+*/
+func Lookup(name string) (c Compressor, ok bool) {
+	c, ok = compressors[name]
+	return
+}
+
+/*
+compress.Names() returns the names of all available Compressors
+*/
+func Names() (names []string) {
+	for n := range compressors {
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+
+	return
+}
+
+/*
+compress.Choose() returns the first of preferred that is in supported, or nil if there are none in common
+*/
+func Choose(preferred []Compressor, supported []string) Compressor {
+	for _, c := range preferred {
+		for _, s := range supported {
+			if c.Name() == s {
+				return c
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+Gzip compresses payloads with gzip, trading speed for smaller payloads
+*/
+type Gzip struct{}
+
+func (Gzip) Name() string {
+	return "gzip"
+}
+
+func (Gzip) Compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (Gzip) Decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
+
+/*
+Snappy compresses payloads with snappy, which is fast but compresses less than Gzip
+*/
+type Snappy struct{}
+
+func (Snappy) Name() string {
+	return "snappy"
+}
+
+func (Snappy) Compress(b []byte) ([]byte, error) {
+	return snappy.Encode(nil, b), nil
+}
+
+func (Snappy) Decompress(b []byte) ([]byte, error) {
+	return snappy.Decode(nil, b)
+}
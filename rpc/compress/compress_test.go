@@ -0,0 +1,49 @@
+package compress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	payload := []byte(strings.Repeat("skynet payloads are often large and repetitive ", 10000))
+
+	for _, name := range Names() {
+		c, _ := Lookup(name)
+
+		compressed, err := c.Compress(payload)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if len(compressed) >= len(payload) {
+			t.Fatal(name, "did not compress payload")
+		}
+
+		decompressed, err := c.Decompress(compressed)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if !bytes.Equal(decompressed, payload) {
+			t.Fatal(name, "payload was not restored")
+		}
+	}
+}
+
+func TestChoose(t *testing.T) {
+	preferred := []Compressor{Snappy{}, Gzip{}}
+
+	if c := Choose(preferred, []string{"gzip", "snappy"}); c == nil || c.Name() != "snappy" {
+		t.Fatal("Choose() should return the first preferred compressor that's supported")
+	}
+
+	if c := Choose(preferred, []string{"gzip"}); c == nil || c.Name() != "gzip" {
+		t.Fatal("Choose() should skip compressors that aren't supported")
+	}
+
+	if c := Choose(preferred, nil); c != nil {
+		t.Fatal("Choose() should return nil when no compressors are supported")
+	}
+}
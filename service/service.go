@@ -1,11 +1,13 @@
 package service
 
 import (
+	"crypto/tls"
 	"github.com/skynetservices/skynet"
 	"github.com/skynetservices/skynet/config"
 	"github.com/skynetservices/skynet/daemon"
 	"github.com/skynetservices/skynet/log"
 	"github.com/skynetservices/skynet/rpc/bsonrpc"
+	"github.com/skynetservices/skynet/rpc/compress"
 	"io"
 	"net"
 	"net/rpc"
@@ -15,6 +17,7 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 )
 
 // A Generic struct to represent any service in the SkyNet system.
@@ -27,12 +30,22 @@ type ServiceDelegate interface {
 
 type ClientInfo struct {
 	Address net.Addr
+
+	// sent by the client in its handshake, empty for clients that don't identify themselves
+	Name     string
+	Version  string
+	Metadata map[string]string
+
+	// agreed during the handshake, nil if payloads aren't compressed
+	compressor compress.Compressor
 }
 
 type Service struct {
 	*skynet.ServiceInfo
 	Delegate       ServiceDelegate
+	TLSConfig      *tls.Config
 	methods        map[string]reflect.Value
+	methodNames    []string
 	RPCServ        *rpc.Server
 	rpcListener    *net.TCPListener
 	activeRequests sync.WaitGroup
@@ -51,6 +64,12 @@ type Service struct {
 
 	shuttingDown bool
 	pipe         *daemon.Pipe
+
+	// requests carrying more bytes of metadata are refused, 0 is unlimited
+	maxMetadataBytes int
+
+	// how long a client has to complete the TLS handshake once it connects, 0 waits indefinitely
+	tlsHandshakeTimeout time.Duration
 }
 
 // Wraps your custom service in Skynet
@@ -66,6 +85,20 @@ func CreateService(sd ServiceDelegate, si *skynet.ServiceInfo) (s *Service) {
 		shuttingDown:   false,
 	}
 
+	s.maxMetadataBytes = config.DefaultMaxMetadataBytes
+	if n, err := config.Int(si.Name, si.Version, "service.metadata.max"); err == nil {
+		s.maxMetadataBytes = n
+	}
+
+	s.tlsHandshakeTimeout = config.DefaultTLSHandshakeTimeout
+	if d, err := config.String(si.Name, si.Version, "service.timeout.tls"); err == nil {
+		if timeout, err := time.ParseDuration(d); err == nil {
+			s.tlsHandshakeTimeout = timeout
+		} else {
+			log.Println(log.ERROR, "Failed to parse service.timeout.tls", err)
+		}
+	}
+
 	// Override LogLevel for Service
 	if l, err := config.String(s.Name, s.Version, "log.level"); err != nil {
 		log.SetLogLevel(log.LevelFromString(l))
@@ -100,6 +133,7 @@ func CreateService(sd ServiceDelegate, si *skynet.ServiceInfo) (s *Service) {
 	s.RPCServ = rpc.NewServer()
 	rpcForwarder := NewServiceRPC(s)
 	s.RPCServ.RegisterName(si.Name, rpcForwarder)
+	s.methodNames = rpcForwarder.MethodNames
 
 	// Daemon doesn't accept commands over pipe
 	if si.Name != "SkynetDaemon" {
@@ -288,8 +322,29 @@ func (s *Service) mux() {
 loop:
 	for {
 		select {
-		case conn := <-s.connectionChan:
+		case tcpConn := <-s.connectionChan:
 			go func() {
+				var conn net.Conn = tcpConn
+
+				// the whole handshake is over TLS, a client that doesn't start one is closed once the timeout passes
+				if s.TLSConfig != nil {
+					tlsConn := tls.Server(tcpConn, s.TLSConfig)
+
+					if s.tlsHandshakeTimeout > 0 {
+						tlsConn.SetDeadline(time.Now().Add(s.tlsHandshakeTimeout))
+					}
+
+					log.Println(log.TRACE, "Performing TLS handshake")
+					if err := tlsConn.Handshake(); err != nil {
+						log.Println(log.ERROR, "TLS handshake failed", err.Error())
+						tlsConn.Close()
+						return
+					}
+
+					tlsConn.SetDeadline(time.Time{})
+					conn = tlsConn
+				}
+
 				clientID := config.NewUUID()
 
 				s.clientMutex.Lock()
@@ -300,9 +355,15 @@ loop:
 
 				// send the server handshake
 				sh := skynet.ServiceHandshake{
-					Registered: s.Registered,
-					ClientID:   clientID,
-					Name:       s.Name,
+					Registered:      s.Registered,
+					ClientID:        clientID,
+					Name:            s.Name,
+					TLSRequired:     s.TLSConfig != nil,
+					Codecs:          []string{"bson"},
+					Compressors:     compress.Names(),
+					ProtocolVersion: skynet.ProtocolVersion,
+					Version:         s.Version,
+					Methods:         s.methodNames,
 				}
 
 				codec := bsonrpc.NewServerCodec(conn)
@@ -330,6 +391,33 @@ loop:
 					return
 				}
 
+				if ch.Codec != "" && ch.Codec != "bson" {
+					log.Println(log.ERROR, "Client requested unsupported codec: "+ch.Codec)
+					conn.Close()
+					return
+				}
+
+				var compressor compress.Compressor
+				if ch.Compression != "" {
+					var ok bool
+					if compressor, ok = compress.Lookup(ch.Compression); !ok {
+						log.Println(log.ERROR, "Client requested unsupported compression: "+ch.Compression)
+						conn.Close()
+						return
+					}
+				}
+
+				if ch.ClientName != "" {
+					log.Println(log.TRACE, "Client "+ch.ClientName+" "+ch.ClientVersion+" connected from "+conn.RemoteAddr().String())
+				}
+
+				s.clientMutex.Lock()
+				ci := s.ClientInfo[clientID]
+				ci.compressor = compressor
+				ci.Name, ci.Version, ci.Metadata = ch.ClientName, ch.ClientVersion, ch.Metadata
+				s.ClientInfo[clientID] = ci
+				s.clientMutex.Unlock()
+
 				// here do stuff with the client handshake
 				log.Println(log.TRACE, "Handing connection to RPC layer")
 				s.RPCServ.ServeCodec(codec)
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPlaintextClientToTLSService(t *testing.T) {
+	addr, _ := tlsService(t)
+
+	_, err := conn.NewConnection("EchoRPC", "tcp", addr, time.Second)
+
+	// the client waits on the ServiceHandshake while the service waits on the TLS handshake, until the service gives up
+	if err != conn.HandshakeFailed {
+		t.Fatal("Plaintext client should fail once the service gives up on the TLS handshake", err)
+	}
+}
+
+func TestTLSClientToTLSService(t *testing.T) {
+	addr, pool := tlsService(t)
+
+	c, err := conn.NewConnectionWithOptions("EchoRPC", "tcp", addr, time.Second, conn.Options{
+		TLSConfig: &tls.Config{RootCAs: pool},
+	})
+
+	if err != nil {
+		t.Fatal("Failed to perform handshake over TLS", err)
+	}
+	defer c.Close()
+
+	out := M{}
+	err = c.SendTimeout(&skynet.RequestInfo{RequestID: "id"}, "Foo", M{"Hi": "there"}, &out, time.Second)
+
+	if err != nil {
+		t.Fatal("Request over TLS failed", err)
+	}
+
+	if v, ok := out["Hi"].(string); !ok || v != "there" {
+		t.Fatal("Response over TLS not copied to out", out)
+	}
+}
+
+/*
+* Test Helpers
+ */
+
+var (
+	tlsServiceOnce sync.Once
+	tlsServiceAddr string
+	tlsServiceCAs  *x509.CertPool
+)
+
+// Starts a service requiring TLS, listening without registering with a ServiceManager. It's shared by the tests
+// as CreateService() sets the global log level
+func tlsService(t *testing.T) (addr string, pool *x509.CertPool) {
+	tlsServiceOnce.Do(func() {
+		cert, pool := selfSignedCert(t)
+
+		si := skynet.NewServiceInfo("EchoRPC", "1.0.0")
+		si.ServiceAddr = skynet.BindAddr{IPAddress: "127.0.0.1"}
+		si.Registered = true
+
+		s := CreateService(EchoRPC{}, si)
+		s.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		s.doneChan = make(chan bool, 1)
+		s.tlsHandshakeTimeout = 200 * time.Millisecond
+
+		bindWait := &sync.WaitGroup{}
+		bindWait.Add(1)
+		go s.listen(s.ServiceAddr, bindWait)
+		bindWait.Wait()
+
+		go s.mux()
+
+		tlsServiceAddr, tlsServiceCAs = s.rpcListener.Addr().String(), pool
+	})
+
+	return tlsServiceAddr, tlsServiceCAs
+}
+
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"skynet"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
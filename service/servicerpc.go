@@ -18,6 +18,12 @@ var (
 	ErrorType = reflect.TypeOf(&anError).Elem()
 )
 
+// DeadlineExceeded is returned without calling the method when a request arrives after the client's deadline
+var DeadlineExceeded = errors.New("Request deadline exceeded")
+
+// MetadataTooLarge is returned without calling the method when a request's metadata is over service.metadata.max
+var MetadataTooLarge = errors.New("Request metadata too large")
+
 type ServiceRPC struct {
 	service     *Service
 	methods     map[string]reflect.Value
@@ -124,6 +130,19 @@ func (srpc *ServiceRPC) Forward(in skynet.ServiceRPCInRead, out *skynet.ServiceR
 		in.RequestInfo.OriginAddress = in.RequestInfo.ConnectionAddress
 	}
 
+	// The client has already given up on this request
+	if !in.RequestInfo.Deadline.IsZero() && time.Now().After(in.RequestInfo.Deadline) {
+		err = DeadlineExceeded
+		log.Printf(log.ERROR, "%+v", MethodError{in.RequestInfo, in.Method, err})
+		return
+	}
+
+	if max := srpc.service.maxMetadataBytes; max > 0 && in.RequestInfo.MetadataSize() > max {
+		err = MetadataTooLarge
+		log.Printf(log.ERROR, "%+v", MethodError{in.RequestInfo, in.Method, err})
+		return
+	}
+
 	mc := MethodCall{
 		MethodName:  in.Method,
 		RequestInfo: in.RequestInfo,
@@ -140,6 +159,13 @@ func (srpc *ServiceRPC) Forward(in skynet.ServiceRPCInRead, out *skynet.ServiceR
 
 	inValuePtr := reflect.New(m.Type().In(2))
 
+	if clientInfo.compressor != nil {
+		if in.In, err = clientInfo.compressor.Decompress(in.In); err != nil {
+			log.Println(log.ERROR, "Error decompressing request ", err)
+			return
+		}
+	}
+
 	err = bson.Unmarshal(in.In, inValuePtr.Interface())
 	if err != nil {
 		log.Println(log.ERROR, "Error unmarshaling request ", err)
@@ -189,6 +215,13 @@ func (srpc *ServiceRPC) Forward(in skynet.ServiceRPCInRead, out *skynet.ServiceR
 		return
 	}
 
+	if clientInfo.compressor != nil {
+		if b, err = clientInfo.compressor.Compress(b); err != nil {
+			log.Printf(log.ERROR, "%+v", MethodError{in.RequestInfo, in.Method, fmt.Errorf("Error compressing response: %v", err)})
+			return
+		}
+	}
+
 	out.Out = bson.Binary{
 		0x00,
 		b,
@@ -200,6 +233,10 @@ func (srpc *ServiceRPC) Forward(in skynet.ServiceRPCInRead, out *skynet.ServiceR
 		rerr, _ = erri.(error)
 		out.ErrString = rerr.Error()
 
+		if coder, ok := rerr.(skynet.ErrorCoder); ok {
+			out.ErrCode = coder.ErrorCode()
+		}
+
 		log.Printf(log.ERROR, "%+v", MethodError{in.RequestInfo, in.Method, fmt.Errorf("Method returned error:", err)})
 	}
 
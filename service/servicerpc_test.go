@@ -26,10 +26,19 @@ func (e EchoRPC) Foo(rinfo *skynet.RequestInfo, in M, out *M) (err error) {
 	return
 }
 
+type codedError struct{}
+
+func (codedError) Error() string     { return "No such widget" }
+func (codedError) ErrorCode() string { return "NotFound" }
+
+func (e EchoRPC) Missing(rinfo *skynet.RequestInfo, in M, out *M) (err error) {
+	return codedError{}
+}
+
 func TestServiceRPCBasic(t *testing.T) {
 	var addr net.Addr
 
-	config := &skynet.ServiceConfig{Name: "EchoRPC"}
+	config := skynet.NewServiceInfo("EchoRPC", "1.0.0")
 	service := CreateService(EchoRPC{}, config)
 	service.ClientInfo = make(map[string]ClientInfo, 1)
 
@@ -47,7 +56,7 @@ func TestServiceRPCBasic(t *testing.T) {
 	in := M{"Hi": "there"}
 	out := &M{}
 
-	sin := skynet.ServiceRPCIn{
+	sin := skynet.ServiceRPCInRead{
 		RequestInfo: &skynet.RequestInfo{
 			RequestID:         "id",
 			OriginAddress:     addr.String(),
@@ -59,16 +68,69 @@ func TestServiceRPCBasic(t *testing.T) {
 
 	sin.In, _ = bson.Marshal(in)
 
-	sout := skynet.ServiceRPCOut{}
+	sout := skynet.ServiceRPCOutWrite{}
 
 	err := srpc.Forward(sin, &sout)
 	if err != nil {
 		t.Error(err)
 	}
 
-	bson.Unmarshal(sout.Out, out)
+	bson.Unmarshal(sout.Out.Data, out)
 
 	if v, ok := (*out)["Hi"].(string); !ok || v != "there" {
 		t.Error(fmt.Sprintf("Expected %v, got %v", in, *out))
 	}
 }
+
+func TestServiceRPCSendsErrorCode(t *testing.T) {
+	config := skynet.NewServiceInfo("EchoRPC", "1.0.0")
+	service := CreateService(EchoRPC{}, config)
+	service.ClientInfo = map[string]ClientInfo{
+		"123": ClientInfo{Address: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 123}},
+	}
+
+	srpc := NewServiceRPC(service)
+
+	sin := skynet.ServiceRPCInRead{
+		RequestInfo: &skynet.RequestInfo{RequestID: "id"},
+		Method:      "Missing",
+		ClientID:    "123",
+	}
+
+	sin.In, _ = bson.Marshal(M{})
+
+	sout := skynet.ServiceRPCOutWrite{}
+
+	if err := srpc.Forward(sin, &sout); err != nil {
+		t.Fatal(err)
+	}
+
+	if sout.ErrString != "No such widget" || sout.ErrCode != "NotFound" {
+		t.Fatal("Errors with an ErrorCode() should be sent with their code", sout.ErrString, sout.ErrCode)
+	}
+}
+
+func TestServiceRPCRefusesLargeMetadata(t *testing.T) {
+	config := skynet.NewServiceInfo("EchoRPC", "1.0.0")
+	service := CreateService(EchoRPC{}, config)
+	service.ClientInfo = map[string]ClientInfo{
+		"123": ClientInfo{Address: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 123}},
+	}
+	service.maxMetadataBytes = 8
+
+	srpc := NewServiceRPC(service)
+
+	sin := skynet.ServiceRPCInRead{
+		RequestInfo: &skynet.RequestInfo{RequestID: "id", Metadata: map[string]string{"tenant": "acme"}},
+		Method:      "Foo",
+		ClientID:    "123",
+	}
+
+	sin.In, _ = bson.Marshal(M{"Hi": "there"})
+
+	sout := skynet.ServiceRPCOutWrite{}
+
+	if err := srpc.Forward(sin, &sout); err != MetadataTooLarge {
+		t.Fatal("Requests with metadata over service.metadata.max should be refused", err)
+	}
+}
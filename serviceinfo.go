@@ -31,16 +31,60 @@ type ServiceInfo struct {
 	Version string
 	Region  string
 
+	// Namespace is the namespace the instance was discovered in, it's only set by a NamespacedServiceManager
+	Namespace string
+
 	ServiceAddr BindAddr
 
 	// Registered indicates if the instance is currently accepting requests.
 	Registered bool
+
+	// Weight is the relative share of requests this instance should receive, unset or 0 is treated as 1.
+	Weight int
+
+	// Labels are arbitrary key values describing the instance, such as gpu=true, requests can be restricted to instances
+	// with particular labels by their RequestInfo.LabelSelector
+	Labels map[string]string
 }
 
 func (si ServiceInfo) AddrString() string {
 	return si.ServiceAddr.String()
 }
 
+// MatchesLabels returns true if the instance has every label in selector, a selector value of "" only requires the label be set
+func (si ServiceInfo) MatchesLabels(selector map[string]string) bool {
+	for k, v := range selector {
+		if l, ok := si.Labels[k]; !ok || (v != "" && l != v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+ParseLabels parses comma separated labels such as "gpu=true,tier=premium". Labels without a value, such as "gpu",
+are given a value of ""
+*/
+func ParseLabels(s string) map[string]string {
+	labels := make(map[string]string)
+
+	for _, l := range strings.Split(s, ",") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+
+		if i := strings.Index(l, "="); i != -1 {
+			labels[strings.TrimSpace(l[:i])] = strings.TrimSpace(l[i+1:])
+		} else {
+			labels[l] = ""
+		}
+	}
+
+	return labels
+}
+
 func NewServiceInfo(name, version string) (si *ServiceInfo) {
 	// TODO: we need to grab Host/Region/ServiceAddr from config
 	si = &ServiceInfo{
@@ -58,6 +102,14 @@ func NewServiceInfo(name, version string) (si *ServiceInfo) {
 		si.Region = config.DefaultRegion
 	}
 
+	if w, err := config.Int(name, version, "service.weight"); err == nil {
+		si.Weight = w
+	}
+
+	if l, err := config.String(name, version, "service.labels"); err == nil {
+		si.Labels = ParseLabels(l)
+	}
+
 	if h, err := config.String(name, version, "host"); err == nil {
 		host = h
 	} else {
@@ -11,9 +11,41 @@ const (
 	InstanceUpdated
 )
 
+// RemovalReason is why an instance was removed, it's carried by InstanceRemoved notifications.
+type RemovalReason int
+
+const (
+	// RemovedByServiceManager the ServiceManager reported the instance was removed, ex: it shut down.
+	RemovedByServiceManager RemovalReason = iota
+	// RemovedExpired the ServiceManager's record of the instance expired without it being removed, ex: it crashed.
+	RemovedExpired
+	// RemovedMissing the instance was missing when the client re-synced with the ServiceManager, its notification was missed.
+	RemovedMissing
+)
+
+func (r RemovalReason) String() string {
+	switch r {
+	case RemovedByServiceManager:
+		return "removed"
+	case RemovedExpired:
+		return "expired"
+	case RemovedMissing:
+		return "missing"
+	}
+
+	return "unknown"
+}
+
 type InstanceNotification struct {
 	Type    int
 	Service ServiceInfo
+
+	// Reason is only set for InstanceRemoved notifications
+	Reason RemovalReason
+
+	// Revision is the ServiceManager's revision of the change when it numbers its changes, such as by the revision of its
+	// store, each should be higher than the last. It's 0 otherwise
+	Revision uint64
 }
 
 type ServiceManager interface {
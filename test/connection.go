@@ -7,13 +7,17 @@ import (
 
 type Connection struct {
 	SetIdleTimeoutFunc func(timeout time.Duration)
+	SetHealthCheckFunc func(staleAfter time.Duration, pingMethod string)
 	AddrFunc           func() string
+	ServiceNameFunc    func() string
 
-	CloseFunc    func()
-	IsClosedFunc func() bool
+	CloseFunc     func()
+	IsClosedFunc  func() bool
+	IsHealthyFunc func() bool
 
 	SendFunc        func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)
 	SendTimeoutFunc func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, timeout time.Duration) (err error)
+	SendOnewayFunc  func(ri *skynet.RequestInfo, fn string, in interface{}) (err error)
 }
 
 func (c *Connection) SetIdleTimeout(timeout time.Duration) {
@@ -22,6 +26,12 @@ func (c *Connection) SetIdleTimeout(timeout time.Duration) {
 	}
 }
 
+func (c *Connection) SetHealthCheck(staleAfter time.Duration, pingMethod string) {
+	if c.SetHealthCheckFunc != nil {
+		c.SetHealthCheckFunc(staleAfter, pingMethod)
+	}
+}
+
 func (c *Connection) Addr() string {
 	if c.AddrFunc != nil {
 		return c.AddrFunc()
@@ -30,6 +40,14 @@ func (c *Connection) Addr() string {
 	return ""
 }
 
+func (c *Connection) ServiceName() string {
+	if c.ServiceNameFunc != nil {
+		return c.ServiceNameFunc()
+	}
+
+	return ""
+}
+
 func (c *Connection) Close() {
 	if c.CloseFunc != nil {
 		c.CloseFunc()
@@ -44,6 +62,14 @@ func (c *Connection) IsClosed() bool {
 	return false
 }
 
+func (c *Connection) IsHealthy() bool {
+	if c.IsHealthyFunc != nil {
+		return c.IsHealthyFunc()
+	}
+
+	return true
+}
+
 func (c *Connection) Send(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
 	if c.SendFunc != nil {
 		return c.SendFunc(ri, fn, in, out)
@@ -59,3 +85,11 @@ func (c *Connection) SendTimeout(ri *skynet.RequestInfo, fn string, in interface
 
 	return nil
 }
+
+func (c *Connection) SendOneway(ri *skynet.RequestInfo, fn string, in interface{}) (err error) {
+	if c.SendOnewayFunc != nil {
+		return c.SendOnewayFunc(ri, fn, in)
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package test
+
+import (
+	"errors"
+	"github.com/skynetservices/skynet"
+	"sort"
+	"sync"
+)
+
+var UnknownInstance = errors.New("Unknown instance")
+
+/*
+FakeServiceManager is an in memory ServiceManager, instances added, updated, removed, registered or unregistered
+through it are sent to anything watching it that matches, so tests can script discovery events.
+Shutdown closes every watch.
+*/
+type FakeServiceManager struct {
+	mutex     sync.Mutex
+	instances map[string]skynet.ServiceInfo
+	watchers  []fakeWatcher
+}
+
+type fakeWatcher struct {
+	criteria skynet.CriteriaMatcher
+	c        chan<- skynet.InstanceNotification
+}
+
+/*
+test.NewFakeServiceManager() returns a FakeServiceManager already aware of the supplied instances
+*/
+func NewFakeServiceManager(instances ...skynet.ServiceInfo) *FakeServiceManager {
+	sm := &FakeServiceManager{
+		instances: make(map[string]skynet.ServiceInfo),
+	}
+
+	for _, s := range instances {
+		sm.instances[s.UUID] = s
+	}
+
+	return sm
+}
+
+func (sm *FakeServiceManager) Add(s skynet.ServiceInfo) error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	sm.instances[s.UUID] = s
+	sm.notify(skynet.InstanceAdded, s)
+
+	return nil
+}
+
+func (sm *FakeServiceManager) Update(s skynet.ServiceInfo) error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	if _, ok := sm.instances[s.UUID]; !ok {
+		return UnknownInstance
+	}
+
+	sm.instances[s.UUID] = s
+	sm.notify(skynet.InstanceUpdated, s)
+
+	return nil
+}
+
+func (sm *FakeServiceManager) Remove(s skynet.ServiceInfo) error {
+	return sm.remove(s, skynet.RemovedByServiceManager)
+}
+
+/*
+FakeServiceManager.Expire() removes the instance as though its record expired without it being removed
+*/
+func (sm *FakeServiceManager) Expire(s skynet.ServiceInfo) error {
+	return sm.remove(s, skynet.RemovedExpired)
+}
+
+func (sm *FakeServiceManager) remove(s skynet.ServiceInfo, reason skynet.RemovalReason) error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	s, ok := sm.instances[s.UUID]
+	if !ok {
+		return UnknownInstance
+	}
+
+	delete(sm.instances, s.UUID)
+	sm.send(skynet.InstanceNotification{Type: skynet.InstanceRemoved, Service: s, Reason: reason})
+
+	return nil
+}
+
+func (sm *FakeServiceManager) Register(uuid string) error {
+	return sm.setRegistered(uuid, true)
+}
+
+func (sm *FakeServiceManager) Unregister(uuid string) error {
+	return sm.setRegistered(uuid, false)
+}
+
+func (sm *FakeServiceManager) Shutdown() error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	for _, w := range sm.watchers {
+		close(w.c)
+	}
+
+	sm.watchers = nil
+
+	return nil
+}
+
+func (sm *FakeServiceManager) ListHosts(c skynet.CriteriaMatcher) ([]string, error) {
+	return sm.list(c, func(s skynet.ServiceInfo) string { return s.ServiceAddr.IPAddress }), nil
+}
+
+func (sm *FakeServiceManager) ListRegions(c skynet.CriteriaMatcher) ([]string, error) {
+	return sm.list(c, func(s skynet.ServiceInfo) string { return s.Region }), nil
+}
+
+func (sm *FakeServiceManager) ListServices(c skynet.CriteriaMatcher) ([]string, error) {
+	return sm.list(c, func(s skynet.ServiceInfo) string { return s.Name }), nil
+}
+
+func (sm *FakeServiceManager) ListVersions(c skynet.CriteriaMatcher) ([]string, error) {
+	return sm.list(c, func(s skynet.ServiceInfo) string { return s.Version }), nil
+}
+
+func (sm *FakeServiceManager) ListInstances(c skynet.CriteriaMatcher) ([]skynet.ServiceInfo, error) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	return sm.matching(c), nil
+}
+
+func (sm *FakeServiceManager) Watch(criteria skynet.CriteriaMatcher, c chan<- skynet.InstanceNotification) []skynet.ServiceInfo {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	sm.watchers = append(sm.watchers, fakeWatcher{criteria: criteria, c: c})
+
+	return sm.matching(criteria)
+}
+
+func (sm *FakeServiceManager) setRegistered(uuid string, registered bool) error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	s, ok := sm.instances[uuid]
+	if !ok {
+		return UnknownInstance
+	}
+
+	s.Registered = registered
+	sm.instances[uuid] = s
+	sm.notify(skynet.InstanceUpdated, s)
+
+	return nil
+}
+
+func (sm *FakeServiceManager) notify(typ int, s skynet.ServiceInfo) {
+	sm.send(skynet.InstanceNotification{Type: typ, Service: s})
+}
+
+// notifications are sent while holding the mutex so that watchers receive them in order
+func (sm *FakeServiceManager) send(n skynet.InstanceNotification) {
+	for _, w := range sm.watchers {
+		if w.criteria.Matches(n.Service) {
+			w.c <- n
+		}
+	}
+}
+
+// callers must hold the mutex
+func (sm *FakeServiceManager) matching(c skynet.CriteriaMatcher) (instances []skynet.ServiceInfo) {
+	instances = []skynet.ServiceInfo{}
+
+	for _, s := range sm.instances {
+		if c.Matches(s) {
+			instances = append(instances, s)
+		}
+	}
+
+	return
+}
+
+func (sm *FakeServiceManager) list(c skynet.CriteriaMatcher, field func(s skynet.ServiceInfo) string) []string {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	seen := make(map[string]bool)
+	values := []string{}
+
+	for _, s := range sm.matching(c) {
+		if v := field(s); !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+
+	sort.Strings(values)
+
+	return values
+}
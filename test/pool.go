@@ -3,6 +3,7 @@ package test
 import (
 	"github.com/skynetservices/skynet"
 	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/pools"
 )
 
 type Pool struct {
@@ -12,10 +13,14 @@ type Pool struct {
 
 	AcquireFunc func(s skynet.ServiceInfo) (conn.Connection, error)
 	ReleaseFunc func(conn.Connection)
+	FlushFunc   func(s skynet.ServiceInfo)
 
-	CloseFunc          func()
-	NumInstancesFunc   func() int
-	NumConnectionsFunc func() int
+	CloseFunc                func()
+	NumInstancesFunc         func() int
+	NumConnectionsFunc       func() int
+	NumActiveConnectionsFunc func(s skynet.ServiceInfo) int
+	NumInFlightFunc          func(s skynet.ServiceInfo) int
+	StatsFunc                func(instances ...skynet.ServiceInfo) map[string]pools.Stats
 }
 
 func (p *Pool) AddInstance(s skynet.ServiceInfo) {
@@ -50,6 +55,12 @@ func (p *Pool) Release(c conn.Connection) {
 	}
 }
 
+func (p *Pool) Flush(s skynet.ServiceInfo) {
+	if p.FlushFunc != nil {
+		p.FlushFunc(s)
+	}
+}
+
 func (p *Pool) Close() {
 	if p.CloseFunc != nil {
 		p.CloseFunc()
@@ -71,3 +82,27 @@ func (p *Pool) NumConnections() int {
 
 	return 0
 }
+
+func (p *Pool) NumActiveConnections(s skynet.ServiceInfo) int {
+	if p.NumActiveConnectionsFunc != nil {
+		return p.NumActiveConnectionsFunc(s)
+	}
+
+	return 0
+}
+
+func (p *Pool) NumInFlight(s skynet.ServiceInfo) int {
+	if p.NumInFlightFunc != nil {
+		return p.NumInFlightFunc(s)
+	}
+
+	return 0
+}
+
+func (p *Pool) Stats(instances ...skynet.ServiceInfo) map[string]pools.Stats {
+	if p.StatsFunc != nil {
+		return p.StatsFunc(instances...)
+	}
+
+	return map[string]pools.Stats{}
+}
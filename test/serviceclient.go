@@ -1,18 +1,50 @@
 package test
 
 import (
+	"context"
 	"github.com/skynetservices/skynet"
+	"github.com/skynetservices/skynet/client/conn"
+	"github.com/skynetservices/skynet/pools"
 	"time"
 )
 
 type ServiceClient struct {
 	SetDefaultTimeoutFunc func(retry, giveup time.Duration)
 	GetDefaultTimeoutFunc func() (retry, giveup time.Duration)
+	SetMethodTimeoutFunc  func(fn string, giveup time.Duration)
 
-	CloseFunc func()
+	CloseFunc     func()
+	DrainFunc     func()
+	DrainWaitFunc func(ctx context.Context) error
 
-	SendFunc     func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)
-	SendOnceFunc func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)
+	SendFunc            func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)
+	SendContextFunc     func(ctx context.Context, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)
+	SendTimeoutFunc     func(retry, giveup time.Duration, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)
+	SendOnceFunc        func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)
+	SendOnceTimeoutFunc func(giveup time.Duration, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)
+	SendBatchFunc       func(ri *skynet.RequestInfo, calls []skynet.Call) (errs []error)
+	SendDetailedFunc    func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (result skynet.SendResult, err error)
+	SendFutureFunc      func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) *skynet.Future
+	SendStreamFunc      func(ri *skynet.RequestInfo, fn string, in interface{}) (*conn.Stream, error)
+	SendToFunc          func(addr string, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error)
+	SendAllFunc         func(ri *skynet.RequestInfo, fn string, in interface{}) (errs map[string]error)
+	SendOnewayFunc      func(ri *skynet.RequestInfo, fn string, in interface{}) (err error)
+	SendFallbackFunc    func(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, fallback func(err error) error) (err error)
+
+	InstanceCountFunc    func() int
+	WaitForInstancesFunc func(ctx context.Context, min int) error
+	ReadyFunc            func() <-chan struct{}
+	PoolStatsFunc        func() map[string]pools.Stats
+	ListInstancesFunc    func() []skynet.InstanceInfo
+	HealthCheckFunc      func(ctx context.Context) map[string]skynet.InstanceHealth
+
+	EvictInstanceFunc    func(addr string)
+	UnevictInstanceFunc  func(addr string)
+	SeedLoadBalancerFunc func(seed int64)
+
+	OnInstanceChangeFunc func(f func(n skynet.InstanceNotification))
+	PauseDiscoveryFunc   func()
+	ResumeDiscoveryFunc  func()
 
 	NotifyFunc  func(n skynet.InstanceNotification)
 	MatchesFunc func(n skynet.ServiceInfo) bool
@@ -34,6 +66,12 @@ func (sc *ServiceClient) GetDefaultTimeout() (retry, giveup time.Duration) {
 	return
 }
 
+func (sc *ServiceClient) SetMethodTimeout(fn string, giveup time.Duration) {
+	if sc.SetMethodTimeoutFunc != nil {
+		sc.SetMethodTimeoutFunc(fn, giveup)
+	}
+}
+
 func (sc *ServiceClient) Send(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
 	if sc.SendFunc != nil {
 		return sc.SendFunc(ri, fn, in, out)
@@ -42,6 +80,22 @@ func (sc *ServiceClient) Send(ri *skynet.RequestInfo, fn string, in interface{},
 	return
 }
 
+func (sc *ServiceClient) SendContext(ctx context.Context, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+	if sc.SendContextFunc != nil {
+		return sc.SendContextFunc(ctx, ri, fn, in, out)
+	}
+
+	return
+}
+
+func (sc *ServiceClient) SendTimeout(retry, giveup time.Duration, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+	if sc.SendTimeoutFunc != nil {
+		return sc.SendTimeoutFunc(retry, giveup, ri, fn, in, out)
+	}
+
+	return
+}
+
 func (sc *ServiceClient) SendOnce(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
 	if sc.SendOnceFunc != nil {
 		return sc.SendOnceFunc(ri, fn, in, out)
@@ -50,6 +104,80 @@ func (sc *ServiceClient) SendOnce(ri *skynet.RequestInfo, fn string, in interfac
 	return
 }
 
+func (sc *ServiceClient) SendOnceTimeout(giveup time.Duration, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+	if sc.SendOnceTimeoutFunc != nil {
+		return sc.SendOnceTimeoutFunc(giveup, ri, fn, in, out)
+	}
+
+	return
+}
+
+func (sc *ServiceClient) SendBatch(ri *skynet.RequestInfo, calls []skynet.Call) (errs []error) {
+	if sc.SendBatchFunc != nil {
+		return sc.SendBatchFunc(ri, calls)
+	}
+
+	return make([]error, len(calls))
+}
+
+func (sc *ServiceClient) SendDetailed(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (result skynet.SendResult, err error) {
+	if sc.SendDetailedFunc != nil {
+		return sc.SendDetailedFunc(ri, fn, in, out)
+	}
+
+	return
+}
+
+func (sc *ServiceClient) SendFuture(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) *skynet.Future {
+	if sc.SendFutureFunc != nil {
+		return sc.SendFutureFunc(ri, fn, in, out)
+	}
+
+	return skynet.NewFuture(func(ctx context.Context) error {
+		return nil
+	})
+}
+
+func (sc *ServiceClient) SendStream(ri *skynet.RequestInfo, fn string, in interface{}) (*conn.Stream, error) {
+	if sc.SendStreamFunc != nil {
+		return sc.SendStreamFunc(ri, fn, in)
+	}
+
+	return nil, nil
+}
+
+func (sc *ServiceClient) SendTo(addr string, ri *skynet.RequestInfo, fn string, in interface{}, out interface{}) (err error) {
+	if sc.SendToFunc != nil {
+		return sc.SendToFunc(addr, ri, fn, in, out)
+	}
+
+	return
+}
+
+func (sc *ServiceClient) SendAll(ri *skynet.RequestInfo, fn string, in interface{}) (errs map[string]error) {
+	if sc.SendAllFunc != nil {
+		return sc.SendAllFunc(ri, fn, in)
+	}
+
+	return
+}
+
+func (sc *ServiceClient) SendOneway(ri *skynet.RequestInfo, fn string, in interface{}) (err error) {
+	if sc.SendOnewayFunc != nil {
+		return sc.SendOnewayFunc(ri, fn, in)
+	}
+
+	return
+}
+
+func (sc *ServiceClient) SendFallback(ri *skynet.RequestInfo, fn string, in interface{}, out interface{}, fallback func(err error) error) (err error) {
+	if sc.SendFallbackFunc != nil {
+		return sc.SendFallbackFunc(ri, fn, in, out, fallback)
+	}
+
+	return
+}
+
 func (sc *ServiceClient) Close() {
 	if sc.CloseFunc != nil {
 		sc.CloseFunc()
@@ -58,6 +186,109 @@ func (sc *ServiceClient) Close() {
 	return
 }
 
+func (sc *ServiceClient) Drain() {
+	if sc.DrainFunc != nil {
+		sc.DrainFunc()
+	}
+
+	return
+}
+
+func (sc *ServiceClient) DrainWait(ctx context.Context) error {
+	if sc.DrainWaitFunc != nil {
+		return sc.DrainWaitFunc(ctx)
+	}
+
+	return nil
+}
+
+func (sc *ServiceClient) InstanceCount() int {
+	if sc.InstanceCountFunc != nil {
+		return sc.InstanceCountFunc()
+	}
+
+	return 0
+}
+
+func (sc *ServiceClient) WaitForInstances(ctx context.Context, min int) error {
+	if sc.WaitForInstancesFunc != nil {
+		return sc.WaitForInstancesFunc(ctx, min)
+	}
+
+	return nil
+}
+
+func (sc *ServiceClient) Ready() <-chan struct{} {
+	if sc.ReadyFunc != nil {
+		return sc.ReadyFunc()
+	}
+
+	ready := make(chan struct{})
+	close(ready)
+
+	return ready
+}
+
+func (sc *ServiceClient) PoolStats() map[string]pools.Stats {
+	if sc.PoolStatsFunc != nil {
+		return sc.PoolStatsFunc()
+	}
+
+	return map[string]pools.Stats{}
+}
+
+func (sc *ServiceClient) ListInstances() []skynet.InstanceInfo {
+	if sc.ListInstancesFunc != nil {
+		return sc.ListInstancesFunc()
+	}
+
+	return []skynet.InstanceInfo{}
+}
+
+func (sc *ServiceClient) HealthCheck(ctx context.Context) map[string]skynet.InstanceHealth {
+	if sc.HealthCheckFunc != nil {
+		return sc.HealthCheckFunc(ctx)
+	}
+
+	return map[string]skynet.InstanceHealth{}
+}
+
+func (sc *ServiceClient) EvictInstance(addr string) {
+	if sc.EvictInstanceFunc != nil {
+		sc.EvictInstanceFunc(addr)
+	}
+}
+
+func (sc *ServiceClient) UnevictInstance(addr string) {
+	if sc.UnevictInstanceFunc != nil {
+		sc.UnevictInstanceFunc(addr)
+	}
+}
+
+func (sc *ServiceClient) SeedLoadBalancer(seed int64) {
+	if sc.SeedLoadBalancerFunc != nil {
+		sc.SeedLoadBalancerFunc(seed)
+	}
+}
+
+func (sc *ServiceClient) OnInstanceChange(f func(n skynet.InstanceNotification)) {
+	if sc.OnInstanceChangeFunc != nil {
+		sc.OnInstanceChangeFunc(f)
+	}
+}
+
+func (sc *ServiceClient) PauseDiscovery() {
+	if sc.PauseDiscoveryFunc != nil {
+		sc.PauseDiscoveryFunc()
+	}
+}
+
+func (sc *ServiceClient) ResumeDiscovery() {
+	if sc.ResumeDiscoveryFunc != nil {
+		sc.ResumeDiscoveryFunc()
+	}
+}
+
 func (sc *ServiceClient) Notify(n skynet.InstanceNotification) {
 	if sc.NotifyFunc != nil {
 		sc.NotifyFunc(n)
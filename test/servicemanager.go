@@ -110,7 +110,7 @@ func (sm *ServiceManager) ListInstances(c skynet.CriteriaMatcher) ([]skynet.Serv
 }
 
 func (sm *ServiceManager) Watch(criteria skynet.CriteriaMatcher, c chan<- skynet.InstanceNotification) (s []skynet.ServiceInfo) {
-	if sm.ListInstancesFunc != nil {
+	if sm.WatchFunc != nil {
 		return sm.WatchFunc(criteria, c)
 	}
 